@@ -1,7 +1,9 @@
 import os
+import json
 import logging
 from fastapi import FastAPI, HTTPException
 from fastapi.middleware.cors import CORSMiddleware
+from fastapi.responses import StreamingResponse
 from pydantic import BaseModel
 from typing import Optional, List, Dict, Any
 import uvicorn
@@ -38,14 +40,23 @@ chat_service = ChatService()
 prediction_service = PredictionService()
 
 # Request/Response models
+class ChatTurn(BaseModel):
+    role: str  # 'user' or 'assistant'
+    content: str
+
 class ChatRequest(BaseModel):
     message: str
     context: Optional[str] = ""
+    history: List[ChatTurn] = []  # earlier turns, oldest first
+    cluster: Optional[str] = ""  # live cluster summary from the orchestrator
 
 class ChatResponse(BaseModel):
     response: str
     code: Optional[str] = None
     language: Optional[str] = None
+    # Structured cluster action (scale, restart, delete, create) for the
+    # orchestrator to preview and execute once the user confirms
+    intent: Optional[Dict[str, Any]] = None
 
 class PredictionRequest(BaseModel):
     metrics: List[Dict[str, Any]]
@@ -80,7 +91,9 @@ async def handle_chat(request: ChatRequest):
         
         response = await chat_service.process_message(
             message=request.message,
-            context=request.context
+            context=request.context,
+            history=[turn.model_dump() for turn in request.history],
+            cluster=request.cluster
         )
         
         return ChatResponse(**response)
@@ -89,6 +102,30 @@ async def handle_chat(request: ChatRequest):
         logger.error(f"Chat processing error: {str(e)}")
         raise HTTPException(status_code=500, detail=str(e))
 
+# Streaming chat endpoint
+@app.post("/api/chat/stream")
+def handle_chat_stream(request: ChatRequest):
+    """
+    Stream a chat response as newline-delimited JSON: "token" chunks followed
+    by a final "done" object carrying the full response, code and language.
+    """
+    logger.info(f"Streaming chat message: {request.message[:50]}...")
+
+    def generate():
+        try:
+            for chunk in chat_service.stream_message(
+                message=request.message,
+                context=request.context,
+                history=[turn.model_dump() for turn in request.history],
+                cluster=request.cluster
+            ):
+                yield json.dumps(chunk) + "\n"
+        except Exception as e:
+            logger.error(f"Chat streaming error: {str(e)}")
+            yield json.dumps({"type": "error", "error": str(e)}) + "\n"
+
+    return StreamingResponse(generate(), media_type="application/x-ndjson")
+
 # Prediction endpoint
 @app.post("/api/predict", response_model=PredictionResponse)
 async def generate_predictions(request: PredictionRequest):
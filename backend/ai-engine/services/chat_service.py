@@ -1,6 +1,8 @@
 import os
+import re
+import json
 import logging
-from typing import Dict, Any
+from typing import Dict, Any, Iterator, List, Optional
 import requests
 
 logger = logging.getLogger(__name__)
@@ -28,34 +30,87 @@ class ChatService:
     def is_available(self) -> bool:
         return self.available
     
-    async def process_message(self, message: str, context: str = "") -> Dict[str, Any]:
+    async def process_message(self, message: str, context: str = "", history: Optional[List[Dict[str, str]]] = None,
+                              cluster: str = "") -> Dict[str, Any]:
         """
         Process a chat message and generate a response.
         """
         message_lower = message.lower()
         
         # Infrastructure creation patterns
-        if "create" in message_lower or "deploy" in message_lower:
+        if re.search(r"\b(create|deploy)\b", message_lower):
             return self._handle_creation(message)
         
         # Scaling patterns
         elif "scale" in message_lower:
             return self._handle_scaling(message)
         
-        # Cost/status queries
+        # Restart and delete patterns
+        elif "restart" in message_lower or "delete" in message_lower:
+            return self._handle_lifecycle(message)
+        
+        # Cost/status queries, answered by the LLM when it can see the cluster
         elif "cost" in message_lower or "status" in message_lower or "show" in message_lower:
-            return self._handle_query(message)
+            if cluster and self.available:
+                return await self._call_ollama(message, context, history, cluster)
+            return self._handle_query(message, cluster)
         
         # Default conversational response
         else:
             if self.available:
-                return await self._call_ollama(message, context)
+                return await self._call_ollama(message, context, history, cluster)
             else:
                 return self._mock_response(message)
     
+    def stream_message(self, message: str, context: str = "", history: Optional[List[Dict[str, str]]] = None,
+                       cluster: str = "") -> Iterator[Dict[str, Any]]:
+        """
+        Process a chat message, yielding the response as it is generated.
+
+        Yields {"type": "token", "content": ...} chunks followed by a single
+        {"type": "done", "response": ..., "code": ..., "language": ...}.
+        Only LLM answers are generated incrementally; pattern-matched answers
+        arrive as one token.
+        """
+        message_lower = message.lower()
+        pattern_matched = any(
+            re.search(rf"\b{word}\b", message_lower)
+            for word in ("create", "deploy", "scale", "restart", "delete")
+        )
+        query = any(word in message_lower for word in ("cost", "status", "show"))
+        if query and not (cluster and self.available):
+            pattern_matched = True
+
+        if pattern_matched or not self.available:
+            if re.search(r"\b(create|deploy)\b", message_lower):
+                result = self._handle_creation(message)
+            elif "scale" in message_lower:
+                result = self._handle_scaling(message)
+            elif "restart" in message_lower or "delete" in message_lower:
+                result = self._handle_lifecycle(message)
+            elif query:
+                result = self._handle_query(message, cluster)
+            else:
+                result = self._mock_response(message)
+            yield {"type": "token", "content": result["response"]}
+            yield {"type": "done", **result}
+            return
+
+        yield from self._stream_ollama(message, context, history, cluster)
+
     def _handle_creation(self, message: str) -> Dict[str, Any]:
         """Generate infrastructure code for creation requests."""
         
+        intent = self._extract_intent(message)
+        if intent and intent["action"] == "create":
+            return {
+                "response": f"I'll create deployment {intent['name']} in namespace {intent['namespace']} "
+                            f"running {intent['image']} with {intent['replicas']} replica(s).",
+                "code": None,
+                "language": None,
+                "intent": intent
+            }
+        
         if "redis" in message.lower():
             code = '''resource "aws_elasticache_cluster" "redis" {
   cluster_id           = "redis-cache"
@@ -106,15 +161,105 @@ spec:
     
     def _handle_scaling(self, message: str) -> Dict[str, Any]:
         """Handle scaling requests."""
-        code = '''kubectl scale deployment frontend --replicas=5'''
+        intent = self._extract_intent(message)
+        if not intent or intent["action"] != "scale":
+            return {
+                "response": "Which deployment should I scale, and to how many replicas? "
+                            "For example: 'Scale the frontend deployment to 5 replicas'",
+                "code": None,
+                "language": None
+            }
+        
+        code = f"kubectl scale deployment {intent['name']} -n {intent['namespace']} --replicas={intent['replicas']}"
         
         return {
-            "response": "I'll scale your deployment. Here's the command:",
+            "response": f"I'll scale deployment {intent['name']} to {intent['replicas']} replicas. Here's the equivalent command:",
             "code": code,
-            "language": "bash"
+            "language": "bash",
+            "intent": intent
         }
     
-    def _handle_query(self, message: str) -> Dict[str, Any]:
+    def _handle_lifecycle(self, message: str) -> Dict[str, Any]:
+        """Handle restart and delete requests."""
+        intent = self._extract_intent(message)
+        if not intent or intent["action"] not in ("restart", "delete"):
+            return {
+                "response": "Which resource do you mean? For example: 'Restart the frontend deployment' "
+                            "or 'Delete pod frontend-7d4b9c-x2k4q in namespace staging'",
+                "code": None,
+                "language": None
+            }
+        
+        if intent["action"] == "restart":
+            code = f"kubectl rollout restart deployment {intent['name']} -n {intent['namespace']}"
+            response = f"I'll restart deployment {intent['name']}. Here's the equivalent command:"
+        else:
+            code = f"kubectl delete {intent['kind'].lower()} {intent['name']} -n {intent['namespace']}"
+            response = f"I'll delete {intent['kind'].lower()} {intent['name']}. Here's the equivalent command:"
+        
+        return {
+            "response": response,
+            "code": code,
+            "language": "bash",
+            "intent": intent
+        }
+    
+    def _extract_intent(self, message: str) -> Optional[Dict[str, Any]]:
+        """
+        Parse a structured cluster action from the message. The orchestrator
+        previews and confirms intents before executing them.
+        """
+        text = message.lower()
+        name_pattern = r"([a-z0-9]([-a-z0-9.]*[a-z0-9])?)"
+        namespace_match = re.search(r"\bin (?:the )?(?:namespace )?([a-z0-9-]+)(?: namespace)?\b", text)
+        namespace = namespace_match.group(1) if namespace_match else "default"
+        
+        match = re.search(r"\bscale (?:the )?(?:deployment )?" + name_pattern + r"(?: deployment)?\b.*?\bto (\d+)", text)
+        if match:
+            return {
+                "action": "scale",
+                "kind": "Deployment",
+                "namespace": namespace,
+                "name": match.group(1),
+                "replicas": int(match.group(3))
+            }
+        
+        match = re.search(r"\brestart (?:the )?(?:deployment )?" + name_pattern + r"(?: deployment)?\b", text)
+        if match:
+            return {
+                "action": "restart",
+                "kind": "Deployment",
+                "namespace": namespace,
+                "name": match.group(1)
+            }
+        
+        # "delete pod NAME" or "delete the NAME deployment"
+        match = re.search(r"\bdelete (?:the )?(?P<kind>pod|deployment) (?P<name>[a-z0-9][-a-z0-9.]*)", text) or \
+            re.search(r"\bdelete (?:the )?(?P<name>[a-z0-9][-a-z0-9.]*) (?P<kind>pod|deployment)\b", text)
+        if match:
+            return {
+                "action": "delete",
+                "kind": match.group("kind").capitalize(),
+                "namespace": namespace,
+                "name": match.group("name")
+            }
+        
+        match = re.search(r"\bdeployment (?:called |named )?" + name_pattern, text)
+        image = re.search(r"\bimage ([^\s,]+)", message)
+        if re.search(r"\b(create|deploy)\b", text) and match and image:
+            replicas = re.search(r"\b(\d+) replicas?\b", text)
+            return {
+                "action": "create",
+                "kind": "Deployment",
+                "namespace": namespace,
+                "name": match.group(1),
+                "image": image.group(1),
+                "replicas": int(replicas.group(1)) if replicas else 1
+            }
+        
+        return None
+    
+    def _handle_query(self, message: str, cluster: str = "") -> Dict[str, Any]:
         """Handle status and cost queries."""
         
         if "cost" in message.lower():
@@ -123,6 +268,12 @@ spec:
                 "code": None,
                 "language": None
             }
+        elif cluster:
+            return {
+                "response": f"Current cluster state:\n{cluster}",
+                "code": None,
+                "language": None
+            }
         else:
             return {
                 "response": "System status: All services running normally.\n- 5 active pods\n- CPU usage: 45%\n- Memory usage: 60%",
@@ -130,13 +281,34 @@ spec:
                 "language": None
             }
     
-    async def _call_ollama(self, message: str, context: str) -> Dict[str, Any]:
+    def _build_prompt(self, message: str, context: str, history: Optional[List[Dict[str, str]]],
+                      cluster: str = "") -> str:
+        """Build the LLM prompt from the message, context, earlier turns and cluster state."""
+        prompt = f"You are an infrastructure management AI assistant. Help with: {message}"
+
+        if history:
+            turns = "\n".join(
+                f"{'User' if turn.get('role') == 'user' else 'Assistant'}: {turn.get('content', '')}"
+                for turn in history
+            )
+            prompt = f"Conversation so far:\n{turns}\n\n{prompt}"
+
+        if context:
+            prompt = f"Context: {context}\n\n{prompt}"
+
+        if cluster:
+            prompt = (
+                "Current state of the user's Kubernetes cluster (answer from this data "
+                f"and do not invent figures):\n{cluster}\n\n{prompt}"
+            )
+
+        return prompt
+
+    async def _call_ollama(self, message: str, context: str, history: Optional[List[Dict[str, str]]] = None,
+                           cluster: str = "") -> Dict[str, Any]:
         """Call Ollama API for LLM inference."""
         try:
-            prompt = f"You are an infrastructure management AI assistant. Help with: {message}"
-            
-            if context:
-                prompt = f"Context: {context}\n\n{prompt}"
+            prompt = self._build_prompt(message, context, history, cluster)
             
             response = requests.post(
                 f"{self.ollama_url}/api/generate",
@@ -162,6 +334,45 @@ spec:
             logger.error(f"Ollama API error: {str(e)}")
             return self._mock_response(message)
     
+    def _stream_ollama(self, message: str, context: str, history: Optional[List[Dict[str, str]]] = None,
+                       cluster: str = "") -> Iterator[Dict[str, Any]]:
+        """Stream an Ollama completion chunk by chunk."""
+        prompt = self._build_prompt(message, context, history, cluster)
+
+        parts = []
+        try:
+            with requests.post(
+                f"{self.ollama_url}/api/generate",
+                json={
+                    "model": self.model,
+                    "prompt": prompt,
+                    "stream": True
+                },
+                stream=True,
+                timeout=30
+            ) as response:
+                response.raise_for_status()
+                for line in response.iter_lines():
+                    if not line:
+                        continue
+                    chunk = json.loads(line)
+                    token = chunk.get("response", "")
+                    if token:
+                        parts.append(token)
+                        yield {"type": "token", "content": token}
+                    if chunk.get("done"):
+                        break
+
+        except Exception as e:
+            logger.error(f"Ollama streaming error: {str(e)}")
+            if not parts:
+                result = self._mock_response(message)
+                yield {"type": "token", "content": result["response"]}
+                yield {"type": "done", **result}
+                return
+
+        yield {"type": "done", "response": "".join(parts), "code": None, "language": None}
+
     def _mock_response(self, message: str) -> Dict[str, Any]:
         """Fallback mock response."""
         return {
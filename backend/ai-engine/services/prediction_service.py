@@ -1,7 +1,7 @@
 import logging
 import numpy as np
 from typing import List, Dict, Any
-from datetime import datetime, timedelta
+from datetime import datetime, timedelta, timezone
 
 logger = logging.getLogger(__name__)
 
@@ -37,7 +37,7 @@ class PredictionService:
         
         # Generate future predictions
         predictions = []
-        current_time = datetime.now()
+        current_time = datetime.now(timezone.utc)
         
         for i in range(horizon):
             predicted_value = recent_avg + (trend * i)
@@ -89,7 +89,7 @@ class PredictionService:
         
         base_value = base_values.get(target, 50.0)
         predictions = []
-        current_time = datetime.now()
+        current_time = datetime.now(timezone.utc)
         
         for i in range(horizon):
             # Add some randomness
@@ -198,7 +198,7 @@ class PredictionService:
                 "type": "cpu_spike",
                 "severity": "high",
                 "message": f"CPU usage critically high: {cpu}%",
-                "timestamp": datetime.now().isoformat()
+                "timestamp": datetime.now(timezone.utc).isoformat()
             })
         
         if memory > 90:
@@ -206,7 +206,7 @@ class PredictionService:
                 "type": "memory_pressure",
                 "severity": "high",
                 "message": f"Memory usage critically high: {memory}%",
-                "timestamp": datetime.now().isoformat()
+                "timestamp": datetime.now(timezone.utc).isoformat()
             })
         
         return anomalies
@@ -0,0 +1,122 @@
+package aiengine
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting the engine while the circuit
+// breaker is open.
+var ErrCircuitOpen = errors.New("AI engine circuit breaker is open")
+
+// Breaker states.
+const (
+	StateClosed   = "closed"
+	StateOpen     = "open"
+	StateHalfOpen = "half-open"
+)
+
+// BreakerStatus is a snapshot of the circuit breaker for status endpoints.
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	OpenedAt            *time.Time `json:"openedAt,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastSuccess         *time.Time `json:"lastSuccess,omitempty"`
+}
+
+// breaker opens after threshold consecutive failed calls and rejects calls
+// for cooldown. It then lets a single probe through (half-open): success
+// closes it, failure reopens it for another cooldown. Only failures that
+// mean the engine is unavailable count; see record.
+type breaker struct {
+	mu          sync.Mutex
+	threshold   int
+	cooldown    time.Duration
+	state       string
+	failures    int
+	openedAt    time.Time
+	probing     bool
+	lastError   string
+	lastSuccess time.Time
+}
+
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	return &breaker{threshold: threshold, cooldown: cooldown, state: StateClosed}
+}
+
+// allow reports whether a call may proceed, moving an open breaker to
+// half-open once the cooldown has elapsed.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = StateHalfOpen
+		b.probing = true
+		return nil
+	case StateHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record updates the breaker with the outcome of an allowed call. Network
+// errors, 429 and 5xx responses, the failures worth retrying, count against
+// the engine. Other errors, such as a 4xx for a bad request or a body that
+// does not decode, still mean the engine answered.
+func (b *breaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if err == nil || !retryable(err) {
+		b.state = StateClosed
+		b.failures = 0
+		b.lastSuccess = time.Now()
+		return
+	}
+
+	b.failures++
+	b.lastError = err.Error()
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.state = StateOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// abandon releases a half-open probe whose caller gave up before the engine
+// answered, without counting it either way.
+func (b *breaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+func (b *breaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		LastError:           b.lastError,
+	}
+	if b.state != StateClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if !b.lastSuccess.IsZero() {
+		lastSuccess := b.lastSuccess
+		status.LastSuccess = &lastSuccess
+	}
+	return status
+}
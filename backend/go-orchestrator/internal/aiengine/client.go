@@ -0,0 +1,324 @@
+package aiengine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"orchestrator/internal/executor"
+)
+
+const (
+	defaultBaseURL          = "http://localhost:8001"
+	defaultTimeout          = 30 * time.Second
+	defaultRetries          = 2
+	defaultBackoff          = 200 * time.Millisecond
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// Config tunes the client.
+type Config struct {
+	BaseURL string
+	// Timeout bounds each attempt; the caller's context can only shorten it.
+	Timeout time.Duration
+	// Retries is how many times a failed attempt is retried. Only network
+	// errors, 429 and 5xx responses are retried.
+	Retries int
+	// Backoff is the base delay before the first retry. It doubles on each
+	// retry, and the actual delay is drawn uniformly from [0, delay).
+	Backoff time.Duration
+	// BreakerThreshold consecutive calls failing with a network error, 429 or
+	// 5xx open the circuit breaker for BreakerCooldown.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// ConfigFromEnv reads AI_ENGINE_URL, AI_ENGINE_TIMEOUT, AI_ENGINE_RETRIES,
+// AI_ENGINE_BREAKER_THRESHOLD and AI_ENGINE_BREAKER_COOLDOWN.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		BaseURL:          os.Getenv("AI_ENGINE_URL"),
+		Timeout:          defaultTimeout,
+		Retries:          defaultRetries,
+		Backoff:          defaultBackoff,
+		BreakerThreshold: defaultBreakerThreshold,
+		BreakerCooldown:  defaultBreakerCooldown,
+	}
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL
+	}
+	if value, err := time.ParseDuration(os.Getenv("AI_ENGINE_TIMEOUT")); err == nil && value > 0 {
+		cfg.Timeout = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("AI_ENGINE_RETRIES")); err == nil && value >= 0 {
+		cfg.Retries = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("AI_ENGINE_BREAKER_THRESHOLD")); err == nil && value > 0 {
+		cfg.BreakerThreshold = value
+	}
+	if value, err := time.ParseDuration(os.Getenv("AI_ENGINE_BREAKER_COOLDOWN")); err == nil && value > 0 {
+		cfg.BreakerCooldown = value
+	}
+	return cfg
+}
+
+// Client calls the Python AI engine's HTTP API. It is shared by every caller
+// so they see the same circuit breaker.
+type Client struct {
+	cfg     Config
+	http    *http.Client
+	breaker *breaker
+}
+
+// NewClient creates a client from cfg.
+func NewClient(cfg Config) *Client {
+	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.BreakerThreshold <= 0 {
+		cfg.BreakerThreshold = defaultBreakerThreshold
+	}
+	return &Client{
+		cfg:     cfg,
+		http:    &http.Client{},
+		breaker: newBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+	}
+}
+
+// NewClientFromEnv creates a client configured by ConfigFromEnv.
+func NewClientFromEnv() *Client {
+	return NewClient(ConfigFromEnv())
+}
+
+// Status reports the circuit breaker's state.
+func (c *Client) Status() BreakerStatus {
+	return c.breaker.status()
+}
+
+// StatusError is returned for non-2xx responses from the engine.
+type StatusError struct {
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned HTTP %d: %s", e.Path, e.StatusCode, e.Body)
+}
+
+func (e *StatusError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+type ChatRequest struct {
+	Message string `json:"message"`
+	Context string `json:"context"`
+	// History holds earlier turns of the conversation, oldest first.
+	History []ChatTurn `json:"history,omitempty"`
+	// Cluster is a redacted summary of the live cluster state.
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// ChatTurn is one earlier message in a conversation.
+type ChatTurn struct {
+	Role    string `json:"role"` // "user" or "assistant"
+	Content string `json:"content"`
+}
+
+type ChatResponse struct {
+	Response string `json:"response"`
+	Code     string `json:"code,omitempty"`
+	Language string `json:"language,omitempty"`
+	// Intent is set when the message asks for a cluster change.
+	Intent *executor.Intent `json:"intent,omitempty"`
+}
+
+// MetricValue is one historical point sent to /api/predict.
+type MetricValue struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+type PredictRequest struct {
+	Metrics []MetricValue `json:"metrics"`
+	Target  string        `json:"target"`  // "cpu", "memory" or "traffic"
+	Horizon int           `json:"horizon"` // minutes ahead
+}
+
+type PredictedValue struct {
+	Timestamp  string  `json:"timestamp"`
+	Value      float64 `json:"value"`
+	Confidence float64 `json:"confidence"`
+}
+
+type PredictResponse struct {
+	Predictions     []PredictedValue `json:"predictions"`
+	Confidence      float64          `json:"confidence"`
+	Recommendations []string         `json:"recommendations"`
+}
+
+// Recommendation is an entry returned by /api/recommendations. Payload is the
+// change to make, when the engine can name one; without it the suggestion is
+// informational.
+type Recommendation struct {
+	Type       string           `json:"type"`
+	Target     string           `json:"target"`
+	Action     string           `json:"action"`
+	Confidence float64          `json:"confidence"`
+	Reasoning  string           `json:"reasoning"`
+	Impact     string           `json:"impact"`
+	Payload    *executor.Action `json:"payload,omitempty"`
+}
+
+// Anomaly is an entry returned by /api/anomalies.
+type Anomaly struct {
+	Type      string `json:"type"`
+	Severity  string `json:"severity"`
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+}
+
+// Chat sends a ChatOps message to the engine.
+func (c *Client) Chat(ctx context.Context, req ChatRequest) (*ChatResponse, error) {
+	var resp ChatResponse
+	if err := c.post(ctx, "/api/chat", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Predict forecasts the target metric from its recent history.
+func (c *Client) Predict(ctx context.Context, req PredictRequest) (*PredictResponse, error) {
+	var resp PredictResponse
+	if err := c.post(ctx, "/api/predict", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Recommendations asks the engine for optimisation suggestions based on the
+// current cluster-wide metrics.
+func (c *Client) Recommendations(ctx context.Context, metrics map[string]float64) ([]Recommendation, error) {
+	var resp struct {
+		Recommendations []Recommendation `json:"recommendations"`
+	}
+	if err := c.post(ctx, "/api/recommendations", metrics, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Recommendations, nil
+}
+
+// Anomalies asks the engine to check the current cluster-wide metrics.
+func (c *Client) Anomalies(ctx context.Context, metrics map[string]float64) ([]Anomaly, error) {
+	var resp struct {
+		Anomalies []Anomaly `json:"anomalies"`
+	}
+	if err := c.post(ctx, "/api/anomalies", metrics, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Anomalies, nil
+}
+
+// post sends body as JSON and decodes a 2xx JSON response into out. Each
+// attempt gets its own deadline under ctx, failed attempts are retried with
+// jittered exponential backoff, and the call's overall outcome is recorded by
+// the circuit breaker.
+func (c *Client) post(ctx context.Context, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+
+	delay := c.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		err = c.attempt(ctx, path, data, out)
+		if err == nil || attempt >= c.cfg.Retries || !retryable(err) || ctx.Err() != nil {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(jitter(delay)):
+		}
+		delay *= 2
+	}
+
+	if ctx.Err() != nil {
+		// The caller gave up; that says nothing about the engine's health
+		c.breaker.abandon()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return err
+	}
+	c.breaker.record(err)
+	return err
+}
+
+func (c *Client) attempt(ctx context.Context, path string, data []byte, out interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Path: path, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(payload))}
+	}
+	if err := json.Unmarshal(payload, out); err != nil {
+		return fmt.Errorf("failed to parse %s response: %v", path, err)
+	}
+	return nil
+}
+
+// jitter draws a retry delay uniformly from [0, delay).
+func jitter(delay time.Duration) time.Duration {
+	if delay <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(delay)))
+}
+
+// retryable reports whether a failed attempt is worth repeating. Engine
+// responses other than 429 and 5xx are final, as are malformed bodies.
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.retryable()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
@@ -0,0 +1,260 @@
+package aiengine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// scriptedEngine answers /api/anomalies with the next status in statuses,
+// repeating the last one, and counts the requests it receives. A 200 carries
+// body.
+type scriptedEngine struct {
+	mu       sync.Mutex
+	statuses []int
+	body     string
+	requests int
+	// block, when set, holds every request until it is closed.
+	block chan struct{}
+}
+
+func (e *scriptedEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.Lock()
+	status := e.statuses[0]
+	if len(e.statuses) > 1 {
+		e.statuses = e.statuses[1:]
+	}
+	e.requests++
+	block := e.block
+	e.mu.Unlock()
+
+	if block != nil {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.WriteHeader(status)
+	if status == http.StatusOK {
+		w.Write([]byte(e.body))
+	}
+}
+
+func (e *scriptedEngine) script(statuses ...int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.statuses = statuses
+	e.requests = 0
+}
+
+// hold makes requests wait until the returned channel is closed.
+func (e *scriptedEngine) hold() chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.block = make(chan struct{})
+	return e.block
+}
+
+func (e *scriptedEngine) count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.requests
+}
+
+func newScriptedClient(t *testing.T, cfg Config, statuses ...int) (*Client, *scriptedEngine) {
+	t.Helper()
+	engine := &scriptedEngine{statuses: statuses, body: `{"anomalies": []}`}
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	cfg.BaseURL = server.URL
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+	return NewClient(cfg), engine
+}
+
+func TestPostRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		body         string
+		wantRequests int
+		wantStatus   int
+		wantErr      bool
+	}{
+		{name: "success", statuses: []int{200}, wantRequests: 1},
+		{name: "5xx retried", statuses: []int{503, 500, 200}, wantRequests: 3},
+		{name: "429 retried", statuses: []int{429, 200}, wantRequests: 2},
+		{name: "retries exhausted", statuses: []int{502}, wantRequests: 3, wantStatus: 502, wantErr: true},
+		{name: "4xx final", statuses: []int{400, 200}, wantRequests: 1, wantStatus: 400, wantErr: true},
+		{name: "malformed body final", statuses: []int{200}, body: "not json", wantRequests: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, engine := newScriptedClient(t, Config{Retries: 2, Backoff: time.Millisecond, BreakerThreshold: 10}, tt.statuses...)
+			if tt.body != "" {
+				engine.body = tt.body
+			}
+
+			_, err := client.Anomalies(context.Background(), nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Anomalies() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var statusErr *StatusError
+			if tt.wantStatus != 0 && (!errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus) {
+				t.Errorf("Anomalies() error = %v, want HTTP %d", err, tt.wantStatus)
+			}
+			if got := engine.count(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	if got := jitter(0); got != 0 {
+		t.Errorf("jitter(0) = %v, want 0", got)
+	}
+	const delay = 100 * time.Millisecond
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		got := jitter(delay)
+		if got < 0 || got >= delay {
+			t.Fatalf("jitter(%v) = %v, want within [0, %v)", delay, got, delay)
+		}
+		seen[got] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("jitter(%v) returned the same delay 100 times", delay)
+	}
+}
+
+func TestBreakerTransitions(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	client, engine := newScriptedClient(t, Config{BreakerThreshold: 2, BreakerCooldown: cooldown}, 500)
+	call := func() error {
+		_, err := client.Anomalies(context.Background(), nil)
+		return err
+	}
+	wantState := func(state string, failures int) {
+		t.Helper()
+		status := client.Status()
+		if status.State != state || status.ConsecutiveFailures != failures {
+			t.Fatalf("breaker = %s with %d failures, want %s with %d", status.State, status.ConsecutiveFailures, state, failures)
+		}
+	}
+
+	// Answers that are the caller's fault do not count
+	engine.script(400)
+	for i := 0; i < 3; i++ {
+		call()
+	}
+	wantState(StateClosed, 0)
+
+	engine.script(500)
+	call()
+	wantState(StateClosed, 1)
+	call()
+	wantState(StateOpen, 2)
+
+	// Open: calls fail without reaching the engine
+	engine.script(200)
+	if err := call(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("call while open error = %v, want ErrCircuitOpen", err)
+	}
+	if got := engine.count(); got != 0 {
+		t.Fatalf("requests while open = %d, want 0", got)
+	}
+
+	// A failed probe reopens it for another cooldown
+	time.Sleep(cooldown)
+	engine.script(503)
+	call()
+	wantState(StateOpen, 3)
+	if err := call(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("call after failed probe error = %v, want ErrCircuitOpen", err)
+	}
+
+	// A successful probe closes it
+	time.Sleep(cooldown)
+	engine.script(200)
+	if err := call(); err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	wantState(StateClosed, 0)
+	if client.Status().LastSuccess == nil {
+		t.Error("last success not recorded")
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	client, engine := newScriptedClient(t, Config{BreakerThreshold: 1, BreakerCooldown: cooldown}, 500)
+	client.Anomalies(context.Background(), nil)
+	time.Sleep(cooldown)
+
+	// Hold the probe at the engine while a second call arrives
+	engine.script(200)
+	release := engine.hold()
+	probe := make(chan error)
+	go func() {
+		_, err := client.Anomalies(context.Background(), nil)
+		probe <- err
+	}()
+	for engine.count() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if state := client.Status().State; state != StateHalfOpen {
+		t.Fatalf("state during probe = %s, want %s", state, StateHalfOpen)
+	}
+	if _, err := client.Anomalies(context.Background(), nil); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second call during probe error = %v, want ErrCircuitOpen", err)
+	}
+
+	close(release)
+	if err := <-probe; err != nil {
+		t.Fatalf("probe error = %v", err)
+	}
+	if state := client.Status().State; state != StateClosed {
+		t.Errorf("state after probe = %s, want %s", state, StateClosed)
+	}
+}
+
+func TestBreakerAbandonedProbe(t *testing.T) {
+	const cooldown = 20 * time.Millisecond
+	client, engine := newScriptedClient(t, Config{BreakerThreshold: 1, BreakerCooldown: cooldown}, 500)
+	client.Anomalies(context.Background(), nil)
+	time.Sleep(cooldown)
+
+	// The caller gives up on the probe before the engine answers
+	engine.script(200)
+	release := engine.hold()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for engine.count() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	if _, err := client.Anomalies(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("abandoned probe error = %v, want context.Canceled", err)
+	}
+	status := client.Status()
+	if status.State != StateHalfOpen || status.ConsecutiveFailures != 1 {
+		t.Fatalf("breaker after abandoned probe = %s with %d failures, want %s with 1", status.State, status.ConsecutiveFailures, StateHalfOpen)
+	}
+
+	// The probe slot is free again
+	close(release)
+	if _, err := client.Anomalies(context.Background(), nil); err != nil {
+		t.Fatalf("next probe error = %v", err)
+	}
+	if state := client.Status().State; state != StateClosed {
+		t.Errorf("state after next probe = %s, want %s", state, StateClosed)
+	}
+}
@@ -0,0 +1,134 @@
+package aiengine
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"orchestrator/internal/executor"
+)
+
+// Chat stream chunk types.
+const (
+	ChunkToken = "token"
+	ChunkDone  = "done"
+	ChunkError = "error"
+)
+
+// ChatChunk is one line of the engine's /api/chat/stream response. Token
+// chunks carry Content; the final done chunk carries the full Response with
+// any generated Code and its Language, and any Intent.
+type ChatChunk struct {
+	Type     string           `json:"type"`
+	Content  string           `json:"content,omitempty"`
+	Response string           `json:"response,omitempty"`
+	Code     string           `json:"code,omitempty"`
+	Language string           `json:"language,omitempty"`
+	Intent   *executor.Intent `json:"intent,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// ErrStreamInterrupted is returned when the stream ends without a done chunk.
+var ErrStreamInterrupted = errors.New("AI engine chat stream ended early")
+
+// ChatStream sends a ChatOps message to the engine and calls fn for each
+// chunk of the answer as it arrives, ending with the done chunk. Streams are
+// not retried, since chunks may already have been delivered, but the
+// connection attempt counts towards the circuit breaker. The configured
+// timeout bounds the wait for each chunk rather than the whole answer, and
+// cancelling ctx closes the upstream request.
+func (c *Client) ChatStream(ctx context.Context, req ChatRequest, fn func(ChatChunk) error) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	if err := c.breaker.allow(); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	idle := time.AfterFunc(c.cfg.Timeout, cancel)
+	defer idle.Stop()
+
+	body, err := c.openStream(ctx, data)
+	if err != nil {
+		if ctx.Err() != nil && !idleExpired(idle) {
+			c.breaker.abandon()
+		} else {
+			c.breaker.record(err)
+		}
+		return err
+	}
+	defer body.Close()
+	// The engine answered; mid-stream failures are reported to the caller
+	// but do not count against the engine's availability.
+	c.breaker.record(nil)
+
+	reader := bufio.NewReader(body)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			idle.Reset(c.cfg.Timeout)
+
+			var chunk ChatChunk
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				return fmt.Errorf("failed to parse chat stream chunk: %v", err)
+			}
+			if chunk.Type == ChunkError {
+				return fmt.Errorf("AI engine chat stream failed: %s", chunk.Error)
+			}
+			if err := fn(chunk); err != nil {
+				return err
+			}
+			if chunk.Type == ChunkDone {
+				return nil
+			}
+		}
+		if err != nil {
+			switch {
+			case err == io.EOF:
+				return ErrStreamInterrupted
+			case ctx.Err() != nil && idleExpired(idle):
+				return fmt.Errorf("%w: no data for %s", ErrStreamInterrupted, c.cfg.Timeout)
+			case ctx.Err() != nil:
+				return ctx.Err()
+			}
+			return fmt.Errorf("%w: %v", ErrStreamInterrupted, err)
+		}
+	}
+}
+
+func (c *Client) openStream(ctx context.Context, data []byte) (io.ReadCloser, error) {
+	const path = "/api/chat/stream"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.BaseURL+path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/x-ndjson")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		payload, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &StatusError{Path: path, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(payload))}
+	}
+	return resp.Body, nil
+}
+
+// idleExpired reports whether the idle timer has already fired, i.e. the
+// cancellation came from the engine being silent rather than the caller.
+func idleExpired(idle *time.Timer) bool {
+	return !idle.Stop()
+}
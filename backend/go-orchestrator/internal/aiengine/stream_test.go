@@ -0,0 +1,264 @@
+package aiengine
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// streamEngine serves /api/chat/stream with handler.
+func streamEngine(t *testing.T, cfg Config, handler func(w http.ResponseWriter, r *http.Request)) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	t.Cleanup(server.Close)
+	cfg.BaseURL = server.URL
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+	return NewClient(cfg)
+}
+
+// ndjson writes lines to w one at a time, flushing each and waiting delay
+// before the next.
+func ndjson(w http.ResponseWriter, delay time.Duration, lines ...string) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	for _, line := range lines {
+		w.Write([]byte(line + "\n"))
+		w.(http.Flusher).Flush()
+		time.Sleep(delay)
+	}
+}
+
+// hang waits until the client goes away. The server only notices that once
+// the request body has been read.
+func hang(r *http.Request) {
+	io.Copy(io.Discard, r.Body)
+	<-r.Context().Done()
+}
+
+func collect(client *Client, ctx context.Context) ([]ChatChunk, error) {
+	var chunks []ChatChunk
+	err := client.ChatStream(ctx, ChatRequest{Message: "hello"}, func(chunk ChatChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	return chunks, err
+}
+
+func TestChatStream(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		handler func(w http.ResponseWriter, r *http.Request)
+		// wantTypes are the chunk types passed to the callback.
+		wantTypes []string
+		wantErr   string
+		// wantInterrupted is whether the error is ErrStreamInterrupted.
+		wantInterrupted bool
+	}{
+		{
+			name: "complete answer",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				ndjson(w, 0, `{"type":"token","content":"Hel"}`, ``, `{"type":"token","content":"lo"}`, `{"type":"done","response":"Hello"}`)
+			},
+			wantTypes: []string{ChunkToken, ChunkToken, ChunkDone},
+		},
+		{
+			name: "chunks slower in total than the timeout",
+			// Each chunk arrives within the timeout, which is what it bounds
+			timeout: 150 * time.Millisecond,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				ndjson(w, 50*time.Millisecond, `{"type":"token"}`, `{"type":"token"}`, `{"type":"token"}`, `{"type":"token"}`, `{"type":"done"}`)
+			},
+			wantTypes: []string{ChunkToken, ChunkToken, ChunkToken, ChunkToken, ChunkDone},
+		},
+		{
+			name: "missing done chunk",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				ndjson(w, 0, `{"type":"token","content":"Hel"}`)
+			},
+			wantTypes:       []string{ChunkToken},
+			wantErr:         "ended early",
+			wantInterrupted: true,
+		},
+		{
+			name:    "engine goes silent",
+			timeout: 100 * time.Millisecond,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				ndjson(w, 0, `{"type":"token","content":"Hel"}`)
+				hang(r)
+			},
+			wantTypes:       []string{ChunkToken},
+			wantErr:         "no data for 100ms",
+			wantInterrupted: true,
+		},
+		{
+			name: "error chunk",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				ndjson(w, 0, `{"type":"token","content":"Hel"}`, `{"type":"error","error":"model overloaded"}`, `{"type":"done"}`)
+			},
+			wantTypes: []string{ChunkToken},
+			wantErr:   "AI engine chat stream failed: model overloaded",
+		},
+		{
+			name: "malformed chunk",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				ndjson(w, 0, `{"type":`)
+			},
+			wantErr: "failed to parse chat stream chunk",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := streamEngine(t, Config{Timeout: tt.timeout, BreakerThreshold: 1, BreakerCooldown: time.Minute}, tt.handler)
+
+			chunks, err := collect(client, context.Background())
+			var types []string
+			for _, chunk := range chunks {
+				types = append(types, chunk.Type)
+			}
+			if strings.Join(types, ",") != strings.Join(tt.wantTypes, ",") {
+				t.Errorf("chunks = %v, want %v", types, tt.wantTypes)
+			}
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("ChatStream() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("ChatStream() error = %v, want %q", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrStreamInterrupted) != tt.wantInterrupted {
+				t.Errorf("errors.Is(%v, ErrStreamInterrupted) = %v, want %v", err, !tt.wantInterrupted, tt.wantInterrupted)
+			}
+			// The engine answered, so failures after that do not count
+			if state := client.Status().State; state != StateClosed {
+				t.Errorf("breaker = %s, want %s", state, StateClosed)
+			}
+		})
+	}
+}
+
+func TestChatStreamClientCancel(t *testing.T) {
+	upstreamClosed := make(chan struct{})
+	client := streamEngine(t, Config{BreakerThreshold: 1, BreakerCooldown: time.Minute}, func(w http.ResponseWriter, r *http.Request) {
+		ndjson(w, 0, `{"type":"token","content":"Hel"}`)
+		hang(r)
+		close(upstreamClosed)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err := client.ChatStream(ctx, ChatRequest{Message: "hello"}, func(ChatChunk) error {
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ChatStream() error = %v, want context.Canceled", err)
+	}
+	select {
+	case <-upstreamClosed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request was not closed")
+	}
+	if state := client.Status().State; state != StateClosed {
+		t.Errorf("breaker = %s, want %s", state, StateClosed)
+	}
+}
+
+func TestChatStreamCallbackError(t *testing.T) {
+	client := streamEngine(t, Config{}, func(w http.ResponseWriter, r *http.Request) {
+		ndjson(w, 0, `{"type":"token"}`, `{"type":"token"}`, `{"type":"done"}`)
+	})
+	stop := errors.New("client gone")
+	calls := 0
+	err := client.ChatStream(context.Background(), ChatRequest{}, func(ChatChunk) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("ChatStream() = %v after %d chunks, want the callback's error after 1", err, calls)
+	}
+}
+
+func TestChatStreamBreaker(t *testing.T) {
+	t.Run("error status counts", func(t *testing.T) {
+		client := streamEngine(t, Config{BreakerThreshold: 1, BreakerCooldown: time.Minute}, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "overloaded", http.StatusServiceUnavailable)
+		})
+		_, err := collect(client, context.Background())
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("ChatStream() error = %v, want a 503 StatusError", err)
+		}
+		if state := client.Status().State; state != StateOpen {
+			t.Fatalf("breaker = %s, want %s", state, StateOpen)
+		}
+		if _, err := collect(client, context.Background()); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("ChatStream() with the breaker open error = %v, want ErrCircuitOpen", err)
+		}
+	})
+
+	t.Run("bad request does not count", func(t *testing.T) {
+		client := streamEngine(t, Config{BreakerThreshold: 1, BreakerCooldown: time.Minute}, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "no message", http.StatusBadRequest)
+		})
+		if _, err := collect(client, context.Background()); err == nil {
+			t.Fatal("ChatStream() error = nil, want the 400")
+		}
+		if state := client.Status().State; state != StateClosed {
+			t.Errorf("breaker = %s, want %s", state, StateClosed)
+		}
+	})
+
+	t.Run("silence before the response counts", func(t *testing.T) {
+		client := streamEngine(t, Config{Timeout: 50 * time.Millisecond, BreakerThreshold: 1, BreakerCooldown: time.Minute}, func(w http.ResponseWriter, r *http.Request) {
+			hang(r)
+		})
+		if _, err := collect(client, context.Background()); err == nil {
+			t.Fatal("ChatStream() error = nil, want a timeout")
+		}
+		if state := client.Status().State; state != StateOpen {
+			t.Errorf("breaker = %s, want %s", state, StateOpen)
+		}
+	})
+
+	t.Run("probe abandoned by the caller", func(t *testing.T) {
+		var answering atomic.Bool
+		client := streamEngine(t, Config{BreakerThreshold: 1, BreakerCooldown: 10 * time.Millisecond}, func(w http.ResponseWriter, r *http.Request) {
+			if !answering.Load() {
+				http.Error(w, "overloaded", http.StatusServiceUnavailable)
+				return
+			}
+			hang(r)
+		})
+		collect(client, context.Background())
+		if state := client.Status().State; state != StateOpen {
+			t.Fatalf("breaker = %s, want %s", state, StateOpen)
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		// The half-open probe is cancelled by the caller before the engine
+		// answers, which says nothing about the engine
+		answering.Store(true)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := collect(client, ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("ChatStream() error = %v, want context.DeadlineExceeded", err)
+		}
+		status := client.Status()
+		if status.State != StateHalfOpen || status.ConsecutiveFailures != 1 {
+			t.Errorf("breaker = %+v, want still half-open after one failure", status)
+		}
+		// and the next call may probe
+		ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := collect(client, ctx); errors.Is(err, ErrCircuitOpen) {
+			t.Error("next call rejected: the abandoned probe was not released")
+		}
+	})
+}
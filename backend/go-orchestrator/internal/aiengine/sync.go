@@ -0,0 +1,367 @@
+package aiengine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"orchestrator/internal/metrics"
+	"orchestrator/internal/models"
+	"orchestrator/internal/storage"
+)
+
+const (
+	defaultSyncInterval = 5 * time.Minute
+	defaultHorizon      = 30 // minutes
+	// historyWindow and historyStep shape the series sent to /api/predict.
+	historyWindow = time.Hour
+	historyStep   = time.Minute
+	// predictionRetention is how long stored predictions are kept.
+	predictionRetention = 7 * 24 * time.Hour
+	// engineDetector marks anomalies reported by the AI engine.
+	engineDetector = "ai-engine"
+	// defaultCooldown is how long a decided recommendation suppresses the
+	// same suggestion.
+	defaultCooldown = 24 * time.Hour
+	// syncLease is the storage lease held by the replica that syncs.
+	syncLease = "ai-sync"
+)
+
+// predictionTargets maps the engine's prediction targets to collector series.
+var predictionTargets = []struct {
+	target string
+	metric string
+}{
+	{"cpu", "cpu_usage"},
+	{"memory", "memory_usage"},
+	{"traffic", "network_throughput"},
+}
+
+// Syncer periodically feeds collector history to the AI engine and stores
+// the predictions, recommendations and anomalies it returns.
+type Syncer struct {
+	client    *Client
+	collector *metrics.Collector
+	store     storage.Store
+	interval  time.Duration
+	horizon   int
+	cooldown  time.Duration
+	// holder identifies this replica when taking the sync lease.
+	holder string
+	// active holds the engine-reported anomalies still in progress, by type.
+	active map[string]*models.Anomaly
+}
+
+// NewSyncer creates a syncer. AI_SYNC_INTERVAL (a Go duration),
+// AI_PREDICTION_HORIZON (minutes) and AI_RECOMMENDATION_COOLDOWN (a Go
+// duration) override the defaults.
+func NewSyncer(client *Client, collector *metrics.Collector, store storage.Store) *Syncer {
+	interval := defaultSyncInterval
+	if value, err := time.ParseDuration(os.Getenv("AI_SYNC_INTERVAL")); err == nil && value > 0 {
+		interval = value
+	}
+	horizon := defaultHorizon
+	if value, err := strconv.Atoi(os.Getenv("AI_PREDICTION_HORIZON")); err == nil && value > 0 {
+		horizon = value
+	}
+	cooldown := defaultCooldown
+	if value, err := time.ParseDuration(os.Getenv("AI_RECOMMENDATION_COOLDOWN")); err == nil && value >= 0 {
+		cooldown = value
+	}
+	return &Syncer{
+		client:    client,
+		collector: collector,
+		store:     store,
+		interval:  interval,
+		horizon:   horizon,
+		cooldown:  cooldown,
+		holder:    leaseHolder(),
+		active:    make(map[string]*models.Anomaly),
+	}
+}
+
+// leaseHolder names this process for the sync lease.
+func leaseHolder() string {
+	host, _ := os.Hostname()
+	return host + "/" + uuid.NewString()
+}
+
+// Run syncs immediately and then every interval until ctx is cancelled.
+// With several replicas sharing a database only the one holding the sync
+// lease syncs, so the engine is called and its results stored once; the
+// lease lasts two intervals, after which another replica takes over.
+func (s *Syncer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	leading := false
+	for {
+		held, err := s.store.AcquireLease(ctx, syncLease, s.holder, 2*s.interval)
+		if err != nil {
+			log.Printf("Failed to take the AI sync lease: %v", err)
+		}
+		if held {
+			if !leading {
+				// Another replica may have been tracking anomalies until now
+				log.Printf("Syncing with the AI engine from this replica")
+				s.loadActive(ctx)
+			}
+			s.Sync(ctx)
+		}
+		leading = held
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync performs one round of predictions, recommendations and anomaly
+// checks. Failures are logged per endpoint so one failing call does not
+// block the others.
+func (s *Syncer) Sync(ctx context.Context) {
+	if err := s.syncPredictions(ctx); err != nil {
+		log.Printf("AI engine predictions failed: %v", err)
+	}
+
+	current := s.collector.GetCurrentMetrics()
+	if err := s.syncRecommendations(ctx, current); err != nil {
+		log.Printf("AI engine recommendations failed: %v", err)
+	}
+	if err := s.syncAnomalies(ctx, current); err != nil {
+		log.Printf("AI engine anomaly detection failed: %v", err)
+	}
+
+	if _, err := s.store.DeletePredictionsBefore(ctx, time.Now().Add(-predictionRetention)); err != nil {
+		log.Printf("Failed to prune predictions: %v", err)
+	}
+}
+
+func (s *Syncer) syncPredictions(ctx context.Context) error {
+	now := time.Now()
+	for _, t := range predictionTargets {
+		var history []MetricValue
+		results, _ := s.collector.QueryRange(t.metric, nil, now.Add(-historyWindow), now, historyStep)
+		for _, result := range results {
+			if len(result.Labels) > 0 {
+				continue
+			}
+			for _, point := range result.Points {
+				history = append(history, MetricValue{Timestamp: point.Timestamp, Value: point.Avg})
+			}
+		}
+		if len(history) == 0 {
+			// Without history the engine fabricates sample data; skip instead
+			continue
+		}
+
+		resp, err := s.client.Predict(ctx, PredictRequest{Metrics: history, Target: t.target, Horizon: s.horizon})
+		if err != nil {
+			return fmt.Errorf("%s: %v", t.target, err)
+		}
+
+		prediction := models.Prediction{
+			Type:            t.target,
+			Metric:          t.metric,
+			Confidence:      resp.Confidence,
+			TimeWindow:      fmt.Sprintf("%dm", s.horizon),
+			Recommendations: resp.Recommendations,
+			CreatedAt:       now,
+			ExpiresAt:       now.Add(time.Duration(s.horizon) * time.Minute),
+		}
+		for i, p := range resp.Predictions {
+			timestamp, err := parseTimestamp(p.Timestamp)
+			if err != nil {
+				timestamp = now.Add(time.Duration(i) * time.Minute)
+			}
+			prediction.Points = append(prediction.Points, models.PredictionPoint{
+				Timestamp:  timestamp,
+				Value:      p.Value,
+				Confidence: p.Confidence,
+			})
+			if i == 0 || p.Value > prediction.Peak {
+				prediction.Peak = p.Value
+			}
+		}
+		if len(resp.Recommendations) > 0 {
+			prediction.Message = resp.Recommendations[0]
+		} else {
+			prediction.Message = fmt.Sprintf("%s expected to peak at %.1f in the next %s", t.target, prediction.Peak, prediction.TimeWindow)
+		}
+
+		if err := s.store.SavePrediction(ctx, &prediction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncRecommendations stores the engine's suggestions as pending
+// recommendations. A suggestion matching an existing pending one (same type,
+// target and action) refreshes it instead of adding a duplicate; one matching
+// a recommendation decided within the cooldown is dropped, so that rejected,
+// applied or failed suggestions do not come straight back.
+func (s *Syncer) syncRecommendations(ctx context.Context, current map[string]float64) error {
+	suggestions, err := s.client.Recommendations(ctx, current)
+	if err != nil {
+		return err
+	}
+
+	existing, err := s.store.ListRecommendations(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	pending := make(map[string]models.Recommendation)
+	decided := make(map[string]bool)
+	for _, rec := range existing {
+		if rec.Source != models.RecommendationSourceAIEngine {
+			continue
+		}
+		key := rec.Type + "|" + rec.Target + "|" + rec.Action
+		if rec.Status == models.RecommendationPending {
+			pending[key] = rec
+			continue
+		}
+		updated := rec.UpdatedAt
+		if updated.IsZero() {
+			updated = rec.CreatedAt
+		}
+		if now.Sub(updated) < s.cooldown {
+			decided[key] = true
+		}
+	}
+
+	for _, suggestion := range suggestions {
+		key := suggestion.Type + "|" + suggestion.Target + "|" + suggestion.Action
+		if decided[key] {
+			continue
+		}
+		rec, ok := pending[key]
+		if !ok {
+			rec = models.Recommendation{
+				Type:   suggestion.Type,
+				Target: suggestion.Target,
+				Action: suggestion.Action,
+				Status: models.RecommendationPending,
+				Source: models.RecommendationSourceAIEngine,
+			}
+		}
+		rec.Confidence = suggestion.Confidence
+		rec.Reasoning = suggestion.Reasoning
+		rec.Impact = suggestion.Impact
+		rec.Payload = suggestion.Payload
+		if rec.Payload != nil {
+			if err := rec.Payload.Validate(); err != nil {
+				log.Printf("AI engine recommendation %q has an invalid payload: %v", suggestion.Action, err)
+				rec.Payload = nil
+			}
+		}
+		rec.Informational = rec.Payload == nil
+		if rec.ID == "" {
+			err = s.store.SaveRecommendation(ctx, &rec)
+		} else {
+			// Leave it alone if it was decided since it was listed
+			err = s.store.UpdateRecommendation(ctx, &rec, models.RecommendationPending)
+			if storage.IsConflict(err) {
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+		// A suggestion repeated in one response refreshes the same record
+		pending[key] = rec
+	}
+	return nil
+}
+
+// syncAnomalies records the engine's findings alongside the native detector's.
+// A finding stays active while the engine keeps reporting it and is resolved
+// on the first sync that no longer does.
+func (s *Syncer) syncAnomalies(ctx context.Context, current map[string]float64) error {
+	findings, err := s.client.Anomalies(ctx, current)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	reported := make(map[string]bool)
+	for _, finding := range findings {
+		reported[finding.Type] = true
+		anomaly, ok := s.active[finding.Type]
+		if !ok {
+			anomaly = &models.Anomaly{
+				ID:        uuid.NewString(),
+				Type:      finding.Type,
+				Series:    engineDetector + "/" + finding.Type,
+				Metric:    finding.Type,
+				Service:   "cluster",
+				Status:    models.AnomalyStatusActive,
+				Detectors: []string{engineDetector},
+				StartedAt: now,
+			}
+			s.active[finding.Type] = anomaly
+		}
+		anomaly.Severity = engineSeverity(finding.Severity)
+		anomaly.Message = finding.Message
+		anomaly.Timestamp = now
+		if err := s.store.SaveAnomaly(ctx, anomaly); err != nil {
+			return err
+		}
+	}
+
+	for kind, anomaly := range s.active {
+		if reported[kind] {
+			continue
+		}
+		ended := anomaly.Timestamp
+		anomaly.EndedAt = &ended
+		anomaly.Status = models.AnomalyStatusResolved
+		delete(s.active, kind)
+		if err := s.store.SaveAnomaly(ctx, anomaly); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadActive resumes tracking of engine anomalies left active by a previous
+// run or another replica.
+func (s *Syncer) loadActive(ctx context.Context) {
+	anomalies, err := s.store.ListAnomalies(ctx, storage.AnomalyFilter{Status: models.AnomalyStatusActive})
+	if err != nil {
+		log.Printf("Failed to load active AI engine anomalies: %v", err)
+		return
+	}
+	s.active = make(map[string]*models.Anomaly)
+	for i := range anomalies {
+		anomaly := anomalies[i]
+		if len(anomaly.Detectors) == 1 && anomaly.Detectors[0] == engineDetector {
+			s.active[anomaly.Type] = &anomaly
+		}
+	}
+}
+
+func engineSeverity(severity string) string {
+	if severity == "high" || severity == "critical" {
+		return models.AnomalySeverityCritical
+	}
+	return models.AnomalySeverityWarning
+}
+
+// parseTimestamp accepts RFC 3339 and the naive ISO 8601 timestamps Python's
+// datetime.isoformat produces, which are taken as UTC.
+func parseTimestamp(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05.999999", value, time.UTC)
+}
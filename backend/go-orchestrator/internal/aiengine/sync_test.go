@@ -0,0 +1,188 @@
+package aiengine
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"orchestrator/internal/executor"
+	"orchestrator/internal/metrics"
+	"orchestrator/internal/models"
+	"orchestrator/internal/storage"
+)
+
+func int32Ptr(v int32) *int32 { return &v }
+
+// stubEngine answers /api/recommendations with suggestions.
+func stubEngine(t *testing.T, suggestions []Recommendation) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/recommendations" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"recommendations": suggestions})
+	}))
+	t.Cleanup(server.Close)
+	return NewClient(Config{BaseURL: server.URL, Timeout: time.Second})
+}
+
+func openTestStore(t *testing.T) storage.Store {
+	t.Helper()
+	store, err := storage.Open(context.Background(), storage.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSyncRecommendations(t *testing.T) {
+	scale := Recommendation{
+		Type: "scale", Target: "web", Action: "Scale web to 4 replicas", Confidence: 0.9,
+		Payload: &executor.Action{Kind: executor.KindDeployment, Namespace: "default", Name: "web", Replicas: int32Ptr(4)},
+	}
+	nodes := Recommendation{Type: "scale", Target: "compute-nodes", Action: "Add 2 more nodes", Confidence: 0.8}
+	invalid := scale
+	invalid.Payload = &executor.Action{Kind: executor.KindDeployment, Namespace: "default", Replicas: int32Ptr(4)}
+	existing := func(status, source string) *models.Recommendation {
+		return &models.Recommendation{Type: scale.Type, Target: scale.Target, Action: scale.Action, Confidence: 0.5, Status: status, Source: source}
+	}
+
+	tests := []struct {
+		name     string
+		existing *models.Recommendation
+		cooldown time.Duration
+		suggest  Recommendation
+		// wantPending is the number of pending recommendations after the
+		// sync, and wantInformational whether the suggestion was stored as
+		// informational.
+		wantPending       int
+		wantInformational bool
+	}{
+		{name: "new with payload", suggest: scale, wantPending: 1},
+		{name: "new without payload", suggest: nodes, wantPending: 1, wantInformational: true},
+		{name: "invalid payload dropped", suggest: invalid, wantPending: 1, wantInformational: true},
+		{name: "pending refreshed", existing: existing(models.RecommendationPending, models.RecommendationSourceAIEngine), suggest: scale, wantPending: 1},
+		{name: "rejected within cooldown", existing: existing(models.RecommendationRejected, models.RecommendationSourceAIEngine), suggest: scale},
+		{name: "applied within cooldown", existing: existing(models.RecommendationApplied, models.RecommendationSourceAIEngine), suggest: scale},
+		{name: "failed within cooldown", existing: existing(models.RecommendationFailed, models.RecommendationSourceAIEngine), suggest: scale},
+		{name: "rolled back within cooldown", existing: existing(models.RecommendationRolledBack, models.RecommendationSourceAIEngine), suggest: scale},
+		{name: "rejected after cooldown", existing: existing(models.RecommendationRejected, models.RecommendationSourceAIEngine), cooldown: -1, suggest: scale, wantPending: 1},
+		{name: "pending from another source", existing: existing(models.RecommendationPending, ""), suggest: scale, wantPending: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := openTestStore(t)
+			if tt.existing != nil {
+				if err := store.SaveRecommendation(ctx, tt.existing); err != nil {
+					t.Fatalf("seed recommendation: %v", err)
+				}
+			}
+			cooldown := time.Hour
+			if tt.cooldown != 0 {
+				cooldown = tt.cooldown
+			}
+			s := &Syncer{client: stubEngine(t, []Recommendation{tt.suggest}), store: store, cooldown: cooldown}
+
+			if err := s.syncRecommendations(ctx, map[string]float64{"cpu_usage": 80}); err != nil {
+				t.Fatalf("syncRecommendations() error = %v", err)
+			}
+
+			recs, err := store.ListRecommendations(ctx)
+			if err != nil {
+				t.Fatalf("list recommendations: %v", err)
+			}
+			var pending []models.Recommendation
+			for _, rec := range recs {
+				if rec.Status == models.RecommendationPending {
+					pending = append(pending, rec)
+				}
+			}
+			if len(pending) != tt.wantPending {
+				t.Fatalf("got %d pending recommendations, want %d", len(pending), tt.wantPending)
+			}
+			for _, rec := range pending {
+				if rec.Source != models.RecommendationSourceAIEngine {
+					continue
+				}
+				if rec.Confidence != tt.suggest.Confidence {
+					t.Errorf("confidence = %v, want %v", rec.Confidence, tt.suggest.Confidence)
+				}
+				if rec.Informational != tt.wantInformational {
+					t.Errorf("informational = %v, want %v", rec.Informational, tt.wantInformational)
+				}
+				if (rec.Payload == nil) != tt.wantInformational {
+					t.Errorf("payload = %+v, want payload: %v", rec.Payload, !tt.wantInformational)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncRecommendationsRepeatedInOneResponse(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	nodes := Recommendation{Type: "scale", Target: "compute-nodes", Action: "Add 2 more nodes"}
+	s := &Syncer{client: stubEngine(t, []Recommendation{nodes, nodes}), store: store, cooldown: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		if err := s.syncRecommendations(ctx, nil); err != nil {
+			t.Fatalf("syncRecommendations() error = %v", err)
+		}
+	}
+	recs, err := store.ListRecommendations(ctx)
+	if err != nil {
+		t.Fatalf("list recommendations: %v", err)
+	}
+	if len(recs) != 1 {
+		t.Errorf("got %d recommendations, want 1", len(recs))
+	}
+}
+
+func TestRunHoldsLease(t *testing.T) {
+	store := openTestStore(t)
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	newSyncer := func(holder string) *Syncer {
+		return &Syncer{
+			client:    NewClient(Config{BaseURL: server.URL, Timeout: time.Second}),
+			collector: metrics.NewCollector(store, metrics.NewMockSource(), nil),
+			store:     store,
+			interval:  10 * time.Millisecond,
+			holder:    holder,
+			active:    make(map[string]*models.Anomaly),
+		}
+	}
+	run := func(s *Syncer) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		s.Run(ctx)
+	}
+
+	// Another replica holds the lease
+	if held, err := store.AcquireLease(context.Background(), syncLease, "other", time.Hour); err != nil || !held {
+		t.Fatalf("AcquireLease() = %v, %v", held, err)
+	}
+	run(newSyncer("follower"))
+	if n := calls.Load(); n != 0 {
+		t.Fatalf("follower called the engine %d times, want 0", n)
+	}
+
+	run(newSyncer("other"))
+	if calls.Load() == 0 {
+		t.Error("lease holder never called the engine")
+	}
+}
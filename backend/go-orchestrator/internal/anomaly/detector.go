@@ -0,0 +1,444 @@
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"orchestrator/internal/metrics"
+	"orchestrator/internal/models"
+	"orchestrator/internal/storage"
+)
+
+const (
+	detectorZScore   = "zscore"
+	detectorEWMA     = "ewma"
+	detectorSeasonal = "seasonal"
+
+	persistInterval = 10 * time.Minute
+	// staleAfter is how long a series may go unseen before its in-memory
+	// state is dropped. Its seasonal baseline stays in the store.
+	staleAfter = 24 * time.Hour
+	// warmupWindow is how much stored history Load replays into the rolling
+	// and EWMA detectors.
+	warmupWindow = time.Hour
+)
+
+// DefaultSeries are the metrics watched when ANOMALY_SERIES is unset.
+var DefaultSeries = []string{
+	"cpu_usage",
+	"memory_usage",
+	"network_throughput",
+	"deployment_cpu_usage",
+	"deployment_memory_usage",
+}
+
+// Config tunes the detector.
+type Config struct {
+	// Series are the metric names to watch; every labelled series with one of
+	// these names is tracked separately.
+	Series []string
+	// Window is the number of samples in the rolling z-score window.
+	Window int
+	// Threshold is the z-score, and the number of standard deviations for the
+	// EWMA and seasonal limits, beyond which a sample is flagged.
+	Threshold float64
+	// Alpha is the EWMA smoothing factor.
+	Alpha float64
+	// MinSamples is how many samples a detector needs before it votes.
+	MinSamples int
+	// MinWeeks is how many distinct weeks an hour-of-week bucket needs before
+	// the seasonal detector votes.
+	MinWeeks int
+	// MinVotes is how many detectors must agree for a sample to be anomalous.
+	// When fewer detectors are warmed up, all of them must agree.
+	MinVotes int
+	// ResolveAfter is how long a series must look normal before its anomaly
+	// is resolved.
+	ResolveAfter time.Duration
+}
+
+// DefaultConfig returns the detector defaults.
+func DefaultConfig() Config {
+	return Config{
+		Series:       DefaultSeries,
+		Window:       60,
+		Threshold:    3,
+		Alpha:        0.2,
+		MinSamples:   20,
+		MinWeeks:     2,
+		MinVotes:     2,
+		ResolveAfter: 2 * time.Minute,
+	}
+}
+
+// ConfigFromEnv reads ANOMALY_SERIES (comma-separated), ANOMALY_THRESHOLD,
+// ANOMALY_WINDOW, ANOMALY_MIN_VOTES and ANOMALY_RESOLVE_AFTER over the
+// defaults.
+func ConfigFromEnv() Config {
+	cfg := DefaultConfig()
+	if value := os.Getenv("ANOMALY_SERIES"); value != "" {
+		cfg.Series = nil
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				cfg.Series = append(cfg.Series, name)
+			}
+		}
+	}
+	if value, err := strconv.ParseFloat(os.Getenv("ANOMALY_THRESHOLD"), 64); err == nil && value > 0 {
+		cfg.Threshold = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("ANOMALY_WINDOW")); err == nil && value > 1 {
+		cfg.Window = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("ANOMALY_MIN_VOTES")); err == nil && value > 0 {
+		cfg.MinVotes = value
+	}
+	if value, err := time.ParseDuration(os.Getenv("ANOMALY_RESOLVE_AFTER")); err == nil && value > 0 {
+		cfg.ResolveAfter = value
+	}
+	return cfg
+}
+
+type seriesState struct {
+	name     string
+	labels   map[string]string
+	window   *rollingWindow
+	ewma     ewma
+	seasonal *models.SeasonalBaseline
+	active   *models.Anomaly
+	lastSeen time.Time
+}
+
+// verdict is the outcome of evaluating one sample against a series' state.
+type verdict struct {
+	detectors []string
+	available int
+	expected  float64
+	lower     float64
+	upper     float64
+	score     float64
+}
+
+// Detector watches collector snapshots for anomalous samples. Each series is
+// judged by a rolling z-score, EWMA control limits and an hour-of-week
+// seasonal baseline; a sample is anomalous when enough of them agree.
+// Consecutive anomalous samples form one Anomaly record, which is persisted
+// when it starts, while it grows and when it resolves.
+type Detector struct {
+	mu     sync.Mutex
+	cfg    Config
+	store  storage.Store
+	watch  map[string]bool
+	series map[string]*seriesState
+}
+
+// New creates a detector that persists anomalies and baselines to store.
+func New(store storage.Store, cfg Config) *Detector {
+	watch := make(map[string]bool, len(cfg.Series))
+	for _, name := range cfg.Series {
+		watch[name] = true
+	}
+	return &Detector{
+		cfg:    cfg,
+		store:  store,
+		watch:  watch,
+		series: make(map[string]*seriesState),
+	}
+}
+
+// Load restores seasonal baselines and active anomalies from the store and
+// warms the rolling and EWMA detectors with the last hour of snapshots.
+func (d *Detector) Load(ctx context.Context) error {
+	baselines, err := d.store.ListSeasonalBaselines(ctx)
+	if err != nil {
+		return err
+	}
+	active, err := d.store.ListAnomalies(ctx, storage.AnomalyFilter{Status: models.AnomalyStatusActive})
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	snapshots, err := d.store.ListMetricSnapshots(ctx, now.Add(-warmupWindow), now)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i := range baselines {
+		baseline := baselines[i]
+		if len(baseline.Buckets) != hoursPerWeek {
+			continue
+		}
+		d.series[baseline.Series] = d.newState("", nil, &baseline)
+	}
+	for i := range active {
+		anomaly := active[i]
+		if !d.watch[anomaly.Metric] {
+			// Reported by another source, such as the AI engine
+			continue
+		}
+		state := d.stateFor(anomaly.Series, anomaly.Metric, anomaly.Labels)
+		state.active = &anomaly
+		state.lastSeen = anomaly.Timestamp
+	}
+	for _, snapshot := range snapshots {
+		d.forEachSample(snapshot, func(key string, sample models.Sample) {
+			state := d.stateFor(key, sample.Name, sample.Labels)
+			state.window.add(sample.Value)
+			state.ewma.add(sample.Value)
+			state.lastSeen = snapshot.Timestamp
+		})
+	}
+	return nil
+}
+
+// Observe evaluates every watched sample in snapshot, then folds it into the
+// baselines. It is registered as a collector snapshot subscriber.
+func (d *Detector) Observe(ctx context.Context, snapshot models.MetricSnapshot) {
+	d.mu.Lock()
+	var changed []models.Anomaly
+	seen := make(map[string]bool)
+	d.forEachSample(snapshot, func(key string, sample models.Sample) {
+		seen[key] = true
+		state := d.stateFor(key, sample.Name, sample.Labels)
+		if anomaly := d.evaluate(key, state, snapshot.Timestamp, sample.Value); anomaly != nil {
+			changed = append(changed, *anomaly)
+		}
+		state.window.add(sample.Value)
+		state.ewma.add(sample.Value)
+		addSeasonal(state.seasonal, snapshot.Timestamp, sample.Value)
+		state.lastSeen = snapshot.Timestamp
+	})
+
+	// Series that stopped reporting, e.g. a deleted deployment, resolve too
+	for key, state := range d.series {
+		if !seen[key] && state.active != nil && snapshot.Timestamp.Sub(state.active.Timestamp) >= d.cfg.ResolveAfter {
+			changed = append(changed, d.resolve(state))
+		}
+	}
+	d.mu.Unlock()
+
+	for i := range changed {
+		if err := d.store.SaveAnomaly(ctx, &changed[i]); err != nil {
+			log.Printf("Failed to store anomaly on %s: %v", changed[i].Series, err)
+		}
+	}
+}
+
+// Run persists seasonal baselines every persistInterval and once more when
+// ctx is cancelled.
+func (d *Detector) Run(ctx context.Context) {
+	ticker := time.NewTicker(persistInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			d.persist(context.Background())
+			return
+		case <-ticker.C:
+			d.persist(ctx)
+		}
+	}
+}
+
+func (d *Detector) persist(ctx context.Context) {
+	d.mu.Lock()
+	now := time.Now()
+	baselines := make([]models.SeasonalBaseline, 0, len(d.series))
+	for key, state := range d.series {
+		if state.seasonal.UpdatedAt.IsZero() {
+			continue
+		}
+		baseline := *state.seasonal
+		baseline.Buckets = append([]models.SeasonalBucket(nil), state.seasonal.Buckets...)
+		baselines = append(baselines, baseline)
+
+		if state.active == nil && now.Sub(state.lastSeen) > staleAfter {
+			delete(d.series, key)
+		}
+	}
+	d.mu.Unlock()
+
+	if err := d.store.SaveSeasonalBaselines(ctx, baselines); err != nil {
+		log.Printf("Failed to store anomaly baselines: %v", err)
+	}
+}
+
+// forEachSample calls fn for every watched sample in the snapshot.
+func (d *Detector) forEachSample(snapshot models.MetricSnapshot, fn func(key string, sample models.Sample)) {
+	for name, value := range snapshot.Metrics {
+		if d.watch[name] {
+			fn(name, models.Sample{Name: name, Value: value})
+		}
+	}
+	for _, sample := range snapshot.Series {
+		if d.watch[sample.Name] {
+			fn(metrics.SeriesKey(sample.Name, sample.Labels), sample)
+		}
+	}
+}
+
+func (d *Detector) newState(name string, labels map[string]string, seasonal *models.SeasonalBaseline) *seriesState {
+	return &seriesState{
+		name:     name,
+		labels:   labels,
+		window:   newRollingWindow(d.cfg.Window),
+		ewma:     ewma{alpha: d.cfg.Alpha},
+		seasonal: seasonal,
+	}
+}
+
+func (d *Detector) stateFor(key, name string, labels map[string]string) *seriesState {
+	state, ok := d.series[key]
+	if !ok {
+		state = d.newState(name, labels, newSeasonalBaseline(key))
+		d.series[key] = state
+	}
+	if state.name == "" {
+		// Restored from a baseline, which does not record the series identity
+		state.name, state.labels = name, labels
+	}
+	return state
+}
+
+// evaluate judges value against the state before it is updated and returns
+// the anomaly record if it started, changed or resolved.
+func (d *Detector) evaluate(key string, state *seriesState, ts time.Time, value float64) *models.Anomaly {
+	v := d.judge(state, ts, value)
+	needed := d.cfg.MinVotes
+	if v.available < needed {
+		needed = v.available
+	}
+	anomalous := needed > 0 && len(v.detectors) >= needed
+
+	if !anomalous {
+		if state.active != nil && ts.Sub(state.active.Timestamp) >= d.cfg.ResolveAfter {
+			resolved := d.resolve(state)
+			return &resolved
+		}
+		return nil
+	}
+
+	severity := models.AnomalySeverityWarning
+	if len(v.detectors) >= 3 || v.score >= 2*d.cfg.Threshold {
+		severity = models.AnomalySeverityCritical
+	}
+	kind := "spike"
+	if value < v.expected {
+		kind = "drop"
+	}
+
+	anomaly := state.active
+	if anomaly == nil {
+		anomaly = &models.Anomaly{
+			ID:        uuid.NewString(),
+			Series:    key,
+			Metric:    state.name,
+			Labels:    state.labels,
+			Service:   serviceOf(state.labels),
+			Status:    models.AnomalyStatusActive,
+			StartedAt: ts,
+		}
+		state.active = anomaly
+	}
+	anomaly.Timestamp = ts
+	if anomaly.Severity != models.AnomalySeverityCritical {
+		anomaly.Severity = severity
+	}
+	if v.score >= anomaly.Score {
+		anomaly.Type = kind
+		anomaly.Detectors = v.detectors
+		anomaly.Value = value
+		anomaly.Expected = v.expected
+		anomaly.Lower = v.lower
+		anomaly.Upper = v.upper
+		anomaly.Score = v.score
+		anomaly.Message = fmt.Sprintf("%s %s on %s: %.4g (expected %.4g, limits %.4g–%.4g)",
+			state.name, kind, anomaly.Service, value, v.expected, v.lower, v.upper)
+	}
+
+	updated := *anomaly
+	return &updated
+}
+
+// judge runs each warmed-up detector over value.
+func (d *Detector) judge(state *seriesState, ts time.Time, value float64) verdict {
+	var v verdict
+	threshold := d.cfg.Threshold
+
+	if state.window.len() >= d.cfg.MinSamples {
+		v.available++
+		mean, std := state.window.meanStd()
+		std = stdFloor(std, mean)
+		z := (value - mean) / std
+		if math.Abs(z) >= threshold {
+			v.detectors = append(v.detectors, detectorZScore)
+		}
+		v.expected, v.lower, v.upper = mean, mean-threshold*std, mean+threshold*std
+		v.score = math.Abs(z)
+	}
+
+	if state.ewma.count >= d.cfg.MinSamples {
+		v.available++
+		sigma := stdFloor(math.Sqrt(state.ewma.variance), state.ewma.mean)
+		if math.Abs(value-state.ewma.mean) > threshold*sigma {
+			v.detectors = append(v.detectors, detectorEWMA)
+		}
+		// The EWMA tracks level shifts best, so its limits are reported
+		v.expected = state.ewma.mean
+		v.lower, v.upper = state.ewma.mean-threshold*sigma, state.ewma.mean+threshold*sigma
+		if v.score == 0 {
+			v.score = math.Abs(value-state.ewma.mean) / sigma
+		}
+	}
+
+	bucket := state.seasonal.Buckets[hourOfWeek(ts)]
+	if bucket.Weeks >= d.cfg.MinWeeks && bucket.Count >= int64(d.cfg.MinSamples) {
+		v.available++
+		std := stdFloor(seasonalStd(bucket), bucket.Mean)
+		if math.Abs(value-bucket.Mean) > threshold*std {
+			v.detectors = append(v.detectors, detectorSeasonal)
+		}
+	}
+	return v
+}
+
+// resolve closes the state's active anomaly at the last time it was seen.
+func (d *Detector) resolve(state *seriesState) models.Anomaly {
+	anomaly := state.active
+	state.active = nil
+
+	ended := anomaly.Timestamp
+	anomaly.EndedAt = &ended
+	anomaly.Status = models.AnomalyStatusResolved
+	return *anomaly
+}
+
+// serviceOf names the object a series describes, for display.
+func serviceOf(labels map[string]string) string {
+	namespace := labels["namespace"]
+	for _, key := range []string{"deployment", "pod"} {
+		if name := labels[key]; name != "" {
+			if namespace != "" {
+				return namespace + "/" + name
+			}
+			return name
+		}
+	}
+	if node := labels["node"]; node != "" {
+		return "node/" + node
+	}
+	return "cluster"
+}
@@ -0,0 +1,198 @@
+package anomaly
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"orchestrator/internal/models"
+	"orchestrator/internal/storage"
+)
+
+func testConfig() Config {
+	return Config{
+		Series:       []string{"cpu_usage"},
+		Window:       10,
+		Threshold:    3,
+		Alpha:        0.2,
+		MinSamples:   5,
+		MinWeeks:     2,
+		MinVotes:     2,
+		ResolveAfter: 2 * time.Minute,
+	}
+}
+
+// now falls in the middle of an hour, so the seasonal samples below share its
+// hour-of-week bucket.
+var now = time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+
+// warm feeds the rolling window and EWMA ten samples alternating around 11
+// (σ ≈ 1).
+func warm(state *seriesState) {
+	for i := 0; i < 10; i++ {
+		value := 10.0 + float64(2*(i%2))
+		state.window.add(value)
+		state.ewma.add(value)
+	}
+}
+
+// warmSeasonal gives the current hour-of-week bucket three samples around
+// mean in each of the previous two weeks.
+func warmSeasonal(state *seriesState, mean float64) {
+	week := time.Duration(hoursPerWeek) * time.Hour
+	for w := 1; w <= 2; w++ {
+		for i := 0; i < 3; i++ {
+			addSeasonal(state.seasonal, now.Add(-time.Duration(w)*week+time.Duration(i)*time.Minute), mean-1+float64(i))
+		}
+	}
+}
+
+func TestJudgeVoting(t *testing.T) {
+	tests := []struct {
+		name          string
+		warm          bool
+		seasonal      float64 // bucket mean; 0 leaves the bucket empty
+		minVotes      int
+		value         float64
+		wantDetectors []string
+		wantAvailable int
+		wantAnomalous bool
+		wantSeverity  string
+		wantType      string
+	}{
+		{name: "cold start", value: 1000},
+		{name: "normal value", warm: true, value: 11, wantAvailable: 2},
+		{
+			name: "zscore and ewma agree", warm: true, value: 14.5,
+			wantDetectors: []string{detectorZScore, detectorEWMA}, wantAvailable: 2,
+			wantAnomalous: true, wantSeverity: models.AnomalySeverityWarning, wantType: "spike",
+		},
+		{
+			name: "large deviation is critical", warm: true, value: 50,
+			wantDetectors: []string{detectorZScore, detectorEWMA}, wantAvailable: 2,
+			wantAnomalous: true, wantSeverity: models.AnomalySeverityCritical, wantType: "spike",
+		},
+		{
+			name: "drop", warm: true, value: 7.5,
+			wantDetectors: []string{detectorZScore, detectorEWMA}, wantAvailable: 2,
+			wantAnomalous: true, wantSeverity: models.AnomalySeverityWarning, wantType: "drop",
+		},
+		{
+			name: "all three agree is critical", warm: true, seasonal: 11, value: 14.5,
+			wantDetectors: []string{detectorZScore, detectorEWMA, detectorSeasonal}, wantAvailable: 3,
+			wantAnomalous: true, wantSeverity: models.AnomalySeverityCritical, wantType: "spike",
+		},
+		{
+			name: "seasonal alone is outvoted", warm: true, seasonal: 50, value: 11,
+			wantDetectors: []string{detectorSeasonal}, wantAvailable: 3,
+		},
+		{
+			name: "usual for the hour still outvoted with two of three", warm: true, seasonal: 50, value: 50,
+			wantDetectors: []string{detectorZScore, detectorEWMA}, wantAvailable: 3,
+			wantAnomalous: true, wantSeverity: models.AnomalySeverityCritical, wantType: "spike",
+		},
+		{
+			name: "two of three short of three votes", warm: true, seasonal: 50, minVotes: 3, value: 50,
+			wantDetectors: []string{detectorZScore, detectorEWMA}, wantAvailable: 3,
+		},
+		{
+			name: "only warmed-up detector decides", seasonal: 50, value: 11,
+			wantDetectors: []string{detectorSeasonal}, wantAvailable: 1,
+			wantAnomalous: true, wantSeverity: models.AnomalySeverityWarning, wantType: "spike",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			if tt.minVotes > 0 {
+				cfg.MinVotes = tt.minVotes
+			}
+			d := New(nil, cfg)
+			state := d.stateFor("cpu_usage", "cpu_usage", nil)
+			if tt.warm {
+				warm(state)
+			}
+			if tt.seasonal != 0 {
+				warmSeasonal(state, tt.seasonal)
+			}
+
+			v := d.judge(state, now, tt.value)
+			if !reflect.DeepEqual(v.detectors, tt.wantDetectors) {
+				t.Errorf("detectors = %v, want %v", v.detectors, tt.wantDetectors)
+			}
+			if v.available != tt.wantAvailable {
+				t.Errorf("available = %d, want %d", v.available, tt.wantAvailable)
+			}
+
+			anomaly := d.evaluate("cpu_usage", state, now, tt.value)
+			if (anomaly != nil) != tt.wantAnomalous {
+				t.Fatalf("evaluate() = %+v, want anomalous: %v", anomaly, tt.wantAnomalous)
+			}
+			if anomaly == nil {
+				return
+			}
+			if anomaly.Severity != tt.wantSeverity || anomaly.Type != tt.wantType {
+				t.Errorf("severity, type = %s, %s, want %s, %s", anomaly.Severity, anomaly.Type, tt.wantSeverity, tt.wantType)
+			}
+			if anomaly.Status != models.AnomalyStatusActive || !anomaly.StartedAt.Equal(now) {
+				t.Errorf("anomaly = %+v, want active from %v", anomaly, now)
+			}
+		})
+	}
+}
+
+func TestObserveLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store, err := storage.Open(ctx, storage.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+	d := New(store, testConfig())
+
+	ts := now
+	observe := func(value float64) {
+		d.Observe(ctx, models.MetricSnapshot{Timestamp: ts, Metrics: map[string]float64{"cpu_usage": value, "unwatched": 1000}})
+		ts = ts.Add(time.Minute)
+	}
+	stored := func() []models.Anomaly {
+		t.Helper()
+		anomalies, err := store.ListAnomalies(ctx, storage.AnomalyFilter{})
+		if err != nil {
+			t.Fatalf("list anomalies: %v", err)
+		}
+		return anomalies
+	}
+
+	for i := 0; i < 10; i++ {
+		observe(10 + float64(2*(i%2)))
+	}
+	if got := stored(); len(got) != 0 {
+		t.Fatalf("anomalies during warm-up = %+v, want none", got)
+	}
+
+	// Consecutive anomalous samples extend one record, which keeps the
+	// strongest deviation: 50 against a history around 11, not 100 against
+	// one that already includes 50
+	spike := ts
+	observe(50)
+	observe(100)
+	got := stored()
+	if len(got) != 1 || got[0].Status != models.AnomalyStatusActive || got[0].Value != 50 ||
+		!got[0].StartedAt.Equal(spike) || !got[0].Timestamp.Equal(spike.Add(time.Minute)) {
+		t.Fatalf("anomalies after spike = %+v, want one active from the first to the second sample", got)
+	}
+
+	// Normal samples resolve it once ResolveAfter has passed
+	observe(11)
+	if got := stored(); got[0].Status != models.AnomalyStatusActive {
+		t.Fatalf("resolved after one normal sample, want active until ResolveAfter")
+	}
+	observe(11)
+	got = stored()
+	if len(got) != 1 || got[0].Status != models.AnomalyStatusResolved || got[0].EndedAt == nil || !got[0].EndedAt.Equal(spike.Add(time.Minute)) {
+		t.Fatalf("anomalies after recovery = %+v, want one resolved at the last anomalous sample", got)
+	}
+}
@@ -0,0 +1,115 @@
+package anomaly
+
+import (
+	"math"
+	"time"
+
+	"orchestrator/internal/models"
+)
+
+const hoursPerWeek = 7 * 24
+
+// rollingWindow keeps the last size values for the z-score detector.
+type rollingWindow struct {
+	values []float64
+	next   int
+	full   bool
+}
+
+func newRollingWindow(size int) *rollingWindow {
+	return &rollingWindow{values: make([]float64, size)}
+}
+
+func (w *rollingWindow) add(value float64) {
+	w.values[w.next] = value
+	w.next = (w.next + 1) % len(w.values)
+	if w.next == 0 {
+		w.full = true
+	}
+}
+
+func (w *rollingWindow) len() int {
+	if w.full {
+		return len(w.values)
+	}
+	return w.next
+}
+
+func (w *rollingWindow) meanStd() (float64, float64) {
+	n := w.len()
+	var sum float64
+	for _, value := range w.values[:n] {
+		sum += value
+	}
+	mean := sum / float64(n)
+
+	var squares float64
+	for _, value := range w.values[:n] {
+		squares += (value - mean) * (value - mean)
+	}
+	return mean, math.Sqrt(squares / float64(n))
+}
+
+// ewma tracks an exponentially weighted mean and variance, from which the
+// control limits mean ± threshold·σ are derived.
+type ewma struct {
+	alpha    float64
+	mean     float64
+	variance float64
+	count    int
+}
+
+func (e *ewma) add(value float64) {
+	if e.count == 0 {
+		e.mean = value
+	} else {
+		diff := value - e.mean
+		e.mean += e.alpha * diff
+		e.variance = (1 - e.alpha) * (e.variance + e.alpha*diff*diff)
+	}
+	e.count++
+}
+
+// hourOfWeek maps t to its seasonal bucket, 0 being Sunday 00:00 UTC.
+func hourOfWeek(t time.Time) int {
+	t = t.UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+func weekIndex(t time.Time) int64 {
+	return t.Unix() / int64(hoursPerWeek*3600)
+}
+
+func newSeasonalBaseline(series string) *models.SeasonalBaseline {
+	return &models.SeasonalBaseline{
+		Series:  series,
+		Buckets: make([]models.SeasonalBucket, hoursPerWeek),
+	}
+}
+
+// addSeasonal folds value into the bucket for t using Welford's algorithm.
+func addSeasonal(baseline *models.SeasonalBaseline, t time.Time, value float64) {
+	bucket := &baseline.Buckets[hourOfWeek(t)]
+	if week := weekIndex(t); bucket.Count == 0 || week != bucket.LastWeek {
+		bucket.Weeks++
+		bucket.LastWeek = week
+	}
+	bucket.Count++
+	delta := value - bucket.Mean
+	bucket.Mean += delta / float64(bucket.Count)
+	bucket.M2 += delta * (value - bucket.Mean)
+	baseline.UpdatedAt = t
+}
+
+func seasonalStd(bucket models.SeasonalBucket) float64 {
+	if bucket.Count < 2 {
+		return 0
+	}
+	return math.Sqrt(bucket.M2 / float64(bucket.Count))
+}
+
+// stdFloor keeps a perfectly flat history from turning every tiny change
+// into an infinite z-score.
+func stdFloor(std, mean float64) float64 {
+	return math.Max(std, math.Max(0.01*math.Abs(mean), 1e-9))
+}
@@ -0,0 +1,114 @@
+package anomaly
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRollingWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		values   []float64
+		wantLen  int
+		wantMean float64
+		wantStd  float64
+	}{
+		{"partly filled", 4, []float64{2, 4}, 2, 3, 1},
+		{"exactly full", 4, []float64{2, 4, 4, 6}, 4, 4, math.Sqrt(2)},
+		{"oldest values dropped", 3, []float64{100, 100, 1, 2, 3}, 3, 2, math.Sqrt(2.0 / 3)},
+		{"constant", 3, []float64{5, 5, 5, 5}, 3, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newRollingWindow(tt.size)
+			for _, value := range tt.values {
+				w.add(value)
+			}
+			if got := w.len(); got != tt.wantLen {
+				t.Errorf("len() = %d, want %d", got, tt.wantLen)
+			}
+			mean, std := w.meanStd()
+			if math.Abs(mean-tt.wantMean) > 1e-9 || math.Abs(std-tt.wantStd) > 1e-9 {
+				t.Errorf("meanStd() = %v, %v, want %v, %v", mean, std, tt.wantMean, tt.wantStd)
+			}
+		})
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	tests := []struct {
+		name         string
+		values       []float64
+		wantMean     float64
+		wantVariance float64
+	}{
+		{"first value sets the mean", []float64{10}, 10, 0},
+		{"step", []float64{10, 20}, 15, 25},
+		{"constant", []float64{4, 4, 4, 4}, 4, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ewma{alpha: 0.5}
+			for _, value := range tt.values {
+				e.add(value)
+			}
+			if e.count != len(tt.values) {
+				t.Errorf("count = %d, want %d", e.count, len(tt.values))
+			}
+			if math.Abs(e.mean-tt.wantMean) > 1e-9 || math.Abs(e.variance-tt.wantVariance) > 1e-9 {
+				t.Errorf("mean, variance = %v, %v, want %v, %v", e.mean, e.variance, tt.wantMean, tt.wantVariance)
+			}
+		})
+	}
+}
+
+func TestHourOfWeek(t *testing.T) {
+	tests := []struct {
+		name string
+		t    time.Time
+		want int
+	}{
+		{"sunday midnight", time.Date(2024, 1, 7, 0, 0, 0, 0, time.UTC), 0},
+		{"monday 10:30", time.Date(2024, 1, 8, 10, 30, 0, 0, time.UTC), 34},
+		{"saturday last hour", time.Date(2024, 1, 13, 23, 59, 0, 0, time.UTC), hoursPerWeek - 1},
+		{"converted to UTC", time.Date(2024, 1, 8, 1, 0, 0, 0, time.FixedZone("UTC+2", 2*3600)), 23},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hourOfWeek(tt.t); got != tt.want {
+				t.Errorf("hourOfWeek() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAddSeasonal(t *testing.T) {
+	start := time.Date(2024, 1, 8, 10, 0, 0, 0, time.UTC)
+	week := time.Duration(hoursPerWeek) * time.Hour
+	baseline := newSeasonalBaseline("cpu_usage")
+
+	// Two samples in the same hour one week, one the next, one an hour later
+	addSeasonal(baseline, start, 10)
+	addSeasonal(baseline, start.Add(30*time.Minute), 20)
+	addSeasonal(baseline, start.Add(week), 30)
+	addSeasonal(baseline, start.Add(week+time.Hour), 99)
+
+	bucket := baseline.Buckets[hourOfWeek(start)]
+	if bucket.Count != 3 || bucket.Weeks != 2 {
+		t.Errorf("bucket count, weeks = %d, %d, want 3, 2", bucket.Count, bucket.Weeks)
+	}
+	if bucket.Mean != 20 {
+		t.Errorf("bucket mean = %v, want 20", bucket.Mean)
+	}
+	if std := seasonalStd(bucket); math.Abs(std-math.Sqrt(200.0/3)) > 1e-9 {
+		t.Errorf("seasonalStd() = %v, want %v", std, math.Sqrt(200.0/3))
+	}
+	if next := baseline.Buckets[hourOfWeek(start)+1]; next.Count != 1 || next.Mean != 99 {
+		t.Errorf("next bucket = %+v, want one sample of 99", next)
+	}
+	if !baseline.UpdatedAt.Equal(start.Add(week + time.Hour)) {
+		t.Errorf("UpdatedAt = %v, want the last sample time", baseline.UpdatedAt)
+	}
+}
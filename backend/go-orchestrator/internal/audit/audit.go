@@ -0,0 +1,137 @@
+// Package audit records every change made through the API in the action log:
+// who made it, in which request, the state before and after, how long it took
+// and how it ended. Requests refused for lack of permission are recorded too.
+package audit
+
+import (
+	"context"
+	"log"
+	"reflect"
+	"time"
+
+	"orchestrator/internal/auth"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+// Outcomes recorded as an entry's status.
+const (
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	StatusDenied    = "denied"
+)
+
+// Event is a change in progress. Begin one before making the change and
+// Finish it once the outcome is known.
+type Event struct {
+	ctx     context.Context
+	store   storage.Store
+	entry   models.LogEntry
+	started time.Time
+}
+
+// Begin starts an event of kind typ, described by action, changing target.
+// The caller and request ID are taken from ctx.
+func Begin(ctx context.Context, store storage.Store, typ, action, target string) *Event {
+	return &Event{
+		ctx:   ctx,
+		store: store,
+		entry: models.LogEntry{
+			Type:    typ,
+			Action:  action,
+			Target:  target,
+			Details: map[string]interface{}{},
+		},
+		started: time.Now(),
+	}
+}
+
+// Describe replaces the action and target, for changes whose extent is only
+// known once they have been made.
+func (e *Event) Describe(action, target string) *Event {
+	e.entry.Action = action
+	e.entry.Target = target
+	return e
+}
+
+// Before sets the state of the target before the change.
+func (e *Event) Before(state interface{}) *Event {
+	e.entry.Before = state
+	return e
+}
+
+// After sets the state of the target after the change.
+func (e *Event) After(state interface{}) *Event {
+	e.entry.After = state
+	return e
+}
+
+// Detail adds context specific to the kind of change, such as the
+// recommendation applied.
+func (e *Event) Detail(key string, value interface{}) *Event {
+	e.entry.Details[key] = value
+	return e
+}
+
+// Finish records the event as completed, or as failed with err. Failing to
+// record is logged rather than returned: the change itself has happened.
+func (e *Event) Finish(err error) {
+	e.entry.Status = StatusCompleted
+	if err != nil {
+		e.entry.Status = StatusFailed
+		e.entry.Error = err.Error()
+	}
+	e.entry.Duration = time.Since(e.started).Milliseconds()
+	e.entry.Timestamp = e.started
+	record(e.ctx, e.store, e.entry)
+}
+
+// RecordDenial returns an rbac.Policy hook that records refused requests.
+func RecordDenial(store storage.Store) func(context.Context, rbac.Denial) {
+	return func(ctx context.Context, denial rbac.Denial) {
+		record(ctx, store, models.LogEntry{
+			Type:   "access_denied",
+			Action: denial.Method + " " + denial.Path,
+			Target: denial.Namespace,
+			Status: StatusDenied,
+			User:   denial.User,
+			Details: map[string]interface{}{
+				"permission": denial.Permission,
+				"namespace":  denial.Namespace,
+			},
+		})
+	}
+}
+
+func record(ctx context.Context, store storage.Store, entry models.LogEntry) {
+	if entry.User == "" {
+		entry.User = "anonymous"
+		if identity := auth.IdentityFromContext(ctx); identity != nil {
+			entry.User = identity.Subject
+		}
+	}
+	entry.RequestID = RequestIDFromContext(ctx)
+	entry.Before = present(entry.Before)
+	entry.After = present(entry.After)
+
+	// The client may have gone away; the record is still wanted
+	if err := store.AppendLog(context.WithoutCancel(ctx), &entry); err != nil {
+		log.Printf("Failed to record %s %q on %s by %s: %v", entry.Type, entry.Action, entry.Target, entry.User, err)
+	}
+}
+
+// present turns a nil pointer or map held in an interface into a plain nil,
+// so that it is omitted from the entry rather than stored as null.
+func present(state interface{}) interface{} {
+	if state == nil {
+		return nil
+	}
+	switch v := reflect.ValueOf(state); v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+	}
+	return state
+}
@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/auth"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+func openTestStore(t *testing.T) storage.Store {
+	t.Helper()
+	store, err := storage.Open(context.Background(), storage.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// onlyEntry returns the single entry in the action log.
+func onlyEntry(t *testing.T, store storage.Store) models.LogEntry {
+	t.Helper()
+	entries, err := store.ListLogs(context.Background(), storage.LogFilter{})
+	if err != nil {
+		t.Fatalf("ListLogs() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("ListLogs() returned %d entries, want 1", len(entries))
+	}
+	return entries[0]
+}
+
+func TestPresent(t *testing.T) {
+	var (
+		nilPointer *int32
+		nilMap     map[string]interface{}
+		nilSlice   []string
+		replicas   = int32(3)
+	)
+	tests := []struct {
+		name  string
+		state interface{}
+		want  interface{}
+	}{
+		{name: "nil", state: nil, want: nil},
+		{name: "nil pointer", state: nilPointer, want: nil},
+		{name: "nil map", state: nilMap, want: nil},
+		{name: "nil slice", state: nilSlice, want: nil},
+		{name: "pointer", state: &replicas, want: &replicas},
+		{name: "map", state: map[string]interface{}{"replicas": 3}, want: map[string]interface{}{"replicas": 3}},
+		{name: "empty map", state: map[string]interface{}{}, want: map[string]interface{}{}},
+		{name: "value", state: "running", want: "running"},
+		{name: "zero value", state: 0, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := present(tt.state); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("present(%#v) = %#v, want %#v", tt.state, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFinish(t *testing.T) {
+	tests := []struct {
+		name       string
+		identity   *auth.Identity
+		err        error
+		wantUser   string
+		wantStatus string
+		wantError  string
+	}{
+		{name: "completed", identity: &auth.Identity{Subject: "alice"}, wantUser: "alice", wantStatus: StatusCompleted},
+		{name: "failed", identity: &auth.Identity{Subject: "alice"}, err: errors.New("conflict"), wantUser: "alice", wantStatus: StatusFailed, wantError: "conflict"},
+		{name: "without an identity", wantUser: "anonymous", wantStatus: StatusCompleted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := openTestStore(t)
+			ctx := WithRequestID(context.Background(), "req-1")
+			if tt.identity != nil {
+				ctx = auth.WithIdentity(ctx, tt.identity)
+			}
+
+			var unknown *int32
+			start := time.Now()
+			event := Begin(ctx, store, "scale", "Scale web", "default/web").
+				Detail("recommendationId", "rec-1").
+				Before(unknown).
+				After(map[string]interface{}{"replicas": 4})
+			time.Sleep(20 * time.Millisecond)
+			event.Finish(tt.err)
+			elapsed := time.Since(start)
+
+			entry := onlyEntry(t, store)
+			if entry.Status != tt.wantStatus || entry.Error != tt.wantError {
+				t.Errorf("status, error = %q, %q, want %q, %q", entry.Status, entry.Error, tt.wantStatus, tt.wantError)
+			}
+			if entry.User != tt.wantUser || entry.RequestID != "req-1" {
+				t.Errorf("user, request = %q, %q, want %q, req-1", entry.User, entry.RequestID, tt.wantUser)
+			}
+			if entry.Duration < 20 || entry.Duration > elapsed.Milliseconds() {
+				t.Errorf("duration = %dms, want between 20ms and %dms", entry.Duration, elapsed.Milliseconds())
+			}
+			if entry.Timestamp.Before(start.Truncate(time.Millisecond)) || entry.Timestamp.After(start.Add(10*time.Millisecond)) {
+				t.Errorf("timestamp = %v, want when the event began (%v)", entry.Timestamp, start)
+			}
+			if entry.Before != nil {
+				t.Errorf("before = %#v, want it omitted", entry.Before)
+			}
+			if !reflect.DeepEqual(entry.After, map[string]interface{}{"replicas": float64(4)}) {
+				t.Errorf("after = %#v, want the replicas", entry.After)
+			}
+			if entry.Details["recommendationId"] != "rec-1" {
+				t.Errorf("details = %v, want the recommendation", entry.Details)
+			}
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	store := openTestStore(t)
+	Begin(context.Background(), store, "apply", "Apply manifest", "").
+		Describe("Apply manifest (2 objects)", "default/web, default/api").
+		Finish(nil)
+
+	entry := onlyEntry(t, store)
+	if entry.Action != "Apply manifest (2 objects)" || entry.Target != "default/web, default/api" {
+		t.Errorf("action, target = %q, %q, want the described ones", entry.Action, entry.Target)
+	}
+}
+
+func TestRecordDenial(t *testing.T) {
+	store := openTestStore(t)
+	policy, err := rbac.New(rbac.Config{Bindings: []rbac.Binding{
+		{Role: rbac.RoleViewer, Subjects: []string{"dev"}, Namespaces: []string{"payments"}},
+	}})
+	if err != nil {
+		t.Fatalf("rbac.New() error = %v", err)
+	}
+	policy.OnDenied(RecordDenial(store))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID(), func(c *gin.Context) {
+		identity := &auth.Identity{Subject: "dev", Method: auth.MethodAPIKey}
+		c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+	})
+	router.POST("/deployments/:namespace/stop", rbac.Require(policy, rbac.PermRead), func(c *gin.Context) {
+		if err := rbac.Authorize(c.Request.Context(), rbac.PermOperate, c.Param("namespace")); err != nil {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/deployments/payments/stop", nil)
+	req.Header.Set(RequestIDHeader, "req-denied")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	entry := onlyEntry(t, store)
+	want := models.LogEntry{
+		Type:      "access_denied",
+		Action:    "POST /deployments/payments/stop",
+		Target:    "payments",
+		Status:    StatusDenied,
+		User:      "dev",
+		RequestID: "req-denied",
+		Details:   map[string]interface{}{"permission": string(rbac.PermOperate), "namespace": "payments"},
+	}
+	entry.ID, entry.Timestamp = "", time.Time{}
+	if !reflect.DeepEqual(entry, want) {
+		t.Errorf("entry = %+v, want %+v", entry, want)
+	}
+}
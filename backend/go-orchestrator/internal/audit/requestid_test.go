@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		name string
+		id   string
+		want bool
+	}{
+		{name: "uuid", id: "3f0c8a52-6d1e-4b8e-9a51-0c2b5d3e7f10", want: true},
+		{name: "printable punctuation", id: "req_1/a:b@c", want: true},
+		{name: "longest allowed", id: strings.Repeat("a", maxRequestIDLength), want: true},
+		{name: "empty", id: ""},
+		{name: "too long", id: strings.Repeat("a", maxRequestIDLength+1)},
+		{name: "space", id: "req 1"},
+		{name: "newline", id: "req\r\nX-Injected: 1"},
+		{name: "control character", id: "req\x00"},
+		{name: "non-ASCII", id: "réq"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validRequestID(tt.id); got != tt.want {
+				t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, RequestIDFromContext(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name     string
+		header   string
+		wantKept bool
+	}{
+		{name: "client ID kept", header: "client-123", wantKept: true},
+		{name: "missing ID generated"},
+		{name: "invalid ID replaced", header: "bad id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id != w.Body.String() {
+				t.Errorf("response header %q differs from the context's %q", id, w.Body.String())
+			}
+			if tt.wantKept && id != tt.header {
+				t.Errorf("ID = %q, want the client's %q", id, tt.header)
+			}
+			if !tt.wantKept && (id == tt.header || !validRequestID(id)) {
+				t.Errorf("ID = %q, want a generated one", id)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+)
+
+func TestAPIKeys(t *testing.T) {
+	keys := newAPIKeys(map[string]string{"s3cret-admin": "admin", "s3cret-ops": "ops"})
+
+	// Only digests are kept
+	for _, key := range keys {
+		if key.digest != sha256.Sum256([]byte("s3cret-"+key.name)) {
+			t.Errorf("key %s is not stored as the digest of its key", key.name)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{name: "first key", token: "s3cret-admin", want: "admin"},
+		{name: "second key", token: "s3cret-ops", want: "ops"},
+		{name: "unknown key", token: "s3cret-viewer", wantErr: true},
+		{name: "prefix of a key", token: "s3cret-adm", wantErr: true},
+		{name: "key with a suffix", token: "s3cret-admin ", wantErr: true},
+		{name: "different case", token: "S3CRET-ADMIN", wantErr: true},
+		{name: "empty", token: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := keys.verify(context.Background(), tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("verify() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verify() error = %v", err)
+			}
+			if identity.Subject != tt.want || identity.Method != MethodAPIKey {
+				t.Errorf("identity = %+v, want %s by api_key", identity, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Package auth authenticates API and WebSocket requests with static API keys,
+// JWTs (HS256, or RS256 against a JWKS) and OIDC token introspection.
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrNoCredentials is returned when a request carries no token.
+	ErrNoCredentials = errors.New("authentication required")
+	// ErrInvalidToken is returned when no configured method accepts a token.
+	ErrInvalidToken = errors.New("invalid or expired token")
+)
+
+// Authentication methods recorded on an Identity.
+const (
+	MethodNone          = "none"
+	MethodAPIKey        = "api_key"
+	MethodJWT           = "jwt"
+	MethodIntrospection = "introspection"
+)
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	// Subject names the caller: the API key's name, or the configured user
+	// claim of a token.
+	Subject string `json:"subject"`
+	// Method is how the caller authenticated.
+	Method string `json:"method"`
+	// Groups lists the groups named by the token's groups claim.
+	Groups []string `json:"groups,omitempty"`
+	// Claims holds the token's claims, or the introspection response. It is
+	// empty for API keys.
+	Claims map[string]interface{} `json:"-"`
+}
+
+// Config selects the authentication methods. Any combination may be enabled;
+// a token is accepted by the first method that verifies it.
+type Config struct {
+	// Disabled lets every request through as "anonymous". Development only.
+	Disabled bool
+	// APIKeys maps a key to the name it authenticates as.
+	APIKeys map[string]string
+
+	// JWTSecret verifies HS256 tokens.
+	JWTSecret string
+	// JWKSFile and JWKSURL provide RSA keys for RS256 tokens. The URL is
+	// fetched on first use and refreshed every JWKSRefresh, or sooner when a
+	// token names an unknown key.
+	JWKSFile    string
+	JWKSURL     string
+	JWKSRefresh time.Duration
+	// Issuer and Audience, when set, must match the token's iss and aud.
+	Issuer   string
+	Audience string
+	// UserClaim is the token claim used as the subject, "sub" by default.
+	UserClaim string
+	// GroupsClaim is the token claim listing the caller's groups, "groups"
+	// by default.
+	GroupsClaim string
+
+	// IntrospectionURL is an OAuth 2.0 token introspection endpoint (RFC
+	// 7662), called with ClientID and ClientSecret. Results are cached for
+	// IntrospectionCache, or until the token expires if sooner.
+	IntrospectionURL   string
+	ClientID           string
+	ClientSecret       string
+	IntrospectionCache time.Duration
+
+	// AllowedOrigins are the browser origins allowed to call the API and
+	// open WebSockets. "*" allows any origin.
+	AllowedOrigins []string
+}
+
+// ConfigFromEnv reads AUTH_DISABLED, AUTH_API_KEYS (comma-separated
+// name:key pairs), AUTH_JWT_SECRET, AUTH_JWKS_FILE, AUTH_JWKS_URL,
+// AUTH_JWKS_REFRESH, AUTH_JWT_ISSUER, AUTH_JWT_AUDIENCE, AUTH_USER_CLAIM,
+// AUTH_GROUPS_CLAIM, AUTH_INTROSPECTION_URL, AUTH_CLIENT_ID,
+// AUTH_CLIENT_SECRET, AUTH_INTROSPECTION_CACHE and ALLOWED_ORIGINS.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Disabled:           os.Getenv("AUTH_DISABLED") == "true",
+		APIKeys:            map[string]string{},
+		JWTSecret:          os.Getenv("AUTH_JWT_SECRET"),
+		JWKSFile:           os.Getenv("AUTH_JWKS_FILE"),
+		JWKSURL:            os.Getenv("AUTH_JWKS_URL"),
+		JWKSRefresh:        time.Hour,
+		Issuer:             os.Getenv("AUTH_JWT_ISSUER"),
+		Audience:           os.Getenv("AUTH_JWT_AUDIENCE"),
+		UserClaim:          os.Getenv("AUTH_USER_CLAIM"),
+		GroupsClaim:        os.Getenv("AUTH_GROUPS_CLAIM"),
+		IntrospectionURL:   os.Getenv("AUTH_INTROSPECTION_URL"),
+		ClientID:           os.Getenv("AUTH_CLIENT_ID"),
+		ClientSecret:       os.Getenv("AUTH_CLIENT_SECRET"),
+		IntrospectionCache: time.Minute,
+		AllowedOrigins:     []string{"http://localhost:8080", "http://localhost:5173"},
+	}
+	for _, pair := range splitList(os.Getenv("AUTH_API_KEYS")) {
+		if name, key, ok := strings.Cut(pair, ":"); ok && name != "" && key != "" {
+			cfg.APIKeys[key] = name
+		}
+	}
+	if cfg.UserClaim == "" {
+		cfg.UserClaim = "sub"
+	}
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if value, err := time.ParseDuration(os.Getenv("AUTH_JWKS_REFRESH")); err == nil && value > 0 {
+		cfg.JWKSRefresh = value
+	}
+	if value, err := time.ParseDuration(os.Getenv("AUTH_INTROSPECTION_CACHE")); err == nil && value >= 0 {
+		cfg.IntrospectionCache = value
+	}
+	if origins := splitList(os.Getenv("ALLOWED_ORIGINS")); len(origins) > 0 {
+		cfg.AllowedOrigins = origins
+	}
+	return cfg
+}
+
+// verifier is one authentication method. It returns ErrInvalidToken for
+// tokens it does not accept.
+type verifier interface {
+	verify(ctx context.Context, token string) (*Identity, error)
+}
+
+// Authenticator checks request tokens against the configured methods.
+type Authenticator struct {
+	disabled  bool
+	verifiers []verifier
+}
+
+// New builds an authenticator from cfg. It fails when no method is
+// configured and auth is not disabled, so that a missing setting never
+// leaves the API open.
+func New(cfg Config) (*Authenticator, error) {
+	a := &Authenticator{disabled: cfg.Disabled}
+	if cfg.Disabled {
+		return a, nil
+	}
+
+	if len(cfg.APIKeys) > 0 {
+		a.verifiers = append(a.verifiers, newAPIKeys(cfg.APIKeys))
+	}
+	if cfg.JWTSecret != "" || cfg.JWKSFile != "" || cfg.JWKSURL != "" {
+		v, err := newJWTVerifier(cfg)
+		if err != nil {
+			return nil, err
+		}
+		a.verifiers = append(a.verifiers, v)
+	}
+	if cfg.IntrospectionURL != "" {
+		a.verifiers = append(a.verifiers, newIntrospector(cfg))
+	}
+
+	if len(a.verifiers) == 0 {
+		return nil, fmt.Errorf("no authentication method configured: set AUTH_API_KEYS, AUTH_JWT_SECRET, AUTH_JWKS_FILE, AUTH_JWKS_URL or AUTH_INTROSPECTION_URL, or AUTH_DISABLED=true for development")
+	}
+	return a, nil
+}
+
+// Disabled reports whether every request is let through unauthenticated.
+func (a *Authenticator) Disabled() bool {
+	return a.disabled
+}
+
+// Authenticate returns the identity a token belongs to. Methods are tried in
+// order: API keys, JWT, then introspection.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	if a.disabled {
+		return &Identity{Subject: "anonymous", Method: MethodNone}, nil
+	}
+	if token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	var lastErr error
+	for _, v := range a.verifiers {
+		identity, err := v.verify(ctx, token)
+		if err == nil {
+			return identity, nil
+		}
+		if !errors.Is(err, ErrInvalidToken) {
+			// Keep errors that explain more than a rejection, such as an
+			// unreachable identity provider
+			lastErr = err
+		}
+	}
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, ErrInvalidToken
+}
+
+// claimList reads a claim holding a list of strings, or a single string.
+func claimList(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var items []string
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				items = append(items, s)
+			}
+		}
+		return items
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
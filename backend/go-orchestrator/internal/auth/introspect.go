@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCachedTokens bounds the introspection cache; expired entries are
+// dropped when it fills.
+const maxCachedTokens = 10000
+
+// introspector validates opaque tokens with an OAuth 2.0 introspection
+// endpoint, as offered by OIDC providers.
+type introspector struct {
+	url          string
+	clientID     string
+	clientSecret string
+	userClaim    string
+	groupsClaim  string
+	cacheTTL     time.Duration
+	client       *http.Client
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]cachedToken
+}
+
+// cachedToken is an introspection result. Inactive tokens are cached too, so
+// a repeated bad token does not reach the provider every time.
+type cachedToken struct {
+	identity *Identity
+	expires  time.Time
+}
+
+func newIntrospector(cfg Config) *introspector {
+	return &introspector{
+		url:          cfg.IntrospectionURL,
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		userClaim:    cfg.UserClaim,
+		groupsClaim:  cfg.GroupsClaim,
+		cacheTTL:     cfg.IntrospectionCache,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		cache:        map[[sha256.Size]byte]cachedToken{},
+	}
+}
+
+func (i *introspector) verify(ctx context.Context, token string) (*Identity, error) {
+	key := sha256.Sum256([]byte(token))
+	i.mu.Lock()
+	cached, ok := i.cache[key]
+	i.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		if cached.identity == nil {
+			return nil, ErrInvalidToken
+		}
+		return cached.identity, nil
+	}
+
+	identity, expires, err := i.introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if until := time.Now().Add(i.cacheTTL); expires.IsZero() || until.Before(expires) {
+		expires = until
+	}
+	i.store(key, cachedToken{identity: identity, expires: expires})
+	if identity == nil {
+		return nil, ErrInvalidToken
+	}
+	return identity, nil
+}
+
+// introspect asks the provider about token. An inactive token yields a nil
+// identity; expires is the token's exp, or zero when the provider omits it.
+func (i *introspector) introspect(ctx context.Context, token string) (*Identity, time.Time, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("token introspection failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("token introspection failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("token introspection failed: %s", resp.Status)
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode introspection response: %v", err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, time.Time{}, nil
+	}
+
+	var expires time.Time
+	if exp, ok := claims["exp"].(float64); ok {
+		expires = time.Unix(int64(exp), 0)
+	}
+	// Providers name the user differently; fall back from the configured
+	// claim to username and then sub
+	subject, _ := claims[i.userClaim].(string)
+	if subject == "" {
+		subject, _ = claims["username"].(string)
+	}
+	if subject == "" {
+		subject, _ = claims["sub"].(string)
+	}
+	if subject == "" {
+		return nil, expires, nil
+	}
+	identity := &Identity{
+		Subject: subject,
+		Method:  MethodIntrospection,
+		Groups:  claimList(claims, i.groupsClaim),
+		Claims:  claims,
+	}
+	return identity, expires, nil
+}
+
+func (i *introspector) store(key [sha256.Size]byte, entry cachedToken) {
+	if i.cacheTTL <= 0 {
+		return
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.cache) >= maxCachedTokens {
+		now := time.Now()
+		for k, cached := range i.cache {
+			if now.After(cached.expires) {
+				delete(i.cache, k)
+			}
+		}
+		if len(i.cache) >= maxCachedTokens {
+			return
+		}
+	}
+	i.cache[key] = entry
+}
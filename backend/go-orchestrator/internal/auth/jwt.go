@@ -0,0 +1,299 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clockSkew is the leeway allowed on exp and nbf.
+	clockSkew = time.Minute
+	// jwksMinRefresh limits refetches triggered by unknown key IDs.
+	jwksMinRefresh = time.Minute
+)
+
+// jwtVerifier validates HS256 tokens against a shared secret and RS256
+// tokens against a JSON Web Key Set.
+type jwtVerifier struct {
+	secret      []byte
+	keys        *keySet
+	issuer      string
+	audience    string
+	userClaim   string
+	groupsClaim string
+}
+
+func newJWTVerifier(cfg Config) (*jwtVerifier, error) {
+	v := &jwtVerifier{
+		secret:      []byte(cfg.JWTSecret),
+		issuer:      cfg.Issuer,
+		audience:    cfg.Audience,
+		userClaim:   cfg.UserClaim,
+		groupsClaim: cfg.GroupsClaim,
+	}
+	switch {
+	case cfg.JWKSFile != "":
+		data, err := os.ReadFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read JWKS file: %v", err)
+		}
+		keys, err := parseJWKS(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse JWKS file %s: %v", cfg.JWKSFile, err)
+		}
+		v.keys = &keySet{keys: keys}
+	case cfg.JWKSURL != "":
+		v.keys = &keySet{
+			url:     cfg.JWKSURL,
+			refresh: cfg.JWKSRefresh,
+			client:  &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+	return v, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (v *jwtVerifier) verify(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	// The algorithm is fixed by which keys are configured, never chosen by
+	// the token alone
+	switch {
+	case header.Alg == "HS256" && len(v.secret) > 0:
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write(signed)
+		if !hmac.Equal(signature, mac.Sum(nil)) {
+			return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case header.Alg == "RS256" && v.keys != nil:
+		keys, err := v.keys.lookup(ctx, header.Kid)
+		if err != nil {
+			return nil, err
+		}
+		digest := sha256.Sum256(signed)
+		verified := false
+		for _, key := range keys {
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil {
+				verified = true
+				break
+			}
+		}
+		if !verified {
+			return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Alg)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	subject, _ := claims[v.userClaim].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: missing %s claim", ErrInvalidToken, v.userClaim)
+	}
+	return &Identity{
+		Subject: subject,
+		Method:  MethodJWT,
+		Groups:  claimList(claims, v.groupsClaim),
+		Claims:  claims,
+	}, nil
+}
+
+// checkClaims requires an unexpired token and, when configured, the expected
+// issuer and audience.
+func (v *jwtVerifier) checkClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("%w: token not yet valid", ErrInvalidToken)
+	}
+	if v.issuer != "" && claims["iss"] != v.issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.audience != "" && !hasAudience(claims["aud"], v.audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	return nil
+}
+
+// hasAudience matches aud, which may be a string or a list of strings.
+func hasAudience(aud interface{}, want string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == want
+	case []interface{}:
+		for _, item := range aud {
+			if item == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// keySet holds RSA public keys by key ID, loaded once from a file or fetched
+// from a URL.
+type keySet struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	fetchErr  error
+}
+
+// lookup returns the key with the given ID, or every key when the token
+// names none. An unknown ID triggers a refetch, at most once a minute, to
+// pick up rotated keys.
+func (s *keySet) lookup(ctx context.Context, kid string) ([]*rsa.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.url != "" {
+		age := time.Since(s.fetchedAt)
+		_, known := s.keys[kid]
+		if s.fetchedAt.IsZero() || age > s.refresh || (kid != "" && !known && age > jwksMinRefresh) {
+			s.fetchErr = s.fetch(ctx)
+		}
+		if s.keys == nil {
+			return nil, s.fetchErr
+		}
+	}
+
+	if kid != "" {
+		if key, ok := s.keys[kid]; ok {
+			return []*rsa.PublicKey{key}, nil
+		}
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidToken, kid)
+	}
+	keys := make([]*rsa.PublicKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// fetch replaces the keys with the URL's current set. A failed fetch keeps
+// the previous keys until the next attempt.
+func (s *keySet) fetch(ctx context.Context) error {
+	// Count failures too, so an unreachable endpoint is not retried on
+	// every request
+	s.fetchedAt = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("failed to read JWKS: %v", err)
+	}
+	keys, err := parseJWKS(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse JWKS: %v", err)
+	}
+	s.keys = keys
+	return nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// parseJWKS extracts the RSA signing keys from a JSON Web Key Set. Keys of
+// other types or uses are skipped.
+func parseJWKS(data []byte) (map[string]*rsa.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") || (key.Alg != "" && key.Alg != "RS256") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid modulus", key.Kid)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("key %q: invalid exponent", key.Kid)
+		}
+		keys[key.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no RS256 keys found")
+	}
+	return keys, nil
+}
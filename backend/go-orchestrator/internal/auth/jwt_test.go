@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const testSecret = "shared-secret"
+
+var (
+	rsaKeysOnce sync.Once
+	rsaKeys     [2]*rsa.PrivateKey
+)
+
+// testRSAKeys returns two RSA keys, generated once for the package's tests.
+func testRSAKeys(t *testing.T) [2]*rsa.PrivateKey {
+	t.Helper()
+	rsaKeysOnce.Do(func() {
+		for i := range rsaKeys {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				panic(err)
+			}
+			rsaKeys[i] = key
+		}
+	})
+	return rsaKeys
+}
+
+func segment(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signHS256 builds a token with the given header, signed with HMAC-SHA256
+// whatever the header says.
+func signHS256(secret []byte, header map[string]string, claims map[string]interface{}) string {
+	signed := segment(header) + "." + segment(claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	signed := segment(header) + "." + segment(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// jwks encodes public keys as a JSON Web Key Set, by key ID.
+func jwks(keys map[string]*rsa.PublicKey) []byte {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA", Kid: kid, Use: "sig", Alg: "RS256",
+			N: base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+	data, _ := json.Marshal(set)
+	return data
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{"sub": "alice", "exp": float64(time.Now().Add(time.Hour).Unix())}
+}
+
+func TestJWTAlgorithmPinning(t *testing.T) {
+	keys := testRSAKeys(t)
+	publicDER := x509.MarshalPKCS1PublicKey(&keys[0].PublicKey)
+	claims := validClaims()
+	hs256 := map[string]string{"alg": "HS256", "typ": "JWT"}
+
+	secretOnly := &jwtVerifier{secret: []byte(testSecret), userClaim: "sub"}
+	keysOnly := &jwtVerifier{keys: &keySet{keys: map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey}}, userClaim: "sub"}
+	both := &jwtVerifier{secret: []byte(testSecret), keys: keysOnly.keys, userClaim: "sub"}
+
+	unsigned := segment(map[string]string{"alg": "none"}) + "." + segment(claims) + "."
+	tests := []struct {
+		name     string
+		verifier *jwtVerifier
+		token    string
+		wantErr  bool
+	}{
+		{name: "HS256 with a secret", verifier: secretOnly, token: signHS256([]byte(testSecret), hs256, claims)},
+		{name: "RS256 with keys", verifier: keysOnly, token: signRS256(keys[0], "k1", claims)},
+		{name: "RS256 without a kid tries every key", verifier: keysOnly, token: signRS256(keys[0], "", claims)},
+		{name: "either algorithm when both are configured", verifier: both, token: signRS256(keys[0], "k1", claims)},
+		{name: "HS256 without a secret", verifier: keysOnly, token: signHS256([]byte(testSecret), hs256, claims), wantErr: true},
+		{name: "HS256 signed with the public key", verifier: keysOnly, token: signHS256(publicDER, hs256, claims), wantErr: true},
+		{name: "RS256 without keys", verifier: secretOnly, token: signRS256(keys[0], "k1", claims), wantErr: true},
+		{name: "alg none", verifier: both, token: unsigned, wantErr: true},
+		{name: "HS512", verifier: secretOnly, token: signHS256([]byte(testSecret), map[string]string{"alg": "HS512"}, claims), wantErr: true},
+		{name: "lowercase alg", verifier: secretOnly, token: signHS256([]byte(testSecret), map[string]string{"alg": "hs256"}, claims), wantErr: true},
+		{name: "wrong secret", verifier: secretOnly, token: signHS256([]byte("other"), hs256, claims), wantErr: true},
+		{name: "wrong RSA key", verifier: keysOnly, token: signRS256(keys[1], "k1", claims), wantErr: true},
+		{name: "unknown kid", verifier: keysOnly, token: signRS256(keys[0], "k2", claims), wantErr: true},
+		{name: "not a JWT", verifier: both, token: "abc.def", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := tt.verifier.verify(context.Background(), tt.token)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("verify() = %+v, %v, want ErrInvalidToken", identity, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("verify() error = %v", err)
+			}
+			if identity.Subject != "alice" || identity.Method != MethodJWT {
+				t.Errorf("identity = %+v, want alice by jwt", identity)
+			}
+		})
+	}
+}
+
+func TestJWTTamperedPayload(t *testing.T) {
+	v := &jwtVerifier{secret: []byte(testSecret), userClaim: "sub"}
+	token := signHS256([]byte(testSecret), map[string]string{"alg": "HS256"}, validClaims())
+	forged := validClaims()
+	forged["sub"] = "admin"
+	parts := strings.Split(token, ".")
+	if _, err := v.verify(context.Background(), parts[0]+"."+segment(forged)+"."+parts[2]); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("verify() error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		issuer    string
+		audience  string
+		userClaim string
+		claims    map[string]interface{}
+		wantUser  string
+		wantGroup []string
+		wantErr   bool
+	}{
+		{name: "valid", claims: map[string]interface{}{"sub": "alice", "exp": float64(now.Add(time.Hour).Unix())}, wantUser: "alice"},
+		{name: "missing exp", claims: map[string]interface{}{"sub": "alice"}, wantErr: true},
+		{name: "expired", claims: map[string]interface{}{"sub": "alice", "exp": float64(now.Add(-2 * time.Minute).Unix())}, wantErr: true},
+		{name: "expired within skew", claims: map[string]interface{}{"sub": "alice", "exp": float64(now.Add(-30 * time.Second).Unix())}, wantUser: "alice"},
+		{name: "not yet valid", claims: map[string]interface{}{"sub": "alice", "exp": float64(now.Add(time.Hour).Unix()), "nbf": float64(now.Add(5 * time.Minute).Unix())}, wantErr: true},
+		{name: "issuer matches", issuer: "https://idp", claims: map[string]interface{}{"sub": "alice", "iss": "https://idp", "exp": float64(now.Add(time.Hour).Unix())}, wantUser: "alice"},
+		{name: "wrong issuer", issuer: "https://idp", claims: map[string]interface{}{"sub": "alice", "iss": "https://evil", "exp": float64(now.Add(time.Hour).Unix())}, wantErr: true},
+		{name: "audience in list", audience: "orchestrator", claims: map[string]interface{}{"sub": "alice", "aud": []interface{}{"other", "orchestrator"}, "exp": float64(now.Add(time.Hour).Unix())}, wantUser: "alice"},
+		{name: "wrong audience", audience: "orchestrator", claims: map[string]interface{}{"sub": "alice", "aud": "other", "exp": float64(now.Add(time.Hour).Unix())}, wantErr: true},
+		{name: "missing subject", claims: map[string]interface{}{"exp": float64(now.Add(time.Hour).Unix())}, wantErr: true},
+		{name: "custom user claim and groups", userClaim: "email", claims: map[string]interface{}{"sub": "123", "email": "alice@example.com", "groups": []interface{}{"ops", "dev"}, "exp": float64(now.Add(time.Hour).Unix())}, wantUser: "alice@example.com", wantGroup: []string{"ops", "dev"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userClaim := tt.userClaim
+			if userClaim == "" {
+				userClaim = "sub"
+			}
+			v := &jwtVerifier{secret: []byte(testSecret), issuer: tt.issuer, audience: tt.audience, userClaim: userClaim, groupsClaim: "groups"}
+			identity, err := v.verify(context.Background(), signHS256([]byte(testSecret), map[string]string{"alg": "HS256"}, tt.claims))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if identity.Subject != tt.wantUser {
+				t.Errorf("subject = %q, want %q", identity.Subject, tt.wantUser)
+			}
+			if !reflect.DeepEqual(identity.Groups, tt.wantGroup) {
+				t.Errorf("groups = %v, want %v", identity.Groups, tt.wantGroup)
+			}
+		})
+	}
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	keys := testRSAKeys(t)
+	var (
+		fetches atomic.Int32
+		mu      sync.Mutex
+		body    []byte // nil serves a 500
+	)
+	serve := func(data []byte) {
+		mu.Lock()
+		body = data
+		mu.Unlock()
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		mu.Lock()
+		defer mu.Unlock()
+		if body == nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+
+	first := jwks(map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey})
+	rotated := jwks(map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey, "k2": &keys[1].PublicKey})
+	set := &keySet{url: server.URL, refresh: time.Hour, client: server.Client()}
+	v := &jwtVerifier{keys: set, userClaim: "sub"}
+
+	// Each step runs against the state the previous ones left
+	steps := []struct {
+		name string
+		// serve replaces what the endpoint returns when set; fail makes it
+		// return an error.
+		serve []byte
+		fail  bool
+		// age moves the last fetch this far into the past.
+		age         time.Duration
+		key         int
+		kid         string
+		wantFetches int32
+		wantErr     bool
+		// wantUnavailable expects the fetch error rather than a rejection.
+		wantUnavailable bool
+	}{
+		{name: "endpoint down before the first fetch", fail: true, kid: "k1", wantFetches: 1, wantErr: true, wantUnavailable: true},
+		{name: "failed fetch is not retried at once", kid: "k1", serve: first, wantFetches: 1, wantErr: true, wantUnavailable: true},
+		{name: "first fetch", age: 2 * time.Hour, kid: "k1", wantFetches: 2},
+		{name: "known key is cached", kid: "k1", wantFetches: 2},
+		{name: "unknown key within a minute is not refetched", serve: rotated, key: 1, kid: "k2", wantFetches: 2, wantErr: true},
+		{name: "unknown key after a minute refetches", age: 2 * time.Minute, key: 1, kid: "k2", wantFetches: 3},
+		{name: "periodic refresh keeps keys when it fails", fail: true, age: 2 * time.Hour, key: 1, kid: "k2", wantFetches: 4},
+		{name: "token without kid uses every key", key: 1, wantFetches: 4},
+	}
+	for _, step := range steps {
+		if step.fail {
+			serve(nil)
+		} else if step.serve != nil {
+			serve(step.serve)
+		}
+		if step.age > 0 {
+			set.mu.Lock()
+			set.fetchedAt = time.Now().Add(-step.age)
+			set.mu.Unlock()
+		}
+
+		_, err := v.verify(context.Background(), signRS256(keys[step.key], step.kid, validClaims()))
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: verify() error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+		if step.wantErr && errors.Is(err, ErrInvalidToken) == step.wantUnavailable {
+			t.Errorf("%s: verify() error = %v, want unavailable: %v", step.name, err, step.wantUnavailable)
+		}
+		if got := fetches.Load(); got != step.wantFetches {
+			t.Fatalf("%s: %d fetches, want %d", step.name, got, step.wantFetches)
+		}
+	}
+}
+
+func TestParseJWKS(t *testing.T) {
+	keys := testRSAKeys(t)
+	valid := jwks(map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey})
+	tests := []struct {
+		name     string
+		data     string
+		wantKeys int
+		wantErr  bool
+	}{
+		{name: "RSA signing key", data: string(valid), wantKeys: 1},
+		{name: "other key types and uses skipped", data: `{"keys":[{"kty":"EC","kid":"e"},{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"}]}`, wantErr: true},
+		{name: "invalid exponent", data: `{"keys":[{"kty":"RSA","kid":"k","n":"AQAB","e":""}]}`, wantErr: true},
+		{name: "not JSON", data: `keys`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseJWKS([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJWKS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.wantKeys {
+				t.Errorf("got %d keys, want %d", len(got), tt.wantKeys)
+			}
+		})
+	}
+}
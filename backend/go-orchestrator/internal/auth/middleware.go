@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying identity.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFromContext returns the identity the middleware authenticated, or
+// nil outside an authenticated request.
+func IdentityFromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(identityKey{}).(*Identity)
+	return identity
+}
+
+// Middleware rejects requests without a valid token and stores the caller's
+// identity in the request context. Tokens are read from an
+// "Authorization: Bearer" or X-API-Key header. Browsers cannot set headers
+// on a WebSocket handshake, so upgrade requests may pass the token in the
+// access_token query parameter instead.
+func Middleware(a *Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity, err := a.Authenticate(c.Request.Context(), requestToken(c))
+		if err != nil {
+			if errors.Is(err, ErrNoCredentials) || errors.Is(err, ErrInvalidToken) {
+				c.Header("WWW-Authenticate", `Bearer realm="orchestrator"`)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			log.Printf("Authentication failed: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Authentication provider unavailable"})
+			return
+		}
+		c.Request = c.Request.WithContext(WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+func requestToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+		return ""
+	}
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		return key
+	}
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		return c.Query("access_token")
+	}
+	return ""
+}
@@ -0,0 +1,320 @@
+// Package chatcontext summarises the live cluster for ChatOps prompts.
+package chatcontext
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"orchestrator/internal/k8s"
+	"orchestrator/internal/metrics"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+const (
+	defaultTokenBudget = 1500
+	// charsPerToken is a conservative estimate for English and YAML-ish text.
+	charsPerToken = 4
+	// anomalyLookback is how far back resolved anomalies are still mentioned.
+	anomalyLookback = time.Hour
+)
+
+// Config tunes the context document.
+type Config struct {
+	// Disabled turns context injection off.
+	Disabled bool
+	// TokenBudget caps the document's estimated size in LLM tokens.
+	TokenBudget int
+}
+
+// ConfigFromEnv reads CHAT_CONTEXT_ENABLED and CHAT_CONTEXT_TOKENS.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Disabled:    os.Getenv("CHAT_CONTEXT_ENABLED") == "false",
+		TokenBudget: defaultTokenBudget,
+	}
+	if value, err := strconv.Atoi(os.Getenv("CHAT_CONTEXT_TOKENS")); err == nil && value > 0 {
+		cfg.TokenBudget = value
+	}
+	return cfg
+}
+
+// Builder assembles the context document from the collector's current
+// metrics, the workload inventory, open recommendations and recent anomalies.
+type Builder struct {
+	cfg       Config
+	k8sClient *k8s.Client
+	collector *metrics.Collector
+	store     storage.Store
+}
+
+// New creates a builder. k8sClient may be nil, in which case the inventory is
+// left out.
+func New(k8sClient *k8s.Client, collector *metrics.Collector, store storage.Store, cfg Config) *Builder {
+	if cfg.TokenBudget <= 0 {
+		cfg.TokenBudget = defaultTokenBudget
+	}
+	return &Builder{cfg: cfg, k8sClient: k8sClient, collector: collector, store: store}
+}
+
+// Build returns the context document for a caller who may read scope, or ""
+// when injection is disabled. Every section is limited to the namespaces in
+// scope, and cluster-wide metrics to a cluster-wide scope. Sections are
+// filled in priority order until the token budget runs out, and every line is
+// passed through Redact. Env values and secrets are never read.
+func (b *Builder) Build(ctx context.Context, scope rbac.Scope) string {
+	if b == nil || b.cfg.Disabled {
+		return ""
+	}
+
+	doc := newDocument(b.cfg.TokenBudget * charsPerToken)
+	doc.section("Cluster metrics", b.metricLines(scope))
+	if b.k8sClient != nil {
+		deployments, err := b.k8sClient.GetDeployments(metav1.NamespaceAll)
+		if err != nil {
+			log.Printf("Chat context: failed to list deployments: %v", err)
+		}
+		pods, err := b.k8sClient.GetPods(metav1.NamespaceAll)
+		if err != nil {
+			log.Printf("Chat context: failed to list pods: %v", err)
+		}
+		doc.section("Deployments", deploymentLines(readableDeployments(deployments, scope)))
+		doc.section("Pods", podLines(readablePods(pods, scope)))
+	}
+	doc.section("Active and recent anomalies", b.anomalyLines(ctx, scope))
+	doc.section("Open recommendations", b.recommendationLines(ctx, scope))
+	return doc.String()
+}
+
+// metricLines lists the cluster-wide metrics for a cluster-wide scope, and
+// otherwise each namespace's metrics prefixed with its name.
+func (b *Builder) metricLines(scope rbac.Scope) []string {
+	if scope.All {
+		return valueLines("", b.collector.GetCurrentMetrics())
+	}
+	var lines []string
+	for _, namespace := range scope.Namespaces() {
+		lines = append(lines, valueLines(namespace+" ", b.collector.GetNamespaceCounters(namespace))...)
+	}
+	return lines
+}
+
+func valueLines(prefix string, values map[string]float64) []string {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := make([]string, 0, len(names))
+	for _, name := range names {
+		lines = append(lines, fmt.Sprintf("%s%s: %s", prefix, name, strconv.FormatFloat(values[name], 'f', -1, 64)))
+	}
+	return lines
+}
+
+func readableDeployments(deployments []appsv1.Deployment, scope rbac.Scope) []appsv1.Deployment {
+	var readable []appsv1.Deployment
+	for _, d := range deployments {
+		if scope.Allows(d.Namespace) {
+			readable = append(readable, d)
+		}
+	}
+	return readable
+}
+
+func readablePods(pods []corev1.Pod, scope rbac.Scope) []corev1.Pod {
+	var readable []corev1.Pod
+	for _, pod := range pods {
+		if scope.Allows(pod.Namespace) {
+			readable = append(readable, pod)
+		}
+	}
+	return readable
+}
+
+func deploymentLines(deployments []appsv1.Deployment) []string {
+	sort.Slice(deployments, func(i, j int) bool {
+		return deployments[i].Namespace+"/"+deployments[i].Name < deployments[j].Namespace+"/"+deployments[j].Name
+	})
+
+	lines := make([]string, 0, len(deployments))
+	for _, d := range deployments {
+		desired := int32(1)
+		if d.Spec.Replicas != nil {
+			desired = *d.Spec.Replicas
+		}
+		line := fmt.Sprintf("%s/%s: %d/%d ready", d.Namespace, d.Name, d.Status.ReadyReplicas, desired)
+		for _, container := range d.Spec.Template.Spec.Containers {
+			line += "; " + containerSummary(container)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// containerSummary describes a container by image, resources and the names
+// of its env vars. Env values are never included: they often hold secrets.
+func containerSummary(container corev1.Container) string {
+	parts := []string{container.Name + " " + container.Image}
+	if cpu, ok := container.Resources.Requests[corev1.ResourceCPU]; ok {
+		parts = append(parts, "cpu request "+cpu.String())
+	}
+	if memory, ok := container.Resources.Requests[corev1.ResourceMemory]; ok {
+		parts = append(parts, "memory request "+memory.String())
+	}
+	if cpu, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
+		parts = append(parts, "cpu limit "+cpu.String())
+	}
+	if memory, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+		parts = append(parts, "memory limit "+memory.String())
+	}
+	if len(container.Env) > 0 {
+		names := make([]string, 0, len(container.Env))
+		for _, env := range container.Env {
+			names = append(names, env.Name)
+		}
+		parts = append(parts, "env "+strings.Join(names, ",")+" (values redacted)")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// podLines counts pods by namespace and phase, then lists the unhealthy ones.
+func podLines(pods []corev1.Pod) []string {
+	counts := make(map[string]map[corev1.PodPhase]int)
+	var unhealthy []string
+	for _, pod := range pods {
+		if counts[pod.Namespace] == nil {
+			counts[pod.Namespace] = make(map[corev1.PodPhase]int)
+		}
+		counts[pod.Namespace][pod.Status.Phase]++
+
+		var restarts int32
+		var waiting string
+		for _, status := range pod.Status.ContainerStatuses {
+			restarts += status.RestartCount
+			if status.State.Waiting != nil && waiting == "" {
+				waiting = status.State.Waiting.Reason
+			}
+		}
+		if pod.Status.Phase != corev1.PodRunning && pod.Status.Phase != corev1.PodSucceeded || waiting != "" || restarts > 0 {
+			line := fmt.Sprintf("%s/%s: %s", pod.Namespace, pod.Name, pod.Status.Phase)
+			if waiting != "" {
+				line += " (" + waiting + ")"
+			}
+			if restarts > 0 {
+				line += fmt.Sprintf(", %d restarts", restarts)
+			}
+			unhealthy = append(unhealthy, line)
+		}
+	}
+
+	namespaces := make([]string, 0, len(counts))
+	for namespace := range counts {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+
+	lines := make([]string, 0, len(namespaces)+len(unhealthy))
+	for _, namespace := range namespaces {
+		phases := make([]string, 0, len(counts[namespace]))
+		for phase, count := range counts[namespace] {
+			phases = append(phases, fmt.Sprintf("%d %s", count, strings.ToLower(string(phase))))
+		}
+		sort.Strings(phases)
+		lines = append(lines, fmt.Sprintf("%s: %s", namespace, strings.Join(phases, ", ")))
+	}
+	sort.Strings(unhealthy)
+	return append(lines, unhealthy...)
+}
+
+func (b *Builder) anomalyLines(ctx context.Context, scope rbac.Scope) []string {
+	anomalies, err := b.store.ListAnomalies(ctx, storage.AnomalyFilter{
+		From:  time.Now().Add(-anomalyLookback),
+		Limit: 50,
+	})
+	if err != nil {
+		log.Printf("Chat context: failed to list anomalies: %v", err)
+		return nil
+	}
+
+	lines := make([]string, 0, len(anomalies))
+	for _, anomaly := range anomalies {
+		if !scope.Allows(anomaly.Labels["namespace"]) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("[%s, %s] %s: %s", anomaly.Severity, anomaly.Status, anomaly.Service, anomaly.Message))
+	}
+	return lines
+}
+
+func (b *Builder) recommendationLines(ctx context.Context, scope rbac.Scope) []string {
+	recommendations, err := b.store.ListRecommendations(ctx)
+	if err != nil {
+		log.Printf("Chat context: failed to list recommendations: %v", err)
+		return nil
+	}
+
+	var lines []string
+	for _, rec := range recommendations {
+		if rec.Status != models.RecommendationPending {
+			continue
+		}
+		namespace := ""
+		if rec.Payload != nil {
+			namespace = rec.Payload.Namespace
+		}
+		if !scope.Allows(namespace) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s (confidence %.0f%%)", rec.Type, rec.Target, rec.Action, rec.Confidence*100))
+	}
+	return lines
+}
+
+// document accumulates sections within a character budget.
+type document struct {
+	b      strings.Builder
+	budget int
+}
+
+func newDocument(budget int) *document {
+	return &document{budget: budget}
+}
+
+// section appends a heading and as many lines as fit, noting how many were
+// left out. Empty sections are skipped.
+func (d *document) section(title string, lines []string) {
+	if len(lines) == 0 {
+		return
+	}
+	heading := "## " + title + "\n"
+	if d.b.Len()+len(heading) > d.budget {
+		return
+	}
+	d.b.WriteString(heading)
+
+	for i, line := range lines {
+		line = "- " + Redact(line) + "\n"
+		if d.b.Len()+len(line) > d.budget {
+			d.b.WriteString(fmt.Sprintf("- … %d more omitted\n", len(lines)-i))
+			return
+		}
+		d.b.WriteString(line)
+	}
+}
+
+func (d *document) String() string {
+	return strings.TrimSpace(d.b.String())
+}
@@ -0,0 +1,138 @@
+package chatcontext
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"orchestrator/internal/auth"
+	"orchestrator/internal/executor"
+	"orchestrator/internal/k8s"
+	"orchestrator/internal/metrics"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+// testBuilder returns a builder over workloads, anomalies and recommendations
+// in the payments and default namespaces, plus cluster-level ones.
+func testBuilder(t *testing.T) *Builder {
+	t.Helper()
+	ctx := context.Background()
+	store, err := storage.Open(ctx, storage.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	now := time.Now()
+	if err := store.AppendMetricSnapshot(ctx, models.MetricSnapshot{
+		Timestamp: now,
+		Metrics:   map[string]float64{"cpu_usage": 42},
+		Namespaces: map[string]map[string]float64{
+			"payments": {"pod_count": 1},
+			"default":  {"pod_count": 3},
+		},
+	}); err != nil {
+		t.Fatalf("save snapshot: %v", err)
+	}
+
+	clientset := fake.NewSimpleClientset()
+	for _, namespace := range []string{"payments", "default"} {
+		meta := metav1.ObjectMeta{Namespace: namespace, Name: namespace + "-app"}
+		if _, err := clientset.AppsV1().Deployments(namespace).Create(ctx, &appsv1.Deployment{ObjectMeta: meta}, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("seed deployment: %v", err)
+		}
+		pod := &corev1.Pod{ObjectMeta: meta, Status: corev1.PodStatus{Phase: corev1.PodPending}}
+		if _, err := clientset.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("seed pod: %v", err)
+		}
+	}
+
+	for _, anomaly := range []models.Anomaly{
+		{ID: "a1", Labels: map[string]string{"namespace": "payments"}, Message: "payments-anomaly"},
+		{ID: "a2", Labels: map[string]string{"namespace": "default"}, Message: "default-anomaly"},
+		{ID: "a3", Message: "cluster-anomaly"},
+	} {
+		anomaly.Status = models.AnomalyStatusActive
+		anomaly.StartedAt = now.Add(-time.Minute)
+		anomaly.Timestamp = now
+		if err := store.SaveAnomaly(ctx, &anomaly); err != nil {
+			t.Fatalf("save anomaly: %v", err)
+		}
+	}
+	for _, rec := range []models.Recommendation{
+		{ID: "r1", Target: "payments-rec", Payload: &executor.Action{Namespace: "payments", Kind: "deployment", Name: "payments-app"}},
+		{ID: "r2", Target: "default-rec", Payload: &executor.Action{Namespace: "default", Kind: "deployment", Name: "default-app"}},
+		{ID: "r3", Target: "cluster-rec", Informational: true},
+	} {
+		rec.Status = models.RecommendationPending
+		if err := store.SaveRecommendation(ctx, &rec); err != nil {
+			t.Fatalf("save recommendation: %v", err)
+		}
+	}
+
+	collector := metrics.NewCollector(store, nil, nil)
+	return New(k8s.NewClientFromClientset(clientset, k8s.NamespaceFilter{}), collector, store, Config{TokenBudget: 10000})
+}
+
+func TestBuildScope(t *testing.T) {
+	policy, err := rbac.New(rbac.Config{Bindings: []rbac.Binding{
+		{Role: rbac.RoleViewer, Subjects: []string{"dev"}, Namespaces: []string{"payments"}},
+	}})
+	if err != nil {
+		t.Fatalf("rbac.New() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		scope       rbac.Scope
+		wantLines   []string
+		unwantLines []string
+	}{
+		{
+			name:  "cluster-wide",
+			scope: rbac.Scope{All: true},
+			wantLines: []string{
+				"cpu_usage: 42", "payments/payments-app", "default/default-app",
+				"payments-anomaly", "default-anomaly", "cluster-anomaly",
+				"payments-rec", "default-rec", "cluster-rec",
+			},
+		},
+		{
+			name:      "one namespace",
+			scope:     policy.Scope(&auth.Identity{Subject: "dev"}, rbac.PermRead),
+			wantLines: []string{"payments pod_count: 1", "payments/payments-app", "payments-anomaly", "payments-rec"},
+			unwantLines: []string{
+				"cpu_usage", "default", "cluster-anomaly", "cluster-rec",
+			},
+		},
+		{
+			name:        "nothing",
+			unwantLines: []string{"cpu_usage", "pod_count", "payments", "default", "cluster"},
+		},
+	}
+	builder := testBuilder(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := builder.Build(context.Background(), tt.scope)
+			for _, line := range tt.wantLines {
+				if !strings.Contains(doc, line) {
+					t.Errorf("document is missing %q:\n%s", line, doc)
+				}
+			}
+			for _, line := range tt.unwantLines {
+				if strings.Contains(doc, line) {
+					t.Errorf("document mentions %q:\n%s", line, doc)
+				}
+			}
+		})
+	}
+}
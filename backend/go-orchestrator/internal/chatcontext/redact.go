@@ -0,0 +1,33 @@
+package chatcontext
+
+import "regexp"
+
+const redacted = "[REDACTED]"
+
+// secretPatterns match credentials that may appear in free text such as
+// recommendation reasoning, anomaly messages or object annotations.
+var secretPatterns = []struct {
+	pattern     *regexp.Regexp
+	replacement string
+}{
+	// key=value and key: value pairs whose key names a credential
+	{regexp.MustCompile(`(?i)\b([\w.-]*(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|private[_-]?key|credentials?)[\w.-]*)(\s*[:=]\s*)("[^"]*"|'[^']*'|\S+)`), "${1}${2}" + redacted},
+	// Authorization headers
+	{regexp.MustCompile(`(?i)\b(bearer|basic)\s+[A-Za-z0-9\-._~+/]{8,}=*`), "${1} " + redacted},
+	// JSON Web Tokens
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]+`), redacted},
+	// AWS access key IDs
+	{regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), redacted},
+	// PEM blocks
+	{regexp.MustCompile(`-----BEGIN [A-Z ]+-----[\s\S]*?-----END [A-Z ]+-----`), redacted},
+	// Credentials embedded in URLs
+	{regexp.MustCompile(`(://)[^/\s:@]+:[^/\s@]+@`), "${1}" + redacted + "@"},
+}
+
+// Redact masks credentials in text.
+func Redact(text string) string {
+	for _, p := range secretPatterns {
+		text = p.pattern.ReplaceAllString(text, p.replacement)
+	}
+	return text
+}
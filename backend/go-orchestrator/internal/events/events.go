@@ -0,0 +1,130 @@
+// Package events turns what the orchestrator observes and does into
+// WebSocket messages.
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"orchestrator/internal/k8s"
+	"orchestrator/internal/models"
+	"orchestrator/internal/websocket"
+)
+
+// Message types, by topic. The README documents each type's data.
+const (
+	// TopicMetrics
+	TypeMetricsUpdate          = "metrics_update"
+	TypeNamespaceMetricsUpdate = "namespace_metrics_update"
+
+	// TopicRecommendations
+	TypeRecommendationCreated = "recommendation_created"
+	TypeRecommendationUpdated = "recommendation_updated"
+
+	// TopicActions
+	TypeActionLogged = "action_logged"
+
+	// TopicAnomalies
+	TypeAnomalyDetected = "anomaly_detected"
+	TypeAnomalyUpdated  = "anomaly_updated"
+	TypeAnomalyResolved = "anomaly_resolved"
+
+	// TopicResources
+	TypePodEvent        = "pod_event"
+	TypeDeploymentEvent = "deployment_event"
+)
+
+// snapshotWindow matches the collector's interval. Replicas collect on their
+// own schedules, so only the first snapshot in each window is published.
+const snapshotWindow = 30 * time.Second
+
+// Publisher accepts messages without blocking, as websocket.Hub does.
+type Publisher interface {
+	Publish(msg websocket.Message)
+}
+
+// PublishSnapshots returns a collector subscriber that publishes each
+// snapshot's cluster metrics, then each namespace's metrics to that
+// namespace.
+func PublishSnapshots(pub Publisher) func(context.Context, models.MetricSnapshot) {
+	return func(_ context.Context, snapshot models.MetricSnapshot) {
+		window := snapshot.Timestamp.Truncate(snapshotWindow).Unix()
+		pub.Publish(websocket.Message{
+			Type:      TypeMetricsUpdate,
+			Topic:     websocket.TopicMetrics,
+			Timestamp: snapshot.Timestamp,
+			Data: map[string]interface{}{
+				"source":  snapshot.Source,
+				"metrics": snapshot.Metrics,
+			},
+			Key: fmt.Sprintf("%s/%d", TypeMetricsUpdate, window),
+		})
+		for namespace, metrics := range snapshot.Namespaces {
+			pub.Publish(websocket.Message{
+				Type:      TypeNamespaceMetricsUpdate,
+				Topic:     websocket.TopicMetrics,
+				Namespace: namespace,
+				Timestamp: snapshot.Timestamp,
+				Data:      map[string]interface{}{"metrics": metrics},
+				Key:       fmt.Sprintf("%s/%s/%d", TypeNamespaceMetricsUpdate, namespace, window),
+			})
+		}
+	}
+}
+
+// PublishResourceEvents returns a k8s.Client resource event handler that
+// publishes a summary of each changed pod or deployment. Every replica's
+// informers see the same change, so events are keyed by object version.
+func PublishResourceEvents(pub Publisher) func(k8s.ResourceEvent) {
+	return func(event k8s.ResourceEvent) {
+		data := map[string]interface{}{
+			"action":    event.Action,
+			"name":      event.Object.GetName(),
+			"namespace": event.Object.GetNamespace(),
+		}
+
+		var kind string
+		switch object := event.Object.(type) {
+		case *corev1.Pod:
+			kind = TypePodEvent
+			var restarts int32
+			ready := 0
+			for _, status := range object.Status.ContainerStatuses {
+				restarts += status.RestartCount
+				if status.Ready {
+					ready++
+				}
+			}
+			data["phase"] = object.Status.Phase
+			data["node"] = object.Spec.NodeName
+			data["readyContainers"] = ready
+			data["containers"] = len(object.Spec.Containers)
+			data["restarts"] = restarts
+		case *appsv1.Deployment:
+			kind = TypeDeploymentEvent
+			replicas := int32(1)
+			if object.Spec.Replicas != nil {
+				replicas = *object.Spec.Replicas
+			}
+			data["replicas"] = replicas
+			data["readyReplicas"] = object.Status.ReadyReplicas
+			data["updatedReplicas"] = object.Status.UpdatedReplicas
+			data["availableReplicas"] = object.Status.AvailableReplicas
+		default:
+			return
+		}
+
+		pub.Publish(websocket.Message{
+			Type:      kind,
+			Topic:     websocket.TopicResources,
+			Namespace: event.Object.GetNamespace(),
+			Data:      data,
+			Key: fmt.Sprintf("%s/%s/%s/%s/%s", kind, event.Object.GetNamespace(), event.Object.GetName(),
+				event.Object.GetResourceVersion(), event.Action),
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"orchestrator/internal/models"
+	"orchestrator/internal/storage"
+	"orchestrator/internal/websocket"
+)
+
+// publishingStore publishes recommendation, action log and anomaly writes
+// once they have been stored, so every producer reaches the WebSocket without
+// knowing about it.
+type publishingStore struct {
+	storage.Store
+	pub Publisher
+
+	mu sync.Mutex
+	// active holds the IDs of anomalies already announced as detected.
+	active map[string]bool
+}
+
+// WrapStore returns a store that publishes changes written through it.
+func WrapStore(store storage.Store, pub Publisher) storage.Store {
+	return &publishingStore{Store: store, pub: pub, active: make(map[string]bool)}
+}
+
+// SaveRecommendation publishes new recommendations and status changes. Other
+// updates, such as the AI engine refreshing a pending recommendation's
+// confidence, are not published.
+func (s *publishingStore) SaveRecommendation(ctx context.Context, rec *models.Recommendation) error {
+	var previous string
+	if rec.ID != "" {
+		if existing, err := s.Store.GetRecommendation(ctx, rec.ID); err == nil {
+			previous = existing.Status
+		}
+	}
+	if err := s.Store.SaveRecommendation(ctx, rec); err != nil {
+		return err
+	}
+
+	msg := websocket.Message{
+		Type:      TypeRecommendationCreated,
+		Topic:     websocket.TopicRecommendations,
+		Namespace: recommendationNamespace(rec),
+		Data:      map[string]interface{}{"recommendation": rec},
+	}
+	switch {
+	case previous == "":
+	case previous != rec.Status:
+		msg.Type = TypeRecommendationUpdated
+		msg.Data["previousStatus"] = previous
+	default:
+		return nil
+	}
+	s.pub.Publish(msg)
+	return nil
+}
+
+func (s *publishingStore) AppendLog(ctx context.Context, entry *models.LogEntry) error {
+	if err := s.Store.AppendLog(ctx, entry); err != nil {
+		return err
+	}
+	s.pub.Publish(websocket.Message{
+		Type:      TypeActionLogged,
+		Topic:     websocket.TopicActions,
+		Timestamp: entry.Timestamp,
+		Data:      map[string]interface{}{"entry": entry},
+	})
+	return nil
+}
+
+func (s *publishingStore) SaveAnomaly(ctx context.Context, anomaly *models.Anomaly) error {
+	if err := s.Store.SaveAnomaly(ctx, anomaly); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	kind := TypeAnomalyUpdated
+	switch {
+	case anomaly.Status == models.AnomalyStatusResolved:
+		kind = TypeAnomalyResolved
+		delete(s.active, anomaly.ID)
+	case !s.active[anomaly.ID]:
+		kind = TypeAnomalyDetected
+		s.active[anomaly.ID] = true
+	}
+	s.mu.Unlock()
+
+	s.pub.Publish(websocket.Message{
+		Type:      kind,
+		Topic:     websocket.TopicAnomalies,
+		Namespace: anomaly.Labels["namespace"],
+		Data:      map[string]interface{}{"anomaly": anomaly},
+	})
+	return nil
+}
+
+func recommendationNamespace(rec *models.Recommendation) string {
+	if rec.Payload != nil {
+		return rec.Payload.Namespace
+	}
+	return ""
+}
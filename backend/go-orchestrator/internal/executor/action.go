@@ -0,0 +1,77 @@
+package executor
+
+import (
+	"fmt"
+	"time"
+)
+
+// KindDeployment is currently the only workload kind the executor can change.
+const KindDeployment = "Deployment"
+
+// Action is the machine-readable payload of a recommendation: which object to
+// change and the desired end state. Unset fields are left untouched.
+type Action struct {
+	Namespace string               `json:"namespace"`
+	Kind      string               `json:"kind"`
+	Name      string               `json:"name"`
+	Replicas  *int32               `json:"replicas,omitempty"`
+	Resources []ContainerResources `json:"resources,omitempty"`
+}
+
+// ContainerResources describes requests and limits for one container, keyed by
+// resource name ("cpu", "memory") with Kubernetes quantity strings as values.
+// Container may be empty for single-container pods.
+type ContainerResources struct {
+	Container string            `json:"container,omitempty"`
+	Requests  map[string]string `json:"requests,omitempty"`
+	Limits    map[string]string `json:"limits,omitempty"`
+}
+
+// State captures the fields of an object the executor may change, along with
+// the version information needed to detect later edits.
+type State struct {
+	Kind            string               `json:"kind"`
+	Namespace       string               `json:"namespace"`
+	Name            string               `json:"name"`
+	Replicas        *int32               `json:"replicas,omitempty"`
+	Resources       []ContainerResources `json:"resources,omitempty"`
+	ResourceVersion string               `json:"resourceVersion"`
+	Generation      int64                `json:"generation"`
+	CapturedAt      time.Time            `json:"capturedAt"`
+}
+
+// Result holds the object state before and after an action was performed.
+type Result struct {
+	Before *State `json:"before,omitempty"`
+	After  *State `json:"after,omitempty"`
+}
+
+// Validate checks that the action names an object and asks for a change.
+func (a Action) Validate() error {
+	if a.Kind != KindDeployment {
+		return fmt.Errorf("unsupported kind %q", a.Kind)
+	}
+	if a.Namespace == "" || a.Name == "" {
+		return fmt.Errorf("namespace and name are required")
+	}
+	if a.Replicas == nil && len(a.Resources) == 0 {
+		return fmt.Errorf("action does not change replicas or resources")
+	}
+	if a.Replicas != nil && *a.Replicas < 0 {
+		return fmt.Errorf("replicas must not be negative")
+	}
+	return nil
+}
+
+// Describe returns a short human-readable summary of the action.
+func (a Action) Describe() string {
+	target := fmt.Sprintf("%s %s/%s", a.Kind, a.Namespace, a.Name)
+	switch {
+	case a.Replicas != nil && len(a.Resources) > 0:
+		return fmt.Sprintf("Scale %s to %d replicas and update resources", target, *a.Replicas)
+	case a.Replicas != nil:
+		return fmt.Sprintf("Scale %s to %d replicas", target, *a.Replicas)
+	default:
+		return fmt.Sprintf("Update resources of %s", target)
+	}
+}
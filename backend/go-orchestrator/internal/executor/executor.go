@@ -0,0 +1,159 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"orchestrator/internal/k8s"
+)
+
+// ErrNoCluster is returned when the orchestrator runs without a Kubernetes
+// connection.
+var ErrNoCluster = errors.New("kubernetes client not available")
+
+// Executor performs recommendation actions against the cluster.
+type Executor struct {
+	k8sClient *k8s.Client
+}
+
+func New(k8sClient *k8s.Client) *Executor {
+	return &Executor{k8sClient: k8sClient}
+}
+
+// Execute applies the action and returns the object state before and after.
+// When the update itself fails, the returned result still carries the
+// "before" state so callers can record what was attempted.
+func (e *Executor) Execute(ctx context.Context, action Action) (*Result, error) {
+	if err := action.Validate(); err != nil {
+		return nil, err
+	}
+	if e.k8sClient == nil {
+		return nil, ErrNoCluster
+	}
+	if !e.k8sClient.NamespaceAllowed(action.Namespace) {
+		return nil, fmt.Errorf("%w: %s", k8s.ErrNamespaceNotAllowed, action.Namespace)
+	}
+
+	deployment, err := e.k8sClient.GetDeployment(ctx, action.Namespace, action.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Before: CaptureDeployment(deployment)}
+
+	if action.Replicas != nil {
+		replicas := *action.Replicas
+		deployment.Spec.Replicas = &replicas
+	}
+	for _, res := range action.Resources {
+		if err := applyResources(&deployment.Spec.Template.Spec, res); err != nil {
+			return result, err
+		}
+	}
+
+	updated, err := e.k8sClient.UpdateDeployment(ctx, deployment)
+	if err != nil {
+		return result, err
+	}
+
+	result.After = CaptureDeployment(updated)
+	return result, nil
+}
+
+// CaptureDeployment records the executor-managed fields of a deployment.
+func CaptureDeployment(deployment *appsv1.Deployment) *State {
+	state := &State{
+		Kind:            KindDeployment,
+		Namespace:       deployment.Namespace,
+		Name:            deployment.Name,
+		ResourceVersion: deployment.ResourceVersion,
+		Generation:      deployment.Generation,
+		CapturedAt:      time.Now(),
+	}
+	if deployment.Spec.Replicas != nil {
+		replicas := *deployment.Spec.Replicas
+		state.Replicas = &replicas
+	}
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		state.Resources = append(state.Resources, ContainerResources{
+			Container: container.Name,
+			Requests:  quantities(container.Resources.Requests),
+			Limits:    quantities(container.Resources.Limits),
+		})
+	}
+	return state
+}
+
+func applyResources(spec *corev1.PodSpec, res ContainerResources) error {
+	container, err := findContainer(spec, res.Container)
+	if err != nil {
+		return err
+	}
+
+	requests, err := parseQuantities(res.Requests)
+	if err != nil {
+		return err
+	}
+	limits, err := parseQuantities(res.Limits)
+	if err != nil {
+		return err
+	}
+
+	if container.Resources.Requests == nil && len(requests) > 0 {
+		container.Resources.Requests = corev1.ResourceList{}
+	}
+	for name, quantity := range requests {
+		container.Resources.Requests[name] = quantity
+	}
+	if container.Resources.Limits == nil && len(limits) > 0 {
+		container.Resources.Limits = corev1.ResourceList{}
+	}
+	for name, quantity := range limits {
+		container.Resources.Limits[name] = quantity
+	}
+	return nil
+}
+
+func findContainer(spec *corev1.PodSpec, name string) (*corev1.Container, error) {
+	if name == "" {
+		if len(spec.Containers) != 1 {
+			return nil, fmt.Errorf("container name is required for pods with %d containers", len(spec.Containers))
+		}
+		return &spec.Containers[0], nil
+	}
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == name {
+			return &spec.Containers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("container %q not found", name)
+}
+
+func parseQuantities(values map[string]string) (corev1.ResourceList, error) {
+	list := corev1.ResourceList{}
+	for name, value := range values {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s quantity %q: %v", name, value, err)
+		}
+		list[corev1.ResourceName(name)] = quantity
+	}
+	return list, nil
+}
+
+func quantities(list corev1.ResourceList) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+	values := make(map[string]string, len(list))
+	for name, quantity := range list {
+		values[string(name)] = quantity.String()
+	}
+	return values
+}
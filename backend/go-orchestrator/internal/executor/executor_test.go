@@ -0,0 +1,212 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"orchestrator/internal/k8s"
+)
+
+func int32Ptr(v int32) *int32 { return &v }
+
+func testDeployment(replicas int32, generation int64) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "web", Generation: generation},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: int32Ptr(replicas),
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name: "app",
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+func newTestExecutor(t *testing.T, filter k8s.NamespaceFilter, objects ...*appsv1.Deployment) (*Executor, *fake.Clientset) {
+	t.Helper()
+	clientset := fake.NewSimpleClientset()
+	for _, obj := range objects {
+		if _, err := clientset.AppsV1().Deployments(obj.Namespace).Create(context.Background(), obj, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("seed deployment: %v", err)
+		}
+	}
+	return New(k8s.NewClientFromClientset(clientset, filter)), clientset
+}
+
+func liveDeployment(t *testing.T, clientset *fake.Clientset) *appsv1.Deployment {
+	t.Helper()
+	deployment, err := clientset.AppsV1().Deployments("default").Get(context.Background(), "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get deployment: %v", err)
+	}
+	return deployment
+}
+
+func TestActionValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		action  Action
+		wantErr bool
+	}{
+		{"scale", Action{Kind: KindDeployment, Namespace: "default", Name: "web", Replicas: int32Ptr(3)}, false},
+		{"resources", Action{Kind: KindDeployment, Namespace: "default", Name: "web", Resources: []ContainerResources{{Requests: map[string]string{"cpu": "200m"}}}}, false},
+		{"scale to zero", Action{Kind: KindDeployment, Namespace: "default", Name: "web", Replicas: int32Ptr(0)}, false},
+		{"unsupported kind", Action{Kind: "StatefulSet", Namespace: "default", Name: "web", Replicas: int32Ptr(3)}, true},
+		{"missing name", Action{Kind: KindDeployment, Namespace: "default", Replicas: int32Ptr(3)}, true},
+		{"no change", Action{Kind: KindDeployment, Namespace: "default", Name: "web"}, true},
+		{"negative replicas", Action{Kind: KindDeployment, Namespace: "default", Name: "web", Replicas: int32Ptr(-1)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.action.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name         string
+		filter       k8s.NamespaceFilter
+		action       Action
+		wantErr      error
+		wantReplicas int32
+		wantCPU      string
+	}{
+		{
+			name:         "scale",
+			action:       Action{Kind: KindDeployment, Namespace: "default", Name: "web", Replicas: int32Ptr(5)},
+			wantReplicas: 5,
+			wantCPU:      "100m",
+		},
+		{
+			name: "resources of the only container",
+			action: Action{Kind: KindDeployment, Namespace: "default", Name: "web",
+				Resources: []ContainerResources{{Requests: map[string]string{"cpu": "250m"}}}},
+			wantReplicas: 2,
+			wantCPU:      "250m",
+		},
+		{
+			name:    "excluded namespace",
+			filter:  k8s.NamespaceFilter{Exclude: []string{"default"}},
+			action:  Action{Kind: KindDeployment, Namespace: "default", Name: "web", Replicas: int32Ptr(5)},
+			wantErr: k8s.ErrNamespaceNotAllowed,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, clientset := newTestExecutor(t, tt.filter, testDeployment(2, 1))
+
+			result, err := e.Execute(context.Background(), tt.action)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Execute() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+
+			if got := *result.Before.Replicas; got != 2 {
+				t.Errorf("before replicas = %d, want 2", got)
+			}
+			if got := *result.After.Replicas; got != tt.wantReplicas {
+				t.Errorf("after replicas = %d, want %d", got, tt.wantReplicas)
+			}
+			live := liveDeployment(t, clientset)
+			if got := *live.Spec.Replicas; got != tt.wantReplicas {
+				t.Errorf("live replicas = %d, want %d", got, tt.wantReplicas)
+			}
+			cpu := live.Spec.Template.Spec.Containers[0].Resources.Requests[corev1.ResourceCPU]
+			if got := cpu.String(); got != tt.wantCPU {
+				t.Errorf("live cpu request = %s, want %s", got, tt.wantCPU)
+			}
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	tests := []struct {
+		name string
+		// liveGeneration is the deployment's generation at rollback time; the
+		// apply recorded generation 2.
+		liveGeneration int64
+		force          bool
+		wantConflict   bool
+	}{
+		{name: "unchanged since apply", liveGeneration: 2},
+		{name: "modified since apply", liveGeneration: 3, wantConflict: true},
+		{name: "modified since apply, forced", liveGeneration: 3, force: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, clientset := newTestExecutor(t, k8s.NamespaceFilter{}, testDeployment(2, 1))
+
+			applied, err := e.Execute(context.Background(), Action{
+				Kind: KindDeployment, Namespace: "default", Name: "web",
+				Replicas:  int32Ptr(6),
+				Resources: []ContainerResources{{Requests: map[string]string{"cpu": "500m", "memory": "256Mi"}}},
+			})
+			if err != nil {
+				t.Fatalf("Execute() error = %v", err)
+			}
+			// The fake clientset does not bump generations; stand in for the
+			// API server
+			applied.After.Generation = 2
+			live := liveDeployment(t, clientset)
+			live.Generation = tt.liveGeneration
+			if _, err := clientset.AppsV1().Deployments("default").Update(context.Background(), live, metav1.UpdateOptions{}); err != nil {
+				t.Fatalf("update generation: %v", err)
+			}
+
+			_, err = e.Rollback(context.Background(), applied.Before, applied.After, tt.force)
+			if tt.wantConflict {
+				if !apierrors.IsConflict(err) {
+					t.Fatalf("Rollback() error = %v, want conflict", err)
+				}
+				if got := *liveDeployment(t, clientset).Spec.Replicas; got != 6 {
+					t.Errorf("replicas after refused rollback = %d, want 6", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Rollback() error = %v", err)
+			}
+
+			restored := liveDeployment(t, clientset)
+			if got := *restored.Spec.Replicas; got != 2 {
+				t.Errorf("restored replicas = %d, want 2", got)
+			}
+			requests := restored.Spec.Template.Spec.Containers[0].Resources.Requests
+			if cpu := requests[corev1.ResourceCPU]; cpu.String() != "100m" {
+				t.Errorf("restored cpu request = %s, want 100m", cpu.String())
+			}
+			if _, ok := requests[corev1.ResourceMemory]; ok {
+				t.Errorf("memory request added by the apply was not removed")
+			}
+		})
+	}
+}
+
+func TestRollbackWithoutSnapshot(t *testing.T) {
+	e, _ := newTestExecutor(t, k8s.NamespaceFilter{}, testDeployment(2, 1))
+	if _, err := e.Rollback(context.Background(), nil, nil, false); err == nil {
+		t.Fatal("Rollback() without a snapshot succeeded")
+	}
+}
@@ -0,0 +1,296 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+
+	"orchestrator/internal/k8s"
+)
+
+// Intent actions understood by the executor.
+const (
+	IntentScale   = "scale"
+	IntentRestart = "restart"
+	IntentDelete  = "delete"
+	IntentCreate  = "create"
+)
+
+// KindPod can only be the target of a delete intent.
+const KindPod = "Pod"
+
+// RestartedAtAnnotation is the pod template annotation `kubectl rollout
+// restart` sets; changing it rolls every pod of the deployment.
+const RestartedAtAnnotation = "kubectl.kubernetes.io/restartedAt"
+
+// Intent is a cluster change requested in conversation, as parsed by the AI
+// engine. Replicas applies to scale and create, Image to create.
+type Intent struct {
+	Action    string `json:"action"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Replicas  *int32 `json:"replicas,omitempty"`
+	Image     string `json:"image,omitempty"`
+}
+
+// Change is one field an intent will modify, rendered for display.
+type Change struct {
+	Field string `json:"field"`
+	From  string `json:"from,omitempty"`
+	To    string `json:"to,omitempty"`
+}
+
+// Preview shows exactly what executing an intent will do, based on the
+// object's state when the preview was made.
+type Preview struct {
+	Summary string   `json:"summary"`
+	Changes []Change `json:"changes"`
+	Before  *State   `json:"before,omitempty"`
+	// UID pins deletes to the previewed object, so a recreated object with
+	// the same name is never removed by mistake.
+	UID string `json:"uid,omitempty"`
+	// Manifest is the object a create intent will submit, as YAML.
+	Manifest string `json:"manifest,omitempty"`
+}
+
+// Validate checks that the intent is complete and supported.
+func (i Intent) Validate() error {
+	if i.Namespace == "" || i.Name == "" {
+		return fmt.Errorf("namespace and name are required")
+	}
+	switch i.Action {
+	case IntentScale:
+		if i.Replicas == nil || *i.Replicas < 0 {
+			return fmt.Errorf("scale requires a non-negative replica count")
+		}
+	case IntentCreate:
+		if i.Image == "" {
+			return fmt.Errorf("create requires an image")
+		}
+		if i.Replicas != nil && *i.Replicas < 0 {
+			return fmt.Errorf("replicas must not be negative")
+		}
+	case IntentRestart:
+	case IntentDelete:
+		if i.Kind == KindPod {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported action %q", i.Action)
+	}
+	if i.Kind != KindDeployment {
+		return fmt.Errorf("cannot %s a %s", i.Action, i.Kind)
+	}
+	return nil
+}
+
+// Describe returns a short human-readable summary of the intent.
+func (i Intent) Describe() string {
+	target := fmt.Sprintf("%s %s/%s", i.Kind, i.Namespace, i.Name)
+	switch i.Action {
+	case IntentScale:
+		return fmt.Sprintf("Scale %s to %d replicas", target, *i.Replicas)
+	case IntentRestart:
+		return "Restart " + target
+	case IntentDelete:
+		return "Delete " + target
+	case IntentCreate:
+		return fmt.Sprintf("Create %s running %s with %d replica(s)", target, i.Image, i.replicas())
+	}
+	return fmt.Sprintf("%s %s", i.Action, target)
+}
+
+func (i Intent) replicas() int32 {
+	if i.Replicas == nil {
+		return 1
+	}
+	return *i.Replicas
+}
+
+// PreviewIntent inspects the target object and describes the change the
+// intent would make, without changing anything.
+func (e *Executor) PreviewIntent(ctx context.Context, intent Intent) (*Preview, error) {
+	if err := e.checkIntent(intent); err != nil {
+		return nil, err
+	}
+
+	preview := &Preview{Summary: intent.Describe()}
+	switch {
+	case intent.Action == IntentCreate:
+		if _, err := e.k8sClient.GetDeployment(ctx, intent.Namespace, intent.Name); err == nil {
+			return nil, apierrors.NewAlreadyExists(appsv1.Resource("deployments"), intent.Name)
+		} else if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+		manifest, err := yaml.Marshal(deploymentFor(intent))
+		if err != nil {
+			return nil, err
+		}
+		preview.Manifest = string(manifest)
+		preview.Changes = []Change{{Field: "deployment", To: "created"}}
+
+	case intent.Kind == KindPod:
+		pod, err := e.k8sClient.GetPod(ctx, intent.Namespace, intent.Name)
+		if err != nil {
+			return nil, err
+		}
+		preview.Before = capturePod(pod)
+		preview.UID = string(pod.UID)
+		preview.Changes = []Change{{Field: "pod", From: string(pod.Status.Phase), To: "deleted"}}
+
+	default:
+		deployment, err := e.k8sClient.GetDeployment(ctx, intent.Namespace, intent.Name)
+		if err != nil {
+			return nil, err
+		}
+		preview.Before = CaptureDeployment(deployment)
+		current := strconv.Itoa(int(replicasOf(deployment)))
+
+		switch intent.Action {
+		case IntentScale:
+			if replicasOf(deployment) == *intent.Replicas {
+				return nil, fmt.Errorf("%s already has %d replicas", intent.Name, *intent.Replicas)
+			}
+			preview.Changes = []Change{{Field: "spec.replicas", From: current, To: strconv.Itoa(int(*intent.Replicas))}}
+		case IntentRestart:
+			preview.Changes = []Change{{
+				Field: "spec.template.metadata.annotations[" + RestartedAtAnnotation + "]",
+				From:  deployment.Spec.Template.Annotations[RestartedAtAnnotation],
+				To:    "time of confirmation",
+			}}
+		case IntentDelete:
+			preview.UID = string(deployment.UID)
+			preview.Changes = []Change{{Field: "deployment", From: current + " replica(s)", To: "deleted"}}
+		}
+	}
+	return preview, nil
+}
+
+// ExecuteIntent performs an intent that was previewed earlier and returns the
+// object state before and after. Scales and restarts are refused with a
+// conflict when the deployment has changed since the preview, and deletes
+// only remove the previewed object.
+func (e *Executor) ExecuteIntent(ctx context.Context, intent Intent, preview *Preview) (*Result, error) {
+	if err := e.checkIntent(intent); err != nil {
+		return nil, err
+	}
+
+	switch intent.Action {
+	case IntentScale, IntentRestart:
+		if preview == nil || preview.Before == nil {
+			return nil, fmt.Errorf("no preview recorded for %s", intent.Describe())
+		}
+		deployment, err := e.k8sClient.GetDeployment(ctx, intent.Namespace, intent.Name)
+		if err != nil {
+			return nil, err
+		}
+		if deployment.ResourceVersion != preview.Before.ResourceVersion {
+			return nil, apierrors.NewConflict(appsv1.Resource("deployments"), intent.Name,
+				fmt.Errorf("deployment was modified after the change was previewed (resource version %s, expected %s)",
+					deployment.ResourceVersion, preview.Before.ResourceVersion))
+		}
+
+		result := &Result{Before: CaptureDeployment(deployment)}
+		if intent.Action == IntentScale {
+			replicas := *intent.Replicas
+			deployment.Spec.Replicas = &replicas
+		} else {
+			if deployment.Spec.Template.Annotations == nil {
+				deployment.Spec.Template.Annotations = make(map[string]string)
+			}
+			deployment.Spec.Template.Annotations[RestartedAtAnnotation] = time.Now().Format(time.RFC3339)
+		}
+		updated, err := e.k8sClient.UpdateDeployment(ctx, deployment)
+		if err != nil {
+			return result, err
+		}
+		result.After = CaptureDeployment(updated)
+		return result, nil
+
+	case IntentDelete:
+		var uid types.UID
+		result := &Result{}
+		if preview != nil {
+			uid = types.UID(preview.UID)
+			result.Before = preview.Before
+		}
+		if intent.Kind == KindPod {
+			return result, e.k8sClient.DeletePod(intent.Namespace, intent.Name, uid)
+		}
+		return result, e.k8sClient.DeleteDeployment(intent.Namespace, intent.Name, uid)
+
+	case IntentCreate:
+		created, err := e.k8sClient.CreateDeployment(ctx, deploymentFor(intent))
+		if err != nil {
+			return nil, err
+		}
+		return &Result{After: CaptureDeployment(created)}, nil
+	}
+	return nil, fmt.Errorf("unsupported action %q", intent.Action)
+}
+
+func (e *Executor) checkIntent(intent Intent) error {
+	if err := intent.Validate(); err != nil {
+		return err
+	}
+	if e.k8sClient == nil {
+		return ErrNoCluster
+	}
+	if !e.k8sClient.NamespaceAllowed(intent.Namespace) {
+		return fmt.Errorf("%w: %s", k8s.ErrNamespaceNotAllowed, intent.Namespace)
+	}
+	return nil
+}
+
+// deploymentFor builds the single-container deployment a create intent asks
+// for, selecting its pods by an app label.
+func deploymentFor(intent Intent) *appsv1.Deployment {
+	labels := map[string]string{"app": intent.Name}
+	replicas := intent.replicas()
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: KindDeployment},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      intent.Name,
+			Namespace: intent.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: intent.Name, Image: intent.Image}},
+				},
+			},
+		},
+	}
+}
+
+func capturePod(pod *corev1.Pod) *State {
+	return &State{
+		Kind:            KindPod,
+		Namespace:       pod.Namespace,
+		Name:            pod.Name,
+		ResourceVersion: pod.ResourceVersion,
+		Generation:      pod.Generation,
+		CapturedAt:      time.Now(),
+	}
+}
+
+func replicasOf(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		// The API server defaults an unset replica count to one.
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
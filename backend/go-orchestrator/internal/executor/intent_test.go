@@ -0,0 +1,83 @@
+package executor
+
+import (
+	"context"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"orchestrator/internal/k8s"
+)
+
+func TestExecuteIntent(t *testing.T) {
+	tests := []struct {
+		name   string
+		intent Intent
+		// modified changes the deployment between the preview and the
+		// confirmation.
+		modified     bool
+		wantConflict bool
+		wantReplicas int32
+		wantRestart  bool
+	}{
+		{name: "scale", intent: Intent{Action: IntentScale, Replicas: int32Ptr(5)}, wantReplicas: 5},
+		{name: "scale after a change", intent: Intent{Action: IntentScale, Replicas: int32Ptr(5)}, modified: true, wantConflict: true},
+		{name: "restart", intent: Intent{Action: IntentRestart}, wantReplicas: 2, wantRestart: true},
+		{name: "restart after a change", intent: Intent{Action: IntentRestart}, modified: true, wantConflict: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := testDeployment(2, 1)
+			deployment.ResourceVersion = "1"
+			e, clientset := newTestExecutor(t, k8s.NamespaceFilter{}, deployment)
+			intent := tt.intent
+			intent.Kind, intent.Namespace, intent.Name = KindDeployment, "default", "web"
+
+			preview, err := e.PreviewIntent(context.Background(), intent)
+			if err != nil {
+				t.Fatalf("PreviewIntent() error = %v", err)
+			}
+			if tt.modified {
+				// The fake clientset does not bump resource versions; stand
+				// in for the API server
+				live := liveDeployment(t, clientset)
+				live.ResourceVersion = "2"
+				live.Spec.Replicas = int32Ptr(3)
+				if _, err := clientset.AppsV1().Deployments("default").Update(context.Background(), live, metav1.UpdateOptions{}); err != nil {
+					t.Fatalf("update deployment: %v", err)
+				}
+			}
+
+			_, err = e.ExecuteIntent(context.Background(), intent, preview)
+			if tt.wantConflict {
+				if !apierrors.IsConflict(err) {
+					t.Fatalf("ExecuteIntent() error = %v, want conflict", err)
+				}
+				if got := *liveDeployment(t, clientset).Spec.Replicas; got != 3 {
+					t.Errorf("replicas after refused change = %d, want 3", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExecuteIntent() error = %v", err)
+			}
+
+			live := liveDeployment(t, clientset)
+			if got := *live.Spec.Replicas; got != tt.wantReplicas {
+				t.Errorf("replicas = %d, want %d", got, tt.wantReplicas)
+			}
+			if _, restarted := live.Spec.Template.Annotations[RestartedAtAnnotation]; restarted != tt.wantRestart {
+				t.Errorf("restarted = %v, want %v", restarted, tt.wantRestart)
+			}
+		})
+	}
+}
+
+func TestExecuteIntentWithoutPreview(t *testing.T) {
+	e, _ := newTestExecutor(t, k8s.NamespaceFilter{}, testDeployment(2, 1))
+	intent := Intent{Action: IntentScale, Kind: KindDeployment, Namespace: "default", Name: "web", Replicas: int32Ptr(5)}
+	if _, err := e.ExecuteIntent(context.Background(), intent, nil); err == nil {
+		t.Fatal("ExecuteIntent() without a preview succeeded")
+	}
+}
@@ -0,0 +1,91 @@
+package executor
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"orchestrator/internal/k8s"
+)
+
+// Rollback restores the object to the pre-apply snapshot in before. The object
+// must still be at the generation recorded in after, otherwise someone else
+// has changed its spec since the apply and a conflict is returned. Setting
+// force skips that check.
+func (e *Executor) Rollback(ctx context.Context, before, after *State, force bool) (*Result, error) {
+	if before == nil {
+		return nil, fmt.Errorf("no pre-apply snapshot recorded")
+	}
+	if before.Kind != KindDeployment {
+		return nil, fmt.Errorf("unsupported kind %q", before.Kind)
+	}
+	if e.k8sClient == nil {
+		return nil, ErrNoCluster
+	}
+	if !e.k8sClient.NamespaceAllowed(before.Namespace) {
+		return nil, fmt.Errorf("%w: %s", k8s.ErrNamespaceNotAllowed, before.Namespace)
+	}
+
+	deployment, err := e.k8sClient.GetDeployment(ctx, before.Namespace, before.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !force && after != nil && deployment.Generation != after.Generation {
+		return nil, apierrors.NewConflict(appsv1.Resource("deployments"), before.Name,
+			fmt.Errorf("deployment was modified after the recommendation was applied (generation %d, expected %d)",
+				deployment.Generation, after.Generation))
+	}
+
+	result := &Result{Before: CaptureDeployment(deployment)}
+
+	if before.Replicas != nil {
+		replicas := *before.Replicas
+		deployment.Spec.Replicas = &replicas
+	} else {
+		deployment.Spec.Replicas = nil
+	}
+	for _, snapshot := range before.Resources {
+		if err := restoreResources(deployment, snapshot); err != nil {
+			return result, err
+		}
+	}
+
+	updated, err := e.k8sClient.UpdateDeployment(ctx, deployment)
+	if err != nil {
+		return result, err
+	}
+
+	result.After = CaptureDeployment(updated)
+	return result, nil
+}
+
+// restoreResources replaces a container's requests and limits wholesale with
+// the snapshot, so resources added by the apply are removed again.
+func restoreResources(deployment *appsv1.Deployment, snapshot ContainerResources) error {
+	container, err := findContainer(&deployment.Spec.Template.Spec, snapshot.Container)
+	if err != nil {
+		return err
+	}
+
+	requests, err := parseQuantities(snapshot.Requests)
+	if err != nil {
+		return err
+	}
+	limits, err := parseQuantities(snapshot.Limits)
+	if err != nil {
+		return err
+	}
+
+	container.Resources.Requests = nil
+	if len(requests) > 0 {
+		container.Resources.Requests = requests
+	}
+	container.Resources.Limits = nil
+	if len(limits) > 0 {
+		container.Resources.Limits = limits
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/k8s"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+// GetAnomalies lists detected anomalies overlapping the from/to range (the
+// last 24 hours by default), newest first. status, severity and metric filter
+// the records; namespace and service match the offending series' labels.
+// Only anomalies in the caller's read scope are listed.
+func GetAnomalies(k8sClient *k8s.Client, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		namespace, ok := namespaceParam(c, k8sClient)
+		if !ok {
+			return
+		}
+		from, to, ok := timeRangeParams(c, 24*time.Hour)
+		if !ok {
+			return
+		}
+
+		filter := storage.AnomalyFilter{
+			Metric:   c.Query("metric"),
+			Severity: c.Query("severity"),
+			Status:   c.Query("status"),
+			From:     from,
+			To:       to,
+		}
+		limit := 100
+		if value, err := strconv.Atoi(c.Query("limit")); err == nil && value > 0 {
+			limit = value
+		}
+
+		anomalies, err := store.ListAnomalies(c.Request.Context(), filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load anomalies"})
+			return
+		}
+		anomalies = filterAnomalies(anomalies, k8sClient, readScope(c), namespace, c.Query("service"))
+		if len(anomalies) > limit {
+			anomalies = anomalies[:limit]
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"anomalies": anomalies,
+			"total":     len(anomalies),
+		})
+	}
+}
+
+// filterAnomalies drops anomalies on namespaces the orchestrator does not
+// watch or outside scope, where anomalies on cluster-wide series have no
+// namespace, and, when given, keeps only those on namespace and on the
+// deployment named service.
+func filterAnomalies(anomalies []models.Anomaly, k8sClient *k8s.Client, scope rbac.Scope, namespace, service string) []models.Anomaly {
+	filtered := []models.Anomaly{}
+	for _, anomaly := range anomalies {
+		ns := anomaly.Labels["namespace"]
+		if ns != "" && k8sClient != nil && !k8sClient.NamespaceAllowed(ns) {
+			continue
+		}
+		if !scope.Allows(ns) {
+			continue
+		}
+		if namespace != "" && ns != namespace {
+			continue
+		}
+		if service != "" && anomaly.Labels["deployment"] != service {
+			continue
+		}
+		filtered = append(filtered, anomaly)
+	}
+	return filtered
+}
@@ -1,27 +1,55 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/json"
-	"io"
+	"errors"
+	"log"
 	"net/http"
-	"os"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/aiengine"
+	"orchestrator/internal/chatcontext"
+	"orchestrator/internal/executor"
+	"orchestrator/internal/manifest"
+	"orchestrator/internal/models"
+	"orchestrator/internal/storage"
 )
 
 type ChatRequest struct {
 	Message string `json:"message"`
 	Context string `json:"context"`
+	// SessionID continues an existing conversation; when empty a new session
+	// is started.
+	SessionID string `json:"sessionId"`
 }
 
 type ChatResponse struct {
-	Response string `json:"response"`
-	Code     string `json:"code,omitempty"`
-	Language string `json:"language,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+	Response  string `json:"response"`
+	Code      string `json:"code,omitempty"`
+	Language  string `json:"language,omitempty"`
+	// Degraded is set when the AI engine could not be reached and the
+	// response is the built-in fallback.
+	Degraded bool   `json:"degraded,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	// Action is the cluster change proposed or resolved by this reply.
+	Action *models.ChatAction `json:"action,omitempty"`
+	// Manifest is the validation result for generated YAML in Code.
+	Manifest *models.Manifest `json:"manifest,omitempty"`
 }
 
-func HandleChat() gin.HandlerFunc {
+const chatFallback = "I can help you manage your infrastructure. Try asking:\n- 'Create a Redis cluster with 2 nodes'\n- 'Scale the frontend deployment to 5 replicas'\n- 'Show me current costs'"
+
+// HandleChat answers a ChatOps message, sending the AI engine the session's
+// recent history and a summary of the live cluster.
+//
+// When the AI engine recognises a cluster change (scale, restart, delete or
+// create) the reply carries a preview of it as a pending action, which a
+// following "confirm" or "cancel" message, or the chat action endpoints,
+// resolve. Generated YAML is validated with a server-side dry-run and
+// returned as a manifest.
+func HandleChat(aiClient *aiengine.Client, recExecutor *executor.Executor, validator *manifest.Validator, chatContext *chatcontext.Builder, store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req ChatRequest
 		if err := c.BindJSON(&req); err != nil {
@@ -29,45 +57,137 @@ func HandleChat() gin.HandlerFunc {
 			return
 		}
 
-		// Call AI Engine
-		aiEngineURL := os.Getenv("AI_ENGINE_URL")
-		if aiEngineURL == "" {
-			aiEngineURL = "http://localhost:8001"
+		conv, ok := openConversation(c, store, req)
+		if !ok {
+			return
 		}
-
-		payload := map[string]interface{}{
-			"message": req.Message,
-			"context": req.Context,
+		if reply, ok := conv.resolvePending(c, recExecutor, req.Message); ok {
+			conv.recordReply(c, reply)
+			c.JSON(http.StatusOK, reply)
+			return
 		}
 
-		jsonData, err := json.Marshal(payload)
+		// Call AI Engine, bounded by the client's request
+		resp, err := aiClient.Chat(c.Request.Context(), conv.engineRequest(c, chatContext, req))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
+			if c.Request.Context().Err() != nil {
+				// The client went away; nobody is left to answer
+				return
+			}
+			log.Printf("AI engine chat failed: %v", err)
+			reply := ChatResponse{
+				SessionID: conv.session.ID,
+				Response:  chatFallback,
+				Degraded:  true,
+				Reason:    degradedReason(err),
+			}
+			conv.recordReply(c, reply)
+			c.JSON(http.StatusOK, reply)
 			return
 		}
 
-		resp, err := http.Post(aiEngineURL+"/api/chat", "application/json", bytes.NewBuffer(jsonData))
-		if err != nil {
-			// Return mock response if AI engine is not available
-			c.JSON(http.StatusOK, ChatResponse{
-				Response: "I can help you manage your infrastructure. Try asking:\n- 'Create a Redis cluster with 2 nodes'\n- 'Scale the frontend deployment to 5 replicas'\n- 'Show me current costs'",
-			})
+		reply := ChatResponse{
+			SessionID: conv.session.ID,
+			Response:  resp.Response,
+			Code:      resp.Code,
+			Language:  resp.Language,
+		}
+		conv.proposeAction(c, recExecutor, resp.Intent, &reply)
+		conv.checkManifest(c, validator, &reply)
+		conv.recordReply(c, reply)
+		c.JSON(http.StatusOK, reply)
+	}
+}
+
+// StreamChat answers a ChatOps message as Server-Sent Events: "token" events
+// carry pieces of the answer as the AI engine produces them, and a final
+// "done" event carries the full response with any generated code. If the
+// engine fails, the done event is marked degraded; when nothing had been
+// streamed yet it carries the fallback help message. Disconnecting the client
+// cancels the upstream request.
+func StreamChat(aiClient *aiengine.Client, recExecutor *executor.Executor, validator *manifest.Validator, chatContext *chatcontext.Builder, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req ChatRequest
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		defer resp.Body.Close()
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response"})
+		conv, ok := openConversation(c, store, req)
+		if !ok {
+			return
+		}
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no")
+
+		if reply, ok := conv.resolvePending(c, recExecutor, req.Message); ok {
+			conv.recordReply(c, reply)
+			c.SSEvent("token", gin.H{"content": reply.Response})
+			c.SSEvent("done", reply)
+			c.Writer.Flush()
 			return
 		}
 
-		var chatResp ChatResponse
-		if err := json.Unmarshal(body, &chatResp); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse response"})
+		var streamed strings.Builder
+		err := aiClient.ChatStream(c.Request.Context(), conv.engineRequest(c, chatContext, req), func(chunk aiengine.ChatChunk) error {
+			switch chunk.Type {
+			case aiengine.ChunkToken:
+				streamed.WriteString(chunk.Content)
+				c.SSEvent("token", gin.H{"content": chunk.Content})
+			case aiengine.ChunkDone:
+				reply := ChatResponse{
+					SessionID: conv.session.ID,
+					Response:  chunk.Response,
+					Code:      chunk.Code,
+					Language:  chunk.Language,
+				}
+				conv.proposeAction(c, recExecutor, chunk.Intent, &reply)
+				conv.checkManifest(c, validator, &reply)
+				if extra := strings.TrimPrefix(reply.Response, chunk.Response); extra != "" {
+					// Previews and validation results follow the streamed answer
+					c.SSEvent("token", gin.H{"content": extra})
+				}
+				conv.recordReply(c, reply)
+				c.SSEvent("done", reply)
+			}
+			c.Writer.Flush()
+			return c.Request.Context().Err()
+		})
+		if err == nil || c.Request.Context().Err() != nil {
 			return
 		}
 
-		c.JSON(http.StatusOK, chatResp)
+		log.Printf("AI engine chat stream failed: %v", err)
+		done := ChatResponse{
+			SessionID: conv.session.ID,
+			Response:  streamed.String(),
+			Degraded:  true,
+			Reason:    degradedReason(err),
+		}
+		if streamed.Len() == 0 {
+			done.Response = chatFallback
+			c.SSEvent("token", gin.H{"content": chatFallback})
+		}
+		conv.recordReply(c, done)
+		c.SSEvent("done", done)
+		c.Writer.Flush()
+	}
+}
+
+// degradedReason summarises why the fallback was used without leaking the
+// engine's internals to the browser.
+func degradedReason(err error) string {
+	var statusErr *aiengine.StatusError
+	switch {
+	case errors.Is(err, aiengine.ErrCircuitOpen):
+		return "AI engine unavailable (circuit open)"
+	case errors.As(err, &statusErr):
+		return "AI engine error (HTTP " + http.StatusText(statusErr.StatusCode) + ")"
+	case errors.Is(err, aiengine.ErrStreamInterrupted):
+		return "AI engine stream interrupted"
 	}
+	return "AI engine unreachable"
 }
@@ -0,0 +1,339 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/audit"
+	"orchestrator/internal/executor"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+const defaultChatActionTTL = 10 * time.Minute
+
+var (
+	errChatActionResolved = errors.New("chat action is no longer pending")
+	errChatActionExpired  = errors.New("chat action has expired")
+)
+
+// Replies that confirm or cancel the session's pending action.
+var (
+	confirmReplies = map[string]bool{"confirm": true, "yes": true, "y": true, "proceed": true, "do it": true}
+	cancelReplies  = map[string]bool{"cancel": true, "no": true, "n": true, "abort": true}
+)
+
+// chatActionTTL is how long a proposed action can be confirmed, from
+// CHAT_ACTION_TTL.
+func chatActionTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("CHAT_ACTION_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultChatActionTTL
+}
+
+// proposeAction previews the intent the AI engine found in the message and,
+// if it can be carried out, stores it as the session's pending action and
+// appends the preview to the reply. A new proposal supersedes any earlier
+// pending action in the session. Intents outside the caller's read scope are
+// refused without reading the object.
+func (conv *conversation) proposeAction(c *gin.Context, recExecutor *executor.Executor, intent *executor.Intent, reply *ChatResponse) {
+	if intent == nil {
+		return
+	}
+	ctx := c.Request.Context()
+
+	if !readScope(c).Allows(intent.Namespace) {
+		reply.Response += "\n\nI can't do that: namespace not allowed: " + intent.Namespace
+		return
+	}
+	preview, err := recExecutor.PreviewIntent(ctx, *intent)
+	if err != nil {
+		reply.Response += "\n\nI can't do that right now: " + err.Error()
+		return
+	}
+
+	if pending, err := conv.pendingAction(ctx); err == nil && pending != nil {
+		pending.Status = models.ChatActionCancelled
+		resolvedAt := time.Now()
+		pending.ResolvedAt = &resolvedAt
+		// It may have been confirmed meanwhile, which takes precedence
+		err := conv.store.UpdateChatAction(ctx, pending, models.ChatActionPending)
+		if err != nil && !storage.IsConflict(err) {
+			log.Printf("Failed to supersede chat action %s: %v", pending.ID, err)
+		}
+	}
+
+	action := &models.ChatAction{
+		SessionID: conv.session.ID,
+		User:      conv.session.User,
+		Intent:    *intent,
+		Preview:   preview,
+		Status:    models.ChatActionPending,
+		CreatedAt: time.Now(),
+	}
+	action.ExpiresAt = action.CreatedAt.Add(chatActionTTL())
+	if err := conv.store.SaveChatAction(ctx, action); err != nil {
+		log.Printf("Failed to save chat action: %v", err)
+		reply.Response += "\n\nI couldn't prepare this change, please try again."
+		return
+	}
+
+	reply.Action = action
+	reply.Response += "\n\n" + previewText(preview) + "\n\nReply \"confirm\" to proceed or \"cancel\" to discard it."
+}
+
+// resolvePending handles a confirm or cancel reply to the session's pending
+// action. It returns false when the message is not such a reply or nothing is
+// pending, leaving it to the AI engine.
+func (conv *conversation) resolvePending(c *gin.Context, recExecutor *executor.Executor, message string) (ChatResponse, bool) {
+	answer := strings.Trim(strings.ToLower(strings.TrimSpace(message)), ".!")
+	if !confirmReplies[answer] && !cancelReplies[answer] {
+		return ChatResponse{}, false
+	}
+	pending, err := conv.pendingAction(c.Request.Context())
+	if err != nil || pending == nil {
+		return ChatResponse{}, false
+	}
+
+	action, err := resolveChatAction(c, recExecutor, conv.store, pending.ID, confirmReplies[answer])
+	return ChatResponse{
+		SessionID: conv.session.ID,
+		Response:  outcomeText(action, err),
+		Action:    action,
+	}, true
+}
+
+func (conv *conversation) pendingAction(ctx context.Context) (*models.ChatAction, error) {
+	if conv.session.ID == "" {
+		return nil, nil
+	}
+	actions, err := conv.store.ListChatActions(ctx, storage.ChatActionFilter{
+		SessionID: conv.session.ID,
+		Status:    models.ChatActionPending,
+		Limit:     1,
+	})
+	if err != nil || len(actions) == 0 {
+		return nil, err
+	}
+	return &actions[0], nil
+}
+
+// resolveChatAction executes (confirm) or cancels a pending action and
+// records the outcome in the action log. The action is claimed with a
+// conditional update first, so only one request, on any replica, resolves
+// it. The returned action reflects its final state even when an error is
+// returned.
+func resolveChatAction(c *gin.Context, recExecutor *executor.Executor, store storage.Store, id string, confirm bool) (*models.ChatAction, error) {
+	// The request may end before the change does; keep going regardless
+	ctx := context.WithoutCancel(c.Request.Context())
+
+	action, err := store.GetChatAction(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if action.Status != models.ChatActionPending {
+		return action, errChatActionResolved
+	}
+	// Proposing a change only needs the chat permission; carrying it out
+	// needs the permission for the change itself. A refused action stays
+	// pending until it expires or is cancelled.
+	if confirm {
+		if err := rbac.Authorize(ctx, intentPermission(action.Intent), action.Intent.Namespace); err != nil {
+			return action, err
+		}
+	}
+
+	now := time.Now()
+	claimed := *action
+	claimed.ResolvedAt = &now
+	switch {
+	case now.After(action.ExpiresAt):
+		claimed.Status = models.ChatActionExpired
+	case !confirm:
+		claimed.Status = models.ChatActionCancelled
+	default:
+		claimed.Status = models.ChatActionExecuting
+	}
+	if err := store.UpdateChatAction(ctx, &claimed, models.ChatActionPending); err != nil {
+		if !storage.IsConflict(err) {
+			return action, err
+		}
+		if current, err := store.GetChatAction(ctx, id); err == nil {
+			action = current
+		}
+		return action, errChatActionResolved
+	}
+	action = &claimed
+	target := action.Intent.Namespace + "/" + action.Intent.Name
+
+	switch action.Status {
+	case models.ChatActionExpired:
+		return action, errChatActionExpired
+
+	case models.ChatActionCancelled:
+		audit.Begin(ctx, store, "cancel", "Cancel: "+action.Intent.Describe(), target).
+			Detail("chatActionId", action.ID).
+			Detail("sessionId", action.SessionID).
+			Before(map[string]string{"status": models.ChatActionPending}).
+			After(map[string]string{"status": models.ChatActionCancelled}).
+			Finish(nil)
+		return action, nil
+	}
+
+	event := audit.Begin(ctx, store, action.Intent.Action, action.Intent.Describe(), target).
+		Detail("chatActionId", action.ID).
+		Detail("sessionId", action.SessionID).
+		Detail("source", "chatops")
+	result, err := recExecutor.ExecuteIntent(ctx, action.Intent, action.Preview)
+	action.Result = result
+	action.Status = models.ChatActionExecuted
+	if err != nil {
+		action.Status = models.ChatActionFailed
+		action.Error = err.Error()
+	}
+	if result != nil {
+		event.Before(result.Before).After(result.After)
+	}
+	event.Finish(err)
+
+	if saveErr := store.SaveChatAction(ctx, action); saveErr != nil {
+		log.Printf("Failed to save chat action %s: %v", action.ID, saveErr)
+	}
+	return action, err
+}
+
+// intentPermission is the permission needed to carry out an intent.
+func intentPermission(intent executor.Intent) rbac.Permission {
+	if intent.Action == executor.IntentDelete {
+		return rbac.PermDelete
+	}
+	return rbac.PermOperate
+}
+
+// previewText renders a preview for the chat transcript.
+func previewText(preview *executor.Preview) string {
+	var b strings.Builder
+	b.WriteString("Planned change: " + preview.Summary)
+	for _, change := range preview.Changes {
+		fmt.Fprintf(&b, "\n- %s: %s → %s", change.Field, valueOrNone(change.From), valueOrNone(change.To))
+	}
+	return b.String()
+}
+
+func valueOrNone(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+// outcomeText describes how resolving an action went.
+func outcomeText(action *models.ChatAction, err error) string {
+	switch {
+	case action == nil:
+		return "I couldn't find that action: " + err.Error()
+	case errors.Is(err, errChatActionExpired):
+		return "That change expired before it was confirmed. Ask again to get a fresh preview."
+	case errors.Is(err, errChatActionResolved):
+		return "That change was already " + action.Status + "."
+	case action.Status == models.ChatActionCancelled:
+		return "Cancelled: " + action.Intent.Describe() + "."
+	case err != nil:
+		return "Failed: " + action.Intent.Describe() + ": " + err.Error()
+	}
+	return "Done: " + action.Intent.Describe() + "."
+}
+
+// GetChatAction returns one of the caller's proposed actions.
+func GetChatAction(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		action, ok := loadChatAction(c, store)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"action": action})
+	}
+}
+
+// ConfirmChatAction executes a pending action, as replying "confirm" in the
+// conversation would.
+func ConfirmChatAction(recExecutor *executor.Executor, store storage.Store) gin.HandlerFunc {
+	return chatActionResolver(recExecutor, store, true)
+}
+
+// CancelChatAction discards a pending action.
+func CancelChatAction(store storage.Store) gin.HandlerFunc {
+	return chatActionResolver(nil, store, false)
+}
+
+func chatActionResolver(recExecutor *executor.Executor, store storage.Store, confirm bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		pending, ok := loadChatAction(c, store)
+		if !ok {
+			return
+		}
+
+		action, err := resolveChatAction(c, recExecutor, store, pending.ID, confirm)
+		if action != nil {
+			// Keep the conversation in step with actions resolved outside it
+			message := models.ChatMessage{
+				SessionID: action.SessionID,
+				Role:      models.ChatRoleAssistant,
+				Content:   outcomeText(action, err),
+				ActionID:  action.ID,
+			}
+			appendSessionMessage(c.Request.Context(), store, &message)
+		}
+
+		switch {
+		case errors.Is(err, errChatActionResolved), errors.Is(err, errChatActionExpired):
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error(), "action": action})
+		case err != nil:
+			c.JSON(executorErrorStatus(err), gin.H{"success": false, "error": err.Error(), "action": action})
+		default:
+			c.JSON(http.StatusOK, gin.H{"success": true, "action": action})
+		}
+	}
+}
+
+// appendSessionMessage adds a message to a session outside the normal
+// request/reply flow, keeping the session's counters current.
+func appendSessionMessage(ctx context.Context, store storage.Store, message *models.ChatMessage) {
+	if err := store.AppendChatMessage(ctx, message); err != nil {
+		log.Printf("Failed to record message in chat session %s: %v", message.SessionID, err)
+		return
+	}
+	session, err := store.GetChatSession(ctx, message.SessionID)
+	if err != nil {
+		return
+	}
+	session.MessageCount++
+	session.UpdatedAt = message.CreatedAt
+	if err := store.SaveChatSession(ctx, session); err != nil {
+		log.Printf("Failed to update chat session %s: %v", session.ID, err)
+	}
+}
+
+// loadChatAction fetches the :id action if it belongs to the caller.
+func loadChatAction(c *gin.Context, store storage.Store) (*models.ChatAction, bool) {
+	action, err := store.GetChatAction(c.Request.Context(), c.Param("id"))
+	if storage.IsNotFound(err) || (err == nil && action.User != requestUser(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chat action not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load chat action"})
+		return nil, false
+	}
+	return action, true
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/aiengine"
+	"orchestrator/internal/chatcontext"
+	"orchestrator/internal/models"
+	"orchestrator/internal/storage"
+)
+
+const (
+	defaultChatHistoryTurns = 10
+	chatTitleLength         = 60
+)
+
+// chatHistoryTurns is how many earlier exchanges are sent to the AI engine
+// with each message, from CHAT_HISTORY_TURNS.
+func chatHistoryTurns() int {
+	if turns, err := strconv.Atoi(os.Getenv("CHAT_HISTORY_TURNS")); err == nil && turns >= 0 {
+		return turns
+	}
+	return defaultChatHistoryTurns
+}
+
+// conversation is the session a chat request belongs to, with the history
+// loaded before the new message was recorded.
+type conversation struct {
+	store   storage.Store
+	session *models.ChatSession
+	history []models.ChatMessage
+	// lastAt keeps the reply ordered after the question even when both are
+	// stored within the same millisecond.
+	lastAt time.Time
+}
+
+// openConversation resolves or starts the request's session and records the
+// user's message. It writes the error response and returns false when the
+// session cannot be used.
+func openConversation(c *gin.Context, store storage.Store, req ChatRequest) (*conversation, bool) {
+	ctx := c.Request.Context()
+	user := requestUser(c)
+	conv := &conversation{store: store}
+
+	if req.SessionID != "" {
+		session, ok := loadChatSession(c, store, req.SessionID)
+		if !ok {
+			return nil, false
+		}
+		conv.session = session
+
+		if turns := chatHistoryTurns(); turns > 0 {
+			history, err := store.ListChatMessages(ctx, session.ID, 2*turns)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load chat history"})
+				return nil, false
+			}
+			conv.history = history
+		}
+	} else {
+		conv.session = &models.ChatSession{
+			User:  user,
+			Title: chatTitle(req.Message),
+		}
+	}
+
+	message := models.ChatMessage{
+		Role:      models.ChatRoleUser,
+		Content:   req.Message,
+		CreatedAt: time.Now(),
+	}
+	conv.session.MessageCount++
+	conv.session.UpdatedAt = message.CreatedAt
+	if err := store.SaveChatSession(ctx, conv.session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save chat session"})
+		return nil, false
+	}
+	message.SessionID = conv.session.ID
+	if err := store.AppendChatMessage(ctx, &message); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save chat message"})
+		return nil, false
+	}
+	conv.lastAt = message.CreatedAt
+	return conv, true
+}
+
+// engineRequest builds the AI engine request with the conversation so far
+// and the live cluster context the caller may read. Fallback replies are left
+// out since they say nothing about the conversation.
+func (conv *conversation) engineRequest(c *gin.Context, chatContext *chatcontext.Builder, req ChatRequest) aiengine.ChatRequest {
+	engineReq := aiengine.ChatRequest{
+		Message: req.Message,
+		Context: req.Context,
+		Cluster: chatContext.Build(c.Request.Context(), readScope(c)),
+	}
+	for _, message := range conv.history {
+		if message.Degraded {
+			continue
+		}
+		engineReq.History = append(engineReq.History, aiengine.ChatTurn{
+			Role:    message.Role,
+			Content: message.Content,
+		})
+	}
+	return engineReq
+}
+
+// recordReply stores the assistant's reply. It outlives the request so a
+// reply that was produced is kept even if the client has gone.
+func (conv *conversation) recordReply(c *gin.Context, reply ChatResponse) {
+	ctx := context.WithoutCancel(c.Request.Context())
+
+	// Messages are stored to the millisecond; the reply must sort after the
+	// message it answers
+	now := time.Now()
+	if now.UnixMilli() <= conv.lastAt.UnixMilli() {
+		now = conv.lastAt.Truncate(time.Millisecond).Add(time.Millisecond)
+	}
+	message := models.ChatMessage{
+		SessionID: conv.session.ID,
+		Role:      models.ChatRoleAssistant,
+		Content:   reply.Response,
+		Code:      reply.Code,
+		Language:  reply.Language,
+		Degraded:  reply.Degraded,
+		CreatedAt: now,
+	}
+	if reply.Action != nil {
+		message.ActionID = reply.Action.ID
+	}
+	if reply.Manifest != nil {
+		message.ManifestID = reply.Manifest.ID
+	}
+	if err := conv.store.AppendChatMessage(ctx, &message); err != nil {
+		log.Printf("Failed to save chat reply in session %s: %v", conv.session.ID, err)
+		return
+	}
+	conv.lastAt = now
+
+	conv.session.MessageCount++
+	conv.session.UpdatedAt = now
+	if err := conv.store.SaveChatSession(ctx, conv.session); err != nil {
+		log.Printf("Failed to update chat session %s: %v", conv.session.ID, err)
+	}
+}
+
+// chatTitle names a new session after its first message.
+func chatTitle(message string) string {
+	title := strings.Join(strings.Fields(message), " ")
+	if runes := []rune(title); len(runes) > chatTitleLength {
+		title = strings.TrimSpace(string(runes[:chatTitleLength])) + "…"
+	}
+	if title == "" {
+		title = "New conversation"
+	}
+	return title
+}
+
+// ListChatSessions lists the caller's conversations, most recently active
+// first.
+func ListChatSessions(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 50
+		if value, err := strconv.Atoi(c.Query("limit")); err == nil && value > 0 {
+			limit = value
+		}
+
+		sessions, err := store.ListChatSessions(c.Request.Context(), requestUser(c), limit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load chat sessions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"sessions": sessions,
+			"total":    len(sessions),
+		})
+	}
+}
+
+// GetChatSession returns one of the caller's conversations with its messages,
+// oldest first.
+func GetChatSession(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := loadChatSession(c, store, c.Param("id"))
+		if !ok {
+			return
+		}
+
+		messages, err := store.ListChatMessages(c.Request.Context(), session.ID, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load chat history"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"session":  session,
+			"messages": messages,
+		})
+	}
+}
+
+// RenameChatSession changes a conversation's title.
+func RenameChatSession(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Title string `json:"title"`
+		}
+		if err := c.BindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		title := strings.TrimSpace(req.Title)
+		if title == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+			return
+		}
+
+		session, ok := loadChatSession(c, store, c.Param("id"))
+		if !ok {
+			return
+		}
+		session.Title = title
+		if err := store.SaveChatSession(c.Request.Context(), session); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save chat session"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"session": session,
+		})
+	}
+}
+
+// DeleteChatSession removes a conversation and its messages.
+func DeleteChatSession(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, ok := loadChatSession(c, store, c.Param("id"))
+		if !ok {
+			return
+		}
+		if err := store.DeleteChatSession(c.Request.Context(), session.ID); err != nil && !storage.IsNotFound(err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chat session"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Chat session deleted",
+		})
+	}
+}
+
+// loadChatSession fetches a session owned by the caller. Other users'
+// sessions are reported as missing.
+func loadChatSession(c *gin.Context, store storage.Store, id string) (*models.ChatSession, bool) {
+	session, err := store.GetChatSession(c.Request.Context(), id)
+	if storage.IsNotFound(err) || (err == nil && session.User != requestUser(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Chat session not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load chat session"})
+		return nil, false
+	}
+	return session, true
+}
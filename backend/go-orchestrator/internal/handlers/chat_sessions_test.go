@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/auth"
+	"orchestrator/internal/models"
+	"orchestrator/internal/storage"
+)
+
+func TestChatSessionOwnership(t *testing.T) {
+	newRouter := func(t *testing.T) (*gin.Engine, storage.Store) {
+		store, err := storage.Open(context.Background(), storage.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")})
+		if err != nil {
+			t.Fatalf("open store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		now := time.Now()
+		session := &models.ChatSession{ID: "alice-session", User: "alice", Title: "Scaling web", CreatedAt: now, UpdatedAt: now}
+		if err := store.SaveChatSession(context.Background(), session); err != nil {
+			t.Fatalf("SaveChatSession() error = %v", err)
+		}
+		message := &models.ChatMessage{ID: "msg-1", SessionID: session.ID, Role: models.ChatRoleUser, Content: "scale web", CreatedAt: now}
+		if err := store.AppendChatMessage(context.Background(), message); err != nil {
+			t.Fatalf("AppendChatMessage() error = %v", err)
+		}
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			identity := &auth.Identity{Subject: c.GetHeader("X-Subject"), Method: auth.MethodAPIKey}
+			c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+		})
+		router.GET("/chat/sessions/:id", GetChatSession(store))
+		router.PATCH("/chat/sessions/:id", RenameChatSession(store))
+		router.DELETE("/chat/sessions/:id", DeleteChatSession(store))
+		return router, store
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		subject    string
+		id         string
+		wantStatus int
+		// wantTitle is the session's title afterwards, empty if it is deleted.
+		wantTitle string
+	}{
+		{name: "owner reads", method: http.MethodGet, subject: "alice", id: "alice-session", wantStatus: http.StatusOK, wantTitle: "Scaling web"},
+		{name: "other user reads", method: http.MethodGet, subject: "bob", id: "alice-session", wantStatus: http.StatusNotFound, wantTitle: "Scaling web"},
+		{name: "missing session", method: http.MethodGet, subject: "alice", id: "no-such-session", wantStatus: http.StatusNotFound, wantTitle: "Scaling web"},
+		{name: "owner renames", method: http.MethodPatch, subject: "alice", id: "alice-session", wantStatus: http.StatusOK, wantTitle: "Renamed"},
+		{name: "other user renames", method: http.MethodPatch, subject: "bob", id: "alice-session", wantStatus: http.StatusNotFound, wantTitle: "Scaling web"},
+		{name: "owner deletes", method: http.MethodDelete, subject: "alice", id: "alice-session", wantStatus: http.StatusOK},
+		{name: "other user deletes", method: http.MethodDelete, subject: "bob", id: "alice-session", wantStatus: http.StatusNotFound, wantTitle: "Scaling web"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, store := newRouter(t)
+
+			req := httptest.NewRequest(tt.method, "/chat/sessions/"+tt.id, strings.NewReader(`{"title": "Renamed"}`))
+			req.Header.Set("X-Subject", tt.subject)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusNotFound {
+				// Indistinguishable from a session that does not exist
+				if body := w.Body.String(); body != `{"error":"Chat session not found"}` {
+					t.Errorf("body = %s, want the not found error", body)
+				}
+				if strings.Contains(w.Body.String(), "scale web") {
+					t.Error("response leaks the other user's messages")
+				}
+			}
+
+			session, err := store.GetChatSession(context.Background(), "alice-session")
+			if tt.wantTitle == "" {
+				if !storage.IsNotFound(err) {
+					t.Errorf("GetChatSession() error = %v, want the session deleted", err)
+				}
+				return
+			}
+			if err != nil || session.Title != tt.wantTitle {
+				t.Errorf("GetChatSession() = %+v, %v, want title %q", session, err, tt.wantTitle)
+			}
+		})
+	}
+}
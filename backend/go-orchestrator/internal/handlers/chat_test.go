@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/aiengine"
+	"orchestrator/internal/auth"
+	"orchestrator/internal/executor"
+	"orchestrator/internal/manifest"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+// parseSSE splits a text/event-stream body into its events.
+func parseSSE(body string) []sseEvent {
+	var (
+		events  []sseEvent
+		current sseEvent
+	)
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			current.name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			current.data = strings.TrimPrefix(line, "data:")
+		case line == "" && current.name != "":
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	return events
+}
+
+// streamRouter serves StreamChat against an AI engine stub that answers
+// /api/chat/stream with engine. The returned function cancels the request
+// in flight.
+func streamRouter(t *testing.T, cfg aiengine.Config, engine http.HandlerFunc) (*gin.Engine, storage.Store, context.CancelFunc) {
+	t.Helper()
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	cfg.BaseURL = server.URL
+	if cfg.Timeout == 0 {
+		cfg.Timeout = time.Second
+	}
+
+	store, err := storage.Open(context.Background(), storage.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	policy, err := rbac.New(rbac.Config{})
+	if err != nil {
+		t.Fatalf("rbac.New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		identity := &auth.Identity{Subject: "anonymous", Method: auth.MethodNone}
+		c.Request = c.Request.WithContext(auth.WithIdentity(ctx, identity))
+	})
+	router.POST("/chat/stream", rbac.Require(policy, rbac.PermChat),
+		StreamChat(aiengine.NewClient(cfg), executor.New(nil), manifest.New(nil), nil, store))
+	return router, store, cancel
+}
+
+func streamChat(router *gin.Engine) []sseEvent {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/chat/stream", strings.NewReader(`{"message": "hello"}`)))
+	return parseSSE(w.Body.String())
+}
+
+// ndjsonLines answers with lines of NDJSON.
+func ndjsonLines(lines ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range lines {
+			w.Write([]byte(line + "\n"))
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func TestStreamChat(t *testing.T) {
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		engine  http.HandlerFunc
+		// wantTokens is the concatenated content of the token events.
+		wantTokens   string
+		wantResponse string
+		wantReason   string
+	}{
+		{
+			name:         "complete answer",
+			engine:       ndjsonLines(`{"type":"token","content":"Hel"}`, `{"type":"token","content":"lo"}`, `{"type":"done","response":"Hello"}`),
+			wantTokens:   "Hello",
+			wantResponse: "Hello",
+		},
+		{
+			name: "engine error before any token",
+			engine: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "overloaded", http.StatusServiceUnavailable)
+			},
+			wantTokens:   chatFallback,
+			wantResponse: chatFallback,
+			wantReason:   "AI engine error (HTTP Service Unavailable)",
+		},
+		{
+			name:         "missing done chunk",
+			engine:       ndjsonLines(`{"type":"token","content":"Hel"}`),
+			wantTokens:   "Hel",
+			wantResponse: "Hel",
+			wantReason:   "AI engine stream interrupted",
+		},
+		{
+			name:    "engine goes silent",
+			timeout: 100 * time.Millisecond,
+			engine: func(w http.ResponseWriter, r *http.Request) {
+				ndjsonLines(`{"type":"token","content":"Hel"}`)(w, r)
+				<-r.Context().Done()
+			},
+			wantTokens:   "Hel",
+			wantResponse: "Hel",
+			wantReason:   "AI engine stream interrupted",
+		},
+		{
+			name:         "error chunk",
+			engine:       ndjsonLines(`{"type":"token","content":"Hel"}`, `{"type":"error","error":"model overloaded"}`),
+			wantTokens:   "Hel",
+			wantResponse: "Hel",
+			wantReason:   "AI engine unreachable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, store, _ := streamRouter(t, aiengine.Config{Timeout: tt.timeout}, tt.engine)
+
+			events := streamChat(router)
+			if len(events) == 0 || events[len(events)-1].name != "done" {
+				t.Fatalf("events = %+v, want them to end with done", events)
+			}
+			var tokens strings.Builder
+			for _, event := range events[:len(events)-1] {
+				var token struct {
+					Content string `json:"content"`
+				}
+				if event.name != "token" || json.Unmarshal([]byte(event.data), &token) != nil {
+					t.Fatalf("event %+v, want a token", event)
+				}
+				tokens.WriteString(token.Content)
+			}
+			if tokens.String() != tt.wantTokens {
+				t.Errorf("tokens = %q, want %q", tokens.String(), tt.wantTokens)
+			}
+
+			var done ChatResponse
+			if err := json.Unmarshal([]byte(events[len(events)-1].data), &done); err != nil {
+				t.Fatalf("decode done: %v", err)
+			}
+			if done.Response != tt.wantResponse || done.Reason != tt.wantReason || done.Degraded != (tt.wantReason != "") {
+				t.Errorf("done = %+v, want response %q, reason %q", done, tt.wantResponse, tt.wantReason)
+			}
+
+			// The reply is kept in the session either way
+			messages, err := store.ListChatMessages(context.Background(), done.SessionID, 0)
+			if err != nil || len(messages) != 2 {
+				t.Fatalf("ListChatMessages() = %+v, %v, want the message and the reply", messages, err)
+			}
+			if reply := messages[1]; reply.Role != models.ChatRoleAssistant || reply.Content != tt.wantResponse || reply.Degraded != done.Degraded {
+				t.Errorf("stored reply = %+v, want %q", reply, tt.wantResponse)
+			}
+		})
+	}
+}
+
+func TestStreamChatCircuitOpen(t *testing.T) {
+	router, _, _ := streamRouter(t, aiengine.Config{BreakerThreshold: 1, BreakerCooldown: time.Minute}, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	})
+
+	streamChat(router)
+	events := streamChat(router)
+	var done ChatResponse
+	if len(events) == 0 || json.Unmarshal([]byte(events[len(events)-1].data), &done) != nil {
+		t.Fatalf("events = %+v, want a done event", events)
+	}
+	if done.Reason != "AI engine unavailable (circuit open)" || done.Response != chatFallback {
+		t.Errorf("done = %+v, want the fallback with the breaker open", done)
+	}
+}
+
+func TestStreamChatClientCancel(t *testing.T) {
+	upstreamClosed := make(chan struct{})
+	var cancel context.CancelFunc
+	router, store, cancel := streamRouter(t, aiengine.Config{}, func(w http.ResponseWriter, r *http.Request) {
+		ndjsonLines(`{"type":"token","content":"Hel"}`)(w, r)
+		// The browser goes away mid-answer
+		cancel()
+		<-r.Context().Done()
+		close(upstreamClosed)
+	})
+
+	events := streamChat(router)
+	select {
+	case <-upstreamClosed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upstream request was not closed")
+	}
+	for _, event := range events {
+		if event.name == "done" {
+			t.Errorf("done sent to a client that went away: %+v", event)
+		}
+	}
+	sessions, err := store.ListChatSessions(context.Background(), "anonymous", 0)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("ListChatSessions() = %+v, %v, want one session", sessions, err)
+	}
+	if messages, _ := store.ListChatMessages(context.Background(), sessions[0].ID, 0); len(messages) != 1 {
+		t.Errorf("%d messages stored, want only the question", len(messages))
+	}
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"orchestrator/internal/k8s"
+	"orchestrator/internal/rbac"
+)
+
+// k8sErrorStatus maps a Kubernetes API error onto the matching HTTP status so
+// callers can tell a missing object from a conflict or an RBAC denial.
+func k8sErrorStatus(err error) int {
+	switch {
+	case apierrors.IsNotFound(err):
+		return http.StatusNotFound
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		return http.StatusConflict
+	case apierrors.IsForbidden(err), errors.Is(err, k8s.ErrNamespaceNotAllowed), errors.Is(err, rbac.ErrForbidden):
+		return http.StatusForbidden
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// respondK8sError writes err with the status chosen by k8sErrorStatus.
+func respondK8sError(c *gin.Context, err error) {
+	c.JSON(k8sErrorStatus(err), gin.H{
+		"success": false,
+		"error":   err.Error(),
+	})
+}
+
+// authorize checks the caller's permission in the namespace of the object a
+// handler is about to change, writing a 403 and returning false if it is
+// missing.
+func authorize(c *gin.Context, perm rbac.Permission, namespace string) bool {
+	if err := rbac.Authorize(c.Request.Context(), perm, namespace); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// requireK8s writes a 503 and returns false when no cluster is configured.
+func requireK8s(c *gin.Context, k8sClient *k8s.Client) bool {
+	if k8sClient == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"error":   "Kubernetes client not available",
+		})
+		return false
+	}
+	return true
+}
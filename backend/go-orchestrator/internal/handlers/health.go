@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/k8s"
+)
+
+// Ready reports the orchestrator as not ready until the Kubernetes informer
+// cache has synced, so that traffic only arrives once reads are served from
+// it.
+func Ready(k8sClient *k8s.Client) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if k8sClient != nil && !k8sClient.CacheReady() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "syncing"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready"})
+	}
+}
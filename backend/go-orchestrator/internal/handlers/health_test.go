@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"orchestrator/internal/k8s"
+)
+
+func TestReady(t *testing.T) {
+	k8sClient := k8s.NewClientFromClientset(fake.NewSimpleClientset(), k8s.NamespaceFilter{})
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/ready", Ready(k8sClient))
+	router.GET("/ready-without-k8s", Ready(nil))
+	status := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	if code := status("/ready-without-k8s"); code != http.StatusOK {
+		t.Errorf("without Kubernetes status = %d, want %d", code, http.StatusOK)
+	}
+	if code := status("/ready"); code != http.StatusServiceUnavailable {
+		t.Errorf("before the cache syncs status = %d, want %d", code, http.StatusServiceUnavailable)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	k8sClient.StartCache(ctx)
+	deadline := time.Now().Add(5 * time.Second)
+	for status("/ready") != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("not ready after the cache started")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -4,8 +4,14 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	"orchestrator/internal/audit"
 	"orchestrator/internal/k8s"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
 )
 
 type InfrastructureResource struct {
@@ -17,60 +23,85 @@ type InfrastructureResource struct {
 	Metadata map[string]interface{} `json:"metadata"`
 }
 
+// GetInfrastructure lists pods and deployments in the caller's read scope,
+// and the cloud resources when that scope is cluster-wide.
 func GetInfrastructure(k8sClient *k8s.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		namespace, ok := namespaceParam(c, k8sClient)
+		if !ok {
+			return
+		}
+		scope := readScope(c)
+
 		resources := []InfrastructureResource{}
 
 		// Get Kubernetes resources
 		if k8sClient != nil {
-			pods, err := k8sClient.GetPods("default")
-			if err == nil {
-				for _, pod := range pods {
-					resources = append(resources, InfrastructureResource{
-						ID:       string(pod.UID),
-						Name:     pod.Name,
-						Type:     "pod",
-						Provider: "kubernetes",
-						Status:   string(pod.Status.Phase),
-						Metadata: map[string]interface{}{
-							"namespace": pod.Namespace,
-							"created":   pod.CreationTimestamp,
-						},
-					})
+			pods, err := k8sClient.GetPods(namespace)
+			if err != nil {
+				respondK8sError(c, err)
+				return
+			}
+			for _, pod := range pods {
+				if !scope.Allows(pod.Namespace) {
+					continue
 				}
+				resources = append(resources, InfrastructureResource{
+					ID:       string(pod.UID),
+					Name:     pod.Name,
+					Type:     "pod",
+					Provider: "kubernetes",
+					Status:   string(pod.Status.Phase),
+					Metadata: map[string]interface{}{
+						"namespace": pod.Namespace,
+						"created":   pod.CreationTimestamp,
+					},
+				})
 			}
 
-			deployments, err := k8sClient.GetDeployments("default")
-			if err == nil {
-				for _, dep := range deployments {
-					resources = append(resources, InfrastructureResource{
-						ID:       string(dep.UID),
-						Name:     dep.Name,
-						Type:     "deployment",
-						Provider: "kubernetes",
-						Status:   "running",
-						Metadata: map[string]interface{}{
-							"namespace": dep.Namespace,
-							"replicas":  *dep.Spec.Replicas,
-							"created":   dep.CreationTimestamp,
-						},
-					})
+			deployments, err := k8sClient.GetDeployments(namespace)
+			if err != nil {
+				respondK8sError(c, err)
+				return
+			}
+			for _, dep := range deployments {
+				if !scope.Allows(dep.Namespace) {
+					continue
 				}
+				replicas := desiredReplicas(&dep)
+				status := "running"
+				if replicas == 0 {
+					status = "stopped"
+				}
+				resources = append(resources, InfrastructureResource{
+					ID:       string(dep.UID),
+					Name:     dep.Name,
+					Type:     "deployment",
+					Provider: "kubernetes",
+					Status:   status,
+					Metadata: map[string]interface{}{
+						"namespace": dep.Namespace,
+						"replicas":  replicas,
+						"created":   dep.CreationTimestamp,
+					},
+				})
 			}
 		}
 
 		// Add mock AWS resources
-		resources = append(resources, InfrastructureResource{
-			ID:       "i-1234567890abcdef0",
-			Name:     "worker-node-1",
-			Type:     "ec2-instance",
-			Provider: "aws",
-			Status:   "running",
-			Metadata: map[string]interface{}{
-				"instanceType": "t3.medium",
-				"region":       "us-east-1",
-			},
-		})
+		if scope.All {
+			resources = append(resources, InfrastructureResource{
+				ID:       "i-1234567890abcdef0",
+				Name:     "worker-node-1",
+				Type:     "ec2-instance",
+				Provider: "aws",
+				Status:   "running",
+				Metadata: map[string]interface{}{
+					"instanceType": "t3.medium",
+					"region":       "us-east-1",
+				},
+			})
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"resources": resources,
@@ -78,43 +109,125 @@ func GetInfrastructure(k8sClient *k8s.Client) gin.HandlerFunc {
 	}
 }
 
-func StartResource(k8sClient *k8s.Client) gin.HandlerFunc {
+func StartResource(k8sClient *k8s.Client, store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		resourceType := c.Param("type")
 		resourceID := c.Param("id")
 
+		if resourceType != "deployment" {
+			unsupportedResourceType(c, resourceType, "started")
+			return
+		}
+		if !requireK8s(c, k8sClient) {
+			return
+		}
+
+		deployment, err := k8sClient.FindDeploymentByUID(resourceID)
+		if !foundInScope(c, deployment, err) || !authorize(c, rbac.PermOperate, deployment.Namespace) {
+			return
+		}
+
+		event := audit.Begin(c.Request.Context(), store, "start", "Start deployment", deployment.Namespace+"/"+deployment.Name).
+			Before(replicaState(deployment.Spec.Replicas))
+		replicas, err := k8sClient.StartDeployment(deployment.Namespace, deployment.Name)
+		if err == nil {
+			event.After(replicaState(&replicas))
+		}
+		event.Finish(err)
+		if err != nil {
+			respondK8sError(c, err)
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"message": "Resource started",
-			"type":    resourceType,
-			"id":      resourceID,
+			"success":  true,
+			"message":  "Resource started",
+			"type":     resourceType,
+			"id":       resourceID,
+			"replicas": replicas,
 		})
 	}
 }
 
-func StopResource(k8sClient *k8s.Client) gin.HandlerFunc {
+func StopResource(k8sClient *k8s.Client, store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		resourceType := c.Param("type")
 		resourceID := c.Param("id")
 
+		if resourceType != "deployment" {
+			unsupportedResourceType(c, resourceType, "stopped")
+			return
+		}
+		if !requireK8s(c, k8sClient) {
+			return
+		}
+
+		deployment, err := k8sClient.FindDeploymentByUID(resourceID)
+		if !foundInScope(c, deployment, err) || !authorize(c, rbac.PermOperate, deployment.Namespace) {
+			return
+		}
+
+		event := audit.Begin(c.Request.Context(), store, "stop", "Stop deployment", deployment.Namespace+"/"+deployment.Name)
+		previous, err := k8sClient.StopDeployment(deployment.Namespace, deployment.Name)
+		if err == nil {
+			event.Before(replicaState(&previous)).After(replicaState(new(int32)))
+		}
+		event.Finish(err)
+		if err != nil {
+			respondK8sError(c, err)
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"success": true,
-			"message": "Resource stopped",
-			"type":    resourceType,
-			"id":      resourceID,
+			"success":          true,
+			"message":          "Resource stopped",
+			"type":             resourceType,
+			"id":               resourceID,
+			"previousReplicas": previous,
 		})
 	}
 }
 
-func DeleteResource(k8sClient *k8s.Client) gin.HandlerFunc {
+func DeleteResource(k8sClient *k8s.Client, store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		resourceType := c.Param("type")
 		resourceID := c.Param("id")
 
-		// In real implementation, delete the resource
-		// if k8sClient != nil && resourceType == "pod" {
-		//     err := k8sClient.DeletePod(resourceID, namespace)
-		// }
+		if resourceType != "pod" && resourceType != "deployment" {
+			unsupportedResourceType(c, resourceType, "deleted")
+			return
+		}
+		if !requireK8s(c, k8sClient) {
+			return
+		}
+
+		var (
+			object metav1.Object
+			err    error
+		)
+		switch resourceType {
+		case "pod":
+			object, err = k8sClient.FindPodByUID(resourceID)
+		case "deployment":
+			object, err = k8sClient.FindDeploymentByUID(resourceID)
+		}
+		if !foundInScope(c, object, err) || !authorize(c, rbac.PermDelete, object.GetNamespace()) {
+			return
+		}
+
+		event := audit.Begin(c.Request.Context(), store, "delete", "Delete "+resourceType, object.GetNamespace()+"/"+object.GetName()).
+			Before(objectState(resourceType, object))
+		switch resourceType {
+		case "pod":
+			err = k8sClient.DeletePod(object.GetNamespace(), object.GetName(), object.GetUID())
+		case "deployment":
+			err = k8sClient.DeleteDeployment(object.GetNamespace(), object.GetName(), object.GetUID())
+		}
+		event.Finish(err)
+		if err != nil {
+			respondK8sError(c, err)
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
@@ -124,3 +237,58 @@ func DeleteResource(k8sClient *k8s.Client) gin.HandlerFunc {
 		})
 	}
 }
+
+// foundInScope checks the result of looking an object up by UID. Objects
+// outside the caller's read scope get the same 404 as missing ones, so a UID
+// does not reveal what exists in other namespaces.
+func foundInScope(c *gin.Context, object metav1.Object, err error) bool {
+	if apierrors.IsNotFound(err) || (err == nil && !readScope(c).Allows(object.GetNamespace())) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Resource not found",
+		})
+		return false
+	}
+	if err != nil {
+		respondK8sError(c, err)
+		return false
+	}
+	return true
+}
+
+func unsupportedResourceType(c *gin.Context, resourceType, verb string) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"success": false,
+		"error":   "Resources of type " + resourceType + " cannot be " + verb,
+	})
+}
+
+// desiredReplicas reads a deployment's replica count. The API server defaults
+// an unset count to one.
+func desiredReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
+
+// replicaState is a deployment's scale as recorded in the audit log.
+func replicaState(replicas *int32) map[string]interface{} {
+	if replicas == nil {
+		return nil
+	}
+	return map[string]interface{}{"replicas": *replicas}
+}
+
+// objectState identifies an object in the audit log, so that what was
+// deleted can be traced after it is gone.
+func objectState(kind string, object metav1.Object) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":            kind,
+		"namespace":       object.GetNamespace(),
+		"name":            object.GetName(),
+		"uid":             string(object.GetUID()),
+		"resourceVersion": object.GetResourceVersion(),
+		"labels":          object.GetLabels(),
+	}
+}
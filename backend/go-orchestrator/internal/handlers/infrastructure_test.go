@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"orchestrator/internal/auth"
+	"orchestrator/internal/k8s"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+func TestInfrastructureActionScope(t *testing.T) {
+	replicas := int32(3)
+	deployment := func(namespace string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "web", UID: types.UID(namespace + "-web")},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		}
+	}
+	pod := func(namespace string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "web-1", UID: types.UID(namespace + "-pod")}}
+	}
+	newRouter := func(t *testing.T) (*gin.Engine, storage.Store) {
+		clientset := fake.NewSimpleClientset(deployment("payments"), deployment("default"), pod("payments"), pod("default"))
+		k8sClient := k8s.NewClientFromClientset(clientset, k8s.NamespaceFilter{})
+		store, err := storage.Open(context.Background(), storage.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")})
+		if err != nil {
+			t.Fatalf("open store: %v", err)
+		}
+		t.Cleanup(func() { store.Close() })
+		policy, err := rbac.New(rbac.Config{Bindings: []rbac.Binding{
+			{Role: rbac.RoleViewer, Subjects: []string{"dev"}, Namespaces: []string{"payments"}},
+			{Role: rbac.RoleAdmin, Subjects: []string{"ops"}, Namespaces: []string{"payments"}},
+		}})
+		if err != nil {
+			t.Fatalf("rbac.New() error = %v", err)
+		}
+
+		gin.SetMode(gin.TestMode)
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			identity := &auth.Identity{Subject: c.GetHeader("X-Subject"), Method: auth.MethodAPIKey}
+			c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+		})
+		canRead := rbac.Require(policy, rbac.PermRead)
+		router.POST("/infrastructure/:type/:id/start", canRead, StartResource(k8sClient, store))
+		router.POST("/infrastructure/:type/:id/stop", canRead, StopResource(k8sClient, store))
+		router.DELETE("/infrastructure/:type/:id", canRead, DeleteResource(k8sClient, store))
+		return router, store
+	}
+
+	tests := []struct {
+		name       string
+		subject    string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{name: "stop a missing deployment", subject: "ops", method: http.MethodPost, path: "/infrastructure/deployment/missing/stop", wantStatus: http.StatusNotFound},
+		{name: "stop outside the scope", subject: "ops", method: http.MethodPost, path: "/infrastructure/deployment/default-web/stop", wantStatus: http.StatusNotFound},
+		{name: "stop without operate", subject: "dev", method: http.MethodPost, path: "/infrastructure/deployment/payments-web/stop", wantStatus: http.StatusForbidden},
+		{name: "stop", subject: "ops", method: http.MethodPost, path: "/infrastructure/deployment/payments-web/stop", wantStatus: http.StatusOK},
+		{name: "start outside the scope", subject: "ops", method: http.MethodPost, path: "/infrastructure/deployment/default-web/start", wantStatus: http.StatusNotFound},
+		{name: "delete a pod outside the scope", subject: "ops", method: http.MethodDelete, path: "/infrastructure/pod/default-pod", wantStatus: http.StatusNotFound},
+		{name: "delete a pod without delete", subject: "dev", method: http.MethodDelete, path: "/infrastructure/pod/payments-pod", wantStatus: http.StatusForbidden},
+		{name: "delete a pod", subject: "ops", method: http.MethodDelete, path: "/infrastructure/pod/payments-pod", wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, _ := newRouter(t)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("X-Subject", tt.subject)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+		})
+	}
+
+	t.Run("stop is audited with the previous replicas", func(t *testing.T) {
+		router, store := newRouter(t)
+		req := httptest.NewRequest(http.MethodPost, "/infrastructure/deployment/payments-web/stop", nil)
+		req.Header.Set("X-Subject", "ops")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		entries, err := store.ListLogs(context.Background(), storage.LogFilter{Type: "stop"})
+		if err != nil || len(entries) != 1 {
+			t.Fatalf("ListLogs() = %+v, %v, want one stop entry", entries, err)
+		}
+		want := map[string]interface{}{"replicas": float64(3)}
+		if !reflect.DeepEqual(entries[0].Before, want) {
+			t.Errorf("before = %v, want %v", entries[0].Before, want)
+		}
+	})
+}
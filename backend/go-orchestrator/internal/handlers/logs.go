@@ -2,107 +2,45 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
-)
-
-type LogEntry struct {
-	ID        string                 `json:"id"`
-	Type      string                 `json:"type"`
-	Action    string                 `json:"action"`
-	Target    string                 `json:"target"`
-	Status    string                 `json:"status"`
-	User      string                 `json:"user"`
-	Timestamp time.Time              `json:"timestamp"`
-	Details   map[string]interface{} `json:"details"`
-}
 
-var logs = []LogEntry{
-	{
-		ID:        "1",
-		Type:      "scale",
-		Action:    "Scale deployment",
-		Target:    "frontend-deployment",
-		Status:    "completed",
-		User:      "AI System",
-		Timestamp: time.Now().Add(-10 * time.Minute),
-		Details: map[string]interface{}{
-			"from":     2,
-			"to":       4,
-			"reason":   "Predicted traffic increase",
-			"duration": "2.3s",
-		},
-	},
-	{
-		ID:        "2",
-		Type:      "heal",
-		Action:    "Restart pod",
-		Target:    "backend-pod-xyz",
-		Status:    "completed",
-		User:      "AI System",
-		Timestamp: time.Now().Add(-25 * time.Minute),
-		Details: map[string]interface{}{
-			"reason":   "Health check failed",
-			"duration": "8.1s",
-		},
-	},
-	{
-		ID:        "3",
-		Type:      "create",
-		Action:    "Create Redis cluster",
-		Target:    "redis-cache",
-		Status:    "completed",
-		User:      "admin@example.com",
-		Timestamp: time.Now().Add(-1 * time.Hour),
-		Details: map[string]interface{}{
-			"nodes":    2,
-			"region":   "us-east-1",
-			"type":     "cache.t3.micro",
-			"duration": "45.2s",
-		},
-	},
-	{
-		ID:        "4",
-		Type:      "optimize",
-		Action:    "Enable caching",
-		Target:    "api-gateway",
-		Status:    "completed",
-		User:      "AI System",
-		Timestamp: time.Now().Add(-2 * time.Hour),
-		Details: map[string]interface{}{
-			"cacheSize": "1GB",
-			"ttl":       "5m",
-			"hitRate":   "0%",
-		},
-	},
-}
+	"orchestrator/internal/auth"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
 
-func GetLogs() gin.HandlerFunc {
+// GetLogs lists the audit log, newest first, filtered by type, status, user,
+// requestId and a from/to time range. The log spans every namespace, so it
+// needs a cluster-wide admin permission.
+func GetLogs(store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		logType := c.Query("type")
-		status := c.Query("status")
-
-		filteredLogs := logs
-
-		if logType != "" {
-			var filtered []LogEntry
-			for _, log := range filteredLogs {
-				if log.Type == logType {
-					filtered = append(filtered, log)
-				}
-			}
-			filteredLogs = filtered
+		if !authorize(c, rbac.PermAdmin, "") {
+			return
+		}
+		filter := storage.LogFilter{
+			Type:      c.Query("type"),
+			Status:    c.Query("status"),
+			User:      c.Query("user"),
+			RequestID: c.Query("requestId"),
+		}
+		if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+			filter.Limit = limit
+		}
+		var ok bool
+		if filter.From, ok = optionalTimeParam(c, "from"); !ok {
+			return
+		}
+		if filter.To, ok = optionalTimeParam(c, "to"); !ok {
+			return
 		}
 
-		if status != "" {
-			var filtered []LogEntry
-			for _, log := range filteredLogs {
-				if log.Status == status {
-					filtered = append(filtered, log)
-				}
-			}
-			filteredLogs = filtered
+		filteredLogs, err := store.ListLogs(c.Request.Context(), filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load logs"})
+			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
@@ -111,3 +49,34 @@ func GetLogs() gin.HandlerFunc {
 		})
 	}
 }
+
+// optionalTimeParam parses the named query parameter, returning the zero time
+// when it is absent. It responds with 400 when the value is invalid.
+func optionalTimeParam(c *gin.Context, name string) (time.Time, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, true
+	}
+	parsed, err := parseTimeParam(value)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid '" + name + "' parameter: " + err.Error()})
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
+// requestUser identifies who issued the request, for scoping chat sessions to
+// their owner.
+func requestUser(c *gin.Context) string {
+	if identity := auth.IdentityFromContext(c.Request.Context()); identity != nil {
+		return identity.Subject
+	}
+	return "anonymous"
+}
+
+func errorString(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return ""
+}
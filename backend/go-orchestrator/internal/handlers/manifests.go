@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/audit"
+	"orchestrator/internal/manifest"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+// manifestsMu serialises applies so a manifest cannot be applied twice.
+var manifestsMu sync.Mutex
+
+// checkManifest validates YAML generated by the AI engine with a server-side
+// dry-run, stores the outcome and attaches it to the reply with a summary.
+// Objects outside the caller's read scope are reported as not allowed
+// without being looked up.
+func (conv *conversation) checkManifest(c *gin.Context, validator *manifest.Validator, reply *ChatResponse) {
+	language := strings.ToLower(reply.Language)
+	if reply.Code == "" || (language != "yaml" && language != "yml") {
+		return
+	}
+	ctx := c.Request.Context()
+
+	scope := readScope(c)
+	result, err := validator.Validate(ctx, reply.Code, func(ref manifest.ObjectRef) error {
+		if !scope.Allows(ref.Namespace) {
+			return fmt.Errorf("%w: namespace not allowed: %s", rbac.ErrForbidden, ref.Namespace)
+		}
+		return nil
+	})
+	m := &models.Manifest{
+		SessionID:  conv.session.ID,
+		User:       conv.session.User,
+		Content:    reply.Code,
+		Status:     manifestStatus(result, err),
+		Validation: result,
+		Error:      errorString(err),
+	}
+	if err := conv.store.SaveManifest(ctx, m); err != nil {
+		log.Printf("Failed to save manifest: %v", err)
+		return
+	}
+
+	reply.Manifest = m
+	reply.Response += "\n\n" + validationText(m)
+}
+
+func manifestStatus(result *manifest.Result, err error) string {
+	switch {
+	case errors.Is(err, manifest.ErrUnavailable):
+		return models.ManifestUnverified
+	case err != nil, !result.Valid:
+		return models.ManifestInvalid
+	}
+	return models.ManifestValid
+}
+
+// validationText summarises a manifest's dry-run for the chat transcript.
+func validationText(m *models.Manifest) string {
+	var b strings.Builder
+	switch m.Status {
+	case models.ManifestUnverified:
+		return "I couldn't check this manifest against the cluster, so it has not been validated."
+	case models.ManifestInvalid:
+		b.WriteString("This manifest failed validation:")
+	default:
+		b.WriteString("Dry-run passed:")
+	}
+	for _, problem := range m.Validation.Errors {
+		b.WriteString("\n- " + problem)
+	}
+	for _, object := range m.Validation.Objects {
+		switch {
+		case object.Error != "":
+			fmt.Fprintf(&b, "\n- %s: %s", object.Ref, object.Error)
+		case object.Operation == manifest.OperationUpdate:
+			fmt.Fprintf(&b, "\n- %s: update, %d field(s) changed", object.Ref, len(object.Changes))
+		default:
+			fmt.Fprintf(&b, "\n- %s: %s", object.Ref, object.Operation)
+		}
+	}
+	return b.String()
+}
+
+// GetManifest returns one of the caller's generated manifests with its latest
+// validation result.
+func GetManifest(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m, ok := loadManifest(c, store)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"manifest": m})
+	}
+}
+
+// ApplyManifest applies a generated manifest. It is dry-run again first,
+// since the cluster may have changed since it was generated, and nothing is
+// applied unless every object passes.
+func ApplyManifest(validator *manifest.Validator, store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		manifestsMu.Lock()
+		defer manifestsMu.Unlock()
+
+		m, ok := loadManifest(c, store)
+		if !ok {
+			return
+		}
+		if m.Status == models.ManifestApplied {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": "manifest has already been applied", "manifest": m})
+			return
+		}
+
+		// The request may end before the apply does; keep going regardless
+		ctx := context.WithoutCancel(c.Request.Context())
+		start := time.Now()
+		event := audit.Begin(ctx, store, "apply", "Apply manifest", "").
+			Detail("manifestId", m.ID).
+			Detail("sessionId", m.SessionID)
+		result, err := validator.Apply(ctx, m.Content, func(ref manifest.ObjectRef) error {
+			if ref.Privileged() {
+				return rbac.Authorize(ctx, rbac.PermAdmin, ref.Namespace)
+			}
+			return rbac.Authorize(ctx, rbac.PermOperate, ref.Namespace)
+		})
+		// Every attempt is audited, including refused, invalid and unverified ones
+		if len(result.Objects) > 0 {
+			targets := make([]string, 0, len(result.Objects))
+			for _, object := range result.Objects {
+				targets = append(targets, object.Ref.String())
+			}
+			event.Describe(fmt.Sprintf("Apply manifest (%d objects)", len(result.Objects)), strings.Join(targets, ", ")).
+				Detail("objects", result.Objects)
+		}
+		event.Finish(err)
+		if errors.Is(err, rbac.ErrForbidden) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+
+		m.Validation = result
+		m.Error = errorString(err)
+		switch {
+		case err == nil:
+			m.Status = models.ManifestApplied
+			m.AppliedAt = &start
+		case errors.Is(err, manifest.ErrUnavailable):
+			m.Status = models.ManifestUnverified
+		case errors.Is(err, manifest.ErrInvalid):
+			m.Status = models.ManifestInvalid
+		default:
+			m.Status = models.ManifestFailed
+		}
+		if saveErr := store.SaveManifest(ctx, m); saveErr != nil {
+			log.Printf("Failed to save manifest %s: %v", m.ID, saveErr)
+		}
+
+		switch {
+		case errors.Is(err, manifest.ErrUnavailable):
+			c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": err.Error(), "manifest": m})
+		case errors.Is(err, manifest.ErrInvalid):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"success": false, "error": err.Error(), "manifest": m})
+		case err != nil:
+			c.JSON(k8sErrorStatus(err), gin.H{"success": false, "error": err.Error(), "manifest": m})
+		default:
+			c.JSON(http.StatusOK, gin.H{"success": true, "manifest": m})
+		}
+	}
+}
+
+// loadManifest fetches the :id manifest if it belongs to the caller.
+func loadManifest(c *gin.Context, store storage.Store) (*models.Manifest, bool) {
+	m, err := store.GetManifest(c.Request.Context(), c.Param("id"))
+	if storage.IsNotFound(err) || (err == nil && m.User != requestUser(c)) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Manifest not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load manifest"})
+		return nil, false
+	}
+	return m, true
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/audit"
+	"orchestrator/internal/auth"
+	"orchestrator/internal/manifest"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+func TestApplyManifestAudited(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantStatus int
+		wantState  string
+	}{
+		{name: "invalid", content: "kind: [", wantStatus: http.StatusUnprocessableEntity, wantState: models.ManifestInvalid},
+		{
+			name:       "no cluster to verify against",
+			content:    "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: settings\n",
+			wantStatus: http.StatusServiceUnavailable,
+			wantState:  models.ManifestUnverified,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store, err := storage.Open(ctx, storage.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")})
+			if err != nil {
+				t.Fatalf("open store: %v", err)
+			}
+			defer store.Close()
+			if err := store.SaveManifest(ctx, &models.Manifest{ID: "m1", User: "dev", Content: tt.content, Status: models.ManifestValid}); err != nil {
+				t.Fatalf("save manifest: %v", err)
+			}
+			policy, err := rbac.New(rbac.Config{DefaultRole: rbac.RoleOperator})
+			if err != nil {
+				t.Fatalf("rbac.New() error = %v", err)
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), &auth.Identity{Subject: "dev", Method: auth.MethodAPIKey}))
+			})
+			router.POST("/manifests/:id/apply", rbac.Require(policy, rbac.PermOperate), ApplyManifest(manifest.New(nil), store))
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/manifests/m1/apply", nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			m, err := store.GetManifest(ctx, "m1")
+			if err != nil {
+				t.Fatalf("get manifest: %v", err)
+			}
+			if m.Status != tt.wantState {
+				t.Errorf("manifest status = %s, want %s", m.Status, tt.wantState)
+			}
+			entries, err := store.ListLogs(ctx, storage.LogFilter{Type: "apply"})
+			if err != nil {
+				t.Fatalf("list logs: %v", err)
+			}
+			if len(entries) != 1 || entries[0].Status != audit.StatusFailed || entries[0].User != "dev" || entries[0].Details["manifestId"] != "m1" {
+				t.Errorf("audit entries = %+v, want one failed apply of m1 by dev", entries)
+			}
+		})
+	}
+}
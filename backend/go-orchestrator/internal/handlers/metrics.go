@@ -2,90 +2,491 @@ package handlers
 
 import (
 	"net/http"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"orchestrator/internal/k8s"
 	"orchestrator/internal/metrics"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
 )
 
 type MetricsResponse struct {
-	CPU     []MetricPoint `json:"cpu"`
-	Memory  []MetricPoint `json:"memory"`
-	Network []MetricPoint `json:"network"`
-	Anomalies []Anomaly   `json:"anomalies"`
+	Namespace string             `json:"namespace,omitempty"`
+	Service   string             `json:"service,omitempty"`
+	From      time.Time          `json:"from"`
+	To        time.Time          `json:"to"`
+	Step      int64              `json:"step"` // seconds
+	Current   map[string]float64 `json:"current"`
+	CPU       []MetricPoint      `json:"cpu"`
+	Memory    []MetricPoint      `json:"memory"`
+	Network   []MetricPoint      `json:"network"`
+	Anomalies []models.Anomaly   `json:"anomalies"`
 }
 
+// MetricPoint is one bucket of a chart series. Value is the bucket average.
 type MetricPoint struct {
 	Timestamp time.Time `json:"timestamp"`
 	Value     float64   `json:"value"`
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+	P95       float64   `json:"p95"`
 	Service   string    `json:"service,omitempty"`
 }
 
-type Anomaly struct {
-	ID        string    `json:"id"`
-	Type      string    `json:"type"`
-	Service   string    `json:"service"`
-	Severity  string    `json:"severity"`
-	Message   string    `json:"message"`
-	Timestamp time.Time `json:"timestamp"`
+const (
+	// collectionInterval is the collector's tick; finer steps add nothing.
+	collectionInterval = 30 * time.Second
+	// defaultMetricPoints is the target number of points per series when no
+	// step is given; longer windows are averaged down to roughly this many.
+	defaultMetricPoints = 120
+	// maxMetricPoints bounds the points per series an explicit step may ask for.
+	maxMetricPoints = 1000
+)
+
+// metricSeries names the cluster-wide gauge and the per-deployment series
+// behind each chart in MetricsResponse.
+var metricSeries = []struct {
+	cluster    string
+	deployment string
+}{
+	{"cpu_usage", "deployment_cpu_usage"},
+	{"memory_usage", "deployment_memory_usage"},
+	{"network_throughput", "deployment_network_throughput"},
 }
 
-func GetMetrics(metricsCollector *metrics.Collector) gin.HandlerFunc {
+// GetMetrics returns the CPU, memory and network series for the dashboard
+// charts, read from the collector's time-series store, with the anomalies
+// detected in the window.
+//
+// Without ?service the cluster-wide gauges are used (percent, percent, MB/s),
+// which need a cluster-wide scope. With ?service the matching deployment's
+// usage is used (cores, bytes, MB/s), summed across the namespaces in the
+// caller's read scope unless ?namespace narrows it.
+//
+// from/to default to the last hour, and step defaults to whatever keeps each
+// series near defaultMetricPoints points. Windows older than the raw
+// retention are served from rollups, so the step may be raised to the rollup
+// resolution.
+func GetMetrics(k8sClient *k8s.Client, metricsCollector *metrics.Collector, store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		service := c.Query("service")
-		
-		// Generate sample time series data
-		now := time.Now()
-		var cpuData, memoryData, networkData []MetricPoint
-
-		for i := 0; i < 20; i++ {
-			timestamp := now.Add(-time.Duration(20-i) * time.Minute)
-			
-			cpuData = append(cpuData, MetricPoint{
-				Timestamp: timestamp,
-				Value:     45.0 + float64(i)*2.5,
-				Service:   service,
-			})
-			
-			memoryData = append(memoryData, MetricPoint{
-				Timestamp: timestamp,
-				Value:     60.0 + float64(i)*1.5,
-				Service:   service,
-			})
-			
-			networkData = append(networkData, MetricPoint{
-				Timestamp: timestamp,
-				Value:     100.0 + float64(i)*5.0,
-				Service:   service,
-			})
+		namespace, ok := namespaceParam(c, k8sClient)
+		if !ok {
+			return
+		}
+		from, to, ok := timeRangeParams(c, time.Hour)
+		if !ok {
+			return
+		}
+		step, ok := stepParam(c, to.Sub(from))
+		if !ok {
+			return
+		}
+
+		scope := readScope(c)
+		current := currentMetrics(metricsCollector, namespace, scope)
+
+		selector := map[string]string{"deployment": service}
+		if namespace != "" {
+			selector["namespace"] = namespace
+		}
+
+		charts := make([][]MetricPoint, len(metricSeries))
+		for i, names := range metricSeries {
+			var results []metrics.SeriesRange
+			switch {
+			case service != "":
+				results, step = metricsCollector.QueryRange(names.deployment, selector, from, to, step)
+				results = readableSeries(results, scope)
+			case scope.All:
+				results, step = metricsCollector.QueryRange(names.cluster, nil, from, to, step)
+				results = unlabelled(results)
+			}
+			charts[i] = sumSeries(results, service)
 		}
 
 		response := MetricsResponse{
-			CPU:     cpuData,
-			Memory:  memoryData,
-			Network: networkData,
-			Anomalies: []Anomaly{
-				{
-					ID:        "1",
-					Type:      "spike",
-					Service:   "backend-api",
-					Severity:  "warning",
-					Message:   "CPU spike detected: 95% for 3 minutes",
-					Timestamp: now.Add(-15 * time.Minute),
-				},
-			},
+			Namespace: namespace,
+			Service:   service,
+			From:      from,
+			To:        to,
+			Step:      int64(step / time.Second),
+			Current:   current,
+			CPU:       charts[0],
+			Memory:    charts[1],
+			Network:   charts[2],
+			Anomalies: []models.Anomaly{},
+		}
+
+		// Anomalies detected within the charted window
+		anomalies, err := store.ListAnomalies(c.Request.Context(), storage.AnomalyFilter{From: from, To: to, Limit: 50})
+		if err == nil {
+			response.Anomalies = filterAnomalies(anomalies, k8sClient, scope, namespace, service)
 		}
 
 		c.JSON(http.StatusOK, response)
 	}
 }
 
-func GetMetricsHistory(metricsCollector *metrics.Collector) gin.HandlerFunc {
+// currentMetrics returns the latest metrics of namespace, or of the cluster
+// when it is empty. The cluster-wide gauges need a cluster-wide scope.
+func currentMetrics(metricsCollector *metrics.Collector, namespace string, scope rbac.Scope) map[string]float64 {
+	switch {
+	case scope.All && namespace == "":
+		return metricsCollector.GetCurrentMetrics()
+	case scope.All:
+		return metricsCollector.GetNamespaceMetrics(namespace)
+	case namespace == "":
+		return map[string]float64{}
+	}
+	return metricsCollector.GetNamespaceCounters(namespace)
+}
+
+// stepParam reads the optional "step" query parameter, a Go duration ("5m")
+// or a number of seconds. Without it the step is chosen so window yields about
+// defaultMetricPoints points.
+func stepParam(c *gin.Context, window time.Duration) (time.Duration, bool) {
+	value := c.Query("step")
+	if value == "" {
+		step := window / defaultMetricPoints
+		if step < collectionInterval {
+			step = collectionInterval
+		}
+		return step.Truncate(time.Second), true
+	}
+
+	step, err := time.ParseDuration(value)
+	if seconds, convErr := strconv.ParseInt(value, 10, 64); convErr == nil {
+		step, err = time.Duration(seconds)*time.Second, nil
+	}
+	if err != nil || step < time.Second {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'step' parameter: must be a duration of at least 1s"})
+		return 0, false
+	}
+	if window/step > maxMetricPoints {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "'step' is too small for the requested range (at most " + strconv.Itoa(maxMetricPoints) + " points per series)",
+		})
+		return 0, false
+	}
+	return step, true
+}
+
+// unlabelled keeps only cluster-wide series.
+func unlabelled(results []metrics.SeriesRange) []metrics.SeriesRange {
+	var kept []metrics.SeriesRange
+	for _, result := range results {
+		if len(result.Labels) == 0 {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+// readableSeries keeps the series whose namespace label is in scope. Series
+// without one are cluster-level.
+func readableSeries(results []metrics.SeriesRange, scope rbac.Scope) []metrics.SeriesRange {
+	var kept []metrics.SeriesRange
+	for _, result := range results {
+		if scope.Allows(result.Labels["namespace"]) {
+			kept = append(kept, result)
+		}
+	}
+	return kept
+}
+
+// readableSamples keeps the samples whose namespace label is in scope.
+func readableSamples(samples []models.Sample, scope rbac.Scope) []models.Sample {
+	var kept []models.Sample
+	for _, sample := range samples {
+		if scope.Allows(sample.Labels["namespace"]) {
+			kept = append(kept, sample)
+		}
+	}
+	return kept
+}
+
+// sumSeries adds the series bucket by bucket, e.g. a deployment running in
+// several namespaces. Min, max and p95 are summed too, so for more than one
+// series they bound rather than equal the true values.
+func sumSeries(results []metrics.SeriesRange, service string) []MetricPoint {
+	byTime := make(map[time.Time]*MetricPoint)
+	for _, result := range results {
+		for _, agg := range result.Points {
+			point, ok := byTime[agg.Timestamp]
+			if !ok {
+				point = &MetricPoint{Timestamp: agg.Timestamp, Service: service}
+				byTime[agg.Timestamp] = point
+			}
+			point.Value += agg.Avg
+			point.Min += agg.Min
+			point.Max += agg.Max
+			point.P95 += agg.P95
+		}
+	}
+
+	points := make([]MetricPoint, 0, len(byTime))
+	for _, point := range byTime {
+		points = append(points, *point)
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Timestamp.Before(points[j].Timestamp) })
+	return points
+}
+
+type PodUsage struct {
+	Namespace   string  `json:"namespace"`
+	Pod         string  `json:"pod"`
+	Deployment  string  `json:"deployment,omitempty"`
+	CPUCores    float64 `json:"cpuCores"`
+	MemoryBytes float64 `json:"memoryBytes"`
+}
+
+type DeploymentUsage struct {
+	Namespace   string  `json:"namespace"`
+	Deployment  string  `json:"deployment"`
+	CPUCores    float64 `json:"cpuCores"`
+	MemoryBytes float64 `json:"memoryBytes"`
+}
+
+type NodeUsage struct {
+	Node              string  `json:"node"`
+	CPUCores          float64 `json:"cpuCores"`
+	MemoryBytes       float64 `json:"memoryBytes"`
+	CPUUtilization    float64 `json:"cpuUtilization,omitempty"`
+	MemoryUtilization float64 `json:"memoryUtilization,omitempty"`
+}
+
+// seriesLabelParams are the query parameters accepted as label selectors on
+// per-object series.
+var seriesLabelParams = []string{"namespace", "deployment", "pod", "node"}
+
+// GetMetricsHistory returns stored snapshots, or, when ?series=<name> is
+// given, min/max/avg/p95 buckets of every matching series from the
+// time-series store. The namespace, deployment, pod and node parameters
+// narrow the series by label, and step sets the bucket width. Both are
+// filtered to the caller's read scope: outside a cluster-wide scope,
+// snapshots keep only the readable namespaces' metrics.
+func GetMetricsHistory(k8sClient *k8s.Client, metricsCollector *metrics.Collector) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		from, to, ok := timeRangeParams(c, time.Hour)
+		if !ok {
+			return
+		}
+		if _, ok := namespaceParam(c, k8sClient); !ok {
+			return
+		}
+		scope := readScope(c)
+
+		if name := c.Query("series"); name != "" {
+			step, ok := stepParam(c, to.Sub(from))
+			if !ok {
+				return
+			}
+			selector := make(map[string]string)
+			for _, param := range seriesLabelParams {
+				if value := c.Query(param); value != "" {
+					selector[param] = value
+				}
+			}
+
+			series, step := metricsCollector.QueryRange(name, selector, from, to, step)
+			c.JSON(http.StatusOK, gin.H{
+				"from":   from,
+				"to":     to,
+				"step":   int64(step / time.Second),
+				"series": readableSeries(series, scope),
+			})
+			return
+		}
+
+		history, err := metricsCollector.GetHistory(c.Request.Context(), from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load metrics history"})
+			return
+		}
+
+		if !scope.All {
+			history = readableSnapshots(history, scope)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":    from,
+			"to":      to,
+			"history": history,
+		})
+	}
+}
+
+// readableSnapshots strips the cluster-wide metrics from snapshots and keeps
+// only the namespaces and series in scope.
+func readableSnapshots(history []models.MetricSnapshot, scope rbac.Scope) []models.MetricSnapshot {
+	readable := make([]models.MetricSnapshot, 0, len(history))
+	for _, snapshot := range history {
+		namespaces := make(map[string]map[string]float64)
+		for namespace, metrics := range snapshot.Namespaces {
+			if scope.Allows(namespace) {
+				namespaces[namespace] = metrics
+			}
+		}
+		readable = append(readable, models.MetricSnapshot{
+			Timestamp:  snapshot.Timestamp,
+			Source:     snapshot.Source,
+			Metrics:    map[string]float64{},
+			Namespaces: namespaces,
+			Series:     readableSamples(snapshot.Series, scope),
+		})
+	}
+	return readable
+}
+
+// timeRangeParams reads the optional "from" and "to" query parameters, given
+// as RFC 3339 timestamps or Unix seconds. "to" defaults to now and "from" to
+// window before "to".
+func timeRangeParams(c *gin.Context, window time.Duration) (time.Time, time.Time, bool) {
+	to := time.Now()
+	if value := c.Query("to"); value != "" {
+		parsed, err := parseTimeParam(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'to' parameter: " + err.Error()})
+			return time.Time{}, time.Time{}, false
+		}
+		to = parsed
+	}
+
+	from := to.Add(-window)
+	if value := c.Query("from"); value != "" {
+		parsed, err := parseTimeParam(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid 'from' parameter: " + err.Error()})
+			return time.Time{}, time.Time{}, false
+		}
+		from = parsed
+	}
+
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "'from' must be before 'to'"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+func parseTimeParam(value string) (time.Time, error) {
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// GetResourceUsage returns the latest per-pod, per-deployment and per-node
+// CPU and memory usage collected from metrics-server. Pods and deployments
+// are filtered to the caller's read scope; nodes need a cluster-wide scope.
+func GetResourceUsage(k8sClient *k8s.Client, metricsCollector *metrics.Collector) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Return historical metrics
+		namespace, ok := namespaceParam(c, k8sClient)
+		if !ok {
+			return
+		}
+		selector := map[string]string{}
+		if namespace != "" {
+			selector["namespace"] = namespace
+		}
+		scope := readScope(c)
+		current := func(name string, selector map[string]string) []models.Sample {
+			return readableSamples(metricsCollector.GetCurrentSeries(name, selector), scope)
+		}
+
+		pods := make(map[string]*PodUsage)
+		podOrder := []string{}
+		podEntry := func(sample models.Sample) *PodUsage {
+			key := sample.Labels["namespace"] + "/" + sample.Labels["pod"]
+			if entry, ok := pods[key]; ok {
+				return entry
+			}
+			entry := &PodUsage{
+				Namespace:  sample.Labels["namespace"],
+				Pod:        sample.Labels["pod"],
+				Deployment: sample.Labels["deployment"],
+			}
+			pods[key] = entry
+			podOrder = append(podOrder, key)
+			return entry
+		}
+		for _, sample := range current("pod_cpu_usage", selector) {
+			podEntry(sample).CPUCores = sample.Value
+		}
+		for _, sample := range current("pod_memory_usage", selector) {
+			podEntry(sample).MemoryBytes = sample.Value
+		}
+
+		deployments := make(map[string]*DeploymentUsage)
+		deploymentOrder := []string{}
+		deploymentEntry := func(sample models.Sample) *DeploymentUsage {
+			key := sample.Labels["namespace"] + "/" + sample.Labels["deployment"]
+			if entry, ok := deployments[key]; ok {
+				return entry
+			}
+			entry := &DeploymentUsage{
+				Namespace:  sample.Labels["namespace"],
+				Deployment: sample.Labels["deployment"],
+			}
+			deployments[key] = entry
+			deploymentOrder = append(deploymentOrder, key)
+			return entry
+		}
+		for _, sample := range current("deployment_cpu_usage", selector) {
+			deploymentEntry(sample).CPUCores = sample.Value
+		}
+		for _, sample := range current("deployment_memory_usage", selector) {
+			deploymentEntry(sample).MemoryBytes = sample.Value
+		}
+
+		nodes := make(map[string]*NodeUsage)
+		nodeOrder := []string{}
+		nodeEntry := func(sample models.Sample) *NodeUsage {
+			name := sample.Labels["node"]
+			if entry, ok := nodes[name]; ok {
+				return entry
+			}
+			entry := &NodeUsage{Node: name}
+			nodes[name] = entry
+			nodeOrder = append(nodeOrder, name)
+			return entry
+		}
+		for _, sample := range current("node_cpu_usage", nil) {
+			nodeEntry(sample).CPUCores = sample.Value
+		}
+		for _, sample := range current("node_memory_usage", nil) {
+			nodeEntry(sample).MemoryBytes = sample.Value
+		}
+		for _, sample := range current("node_cpu_utilization", nil) {
+			nodeEntry(sample).CPUUtilization = sample.Value
+		}
+		for _, sample := range current("node_memory_utilization", nil) {
+			nodeEntry(sample).MemoryUtilization = sample.Value
+		}
+
+		podList := make([]PodUsage, 0, len(podOrder))
+		for _, key := range podOrder {
+			podList = append(podList, *pods[key])
+		}
+		deploymentList := make([]DeploymentUsage, 0, len(deploymentOrder))
+		for _, key := range deploymentOrder {
+			deploymentList = append(deploymentList, *deployments[key])
+		}
+		nodeList := make([]NodeUsage, 0, len(nodeOrder))
+		for _, name := range nodeOrder {
+			nodeList = append(nodeList, *nodes[name])
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"history": metricsCollector.GetHistory(),
+			"pods":        podList,
+			"deployments": deploymentList,
+			"nodes":       nodeList,
 		})
 	}
 }
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/k8s"
+	"orchestrator/internal/rbac"
+)
+
+// namespaceParam reads the optional "namespace" query parameter and rejects
+// namespaces excluded by the orchestrator's configuration or that the caller
+// may not read. An empty value means "all allowed namespaces", which handlers
+// narrow to the caller's readScope.
+func namespaceParam(c *gin.Context, k8sClient *k8s.Client) (string, bool) {
+	namespace := c.Query("namespace")
+	if namespace == "" {
+		return "", true
+	}
+	if k8sClient != nil && !k8sClient.NamespaceAllowed(namespace) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Namespace not allowed: " + namespace,
+		})
+		return "", false
+	}
+	return namespace, authorize(c, rbac.PermRead, namespace)
+}
+
+// readScope returns where the caller may read. Results spanning namespaces
+// are filtered to it; cluster-level data, such as cluster-wide metrics, nodes
+// and predictions, needs a cluster-wide scope.
+func readScope(c *gin.Context) rbac.Scope {
+	return rbac.ScopeFor(c.Request.Context(), rbac.PermRead)
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"orchestrator/internal/auth"
+	"orchestrator/internal/k8s"
+	"orchestrator/internal/rbac"
+)
+
+func TestInfrastructureNamespaces(t *testing.T) {
+	var objects []runtime.Object
+	for _, namespace := range []string{"default", "payments", "kube-system"} {
+		objects = append(objects,
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}},
+			&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: namespace + "-pod"}},
+		)
+	}
+	k8sClient := k8s.NewClientFromClientset(fake.NewSimpleClientset(objects...), k8s.NamespaceFilter{Exclude: []string{"kube-system"}})
+
+	policy, err := rbac.New(rbac.Config{})
+	if err != nil {
+		t.Fatalf("rbac.New() error = %v", err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		identity := &auth.Identity{Subject: "anonymous", Method: auth.MethodNone}
+		c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+	})
+	router.GET("/infrastructure", rbac.Require(policy, rbac.PermRead), GetInfrastructure(k8sClient))
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+		wantPods   []string
+	}{
+		{name: "every allowed namespace", wantStatus: http.StatusOK, wantPods: []string{"default-pod", "payments-pod"}},
+		{name: "one namespace", query: "?namespace=payments", wantStatus: http.StatusOK, wantPods: []string{"payments-pod"}},
+		{name: "excluded namespace", query: "?namespace=kube-system", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/infrastructure"+tt.query, nil))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				Resources []InfrastructureResource `json:"resources"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			pods := []string{}
+			for _, resource := range body.Resources {
+				if resource.Type == "pod" {
+					pods = append(pods, resource.Name)
+				}
+			}
+			sort.Strings(pods)
+			if !reflect.DeepEqual(pods, tt.wantPods) {
+				t.Errorf("pods = %v, want %v", pods, tt.wantPods)
+			}
+		})
+	}
+}
@@ -2,26 +2,40 @@ package handlers
 
 import (
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	corev1 "k8s.io/api/core/v1"
 
+	"orchestrator/internal/aiengine"
 	"orchestrator/internal/k8s"
 	"orchestrator/internal/metrics"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
 )
 
 type OverviewResponse struct {
 	Status         StatusCards         `json:"status"`
 	RecentActions  []RecentAction      `json:"recentActions"`
-	Predictions    []Prediction        `json:"predictions"`
+	Predictions    []models.Prediction `json:"predictions"`
 	CurrentMetrics map[string]float64  `json:"currentMetrics"`
 }
 
 type StatusCards struct {
-	Clusters  int     `json:"clusters"`
-	Pods      int     `json:"pods"`
-	MonthlyCost float64 `json:"monthlyCost"`
-	Storage   string  `json:"storage"`
+	Clusters    int               `json:"clusters"`
+	Pods        int               `json:"pods"`
+	MonthlyCost float64           `json:"monthlyCost"`
+	Storage     string            `json:"storage"`
+	Namespaces  []NamespaceStatus `json:"namespaces"`
+}
+
+type NamespaceStatus struct {
+	Name        string `json:"name"`
+	Pods        int    `json:"pods"`
+	RunningPods int    `json:"runningPods"`
+	Deployments int    `json:"deployments"`
 }
 
 type RecentAction struct {
@@ -33,27 +47,44 @@ type RecentAction struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
-type Prediction struct {
-	ID         string  `json:"id"`
-	Type       string  `json:"type"`
-	Message    string  `json:"message"`
-	Confidence float64 `json:"confidence"`
-	TimeWindow string  `json:"timeWindow"`
-}
-
-func GetOverview(k8sClient *k8s.Client, metricsCollector *metrics.Collector) gin.HandlerFunc {
+func GetOverview(k8sClient *k8s.Client, metricsCollector *metrics.Collector, store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get current pod count
+		namespace, ok := namespaceParam(c, k8sClient)
+		if !ok {
+			return
+		}
+
+		scope := readScope(c)
+
+		// Get current pod count with a per-namespace breakdown
 		podCount := 0
+		namespaces := []NamespaceStatus{}
 		if k8sClient != nil {
-			pods, err := k8sClient.GetPods("default")
-			if err == nil {
-				podCount = len(pods)
-			}
+			namespaces, podCount = namespaceBreakdown(k8sClient, namespace, scope)
+		}
+
+		// Predictions are cluster-wide
+		predictions := []models.Prediction{}
+		if scope.All {
+			predictions = latestPredictions(c, store)
 		}
 
-		// Get current metrics
-		currentMetrics := metricsCollector.GetCurrentMetrics()
+		// Most recent entries from the action log, for those who may read it
+		recentActions := []RecentAction{}
+		if rbac.ScopeFor(c.Request.Context(), rbac.PermAdmin).All {
+			if entries, err := store.ListLogs(c.Request.Context(), storage.LogFilter{Limit: 5}); err == nil {
+				for _, entry := range entries {
+					recentActions = append(recentActions, RecentAction{
+						ID:        entry.ID,
+						Type:      entry.Type,
+						Target:    entry.Target,
+						Action:    entry.Action,
+						Status:    entry.Status,
+						Timestamp: entry.Timestamp,
+					})
+				}
+			}
+		}
 
 		response := OverviewResponse{
 			Status: StatusCards{
@@ -61,54 +92,94 @@ func GetOverview(k8sClient *k8s.Client, metricsCollector *metrics.Collector) gin
 				Pods:        podCount,
 				MonthlyCost: 234.56,
 				Storage:     "845 GB / 2 TB",
+				Namespaces:  namespaces,
 			},
-			RecentActions: []RecentAction{
-				{
-					ID:        "1",
-					Type:      "scale",
-					Target:    "frontend-deployment",
-					Action:    "Scaled replicas from 2 to 4",
-					Status:    "completed",
-					Timestamp: time.Now().Add(-10 * time.Minute),
-				},
-				{
-					ID:        "2",
-					Type:      "heal",
-					Target:    "backend-pod-xyz",
-					Action:    "Restarted unhealthy pod",
-					Status:    "completed",
-					Timestamp: time.Now().Add(-25 * time.Minute),
-				},
-			},
-			Predictions: []Prediction{
-				{
-					ID:         "1",
-					Type:       "traffic",
-					Message:    "Traffic surge expected in 45 minutes",
-					Confidence: 0.89,
-					TimeWindow: "45m",
-				},
-				{
-					ID:         "2",
-					Type:       "cost",
-					Message:    "Optimize GPU node usage to save $120/mo",
-					Confidence: 0.95,
-					TimeWindow: "monthly",
-				},
-			},
-			CurrentMetrics: currentMetrics,
+			RecentActions:  recentActions,
+			Predictions:    predictions,
+			CurrentMetrics: currentMetrics(metricsCollector, namespace, scope),
 		}
 
 		c.JSON(http.StatusOK, response)
 	}
 }
 
-func GetStatus(k8sClient *k8s.Client) gin.HandlerFunc {
+// latestPredictions returns the newest unexpired AI engine prediction of each
+// type.
+func latestPredictions(c *gin.Context, store storage.Store) []models.Prediction {
+	latest := []models.Prediction{}
+	now := time.Now()
+	predictions, err := store.ListPredictions(c.Request.Context(), storage.PredictionFilter{Since: now.Add(-24 * time.Hour)})
+	if err != nil {
+		return latest
+	}
+
+	seen := make(map[string]bool)
+	for _, prediction := range predictions {
+		if seen[prediction.Type] || prediction.ExpiresAt.Before(now) {
+			continue
+		}
+		seen[prediction.Type] = true
+		// The forecast points are available from /api/v1/predictions
+		prediction.Points = nil
+		latest = append(latest, prediction)
+	}
+	return latest
+}
+
+// namespaceBreakdown counts pods and deployments per namespace within scope.
+// It returns the breakdown sorted by namespace name along with the total pod
+// count.
+func namespaceBreakdown(k8sClient *k8s.Client, namespace string, scope rbac.Scope) ([]NamespaceStatus, int) {
+	byName := make(map[string]*NamespaceStatus)
+	entry := func(name string) *NamespaceStatus {
+		if status, ok := byName[name]; ok {
+			return status
+		}
+		status := &NamespaceStatus{Name: name}
+		byName[name] = status
+		return status
+	}
+
+	podCount := 0
+	if pods, err := k8sClient.GetPods(namespace); err == nil {
+		for _, pod := range pods {
+			if !scope.Allows(pod.Namespace) {
+				continue
+			}
+			podCount++
+			status := entry(pod.Namespace)
+			status.Pods++
+			if pod.Status.Phase == corev1.PodRunning {
+				status.RunningPods++
+			}
+		}
+	}
+
+	if deployments, err := k8sClient.GetDeployments(namespace); err == nil {
+		for _, dep := range deployments {
+			if scope.Allows(dep.Namespace) {
+				entry(dep.Namespace).Deployments++
+			}
+		}
+	}
+
+	breakdown := make([]NamespaceStatus, 0, len(byName))
+	for _, status := range byName {
+		breakdown = append(breakdown, *status)
+	}
+	sort.Slice(breakdown, func(i, j int) bool {
+		return breakdown[i].Name < breakdown[j].Name
+	})
+	return breakdown, podCount
+}
+
+func GetStatus(k8sClient *k8s.Client, aiClient *aiengine.Client) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		status := gin.H{
-			"healthy":     true,
+			"healthy":      true,
 			"k8sConnected": k8sClient != nil,
-			"timestamp":   time.Now(),
+			"aiEngine":     aiClient.Status(),
+			"timestamp":    time.Now(),
 		}
 
 		c.JSON(http.StatusOK, status)
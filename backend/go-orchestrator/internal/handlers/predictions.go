@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/models"
+	"orchestrator/internal/storage"
+)
+
+// GetPredictions lists stored AI engine predictions with their forecast
+// points, newest first. type narrows to one target (cpu, memory or traffic).
+// Predictions are cluster-wide, so callers who can only read some namespaces
+// get none.
+func GetPredictions(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !readScope(c).All {
+			c.JSON(http.StatusOK, gin.H{
+				"predictions": []models.Prediction{},
+				"total":       0,
+			})
+			return
+		}
+
+		filter := storage.PredictionFilter{
+			Type:  c.Query("type"),
+			Limit: 20,
+		}
+		if limit, err := strconv.Atoi(c.Query("limit")); err == nil && limit > 0 {
+			filter.Limit = limit
+		}
+
+		predictions, err := store.ListPredictions(c.Request.Context(), filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load predictions"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"predictions": predictions,
+			"total":       len(predictions),
+		})
+	}
+}
@@ -1,115 +1,265 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
-	"orchestrator/internal/k8s"
+	"orchestrator/internal/audit"
+	"orchestrator/internal/executor"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
 )
 
-type Recommendation struct {
-	ID          string    `json:"id"`
-	Type        string    `json:"type"`
-	Target      string    `json:"target"`
-	Action      string    `json:"action"`
-	Confidence  float64   `json:"confidence"`
-	Reasoning   string    `json:"reasoning"`
-	Impact      string    `json:"impact"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"createdAt"`
-}
+// GetRecommendations lists the recommendations for the namespaces the caller
+// may read. Informational recommendations, which name no namespace, need a
+// cluster-wide scope.
+func GetRecommendations(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		recommendations, err := store.ListRecommendations(c.Request.Context())
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load recommendations"})
+			return
+		}
+
+		scope := readScope(c)
+		readable := []models.Recommendation{}
+		for _, rec := range recommendations {
+			namespace := ""
+			if rec.Payload != nil {
+				namespace = rec.Payload.Namespace
+			}
+			if scope.Allows(namespace) {
+				readable = append(readable, rec)
+			}
+		}
 
-var recommendations = []Recommendation{
-	{
-		ID:         "1",
-		Type:       "scale",
-		Target:     "frontend-deployment",
-		Action:     "Scale replicas from 2 to 4",
-		Confidence: 0.93,
-		Reasoning:  "Traffic pattern indicates 70% increase in next 30 minutes",
-		Impact:     "Improved response times, $12/day cost increase",
-		Status:     "pending",
-		CreatedAt:  time.Now().Add(-5 * time.Minute),
-	},
-	{
-		ID:         "2",
-		Type:       "optimize",
-		Target:     "gpu-node-pool",
-		Action:     "Switch to spot instances",
-		Confidence: 0.87,
-		Reasoning:  "Workload is fault-tolerant, can save 60% on compute",
-		Impact:     "Save $120/month, possible interruptions",
-		Status:     "pending",
-		CreatedAt:  time.Now().Add(-15 * time.Minute),
-	},
-	{
-		ID:         "3",
-		Type:       "security",
-		Target:     "api-gateway",
-		Action:     "Enable rate limiting",
-		Confidence: 0.95,
-		Reasoning:  "Detected unusual traffic patterns from 3 IPs",
-		Impact:     "Prevent potential DDoS, no cost impact",
-		Status:     "pending",
-		CreatedAt:  time.Now().Add(-30 * time.Minute),
-	},
+		c.JSON(http.StatusOK, gin.H{
+			"recommendations": readable,
+		})
+	}
 }
 
-func GetRecommendations() gin.HandlerFunc {
+func ApplyRecommendation(recExecutor *executor.Executor, store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		rec, ok := loadRecommendation(c, store)
+		if !ok {
+			return
+		}
+
+		if rec.Status != models.RecommendationPending && rec.Status != models.RecommendationFailed {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Recommendation is already " + rec.Status,
+			})
+			return
+		}
+		if rec.Payload == nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "Recommendation is informational and has no executable action",
+			})
+			return
+		}
+		if !authorize(c, rbac.PermOperate, rec.Payload.Namespace) {
+			return
+		}
+		rec.Status = models.RecommendationApplying
+		if !claimRecommendation(c, store, rec, models.RecommendationPending, models.RecommendationFailed) {
+			return
+		}
+
+		// The request may end before the change does; keep going regardless,
+		// so the recommendation is not left applying
+		ctx := context.WithoutCancel(c.Request.Context())
+		event := audit.Begin(ctx, store, rec.Type, rec.Payload.Describe(), rec.Target).
+			Detail("recommendationId", rec.ID)
+		result, err := recExecutor.Execute(ctx, *rec.Payload)
+		if result != nil {
+			rec.Before = result.Before
+			rec.After = result.After
+			event.Before(result.Before).After(result.After)
+		}
+		if err != nil {
+			rec.Status = models.RecommendationFailed
+			rec.Error = err.Error()
+		} else {
+			now := time.Now()
+			rec.Status = models.RecommendationApplied
+			rec.Error = ""
+			rec.AppliedAt = &now
+		}
+
+		if saveErr := store.SaveRecommendation(ctx, rec); saveErr != nil {
+			log.Printf("Failed to save recommendation %s: %v", rec.ID, saveErr)
+		}
+		event.Finish(err)
+
+		if err != nil {
+			c.JSON(executorErrorStatus(err), gin.H{
+				"success":        false,
+				"error":          err.Error(),
+				"recommendation": rec,
+			})
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{
-			"recommendations": recommendations,
+			"success":        true,
+			"message":        "Recommendation applied successfully",
+			"recommendation": rec,
 		})
 	}
 }
 
-func ApplyRecommendation(k8sClient *k8s.Client) gin.HandlerFunc {
+// RollbackRecommendation restores the object an applied recommendation
+// changed to its pre-apply snapshot. It refuses with 409 when the object has
+// been modified since the apply, unless ?force=true is given.
+func RollbackRecommendation(recExecutor *executor.Executor, store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-
-		// Find and apply recommendation
-		for i, rec := range recommendations {
-			if rec.ID == id {
-				// Simulate applying the recommendation
-				if k8sClient != nil && rec.Type == "scale" {
-					// In a real implementation, this would scale the deployment
-					// err := k8sClient.ScaleDeployment(rec.Target, newReplicas)
-				}
-
-				recommendations[i].Status = "applied"
-				c.JSON(http.StatusOK, gin.H{
-					"success": true,
-					"message": "Recommendation applied successfully",
-				})
-				return
+		force := c.Query("force") == "true"
+
+		rec, ok := loadRecommendation(c, store)
+		if !ok {
+			return
+		}
+
+		if rec.Status != models.RecommendationApplied {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Only applied recommendations can be rolled back",
+			})
+			return
+		}
+		if rec.Before == nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "Recommendation has no pre-apply snapshot",
+			})
+			return
+		}
+		if !authorize(c, rbac.PermOperate, rec.Before.Namespace) {
+			return
+		}
+		rec.Status = models.RecommendationRollingBack
+		if !claimRecommendation(c, store, rec, models.RecommendationApplied) {
+			return
+		}
+
+		// The request may end before the change does; keep going regardless,
+		// so the recommendation is not left rolling back
+		ctx := context.WithoutCancel(c.Request.Context())
+		event := audit.Begin(ctx, store, "rollback", "Roll back recommendation", rec.Target).
+			Detail("recommendationId", rec.ID).
+			Detail("force", force)
+		result, err := recExecutor.Rollback(ctx, rec.Before, rec.After, force)
+		if result != nil {
+			event.Before(result.Before).After(result.After)
+		}
+		event.Finish(err)
+
+		if err != nil {
+			// The change is still in place
+			rec.Status = models.RecommendationApplied
+			if saveErr := store.SaveRecommendation(ctx, rec); saveErr != nil {
+				log.Printf("Failed to save recommendation %s: %v", rec.ID, saveErr)
 			}
+			c.JSON(executorErrorStatus(err), gin.H{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
 		}
 
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Recommendation not found",
+		now := time.Now()
+		rec.Status = models.RecommendationRolledBack
+		rec.RolledBackAt = &now
+		if err := store.SaveRecommendation(ctx, rec); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recommendation"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":        true,
+			"message":        "Recommendation rolled back",
+			"recommendation": rec,
 		})
 	}
 }
 
-func RejectRecommendation() gin.HandlerFunc {
+func RejectRecommendation(store storage.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id := c.Param("id")
-
-		for i, rec := range recommendations {
-			if rec.ID == id {
-				recommendations[i].Status = "rejected"
-				c.JSON(http.StatusOK, gin.H{
-					"success": true,
-					"message": "Recommendation rejected",
-				})
-				return
-			}
+		rec, ok := loadRecommendation(c, store)
+		if !ok {
+			return
+		}
+		// Applied changes are undone with rollback; rejecting them would hide
+		// the change and the way back
+		if rec.Status != models.RecommendationPending && rec.Status != models.RecommendationFailed {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Recommendation is already " + rec.Status,
+			})
+			return
+		}
+		if rec.Payload != nil && !authorize(c, rbac.PermOperate, rec.Payload.Namespace) {
+			return
+		}
+
+		previous := rec.Status
+		rec.Status = models.RecommendationRejected
+		if !claimRecommendation(c, store, rec, models.RecommendationPending, models.RecommendationFailed) {
+			return
 		}
+		audit.Begin(c.Request.Context(), store, "reject", "Reject recommendation", rec.Target).
+			Detail("recommendationId", rec.ID).
+			Before(map[string]string{"status": previous}).
+			After(map[string]string{"status": rec.Status}).
+			Finish(nil)
 
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Recommendation rejected",
+		})
+	}
+}
+
+// loadRecommendation fetches the recommendation named by the :id parameter,
+// writing a 404 or 500 response when it cannot.
+func loadRecommendation(c *gin.Context, store storage.Store) (*models.Recommendation, bool) {
+	rec, err := store.GetRecommendation(c.Request.Context(), c.Param("id"))
+	if storage.IsNotFound(err) {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "Recommendation not found",
 		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load recommendation"})
+		return nil, false
+	}
+	return rec, true
+}
+
+// claimRecommendation saves rec's new status provided its stored status is
+// still one of from, so that only one request acts on it. Otherwise it writes
+// a 409 or 500 response.
+func claimRecommendation(c *gin.Context, store storage.Store, rec *models.Recommendation, from ...string) bool {
+	err := store.UpdateRecommendation(c.Request.Context(), rec, from...)
+	if storage.IsConflict(err) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Recommendation was changed by another request"})
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save recommendation"})
+		return false
+	}
+	return true
+}
+
+func executorErrorStatus(err error) int {
+	if errors.Is(err, executor.ErrNoCluster) {
+		return http.StatusServiceUnavailable
 	}
+	return k8sErrorStatus(err)
 }
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/auth"
+	"orchestrator/internal/executor"
+	"orchestrator/internal/models"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
+)
+
+// scopedRouter serves the anomaly and recommendation lists and the action log
+// to the caller named by the X-Subject header. dev may only read the payments
+// namespace, and team-admin is an admin there only.
+func scopedRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	ctx := context.Background()
+	store, err := storage.Open(ctx, storage.Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	now := time.Now()
+	for _, anomaly := range []models.Anomaly{
+		{ID: "payments", Labels: map[string]string{"namespace": "payments"}},
+		{ID: "default", Labels: map[string]string{"namespace": "default"}},
+		{ID: "cluster"},
+	} {
+		anomaly.Status = models.AnomalyStatusActive
+		anomaly.StartedAt = now.Add(-time.Minute)
+		anomaly.Timestamp = now
+		if err := store.SaveAnomaly(ctx, &anomaly); err != nil {
+			t.Fatalf("save anomaly: %v", err)
+		}
+	}
+	for _, rec := range []models.Recommendation{
+		{ID: "payments", Payload: &executor.Action{Namespace: "payments", Kind: "deployment", Name: "api"}},
+		{ID: "default", Payload: &executor.Action{Namespace: "default", Kind: "deployment", Name: "web"}},
+		{ID: "cluster", Informational: true},
+	} {
+		rec.Status = models.RecommendationPending
+		if err := store.SaveRecommendation(ctx, &rec); err != nil {
+			t.Fatalf("save recommendation: %v", err)
+		}
+	}
+
+	if err := store.AppendLog(ctx, &models.LogEntry{ID: "entry", Type: "scale", Status: "completed"}); err != nil {
+		t.Fatalf("append log: %v", err)
+	}
+
+	policy, err := rbac.New(rbac.Config{Bindings: []rbac.Binding{
+		{Role: rbac.RoleAdmin, Subjects: []string{"root"}},
+		{Role: rbac.RoleViewer, Subjects: []string{"dev"}, Namespaces: []string{"payments"}},
+		{Role: rbac.RoleAdmin, Subjects: []string{"team-admin"}, Namespaces: []string{"payments"}},
+	}})
+	if err != nil {
+		t.Fatalf("rbac.New() error = %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		identity := &auth.Identity{Subject: c.GetHeader("X-Subject"), Method: auth.MethodAPIKey}
+		c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+	})
+	canRead := rbac.Require(policy, rbac.PermRead)
+	router.GET("/anomalies", canRead, GetAnomalies(nil, store))
+	router.GET("/recommendations", canRead, GetRecommendations(store))
+	router.GET("/logs", rbac.Require(policy, rbac.PermAdmin), GetLogs(store))
+	return router
+}
+
+func TestReadScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		subject    string
+		path       string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{name: "cluster-wide anomalies", subject: "root", path: "/anomalies", wantStatus: http.StatusOK, wantIDs: []string{"cluster", "default", "payments"}},
+		{name: "anomalies in the bound namespace", subject: "dev", path: "/anomalies", wantStatus: http.StatusOK, wantIDs: []string{"payments"}},
+		{name: "anomalies asked for in the bound namespace", subject: "dev", path: "/anomalies?namespace=payments", wantStatus: http.StatusOK, wantIDs: []string{"payments"}},
+		{name: "anomalies asked for elsewhere", subject: "dev", path: "/anomalies?namespace=default", wantStatus: http.StatusForbidden},
+		{name: "cluster-wide recommendations", subject: "root", path: "/recommendations", wantStatus: http.StatusOK, wantIDs: []string{"cluster", "default", "payments"}},
+		{name: "recommendations in the bound namespace", subject: "dev", path: "/recommendations", wantStatus: http.StatusOK, wantIDs: []string{"payments"}},
+		{name: "action log for a cluster-wide admin", subject: "root", path: "/logs", wantStatus: http.StatusOK, wantIDs: []string{"entry"}},
+		{name: "action log for a namespace admin", subject: "team-admin", path: "/logs", wantStatus: http.StatusForbidden},
+		{name: "action log for a viewer", subject: "dev", path: "/logs", wantStatus: http.StatusForbidden},
+		{name: "unbound caller", subject: "nobody", path: "/anomalies", wantStatus: http.StatusForbidden},
+	}
+	router := scopedRouter(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("X-Subject", tt.subject)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				Anomalies       []models.Anomaly        `json:"anomalies"`
+				Recommendations []models.Recommendation `json:"recommendations"`
+				Logs            []models.LogEntry       `json:"logs"`
+			}
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			ids := []string{}
+			for _, anomaly := range body.Anomalies {
+				ids = append(ids, anomaly.ID)
+			}
+			for _, rec := range body.Recommendations {
+				ids = append(ids, rec.ID)
+			}
+			for _, entry := range body.Logs {
+				ids = append(ids, entry.ID)
+			}
+			sort.Strings(ids)
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("listed %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// FieldManager identifies the orchestrator's changes in server-side apply.
+const FieldManager = "inframind-orchestrator"
+
+// ResourceFor resolves an object's kind to its API resource using discovery.
+// Namespaced objects without a namespace are placed in "default".
+func (c *Client) ResourceFor(obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	if c.mapper == nil {
+		return nil, fmt.Errorf("server-side apply is not available without a REST config")
+	}
+	gvk := obj.GroupVersionKind()
+	mapping, err := c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// The kind may have been installed since discovery was cached
+		c.mapper.Reset()
+		mapping, err = c.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return nil, fmt.Errorf("%s is cluster-scoped; only namespaced resources can be applied", gvk.Kind)
+	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(metav1.NamespaceDefault)
+	}
+	if !c.NamespaceAllowed(obj.GetNamespace()) {
+		return nil, fmt.Errorf("%w: %s", ErrNamespaceNotAllowed, obj.GetNamespace())
+	}
+	return c.dynamic.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+}
+
+// GetObject fetches the live version of obj.
+func (c *Client) GetObject(ctx context.Context, obj *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	resource, err := c.ResourceFor(obj)
+	if err != nil {
+		return nil, err
+	}
+	return resource.Get(ctx, obj.GetName(), metav1.GetOptions{})
+}
+
+// ApplyObject server-side applies obj with strict field validation, so
+// unknown or duplicate fields are rejected rather than silently dropped.
+// Fields owned by another manager are not taken over: the apply fails with a
+// conflict instead. With dryRun the API server runs admission and validation
+// and returns the result without persisting it.
+func (c *Client) ApplyObject(ctx context.Context, obj *unstructured.Unstructured, dryRun bool) (*unstructured.Unstructured, error) {
+	resource, err := c.ResourceFor(obj)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	options := metav1.PatchOptions{
+		FieldManager:    FieldManager,
+		FieldValidation: metav1.FieldValidationStrict,
+	}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	return resource.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, options)
+}
@@ -0,0 +1,202 @@
+package k8s
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+const defaultResyncPeriod = 10 * time.Minute
+
+// Cache keeps shared-informer backed copies of the objects the dashboard
+// reads, so handlers and the metrics collector do not issue a full List
+// against the API server on every request.
+type Cache struct {
+	scopes      []*cacheScope
+	nodeFactory informers.SharedInformerFactory
+	nodes       corelisters.NodeLister
+	synced      []toolscache.InformerSynced
+	ready       atomic.Bool
+}
+
+// cacheScope holds the namespaced listers for a single informer factory. A
+// cluster-wide cache has one scope with an empty namespace; an allow list
+// produces one scope per namespace.
+type cacheScope struct {
+	namespace   string
+	factory     informers.SharedInformerFactory
+	pods        corelisters.PodLister
+	deployments appslisters.DeploymentLister
+	services    corelisters.ServiceLister
+	events      corelisters.EventLister
+}
+
+// ResyncPeriodFromEnv reads K8S_RESYNC_PERIOD (a Go duration such as "5m").
+// Zero disables periodic resyncs.
+func ResyncPeriodFromEnv() time.Duration {
+	value := os.Getenv("K8S_RESYNC_PERIOD")
+	if value == "" {
+		return defaultResyncPeriod
+	}
+	period, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("Invalid K8S_RESYNC_PERIOD %q, using %s", value, defaultResyncPeriod)
+		return defaultResyncPeriod
+	}
+	return period
+}
+
+func newCache(clientset kubernetes.Interface, filter NamespaceFilter, resync time.Duration) *Cache {
+	cache := &Cache{}
+
+	namespaces := []string{metav1.NamespaceAll}
+	if len(filter.Include) > 0 {
+		namespaces = nil
+		for _, ns := range filter.Include {
+			if filter.Allows(ns) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+
+	for _, ns := range namespaces {
+		factory := informers.NewSharedInformerFactoryWithOptions(clientset, resync, informers.WithNamespace(ns))
+		scope := &cacheScope{
+			namespace:   ns,
+			factory:     factory,
+			pods:        factory.Core().V1().Pods().Lister(),
+			deployments: factory.Apps().V1().Deployments().Lister(),
+			services:    factory.Core().V1().Services().Lister(),
+			events:      factory.Core().V1().Events().Lister(),
+		}
+		cache.synced = append(cache.synced,
+			factory.Core().V1().Pods().Informer().HasSynced,
+			factory.Apps().V1().Deployments().Informer().HasSynced,
+			factory.Core().V1().Services().Informer().HasSynced,
+			factory.Core().V1().Events().Informer().HasSynced,
+		)
+		cache.scopes = append(cache.scopes, scope)
+	}
+
+	// Nodes are cluster scoped, so they always come from a cluster-wide factory.
+	cache.nodeFactory = informers.NewSharedInformerFactory(clientset, resync)
+	cache.nodes = cache.nodeFactory.Core().V1().Nodes().Lister()
+	cache.synced = append(cache.synced, cache.nodeFactory.Core().V1().Nodes().Informer().HasSynced)
+
+	return cache
+}
+
+// start runs the informers until ctx is cancelled and flips the readiness
+// flag once every informer has completed its initial list.
+func (c *Cache) start(ctx context.Context) {
+	for _, scope := range c.scopes {
+		scope.factory.Start(ctx.Done())
+	}
+	c.nodeFactory.Start(ctx.Done())
+
+	go func() {
+		if !toolscache.WaitForCacheSync(ctx.Done(), c.synced...) {
+			log.Println("Kubernetes informer cache stopped before it synced")
+			return
+		}
+		c.ready.Store(true)
+		log.Println("Kubernetes informer cache synced")
+	}()
+}
+
+// Ready reports whether the initial sync has completed.
+func (c *Cache) Ready() bool {
+	return c != nil && c.ready.Load()
+}
+
+func (c *Cache) scopesFor(namespace string) []*cacheScope {
+	if namespace == metav1.NamespaceAll {
+		return c.scopes
+	}
+	for _, scope := range c.scopes {
+		if scope.namespace == namespace || scope.namespace == metav1.NamespaceAll {
+			return []*cacheScope{scope}
+		}
+	}
+	return nil
+}
+
+func (c *Cache) listPods(namespace string) ([]corev1.Pod, error) {
+	var items []corev1.Pod
+	for _, scope := range c.scopesFor(namespace) {
+		pods, err := scope.pods.Pods(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			items = append(items, *pod.DeepCopy())
+		}
+	}
+	return items, nil
+}
+
+func (c *Cache) listDeployments(namespace string) ([]v1.Deployment, error) {
+	var items []v1.Deployment
+	for _, scope := range c.scopesFor(namespace) {
+		deployments, err := scope.deployments.Deployments(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range deployments {
+			items = append(items, *dep.DeepCopy())
+		}
+	}
+	return items, nil
+}
+
+func (c *Cache) listServices(namespace string) ([]corev1.Service, error) {
+	var items []corev1.Service
+	for _, scope := range c.scopesFor(namespace) {
+		services, err := scope.services.Services(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, svc := range services {
+			items = append(items, *svc.DeepCopy())
+		}
+	}
+	return items, nil
+}
+
+func (c *Cache) listEvents(namespace string) ([]corev1.Event, error) {
+	var items []corev1.Event
+	for _, scope := range c.scopesFor(namespace) {
+		events, err := scope.events.Events(namespace).List(labels.Everything())
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			items = append(items, *event.DeepCopy())
+		}
+	}
+	return items, nil
+}
+
+func (c *Cache) listNodes() ([]corev1.Node, error) {
+	nodes, err := c.nodes.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	items := make([]corev1.Node, 0, len(nodes))
+	for _, node := range nodes {
+		items = append(items, *node.DeepCopy())
+	}
+	return items, nil
+}
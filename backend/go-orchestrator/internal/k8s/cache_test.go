@@ -0,0 +1,154 @@
+package k8s
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func testPod(namespace, name string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+}
+
+// podLists counts the pod List calls that reached the API server.
+func podLists(clientset *fake.Clientset) int {
+	n := 0
+	for _, action := range clientset.Actions() {
+		if action.Matches("list", "pods") {
+			n++
+		}
+	}
+	return n
+}
+
+// waitForCache waits for client's informer cache to sync.
+func waitForCache(t *testing.T, client *Client) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !client.CacheReady() {
+		if time.Now().After(deadline) {
+			t.Fatal("informer cache did not sync")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func podNames(pods []corev1.Pod) []string {
+	names := []string{}
+	for _, pod := range pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestCacheReadsBeforeAndAfterSync(t *testing.T) {
+	clientset := fake.NewSimpleClientset(testPod("default", "web"))
+	client := NewClientFromClientset(clientset, NamespaceFilter{})
+
+	// Before the cache runs, reads go to the API server
+	if client.CacheReady() {
+		t.Fatal("CacheReady() = true before StartCache")
+	}
+	pods, err := client.GetPods("")
+	if err != nil {
+		t.Fatalf("GetPods() error = %v", err)
+	}
+	if got := podNames(pods); len(got) != 1 || got[0] != "default/web" {
+		t.Errorf("GetPods() before sync = %v, want default/web", got)
+	}
+	if n := podLists(clientset); n != 1 {
+		t.Errorf("%d pod lists before sync, want 1", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.StartCache(ctx)
+	waitForCache(t, client)
+
+	// Once synced, reads are served from the informers
+	clientset.ClearActions()
+	if _, err := client.GetPods(""); err != nil {
+		t.Fatalf("GetPods() error = %v", err)
+	}
+	if _, err := client.GetPods("default"); err != nil {
+		t.Fatalf("GetPods(default) error = %v", err)
+	}
+	if n := podLists(clientset); n != 0 {
+		t.Errorf("%d pod lists after sync, want 0", n)
+	}
+
+	// and follow changes through the watch
+	if _, err := clientset.CoreV1().Pods("default").Create(ctx, testPod("default", "api"), metav1.CreateOptions{}); err != nil {
+		t.Fatalf("create pod: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		pods, _ := client.GetPods("default")
+		if len(pods) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GetPods() = %v, want the created pod to appear", podNames(pods))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCacheNotReadyWhenListFails(t *testing.T) {
+	clientset := fake.NewSimpleClientset()
+	clientset.PrependReactor("list", "pods", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(corev1.Resource("pods"), "", nil)
+	})
+	client := NewClientFromClientset(clientset, NamespaceFilter{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.StartCache(ctx)
+	time.Sleep(200 * time.Millisecond)
+	if client.CacheReady() {
+		t.Error("CacheReady() = true although pods could not be listed")
+	}
+}
+
+func TestCacheNamespaceScopes(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		testPod("payments", "api"),
+		testPod("default", "web"),
+		testPod("kube-system", "dns"),
+	)
+	client := NewClientFromClientset(clientset, NamespaceFilter{Include: []string{"payments", "default"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client.StartCache(ctx)
+	waitForCache(t, client)
+
+	tests := []struct {
+		namespace string
+		want      []string
+		wantErr   bool
+	}{
+		{namespace: "", want: []string{"default/web", "payments/api"}},
+		{namespace: "payments", want: []string{"payments/api"}},
+		{namespace: "kube-system", wantErr: true},
+	}
+	for _, tt := range tests {
+		pods, err := client.GetPods(tt.namespace)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("GetPods(%q) error = %v, wantErr %v", tt.namespace, err, tt.wantErr)
+			continue
+		}
+		if got := podNames(pods); !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetPods(%q) = %v, want %v", tt.namespace, got, tt.want)
+		}
+	}
+}
@@ -9,13 +9,21 @@ import (
 	v1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
 	"k8s.io/client-go/tools/clientcmd"
 )
 
 type Client struct {
-	clientset *kubernetes.Clientset
+	clientset  kubernetes.Interface
+	dynamic    dynamic.Interface
+	mapper     *restmapper.DeferredDiscoveryRESTMapper
+	namespaces NamespaceFilter
+	cache      *Cache
 }
 
 func NewClient() (*Client, error) {
@@ -45,11 +53,186 @@ func NewClient() (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %v", err)
 	}
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %v", err)
+	}
+
+	namespaces := NamespaceFilterFromEnv()
+	return &Client{
+		clientset:  clientset,
+		dynamic:    dynamicClient,
+		mapper:     restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(clientset.Discovery())),
+		namespaces: namespaces,
+		cache:      newCache(clientset, namespaces, ResyncPeriodFromEnv()),
+	}, nil
+}
+
+// NewClientFromClientset wraps an existing clientset, such as a fake one in
+// tests. Server-side apply is unavailable, and reads go to the clientset
+// until StartCache is called.
+func NewClientFromClientset(clientset kubernetes.Interface, namespaces NamespaceFilter) *Client {
+	return &Client{
+		clientset:  clientset,
+		namespaces: namespaces,
+		cache:      newCache(clientset, namespaces, ResyncPeriodFromEnv()),
+	}
+}
+
+// StartCache starts the shared informers backing the read methods. Until the
+// cache has synced, reads fall through to the API server.
+func (c *Client) StartCache(ctx context.Context) {
+	c.cache.start(ctx)
+}
 
-	return &Client{clientset: clientset}, nil
+// CacheReady reports whether the informer cache has completed its initial
+// sync and is serving reads.
+func (c *Client) CacheReady() bool {
+	return c.cache.Ready()
+}
+
+// NamespaceAllowed reports whether the namespace is visible under the
+// configured allow/deny lists.
+func (c *Client) NamespaceAllowed(namespace string) bool {
+	return c.namespaces.Allows(namespace)
+}
+
+// GetNamespaces returns the namespaces the orchestrator inventories. When an
+// explicit allow list is configured it is returned as-is so that the service
+// account does not need cluster-wide list permissions on namespaces.
+func (c *Client) GetNamespaces() ([]string, error) {
+	if len(c.namespaces.Include) > 0 {
+		var names []string
+		for _, ns := range c.namespaces.Include {
+			if c.namespaces.Allows(ns) {
+				names = append(names, ns)
+			}
+		}
+		return names, nil
+	}
+
+	list, err := c.clientset.CoreV1().Namespaces().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, ns := range list.Items {
+		if c.namespaces.Allows(ns.Name) {
+			names = append(names, ns.Name)
+		}
+	}
+	return names, nil
 }
 
+// GetPods lists pods in the given namespace, or in every allowed namespace
+// when namespace is empty.
 func (c *Client) GetPods(namespace string) ([]corev1.Pod, error) {
+	targets, err := c.targetNamespaces(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []corev1.Pod
+	for _, ns := range targets {
+		pods, err := c.listPods(ns)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			if c.namespaces.Allows(pod.Namespace) {
+				items = append(items, pod)
+			}
+		}
+	}
+	return items, nil
+}
+
+// GetDeployments lists deployments in the given namespace, or in every
+// allowed namespace when namespace is empty.
+func (c *Client) GetDeployments(namespace string) ([]v1.Deployment, error) {
+	targets, err := c.targetNamespaces(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []v1.Deployment
+	for _, ns := range targets {
+		deployments, err := c.listDeployments(ns)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range deployments {
+			if c.namespaces.Allows(dep.Namespace) {
+				items = append(items, dep)
+			}
+		}
+	}
+	return items, nil
+}
+
+// GetServices lists services in the given namespace, or in every allowed
+// namespace when namespace is empty.
+func (c *Client) GetServices(namespace string) ([]corev1.Service, error) {
+	targets, err := c.targetNamespaces(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []corev1.Service
+	for _, ns := range targets {
+		services, err := c.listServices(ns)
+		if err != nil {
+			return nil, err
+		}
+		for _, svc := range services {
+			if c.namespaces.Allows(svc.Namespace) {
+				items = append(items, svc)
+			}
+		}
+	}
+	return items, nil
+}
+
+// GetEvents lists events in the given namespace, or in every allowed
+// namespace when namespace is empty.
+func (c *Client) GetEvents(namespace string) ([]corev1.Event, error) {
+	targets, err := c.targetNamespaces(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []corev1.Event
+	for _, ns := range targets {
+		events, err := c.listEvents(ns)
+		if err != nil {
+			return nil, err
+		}
+		for _, event := range events {
+			if c.namespaces.Allows(event.Namespace) {
+				items = append(items, event)
+			}
+		}
+	}
+	return items, nil
+}
+
+// GetNodes lists the cluster's nodes.
+func (c *Client) GetNodes() ([]corev1.Node, error) {
+	if c.cache.Ready() {
+		return c.cache.listNodes()
+	}
+	nodes, err := c.clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return nodes.Items, nil
+}
+
+func (c *Client) listPods(namespace string) ([]corev1.Pod, error) {
+	if c.cache.Ready() {
+		return c.cache.listPods(namespace)
+	}
 	pods, err := c.clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{})
 	if err != nil {
 		return nil, err
@@ -57,7 +240,10 @@ func (c *Client) GetPods(namespace string) ([]corev1.Pod, error) {
 	return pods.Items, nil
 }
 
-func (c *Client) GetDeployments(namespace string) ([]v1.Deployment, error) {
+func (c *Client) listDeployments(namespace string) ([]v1.Deployment, error) {
+	if c.cache.Ready() {
+		return c.cache.listDeployments(namespace)
+	}
 	deployments, err := c.clientset.AppsV1().Deployments(namespace).List(context.Background(), metav1.ListOptions{})
 	if err != nil {
 		return nil, err
@@ -65,17 +251,46 @@ func (c *Client) GetDeployments(namespace string) ([]v1.Deployment, error) {
 	return deployments.Items, nil
 }
 
-func (c *Client) ScaleDeployment(namespace, name string, replicas int32) error {
-	deployment, err := c.clientset.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+func (c *Client) listServices(namespace string) ([]corev1.Service, error) {
+	if c.cache.Ready() {
+		return c.cache.listServices(namespace)
+	}
+	services, err := c.clientset.CoreV1().Services(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return services.Items, nil
+}
+
+func (c *Client) listEvents(namespace string) ([]corev1.Event, error) {
+	if c.cache.Ready() {
+		return c.cache.listEvents(namespace)
+	}
+	events, err := c.clientset.CoreV1().Events(namespace).List(context.Background(), metav1.ListOptions{})
 	if err != nil {
-		return err
+		return nil, err
 	}
+	return events.Items, nil
+}
 
-	deployment.Spec.Replicas = &replicas
-	_, err = c.clientset.AppsV1().Deployments(namespace).Update(context.Background(), deployment, metav1.UpdateOptions{})
-	return err
+// targetNamespaces resolves the namespaces a list call has to visit. An empty
+// namespace with no allow list maps to a single cluster-wide list whose
+// results are filtered afterwards.
+func (c *Client) targetNamespaces(namespace string) ([]string, error) {
+	if namespace != metav1.NamespaceAll {
+		if !c.namespaces.Allows(namespace) {
+			return nil, fmt.Errorf("%w: %s", ErrNamespaceNotAllowed, namespace)
+		}
+		return []string{namespace}, nil
+	}
+	if len(c.namespaces.Include) > 0 {
+		return c.GetNamespaces()
+	}
+	return []string{metav1.NamespaceAll}, nil
 }
 
-func (c *Client) DeletePod(namespace, name string) error {
-	return c.clientset.CoreV1().Pods(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+// DeletePod deletes a pod. When uid is set the deletion only succeeds if the
+// pod still has that UID.
+func (c *Client) DeletePod(namespace, name string, uid types.UID) error {
+	return c.clientset.CoreV1().Pods(namespace).Delete(context.Background(), name, deleteOptionsFor(uid))
 }
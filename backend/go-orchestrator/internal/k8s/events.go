@@ -0,0 +1,77 @@
+package k8s
+
+import (
+	"log"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// Resource event actions.
+const (
+	ResourceAdded   = "added"
+	ResourceUpdated = "updated"
+	ResourceDeleted = "deleted"
+)
+
+// ResourceEvent is a change to a pod or deployment seen by the informer
+// cache. Object is a *corev1.Pod or a *v1.Deployment and must not be
+// modified.
+type ResourceEvent struct {
+	Action string
+	Object metav1.Object
+}
+
+// OnResourceEvent registers fn to be called for every pod and deployment
+// change the informers observe. Objects from the initial list and periodic
+// resyncs are not reported. Handlers run on the informer goroutines, so they
+// should not block.
+func (c *Client) OnResourceEvent(fn func(ResourceEvent)) {
+	c.cache.onResourceEvent(fn)
+}
+
+func (c *Cache) onResourceEvent(fn func(ResourceEvent)) {
+	handler := toolscache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			if object, ok := resourceObject(obj); ok && !isInInitialList {
+				fn(ResourceEvent{Action: ResourceAdded, Object: object})
+			}
+		},
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			before, ok := resourceObject(oldObj)
+			after, ok2 := resourceObject(newObj)
+			if ok && ok2 && before.GetResourceVersion() != after.GetResourceVersion() {
+				fn(ResourceEvent{Action: ResourceUpdated, Object: after})
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+			if object, ok := resourceObject(obj); ok {
+				fn(ResourceEvent{Action: ResourceDeleted, Object: object})
+			}
+		},
+	}
+
+	for _, scope := range c.scopes {
+		if _, err := scope.factory.Core().V1().Pods().Informer().AddEventHandler(handler); err != nil {
+			log.Printf("Failed to watch pods in %q: %v", scope.namespace, err)
+		}
+		if _, err := scope.factory.Apps().V1().Deployments().Informer().AddEventHandler(handler); err != nil {
+			log.Printf("Failed to watch deployments in %q: %v", scope.namespace, err)
+		}
+	}
+}
+
+func resourceObject(obj interface{}) (metav1.Object, bool) {
+	switch typed := obj.(type) {
+	case *corev1.Pod:
+		return typed, true
+	case *v1.Deployment:
+		return typed, true
+	}
+	return nil, false
+}
@@ -0,0 +1,57 @@
+package k8s
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// ErrNamespaceNotAllowed is returned when a caller asks for a namespace that
+// the configured allow/deny lists exclude.
+var ErrNamespaceNotAllowed = errors.New("namespace is not allowed by configuration")
+
+// NamespaceFilter restricts which namespaces the orchestrator inventories.
+// An empty Include list means every namespace in the cluster is considered,
+// minus anything listed in Exclude.
+type NamespaceFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// NamespaceFilterFromEnv reads K8S_NAMESPACES and K8S_EXCLUDED_NAMESPACES as
+// comma-separated lists.
+func NamespaceFilterFromEnv() NamespaceFilter {
+	return NamespaceFilter{
+		Include: splitList(os.Getenv("K8S_NAMESPACES")),
+		Exclude: splitList(os.Getenv("K8S_EXCLUDED_NAMESPACES")),
+	}
+}
+
+// Allows reports whether the namespace passes the filter.
+func (f NamespaceFilter) Allows(namespace string) bool {
+	for _, ns := range f.Exclude {
+		if ns == namespace {
+			return false
+		}
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, ns := range f.Include {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
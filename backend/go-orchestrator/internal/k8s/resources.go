@@ -0,0 +1,145 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PreviousReplicasAnnotation records a deployment's replica count before it was
+// stopped, so that starting it again restores the same capacity.
+const PreviousReplicasAnnotation = "inframind.io/previous-replicas"
+
+// GetDeployment fetches a deployment directly from the API server, bypassing
+// the informer cache, so callers that intend to update it see the latest
+// resourceVersion.
+func (c *Client) GetDeployment(ctx context.Context, namespace, name string) (*v1.Deployment, error) {
+	return c.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// UpdateDeployment writes a deployment back. The object's resourceVersion acts
+// as a precondition, so concurrent edits surface as conflicts.
+func (c *Client) UpdateDeployment(ctx context.Context, deployment *v1.Deployment) (*v1.Deployment, error) {
+	return c.clientset.AppsV1().Deployments(deployment.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+}
+
+// CreateDeployment creates a new deployment.
+func (c *Client) CreateDeployment(ctx context.Context, deployment *v1.Deployment) (*v1.Deployment, error) {
+	return c.clientset.AppsV1().Deployments(deployment.Namespace).Create(ctx, deployment, metav1.CreateOptions{})
+}
+
+// GetPod fetches a pod directly from the API server.
+func (c *Client) GetPod(ctx context.Context, namespace, name string) (*corev1.Pod, error) {
+	return c.clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// FindPodByUID looks up a pod in the allowed namespaces by its UID.
+func (c *Client) FindPodByUID(uid string) (*corev1.Pod, error) {
+	pods, err := c.GetPods(metav1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pods {
+		if string(pods[i].UID) == uid {
+			return &pods[i], nil
+		}
+	}
+	return nil, apierrors.NewNotFound(corev1.Resource("pods"), uid)
+}
+
+// FindDeploymentByUID looks up a deployment in the allowed namespaces by its UID.
+func (c *Client) FindDeploymentByUID(uid string) (*v1.Deployment, error) {
+	deployments, err := c.GetDeployments(metav1.NamespaceAll)
+	if err != nil {
+		return nil, err
+	}
+	for i := range deployments {
+		if string(deployments[i].UID) == uid {
+			return &deployments[i], nil
+		}
+	}
+	return nil, apierrors.NewNotFound(v1.Resource("deployments"), uid)
+}
+
+// StopDeployment scales a deployment to zero replicas and remembers the
+// previous count in an annotation. It returns the previous count.
+func (c *Client) StopDeployment(namespace, name string) (int32, error) {
+	deployment, err := c.clientset.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	previous := replicasOf(deployment)
+	if previous == 0 {
+		return 0, apierrors.NewConflict(v1.Resource("deployments"), name, fmt.Errorf("deployment is already stopped"))
+	}
+
+	if deployment.Annotations == nil {
+		deployment.Annotations = make(map[string]string)
+	}
+	deployment.Annotations[PreviousReplicasAnnotation] = strconv.Itoa(int(previous))
+	zero := int32(0)
+	deployment.Spec.Replicas = &zero
+
+	_, err = c.clientset.AppsV1().Deployments(namespace).Update(context.Background(), deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return previous, nil
+}
+
+// StartDeployment restores the replica count recorded by StopDeployment,
+// falling back to a single replica when no count was recorded. It returns the
+// restored count.
+func (c *Client) StartDeployment(namespace, name string) (int32, error) {
+	deployment, err := c.clientset.AppsV1().Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return 0, err
+	}
+
+	if replicasOf(deployment) > 0 {
+		return 0, apierrors.NewConflict(v1.Resource("deployments"), name, fmt.Errorf("deployment is already running"))
+	}
+
+	replicas := int32(1)
+	if value, ok := deployment.Annotations[PreviousReplicasAnnotation]; ok {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			replicas = int32(parsed)
+		}
+		delete(deployment.Annotations, PreviousReplicasAnnotation)
+	}
+	deployment.Spec.Replicas = &replicas
+
+	_, err = c.clientset.AppsV1().Deployments(namespace).Update(context.Background(), deployment, metav1.UpdateOptions{})
+	if err != nil {
+		return 0, err
+	}
+	return replicas, nil
+}
+
+// DeleteDeployment deletes a deployment, requiring that it still has the given
+// UID so a recreated object with the same name is never removed by mistake.
+func (c *Client) DeleteDeployment(namespace, name string, uid types.UID) error {
+	return c.clientset.AppsV1().Deployments(namespace).Delete(context.Background(), name, deleteOptionsFor(uid))
+}
+
+func deleteOptionsFor(uid types.UID) metav1.DeleteOptions {
+	if uid == "" {
+		return metav1.DeleteOptions{}
+	}
+	return metav1.DeleteOptions{Preconditions: metav1.NewUIDPreconditions(string(uid))}
+}
+
+func replicasOf(deployment *v1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		// The API server defaults an unset replica count to one.
+		return 1
+	}
+	return *deployment.Spec.Replicas
+}
@@ -0,0 +1,163 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const metricsAPIPath = "/apis/metrics.k8s.io/v1beta1"
+
+// PodUsage is a pod's current resource usage as reported by metrics-server,
+// summed over its containers. Deployment is empty for pods not owned by a
+// Deployment.
+type PodUsage struct {
+	Namespace   string    `json:"namespace"`
+	Name        string    `json:"name"`
+	Deployment  string    `json:"deployment,omitempty"`
+	CPUCores    float64   `json:"cpuCores"`
+	MemoryBytes float64   `json:"memoryBytes"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// NodeUsage is a node's current resource usage as reported by metrics-server.
+// The allocatable values come from the node object and are zero if unknown.
+type NodeUsage struct {
+	Name                   string    `json:"name"`
+	CPUCores               float64   `json:"cpuCores"`
+	MemoryBytes            float64   `json:"memoryBytes"`
+	AllocatableCPUCores    float64   `json:"allocatableCpuCores"`
+	AllocatableMemoryBytes float64   `json:"allocatableMemoryBytes"`
+	Timestamp              time.Time `json:"timestamp"`
+}
+
+// The metrics.k8s.io types are decoded locally rather than through
+// k8s.io/metrics to avoid pinning another module to the client-go version.
+type podMetricsList struct {
+	Items []struct {
+		Metadata   metav1.ObjectMeta `json:"metadata"`
+		Timestamp  metav1.Time       `json:"timestamp"`
+		Containers []struct {
+			Name  string              `json:"name"`
+			Usage corev1.ResourceList `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+type nodeMetricsList struct {
+	Items []struct {
+		Metadata  metav1.ObjectMeta   `json:"metadata"`
+		Timestamp metav1.Time         `json:"timestamp"`
+		Usage     corev1.ResourceList `json:"usage"`
+	} `json:"items"`
+}
+
+// GetPodUsage reads PodMetrics for the given namespace, or for every allowed
+// namespace when namespace is empty.
+func (c *Client) GetPodUsage(ctx context.Context, namespace string) ([]PodUsage, error) {
+	targets, err := c.targetNamespaces(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map pods to their owning deployment via the ReplicaSet owner reference.
+	deployments := make(map[string]string)
+	if pods, err := c.GetPods(namespace); err == nil {
+		for _, pod := range pods {
+			if name := deploymentOf(&pod); name != "" {
+				deployments[pod.Namespace+"/"+pod.Name] = name
+			}
+		}
+	}
+
+	var usage []PodUsage
+	for _, ns := range targets {
+		path := metricsAPIPath + "/pods"
+		if ns != metav1.NamespaceAll {
+			path = metricsAPIPath + "/namespaces/" + ns + "/pods"
+		}
+
+		raw, err := c.clientset.CoreV1().RESTClient().Get().AbsPath(path).DoRaw(ctx)
+		if err != nil {
+			return nil, err
+		}
+		var list podMetricsList
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+
+		for _, item := range list.Items {
+			if !c.namespaces.Allows(item.Metadata.Namespace) {
+				continue
+			}
+			entry := PodUsage{
+				Namespace:  item.Metadata.Namespace,
+				Name:       item.Metadata.Name,
+				Deployment: deployments[item.Metadata.Namespace+"/"+item.Metadata.Name],
+				Timestamp:  item.Timestamp.Time,
+			}
+			for _, container := range item.Containers {
+				entry.CPUCores += quantityValue(container.Usage, corev1.ResourceCPU)
+				entry.MemoryBytes += quantityValue(container.Usage, corev1.ResourceMemory)
+			}
+			usage = append(usage, entry)
+		}
+	}
+	return usage, nil
+}
+
+// GetNodeUsage reads NodeMetrics for every node.
+func (c *Client) GetNodeUsage(ctx context.Context) ([]NodeUsage, error) {
+	raw, err := c.clientset.CoreV1().RESTClient().Get().AbsPath(metricsAPIPath + "/nodes").DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list nodeMetricsList
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+
+	allocatable := make(map[string]corev1.ResourceList)
+	if nodes, err := c.GetNodes(); err == nil {
+		for _, node := range nodes {
+			allocatable[node.Name] = node.Status.Allocatable
+		}
+	}
+
+	usage := make([]NodeUsage, 0, len(list.Items))
+	for _, item := range list.Items {
+		usage = append(usage, NodeUsage{
+			Name:                   item.Metadata.Name,
+			CPUCores:               quantityValue(item.Usage, corev1.ResourceCPU),
+			MemoryBytes:            quantityValue(item.Usage, corev1.ResourceMemory),
+			AllocatableCPUCores:    quantityValue(allocatable[item.Metadata.Name], corev1.ResourceCPU),
+			AllocatableMemoryBytes: quantityValue(allocatable[item.Metadata.Name], corev1.ResourceMemory),
+			Timestamp:              item.Timestamp.Time,
+		})
+	}
+	return usage, nil
+}
+
+// deploymentOf derives the owning deployment's name from the pod's
+// ReplicaSet owner, whose name is "<deployment>-<pod-template-hash>".
+func deploymentOf(pod *corev1.Pod) string {
+	hash := pod.Labels["pod-template-hash"]
+	for _, owner := range pod.OwnerReferences {
+		if owner.Kind == "ReplicaSet" && hash != "" && strings.HasSuffix(owner.Name, "-"+hash) {
+			return strings.TrimSuffix(owner.Name, "-"+hash)
+		}
+	}
+	return ""
+}
+
+func quantityValue(list corev1.ResourceList, name corev1.ResourceName) float64 {
+	quantity, ok := list[name]
+	if !ok {
+		return 0
+	}
+	return quantity.AsApproximateFloat64()
+}
@@ -0,0 +1,183 @@
+package k8s
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// The fake clientset has no REST client for the metrics API, so these tests
+// run a real one against canned API server responses.
+const (
+	podList = `{"kind":"PodList","apiVersion":"v1","items":[
+		{"metadata":{"namespace":"default","name":"web-7d9f8-x2x4z","labels":{"pod-template-hash":"7d9f8"},
+			"ownerReferences":[{"apiVersion":"apps/v1","kind":"ReplicaSet","name":"web-7d9f8","uid":"rs-1"}]}},
+		{"metadata":{"namespace":"default","name":"debug"}}
+	]}`
+	podMetrics = `{"kind":"PodMetricsList","apiVersion":"metrics.k8s.io/v1beta1","items":[
+		{"metadata":{"namespace":"default","name":"web-7d9f8-x2x4z"},"timestamp":"2026-10-16T12:00:00Z","window":"30s","containers":[
+			{"name":"app","usage":{"cpu":"1","memory":"64Mi"}},
+			{"name":"proxy","usage":{"cpu":"500m","memory":"32Mi"}}
+		]},
+		{"metadata":{"namespace":"default","name":"debug"},"timestamp":"2026-10-16T12:00:00Z","window":"30s","containers":[
+			{"name":"shell","usage":{"cpu":"0","memory":"1Mi"}}
+		]},
+		{"metadata":{"namespace":"kube-system","name":"coredns-1"},"timestamp":"2026-10-16T12:00:00Z","window":"30s","containers":[
+			{"name":"coredns","usage":{"cpu":"100m","memory":"20Mi"}}
+		]}
+	]}`
+	nodeList = `{"kind":"NodeList","apiVersion":"v1","items":[
+		{"metadata":{"name":"node-1"},"status":{"allocatable":{"cpu":"4","memory":"8Gi"}}}
+	]}`
+	nodeMetrics = `{"kind":"NodeMetricsList","apiVersion":"metrics.k8s.io/v1beta1","items":[
+		{"metadata":{"name":"node-1"},"timestamp":"2026-10-16T12:00:00Z","window":"30s","usage":{"cpu":"1500m","memory":"2Gi"}},
+		{"metadata":{"name":"node-2"},"timestamp":"2026-10-16T12:00:00Z","window":"30s","usage":{"cpu":"250m","memory":"512Mi"}}
+	]}`
+)
+
+// apiServer answers GET requests for the paths in responses and records
+// the paths requested.
+func apiServer(t *testing.T, responses map[string]string) (*kubernetes.Clientset, func() []string) {
+	t.Helper()
+	var (
+		mu        sync.Mutex
+		requested []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+
+	clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatalf("create clientset: %v", err)
+	}
+	return clientset, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		paths := append([]string(nil), requested...)
+		sort.Strings(paths)
+		return paths
+	}
+}
+
+func TestGetPodUsage(t *testing.T) {
+	sampled := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	web := PodUsage{Namespace: "default", Name: "web-7d9f8-x2x4z", Deployment: "web", CPUCores: 1.5, MemoryBytes: 96 << 20, Timestamp: sampled}
+	debug := PodUsage{Namespace: "default", Name: "debug", MemoryBytes: 1 << 20, Timestamp: sampled}
+
+	tests := []struct {
+		name      string
+		filter    NamespaceFilter
+		namespace string
+		responses map[string]string
+		want      []PodUsage
+		wantPaths []string
+		wantErr   bool
+	}{
+		{
+			name:      "all namespaces",
+			filter:    NamespaceFilter{Exclude: []string{"kube-system"}},
+			responses: map[string]string{"/api/v1/pods": podList, metricsAPIPath + "/pods": podMetrics},
+			want:      []PodUsage{web, debug},
+			wantPaths: []string{"/api/v1/pods", metricsAPIPath + "/pods"},
+		},
+		{
+			name:      "excluded namespace in the response",
+			filter:    NamespaceFilter{Exclude: []string{"kube-system"}},
+			namespace: "default",
+			responses: map[string]string{"/api/v1/namespaces/default/pods": podList, metricsAPIPath + "/namespaces/default/pods": podMetrics},
+			want:      []PodUsage{web, debug},
+			wantPaths: []string{"/api/v1/namespaces/default/pods", metricsAPIPath + "/namespaces/default/pods"},
+		},
+		{
+			name:      "included namespaces",
+			filter:    NamespaceFilter{Include: []string{"default"}},
+			responses: map[string]string{"/api/v1/namespaces/default/pods": podList, metricsAPIPath + "/namespaces/default/pods": podMetrics},
+			want:      []PodUsage{web, debug},
+			wantPaths: []string{"/api/v1/namespaces/default/pods", metricsAPIPath + "/namespaces/default/pods"},
+		},
+		{
+			name:      "pods unavailable",
+			responses: map[string]string{metricsAPIPath + "/pods": podMetrics},
+			want: []PodUsage{
+				{Namespace: "default", Name: "web-7d9f8-x2x4z", CPUCores: 1.5, MemoryBytes: 96 << 20, Timestamp: sampled},
+				debug,
+				{Namespace: "kube-system", Name: "coredns-1", CPUCores: 0.1, MemoryBytes: 20 << 20, Timestamp: sampled},
+			},
+			wantPaths: []string{"/api/v1/pods", metricsAPIPath + "/pods"},
+		},
+		{
+			name:      "metrics-server missing",
+			responses: map[string]string{"/api/v1/pods": podList},
+			wantErr:   true,
+		},
+		{
+			name:      "malformed metrics",
+			responses: map[string]string{"/api/v1/pods": podList, metricsAPIPath + "/pods": `{"items":[{"containers":[{"usage":{"cpu":"lots"}}]}]}`},
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset, requested := apiServer(t, tt.responses)
+			client := NewClientFromClientset(clientset, tt.filter)
+
+			usage, err := client.GetPodUsage(context.Background(), tt.namespace)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetPodUsage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			for i := range usage {
+				usage[i].Timestamp = usage[i].Timestamp.UTC()
+			}
+			if !reflect.DeepEqual(usage, tt.want) {
+				t.Errorf("GetPodUsage() = %+v, want %+v", usage, tt.want)
+			}
+			if paths := requested(); !reflect.DeepEqual(paths, tt.wantPaths) {
+				t.Errorf("requested %v, want %v", paths, tt.wantPaths)
+			}
+		})
+	}
+}
+
+func TestGetNodeUsage(t *testing.T) {
+	clientset, _ := apiServer(t, map[string]string{"/api/v1/nodes": nodeList, metricsAPIPath + "/nodes": nodeMetrics})
+	client := NewClientFromClientset(clientset, NamespaceFilter{})
+
+	usage, err := client.GetNodeUsage(context.Background())
+	if err != nil {
+		t.Fatalf("GetNodeUsage() error = %v", err)
+	}
+	sampled := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	want := []NodeUsage{
+		{Name: "node-1", CPUCores: 1.5, MemoryBytes: 2 << 30, AllocatableCPUCores: 4, AllocatableMemoryBytes: 8 << 30, Timestamp: sampled},
+		// A node missing from the node list has no allocatable values
+		{Name: "node-2", CPUCores: 0.25, MemoryBytes: 512 << 20, Timestamp: sampled},
+	}
+	for i := range usage {
+		usage[i].Timestamp = usage[i].Timestamp.UTC()
+	}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("GetNodeUsage() = %+v, want %+v", usage, want)
+	}
+}
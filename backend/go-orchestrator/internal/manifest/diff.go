@@ -0,0 +1,119 @@
+package manifest
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Change is one field that differs between the live object and the result of
+// applying the manifest. From is unset for added fields and To for removed
+// ones.
+type Change struct {
+	Path string      `json:"path"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// ignoredMetadata are server-maintained fields that change on every write.
+var ignoredMetadata = []string{"managedFields", "resourceVersion", "generation", "uid", "creationTimestamp", "selfLink"}
+
+// ignoredAnnotations are set by controllers and kubectl rather than by users.
+var ignoredAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+}
+
+// Diff compares two versions of an object, ignoring status and
+// server-maintained metadata, and returns the changed fields sorted by path.
+func Diff(live, desired map[string]interface{}) []Change {
+	var changes []Change
+	diffValue("", normalise(live), normalise(desired), &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+func normalise(obj map[string]interface{}) map[string]interface{} {
+	if obj == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		if key != "status" {
+			copied[key] = value
+		}
+	}
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		cleaned := make(map[string]interface{}, len(metadata))
+		for key, value := range metadata {
+			cleaned[key] = value
+		}
+		for _, key := range ignoredMetadata {
+			delete(cleaned, key)
+		}
+		if annotations, ok := cleaned["annotations"].(map[string]interface{}); ok {
+			kept := make(map[string]interface{}, len(annotations))
+			for key, value := range annotations {
+				kept[key] = value
+			}
+			for _, key := range ignoredAnnotations {
+				delete(kept, key)
+			}
+			if len(kept) == 0 {
+				delete(cleaned, "annotations")
+			} else {
+				cleaned["annotations"] = kept
+			}
+		}
+		copied["metadata"] = cleaned
+	}
+	return copied
+}
+
+func diffValue(path string, from, to interface{}, changes *[]Change) {
+	switch fromTyped := from.(type) {
+	case map[string]interface{}:
+		if toTyped, ok := to.(map[string]interface{}); ok {
+			keys := make(map[string]bool)
+			for key := range fromTyped {
+				keys[key] = true
+			}
+			for key := range toTyped {
+				keys[key] = true
+			}
+			for key := range keys {
+				diffValue(joinPath(path, key), fromTyped[key], toTyped[key], changes)
+			}
+			return
+		}
+	case []interface{}:
+		if toTyped, ok := to.([]interface{}); ok {
+			for i := 0; i < len(fromTyped) || i < len(toTyped); i++ {
+				var a, b interface{}
+				if i < len(fromTyped) {
+					a = fromTyped[i]
+				}
+				if i < len(toTyped) {
+					b = toTyped[i]
+				}
+				diffValue(fmt.Sprintf("%s[%d]", path, i), a, b, changes)
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(from, to) {
+		*changes = append(*changes, Change{Path: path, From: from, To: to})
+	}
+}
+
+func joinPath(path, key string) string {
+	if strings.ContainsAny(key, "./") {
+		key = "[" + key + "]"
+		return path + key
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,80 @@
+// Package manifest validates Kubernetes manifests against the cluster with a
+// server-side dry-run before they are applied.
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// Parse splits a multi-document YAML or JSON manifest into objects. Empty
+// documents are skipped and List kinds are expanded into their items. Every
+// object must have an apiVersion, a kind and a name; the returned errors
+// name the offending document.
+func Parse(content string) ([]*unstructured.Unstructured, []string) {
+	var (
+		objects  []*unstructured.Unstructured
+		problems []string
+	)
+
+	decoder := yaml.NewYAMLOrJSONDecoder(strings.NewReader(content), 4096)
+	for doc := 1; ; doc++ {
+		var raw map[string]interface{}
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("document %d: %v", doc, err))
+			// The decoder cannot resynchronise after a syntax error
+			break
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{Object: raw}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("document %d: %v", doc, err))
+				continue
+			}
+			for i := range list.Items {
+				if problem := checkObject(&list.Items[i]); problem != "" {
+					problems = append(problems, fmt.Sprintf("document %d, item %d: %s", doc, i+1, problem))
+					continue
+				}
+				objects = append(objects, &list.Items[i])
+			}
+			continue
+		}
+		if problem := checkObject(obj); problem != "" {
+			problems = append(problems, fmt.Sprintf("document %d: %s", doc, problem))
+			continue
+		}
+		objects = append(objects, obj)
+	}
+
+	if len(objects) == 0 && len(problems) == 0 {
+		problems = append(problems, "manifest contains no objects")
+	}
+	return objects, problems
+}
+
+func checkObject(obj *unstructured.Unstructured) string {
+	switch {
+	case obj.GetAPIVersion() == "":
+		return "apiVersion is required"
+	case obj.GetKind() == "":
+		return "kind is required"
+	case obj.GetName() == "":
+		return "metadata.name is required"
+	}
+	return ""
+}
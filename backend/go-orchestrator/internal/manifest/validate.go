@@ -0,0 +1,214 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"orchestrator/internal/k8s"
+)
+
+// Object operations.
+const (
+	OperationCreate    = "create"
+	OperationUpdate    = "update"
+	OperationUnchanged = "unchanged"
+)
+
+var (
+	// ErrUnavailable is returned when there is no cluster to validate against.
+	ErrUnavailable = errors.New("manifest validation unavailable: kubernetes client not available")
+	// ErrInvalid is returned by Apply when the manifest fails validation.
+	ErrInvalid = errors.New("manifest is invalid")
+)
+
+// ObjectRef identifies an object in a manifest.
+type ObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+}
+
+func (r ObjectRef) String() string {
+	if r.Namespace == "" {
+		return r.Kind + " " + r.Name
+	}
+	return fmt.Sprintf("%s %s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// ObjectResult is the outcome of validating or applying one object. Changes
+// lists what an update would alter; creates have none.
+type ObjectResult struct {
+	Ref       ObjectRef `json:"ref"`
+	Operation string    `json:"operation,omitempty"`
+	Changes   []Change  `json:"changes,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Result is the outcome of validating or applying a manifest. Errors holds
+// problems with the manifest as a whole, such as YAML syntax errors.
+type Result struct {
+	Valid   bool           `json:"valid"`
+	Errors  []string       `json:"errors,omitempty"`
+	Objects []ObjectResult `json:"objects,omitempty"`
+}
+
+// Validator checks manifests against the cluster's API schemas.
+type Validator struct {
+	k8sClient *k8s.Client
+}
+
+// New creates a validator. k8sClient may be nil, in which case manifests are
+// only parsed and Validate returns ErrUnavailable.
+func New(k8sClient *k8s.Client) *Validator {
+	return &Validator{k8sClient: k8sClient}
+}
+
+// Validate parses content and server-side dry-runs every object, computing
+// the diff against its live version. When allow is set it is called with
+// every object once its namespace is resolved, and objects it refuses are
+// reported without being looked up. The error is only set when validation
+// could not run at all; problems with the manifest are reported in the result.
+func (v *Validator) Validate(ctx context.Context, content string, allow func(ObjectRef) error) (*Result, error) {
+	result, _, _ := v.validate(ctx, content, allow)
+	if v.k8sClient == nil && len(result.Errors) == 0 {
+		return result, ErrUnavailable
+	}
+	return result, nil
+}
+
+// Apply validates content and, if it is valid, applies each object in order.
+// It stops at the first object the API server rejects. When allow is set it
+// is called with every object, once namespaces are resolved, and nothing is
+// applied unless it accepts them all.
+func (v *Validator) Apply(ctx context.Context, content string, allow func(ObjectRef) error) (*Result, error) {
+	result, objects, refused := v.validate(ctx, content, allow)
+	if v.k8sClient == nil && len(result.Errors) == 0 {
+		return result, ErrUnavailable
+	}
+	if refused != nil {
+		return result, refused
+	}
+	if !result.Valid {
+		return result, ErrInvalid
+	}
+
+	for i, obj := range objects {
+		if _, err := v.k8sClient.ApplyObject(ctx, obj, false); err != nil {
+			result.Valid = false
+			result.Objects[i].Error = err.Error()
+			return result, fmt.Errorf("failed to apply %s: %w", result.Objects[i].Ref, err)
+		}
+	}
+	return result, nil
+}
+
+// validate dry-runs every object allow accepts. It also returns the first
+// error from allow.
+func (v *Validator) validate(ctx context.Context, content string, allow func(ObjectRef) error) (*Result, []*unstructured.Unstructured, error) {
+	objects, problems := Parse(content)
+	result := &Result{Errors: problems}
+	if len(problems) > 0 || v.k8sClient == nil {
+		return result, objects, nil
+	}
+
+	var refused error
+	result.Valid = true
+	for _, obj := range objects {
+		outcome, err := v.dryRun(ctx, obj, allow)
+		if outcome.Error != "" {
+			result.Valid = false
+		}
+		if refused == nil {
+			refused = err
+		}
+		result.Objects = append(result.Objects, outcome)
+	}
+	return result, objects, refused
+}
+
+// dryRun validates one object, returning the error from allow if it refused
+// the object.
+func (v *Validator) dryRun(ctx context.Context, obj *unstructured.Unstructured, allow func(ObjectRef) error) (ObjectResult, error) {
+	outcome := ObjectResult{Ref: refOf(obj)}
+
+	// Resolving the resource places the object in its namespace without
+	// reading anything from it
+	_, err := v.k8sClient.ResourceFor(obj)
+	outcome.Ref = refOf(obj)
+	if err != nil {
+		outcome.Error = err.Error()
+		return outcome, nil
+	}
+	if allow != nil {
+		if err := allow(outcome.Ref); err != nil {
+			outcome.Error = err.Error()
+			return outcome, err
+		}
+	}
+
+	live, err := v.k8sClient.GetObject(ctx, obj)
+	if err != nil && !apierrors.IsNotFound(err) {
+		outcome.Error = err.Error()
+		return outcome, nil
+	}
+
+	applied, err := v.k8sClient.ApplyObject(ctx, obj, true)
+	if err != nil {
+		outcome.Error = err.Error()
+		return outcome, nil
+	}
+
+	if live == nil {
+		outcome.Operation = OperationCreate
+		return outcome, nil
+	}
+	outcome.Changes = Diff(live.Object, applied.Object)
+	outcome.Operation = OperationUpdate
+	if len(outcome.Changes) == 0 {
+		outcome.Operation = OperationUnchanged
+	}
+	return outcome, nil
+}
+
+// privilegedGroups are API groups whose objects control access to the
+// cluster. Most of their kinds are cluster-scoped and rejected anyway.
+var privilegedGroups = map[string]bool{
+	"rbac.authorization.k8s.io":    true,
+	"admissionregistration.k8s.io": true,
+	"apiextensions.k8s.io":         true,
+	"certificates.k8s.io":          true,
+}
+
+// privilegedCoreKinds are core kinds that hold or mint credentials.
+var privilegedCoreKinds = map[string]bool{
+	"Secret":         true,
+	"ServiceAccount": true,
+}
+
+// Privileged reports whether applying the object can grant access or expose
+// credentials, and so needs more than permission to change workloads.
+func (r ObjectRef) Privileged() bool {
+	gv, err := schema.ParseGroupVersion(r.APIVersion)
+	if err != nil {
+		return true
+	}
+	if gv.Group == "" {
+		return privilegedCoreKinds[r.Kind]
+	}
+	return privilegedGroups[gv.Group]
+}
+
+func refOf(obj *unstructured.Unstructured) ObjectRef {
+	return ObjectRef{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+}
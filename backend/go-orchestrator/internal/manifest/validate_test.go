@@ -0,0 +1,30 @@
+package manifest
+
+import "testing"
+
+func TestObjectRefPrivileged(t *testing.T) {
+	tests := []struct {
+		name string
+		ref  ObjectRef
+		want bool
+	}{
+		{"deployment", ObjectRef{APIVersion: "apps/v1", Kind: "Deployment"}, false},
+		{"configmap", ObjectRef{APIVersion: "v1", Kind: "ConfigMap"}, false},
+		{"service", ObjectRef{APIVersion: "v1", Kind: "Service"}, false},
+		{"secret", ObjectRef{APIVersion: "v1", Kind: "Secret"}, true},
+		{"service account", ObjectRef{APIVersion: "v1", Kind: "ServiceAccount"}, true},
+		{"role", ObjectRef{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"}, true},
+		{"role binding", ObjectRef{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"}, true},
+		{"cluster role binding", ObjectRef{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"}, true},
+		{"webhook", ObjectRef{APIVersion: "admissionregistration.k8s.io/v1", Kind: "ValidatingWebhookConfiguration"}, true},
+		{"custom resource definition", ObjectRef{APIVersion: "apiextensions.k8s.io/v1", Kind: "CustomResourceDefinition"}, true},
+		{"unparseable api version", ObjectRef{APIVersion: "a/b/c", Kind: "Deployment"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ref.Privileged(); got != tt.want {
+				t.Errorf("Privileged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
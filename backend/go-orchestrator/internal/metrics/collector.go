@@ -1,71 +1,302 @@
 package metrics
 
 import (
+	"context"
+	"log"
+	"os"
 	"sync"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+
 	"orchestrator/internal/k8s"
+	"orchestrator/internal/models"
+	"orchestrator/internal/storage"
 )
 
+const pruneInterval = time.Hour
+
 type Collector struct {
-	mu             sync.RWMutex
-	currentMetrics map[string]float64
-	history        []MetricSnapshot
+	mu               sync.RWMutex
+	store            storage.Store
+	source           Source
+	fallback         Source
+	extra            []Source
+	tsdb             *TSDB
+	lastPrune        time.Time
+	currentMetrics   map[string]float64
+	namespaceMetrics map[string]map[string]float64
+	currentSeries    []models.Sample
+	subscribers      []func(context.Context, models.MetricSnapshot)
 }
 
-type MetricSnapshot struct {
-	Timestamp time.Time              `json:"timestamp"`
-	Metrics   map[string]float64     `json:"metrics"`
+// NewCollector creates a collector that reads gauges from source, switching to
+// fallback (which may be nil) whenever source fails, and persists every
+// snapshot to store. The latest stored snapshot is loaded so current metrics
+// are available before the first collection tick, and the time-series store
+// is rebuilt from stored rollups and raw history.
+func NewCollector(store storage.Store, source, fallback Source) *Collector {
+	c := &Collector{
+		store:            store,
+		source:           source,
+		fallback:         fallback,
+		tsdb:             NewTSDB(TiersFromEnv()),
+		currentMetrics:   make(map[string]float64),
+		namespaceMetrics: make(map[string]map[string]float64),
+	}
+	c.backfill(context.Background())
+
+	if latest, err := store.LatestMetricSnapshot(context.Background()); err == nil {
+		c.currentMetrics = latest.Metrics
+		c.currentSeries = latest.Series
+		if latest.Namespaces != nil {
+			c.namespaceMetrics = latest.Namespaces
+		}
+	} else if !storage.IsNotFound(err) {
+		log.Printf("Failed to load latest metric snapshot: %v", err)
+	}
+	return c
 }
 
-func NewCollector() *Collector {
-	return &Collector{
-		currentMetrics: make(map[string]float64),
-		history:        make([]MetricSnapshot, 0),
+// TiersFromEnv reads the retention of each TSDB tier: raw samples (and the
+// stored snapshots they come from) from METRICS_HISTORY_RETENTION, 5-minute
+// rollups from METRICS_ROLLUP_5M_RETENTION and hourly rollups from
+// METRICS_ROLLUP_1H_RETENTION. Raw retention is never shorter than the
+// widest rollup bucket.
+func TiersFromEnv() []Tier {
+	tiers := make([]Tier, len(DefaultTiers))
+	copy(tiers, DefaultTiers)
+	for i, name := range []string{"METRICS_HISTORY_RETENTION", "METRICS_ROLLUP_5M_RETENTION", "METRICS_ROLLUP_1H_RETENTION"} {
+		tiers[i].Retention = retentionFromEnv(name, tiers[i].Retention)
 	}
+	if widest := tiers[len(tiers)-1].Resolution; tiers[0].Retention < widest {
+		log.Printf("METRICS_HISTORY_RETENTION is shorter than %s, using %s", widest, widest)
+		tiers[0].Retention = widest
+	}
+	return tiers
 }
 
-func (c *Collector) StartCollection(k8sClient *k8s.Client) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+func retentionFromEnv(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention <= 0 {
+		log.Printf("Invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return retention
+}
+
+// backfill loads each rollup tier from the store and replays raw history
+// through the TSDB. Buckets that closed while the orchestrator was down are
+// rolled up and persisted.
+func (c *Collector) backfill(ctx context.Context) {
+	now := time.Now()
+	tiers := c.tsdb.Tiers()
+	for _, tier := range tiers[1:] {
+		rollups, err := c.store.ListRollups(ctx, tier.Resolution, now.Add(-tier.Retention), now)
+		if err != nil {
+			log.Printf("Failed to load %s metric rollups: %v", tier.Resolution, err)
+			continue
+		}
+		c.tsdb.LoadRollups(rollups)
+	}
 
-	for range ticker.C {
-		c.collectMetrics(k8sClient)
+	snapshots, err := c.store.ListMetricSnapshots(ctx, now.Add(-tiers[0].Retention), now)
+	if err != nil {
+		log.Printf("Failed to load metric history: %v", err)
+		return
+	}
+	var closed []models.Rollup
+	for _, snapshot := range snapshots {
+		closed = append(closed, c.tsdb.Append(snapshot.Timestamp, snapshotSamples(snapshot))...)
+	}
+	if err := c.store.AppendRollups(ctx, closed); err != nil {
+		log.Printf("Failed to store metric rollups: %v", err)
 	}
 }
 
-func (c *Collector) collectMetrics(k8sClient *k8s.Client) {
+// snapshotSamples flattens a snapshot's cluster-wide gauges and labelled
+// series into TSDB samples.
+func snapshotSamples(snapshot models.MetricSnapshot) []models.Sample {
+	samples := make([]models.Sample, 0, len(snapshot.Metrics)+len(snapshot.Series))
+	for name, value := range snapshot.Metrics {
+		samples = append(samples, models.Sample{Name: name, Value: value})
+	}
+	return append(samples, snapshot.Series...)
+}
+
+// AddSource registers a supplementary source, such as metrics-server, whose
+// samples are collected alongside the primary source on every tick. Samples
+// that duplicate a series from the primary source are dropped.
+func (c *Collector) AddSource(source Source) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.extra = append(c.extra, source)
+}
 
-	// Collect metrics from Kubernetes
+// OnSnapshot registers fn to be called with every collected snapshot, after
+// it has been stored. Subscribers run on the collection goroutine, so they
+// should not block.
+func (c *Collector) OnSnapshot(fn func(context.Context, models.MetricSnapshot)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribers = append(c.subscribers, fn)
+}
+
+// StartCollection collects a snapshot immediately and then every 30 seconds
+// until ctx is cancelled. k8sClient may be nil.
+func (c *Collector) StartCollection(ctx context.Context, k8sClient *k8s.Client) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		c.collectMetrics(ctx, k8sClient)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Collector) collectMetrics(ctx context.Context, k8sClient *k8s.Client) {
 	metrics := make(map[string]float64)
-	
-	// Mock metrics (in production, query from Prometheus)
-	metrics["cpu_usage"] = 45.0 + float64(time.Now().Unix()%20)
-	metrics["memory_usage"] = 60.0 + float64(time.Now().Unix()%15)
-	metrics["network_throughput"] = 100.0 + float64(time.Now().Unix()%50)
-	
+	var series []models.Sample
+
+	// Gauges from the configured source, or the fallback if it fails
+	sourceName, samples := c.collectSamples(ctx)
+	seen := make(map[string]bool)
+	for _, sample := range samples {
+		if len(sample.Labels) == 0 {
+			metrics[sample.Name] = sample.Value
+		} else {
+			series = append(series, sample)
+		}
+		seen[SeriesKey(sample.Name, sample.Labels)] = true
+	}
+
+	// Supplementary sources; partial results are still recorded
+	c.mu.RLock()
+	extra := c.extra
+	c.mu.RUnlock()
+	for _, source := range extra {
+		samples, err := source.Collect(ctx)
+		if err != nil {
+			log.Printf("Metrics source %s failed: %v", source.Name(), err)
+		}
+		for _, sample := range samples {
+			key := SeriesKey(sample.Name, sample.Labels)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if len(sample.Labels) == 0 {
+				metrics[sample.Name] = sample.Value
+			} else {
+				series = append(series, sample)
+			}
+		}
+	}
+
+	// Inventory counts from Kubernetes
+	namespaces := make(map[string]map[string]float64)
 	if k8sClient != nil {
-		pods, err := k8sClient.GetPods("default")
+		pods, err := k8sClient.GetPods("")
 		if err == nil {
 			metrics["pod_count"] = float64(len(pods))
+			for _, pod := range pods {
+				ns := namespaceEntry(namespaces, pod.Namespace)
+				ns["pod_count"]++
+				if pod.Status.Phase == corev1.PodRunning {
+					ns["running_pods"]++
+				}
+			}
+		}
+
+		deployments, err := k8sClient.GetDeployments("")
+		if err == nil {
+			metrics["deployment_count"] = float64(len(deployments))
+			for _, dep := range deployments {
+				namespaceEntry(namespaces, dep.Namespace)["deployment_count"]++
+			}
 		}
 	}
 
+	c.mu.Lock()
 	c.currentMetrics = metrics
+	c.namespaceMetrics = namespaces
+	c.currentSeries = series
+	c.mu.Unlock()
 
 	// Store in history
-	snapshot := MetricSnapshot{
-		Timestamp: time.Now(),
-		Metrics:   metrics,
+	snapshot := models.MetricSnapshot{
+		Timestamp:  time.Now(),
+		Source:     sourceName,
+		Metrics:    metrics,
+		Namespaces: namespaces,
+		Series:     series,
+	}
+	if err := c.store.AppendMetricSnapshot(ctx, snapshot); err != nil {
+		log.Printf("Failed to store metric snapshot: %v", err)
+	}
+	closed := c.tsdb.Append(snapshot.Timestamp, snapshotSamples(snapshot))
+	if err := c.store.AppendRollups(ctx, closed); err != nil {
+		log.Printf("Failed to store metric rollups: %v", err)
+	}
+
+	c.mu.RLock()
+	subscribers := c.subscribers
+	c.mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(ctx, snapshot)
+	}
+
+	// Drop stored history older than each tier's retention window
+	if time.Since(c.lastPrune) >= pruneInterval {
+		c.lastPrune = time.Now()
+		tiers := c.tsdb.Tiers()
+		if _, err := c.store.DeleteMetricSnapshotsBefore(ctx, time.Now().Add(-tiers[0].Retention)); err != nil {
+			log.Printf("Failed to prune metric history: %v", err)
+		}
+		for _, tier := range tiers[1:] {
+			if _, err := c.store.DeleteRollupsBefore(ctx, tier.Resolution, time.Now().Add(-tier.Retention)); err != nil {
+				log.Printf("Failed to prune %s metric rollups: %v", tier.Resolution, err)
+			}
+		}
+	}
+}
+
+// collectSamples queries the primary source and falls back when it errors.
+// It returns the name of the source that produced the samples.
+func (c *Collector) collectSamples(ctx context.Context) (string, []models.Sample) {
+	samples, err := c.source.Collect(ctx)
+	if err == nil {
+		return c.source.Name(), samples
 	}
-	c.history = append(c.history, snapshot)
+	log.Printf("Metrics source %s failed: %v", c.source.Name(), err)
 
-	// Keep only last 100 snapshots
-	if len(c.history) > 100 {
-		c.history = c.history[1:]
+	if c.fallback == nil {
+		return c.source.Name(), nil
+	}
+	samples, err = c.fallback.Collect(ctx)
+	if err != nil {
+		log.Printf("Fallback metrics source %s failed: %v", c.fallback.Name(), err)
+		return c.fallback.Name(), nil
 	}
+	return c.fallback.Name(), samples
+}
+
+func namespaceEntry(namespaces map[string]map[string]float64, name string) map[string]float64 {
+	entry, ok := namespaces[name]
+	if !ok {
+		entry = make(map[string]float64)
+		namespaces[name] = entry
+	}
+	return entry
 }
 
 func (c *Collector) GetCurrentMetrics() map[string]float64 {
@@ -79,11 +310,55 @@ func (c *Collector) GetCurrentMetrics() map[string]float64 {
 	return metrics
 }
 
-func (c *Collector) GetHistory() []MetricSnapshot {
+// GetNamespaceMetrics returns the latest per-namespace counters for a single
+// namespace. Cluster-wide gauges such as cpu_usage are included so callers
+// always get a complete view.
+func (c *Collector) GetNamespaceMetrics(namespace string) map[string]float64 {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	history := make([]MetricSnapshot, len(c.history))
-	copy(history, c.history)
-	return history
+	metrics := make(map[string]float64)
+	for k, v := range c.currentMetrics {
+		metrics[k] = v
+	}
+	c.namespaceCounters(namespace, metrics)
+	return metrics
+}
+
+// GetNamespaceCounters returns the latest per-namespace counters for a single
+// namespace without the cluster-wide gauges.
+func (c *Collector) GetNamespaceCounters(namespace string) map[string]float64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	metrics := make(map[string]float64)
+	c.namespaceCounters(namespace, metrics)
+	return metrics
+}
+
+func (c *Collector) namespaceCounters(namespace string, metrics map[string]float64) {
+	ns := c.namespaceMetrics[namespace]
+	for _, key := range []string{"pod_count", "running_pods", "deployment_count"} {
+		metrics[key] = ns[key]
+	}
+}
+
+// GetCurrentSeries returns the latest labelled samples named name (all names
+// when empty) whose labels match selector.
+func (c *Collector) GetCurrentSeries(name string, selector map[string]string) []models.Sample {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return FilterSamples(c.currentSeries, name, selector)
+}
+
+// QueryRange returns the matching series between from and to aggregated into
+// step-wide buckets, and the step actually used. See TSDB.Query.
+func (c *Collector) QueryRange(name string, selector map[string]string, from, to time.Time, step time.Duration) ([]SeriesRange, time.Duration) {
+	return c.tsdb.Query(name, selector, from, to, step)
+}
+
+// GetHistory returns the stored snapshots between from and to, oldest first.
+func (c *Collector) GetHistory(ctx context.Context, from, to time.Time) ([]models.MetricSnapshot, error) {
+	return c.store.ListMetricSnapshots(ctx, from, to)
 }
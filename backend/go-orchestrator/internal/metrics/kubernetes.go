@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+
+	"orchestrator/internal/k8s"
+	"orchestrator/internal/models"
+)
+
+// KubernetesUsageSource reads per-pod and per-node usage from metrics-server
+// (metrics.k8s.io). CPU is reported in cores and memory in bytes; pod usage
+// is also summed per deployment.
+type KubernetesUsageSource struct {
+	k8sClient *k8s.Client
+}
+
+func NewKubernetesUsageSource(k8sClient *k8s.Client) *KubernetesUsageSource {
+	return &KubernetesUsageSource{k8sClient: k8sClient}
+}
+
+func (k *KubernetesUsageSource) Name() string {
+	return "metrics-server"
+}
+
+func (k *KubernetesUsageSource) Collect(ctx context.Context) ([]models.Sample, error) {
+	var samples []models.Sample
+
+	pods, podErr := k.k8sClient.GetPodUsage(ctx, "")
+	type deploymentKey struct{ namespace, name string }
+	deploymentCPU := make(map[deploymentKey]float64)
+	deploymentMemory := make(map[deploymentKey]float64)
+	for _, pod := range pods {
+		labels := map[string]string{"namespace": pod.Namespace, "pod": pod.Name}
+		if pod.Deployment != "" {
+			labels["deployment"] = pod.Deployment
+			key := deploymentKey{pod.Namespace, pod.Deployment}
+			deploymentCPU[key] += pod.CPUCores
+			deploymentMemory[key] += pod.MemoryBytes
+		}
+		samples = append(samples,
+			models.Sample{Name: "pod_cpu_usage", Labels: labels, Value: pod.CPUCores},
+			models.Sample{Name: "pod_memory_usage", Labels: labels, Value: pod.MemoryBytes},
+		)
+	}
+	for key, cpu := range deploymentCPU {
+		labels := map[string]string{"namespace": key.namespace, "deployment": key.name}
+		samples = append(samples,
+			models.Sample{Name: "deployment_cpu_usage", Labels: labels, Value: cpu},
+			models.Sample{Name: "deployment_memory_usage", Labels: labels, Value: deploymentMemory[key]},
+		)
+	}
+
+	nodes, nodeErr := k.k8sClient.GetNodeUsage(ctx)
+	for _, node := range nodes {
+		labels := map[string]string{"node": node.Name}
+		samples = append(samples,
+			models.Sample{Name: "node_cpu_usage", Labels: labels, Value: node.CPUCores},
+			models.Sample{Name: "node_memory_usage", Labels: labels, Value: node.MemoryBytes},
+		)
+		if node.AllocatableCPUCores > 0 {
+			samples = append(samples, models.Sample{
+				Name: "node_cpu_utilization", Labels: labels, Value: 100 * node.CPUCores / node.AllocatableCPUCores,
+			})
+		}
+		if node.AllocatableMemoryBytes > 0 {
+			samples = append(samples, models.Sample{
+				Name: "node_memory_utilization", Labels: labels, Value: 100 * node.MemoryBytes / node.AllocatableMemoryBytes,
+			})
+		}
+	}
+
+	if podErr != nil && nodeErr != nil {
+		return nil, fmt.Errorf("metrics-server unavailable: %v", podErr)
+	}
+	if podErr != nil {
+		return samples, fmt.Errorf("pod metrics unavailable: %v", podErr)
+	}
+	if nodeErr != nil {
+		return samples, fmt.Errorf("node metrics unavailable: %v", nodeErr)
+	}
+	return samples, nil
+}
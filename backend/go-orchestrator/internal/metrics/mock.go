@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"orchestrator/internal/models"
+)
+
+// MockSource fabricates plausible cluster gauges. It is used for local
+// development with METRICS_SOURCE=mock and, with METRICS_FALLBACK=mock, when
+// Prometheus is unreachable.
+type MockSource struct{}
+
+func NewMockSource() *MockSource {
+	return &MockSource{}
+}
+
+func (m *MockSource) Name() string {
+	return "mock"
+}
+
+func (m *MockSource) Collect(ctx context.Context) ([]models.Sample, error) {
+	now := time.Now().Unix()
+	return []models.Sample{
+		{Name: "cpu_usage", Value: 45.0 + float64(now%20)},
+		{Name: "memory_usage", Value: 60.0 + float64(now%15)},
+		{Name: "network_throughput", Value: 100.0 + float64(now%50)},
+	}, nil
+}
@@ -0,0 +1,251 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"orchestrator/internal/models"
+)
+
+const defaultPrometheusTimeout = 10 * time.Second
+
+// DefaultPrometheusQueries maps sample names to PromQL. Cluster-wide queries
+// must return a single series; per-object queries keep their result labels.
+// Deployment names are derived from pod names by stripping the ReplicaSet and
+// pod hash suffixes.
+var DefaultPrometheusQueries = map[string]string{
+	"cpu_usage":          `100 * (1 - avg(rate(node_cpu_seconds_total{mode="idle"}[5m])))`,
+	"memory_usage":       `100 * (1 - sum(node_memory_MemAvailable_bytes) / sum(node_memory_MemTotal_bytes))`,
+	"network_throughput": `sum(rate(node_network_receive_bytes_total[5m])) / 1024 / 1024 + sum(rate(node_network_transmit_bytes_total[5m])) / 1024 / 1024`,
+	"deployment_cpu_usage": `sum by (namespace, deployment) (label_replace(rate(container_cpu_usage_seconds_total{container!="",container!="POD"}[5m]),` +
+		` "deployment", "$1", "pod", "(.+)-[a-z0-9]{5,10}-[a-z0-9]{5}"))`,
+	"deployment_memory_usage": `sum by (namespace, deployment) (label_replace(container_memory_working_set_bytes{container!="",container!="POD"},` +
+		` "deployment", "$1", "pod", "(.+)-[a-z0-9]{5,10}-[a-z0-9]{5}"))`,
+	"deployment_network_throughput": `sum by (namespace, deployment) (label_replace(rate(container_network_receive_bytes_total[5m]) + rate(container_network_transmit_bytes_total[5m]),` +
+		` "deployment", "$1", "pod", "(.+)-[a-z0-9]{5,10}-[a-z0-9]{5}")) / 1024 / 1024`,
+}
+
+// PrometheusSource runs a set of instant PromQL queries against the
+// Prometheus HTTP API on every collection.
+type PrometheusSource struct {
+	baseURL string
+	client  *http.Client
+	queries map[string]string
+}
+
+// NewPrometheusSource creates a source for the Prometheus server at baseURL.
+// A nil queries map uses DefaultPrometheusQueries.
+func NewPrometheusSource(baseURL string, queries map[string]string, timeout time.Duration) *PrometheusSource {
+	if queries == nil {
+		queries = DefaultPrometheusQueries
+	}
+	if timeout <= 0 {
+		timeout = defaultPrometheusTimeout
+	}
+	return &PrometheusSource{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		client:  &http.Client{Timeout: timeout},
+		queries: queries,
+	}
+}
+
+// NewPrometheusSourceFromEnv reads PROMETHEUS_URL, PROMETHEUS_TIMEOUT and
+// PROMETHEUS_QUERIES_FILE. The queries file is YAML or JSON mapping sample
+// names to PromQL; its entries override or extend the defaults, and an empty
+// expression removes a default query.
+func NewPrometheusSourceFromEnv() (*PrometheusSource, error) {
+	baseURL := os.Getenv("PROMETHEUS_URL")
+	if baseURL == "" {
+		return nil, errors.New("PROMETHEUS_URL is not set")
+	}
+
+	timeout := defaultPrometheusTimeout
+	if value := os.Getenv("PROMETHEUS_TIMEOUT"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PROMETHEUS_TIMEOUT: %v", err)
+		}
+		timeout = parsed
+	}
+
+	queries := make(map[string]string, len(DefaultPrometheusQueries))
+	for name, expr := range DefaultPrometheusQueries {
+		queries[name] = expr
+	}
+	if path := os.Getenv("PROMETHEUS_QUERIES_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PROMETHEUS_QUERIES_FILE: %v", err)
+		}
+		var overrides map[string]string
+		if err := yaml.Unmarshal(data, &overrides); err != nil {
+			return nil, fmt.Errorf("failed to parse PROMETHEUS_QUERIES_FILE: %v", err)
+		}
+		for name, expr := range overrides {
+			if expr == "" {
+				delete(queries, name)
+				continue
+			}
+			queries[name] = expr
+		}
+	}
+
+	return NewPrometheusSource(baseURL, queries, timeout), nil
+}
+
+func (p *PrometheusSource) Name() string {
+	return "prometheus"
+}
+
+// Collect runs every configured query. Individual query failures are logged
+// and skipped; an error is returned only when no query succeeds, so the
+// collector can switch to its fallback source.
+func (p *PrometheusSource) Collect(ctx context.Context) ([]models.Sample, error) {
+	names := make([]string, 0, len(p.queries))
+	for name := range p.queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		samples  []models.Sample
+		lastErr  error
+		failures int
+	)
+	for _, name := range names {
+		results, err := p.Query(ctx, p.queries[name])
+		if err != nil {
+			failures++
+			lastErr = err
+			log.Printf("Prometheus query %s failed: %v", name, err)
+			continue
+		}
+		for _, result := range results {
+			samples = append(samples, models.Sample{
+				Name:   name,
+				Labels: result.Labels,
+				Value:  result.Value,
+			})
+		}
+	}
+
+	if failures > 0 && failures == len(names) {
+		return nil, fmt.Errorf("all Prometheus queries failed: %v", lastErr)
+	}
+	return samples, nil
+}
+
+// QueryResult is one series of an instant query result.
+type QueryResult struct {
+	Labels map[string]string
+	Value  float64
+}
+
+type prometheusResponse struct {
+	Status    string `json:"status"`
+	ErrorType string `json:"errorType"`
+	Error     string `json:"error"`
+	Data      struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+type prometheusVectorEntry struct {
+	Metric map[string]string `json:"metric"`
+	Value  [2]interface{}    `json:"value"`
+}
+
+// Query runs an instant query through /api/v1/query and returns the vector
+// or scalar result. The __name__ label is dropped from results.
+func (p *PrometheusSource) Query(ctx context.Context, expr string) ([]QueryResult, error) {
+	params := url.Values{}
+	params.Set("query", expr)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/api/v1/query?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed prometheusResponse
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("unexpected response (HTTP %d): %v", resp.StatusCode, err)
+	}
+	if parsed.Status != "success" {
+		return nil, fmt.Errorf("%s: %s", parsed.ErrorType, parsed.Error)
+	}
+
+	switch parsed.Data.ResultType {
+	case "vector":
+		var entries []prometheusVectorEntry
+		if err := json.Unmarshal(parsed.Data.Result, &entries); err != nil {
+			return nil, err
+		}
+		results := make([]QueryResult, 0, len(entries))
+		for _, entry := range entries {
+			value, err := parseSampleValue(entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				// Not representable in JSON, and meaningless on a dashboard.
+				continue
+			}
+			delete(entry.Metric, "__name__")
+			if len(entry.Metric) == 0 {
+				entry.Metric = nil
+			}
+			results = append(results, QueryResult{Labels: entry.Metric, Value: value})
+		}
+		return results, nil
+	case "scalar":
+		var pair [2]interface{}
+		if err := json.Unmarshal(parsed.Data.Result, &pair); err != nil {
+			return nil, err
+		}
+		value, err := parseSampleValue(pair)
+		if err != nil {
+			return nil, err
+		}
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, nil
+		}
+		return []QueryResult{{Value: value}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported result type %q", parsed.Data.ResultType)
+	}
+}
+
+// parseSampleValue decodes a Prometheus [timestamp, "value"] pair.
+func parseSampleValue(pair [2]interface{}) (float64, error) {
+	raw, ok := pair[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected sample value %v", pair[1])
+	}
+	return strconv.ParseFloat(raw, 64)
+}
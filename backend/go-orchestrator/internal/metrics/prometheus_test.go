@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// stubPrometheus answers /api/v1/query with the body registered for the
+// query expression, or a 400 error for unknown expressions.
+func stubPrometheus(t *testing.T, responses map[string]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" {
+			http.NotFound(w, r)
+			return
+		}
+		body, ok := responses[r.URL.Query().Get("query")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			body = `{"status":"error","errorType":"bad_data","error":"parse error"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPrometheusQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     []QueryResult
+		wantErr  bool
+	}{
+		{
+			name:     "cluster-wide vector",
+			response: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"42.5"]}]}}`,
+			want:     []QueryResult{{Value: 42.5}},
+		},
+		{
+			name: "labelled vector drops __name__",
+			response: `{"status":"success","data":{"resultType":"vector","result":[` +
+				`{"metric":{"__name__":"x","namespace":"default","deployment":"web"},"value":[1700000000,"0.25"]}]}}`,
+			want: []QueryResult{{Labels: map[string]string{"namespace": "default", "deployment": "web"}, Value: 0.25}},
+		},
+		{
+			name: "NaN samples skipped",
+			response: `{"status":"success","data":{"resultType":"vector","result":[` +
+				`{"metric":{"pod":"a"},"value":[1700000000,"NaN"]},{"metric":{"pod":"b"},"value":[1700000000,"1"]}]}}`,
+			want: []QueryResult{{Labels: map[string]string{"pod": "b"}, Value: 1}},
+		},
+		{
+			name:     "scalar",
+			response: `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"7"]}}`,
+			want:     []QueryResult{{Value: 7}},
+		},
+		{
+			name:     "empty vector",
+			response: `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			want:     []QueryResult{},
+		},
+		{
+			name:     "query error",
+			response: `{"status":"error","errorType":"execution","error":"query timed out"}`,
+			wantErr:  true,
+		},
+		{
+			name:     "unsupported result type",
+			response: `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+			wantErr:  true,
+		},
+		{
+			name:     "not JSON",
+			response: `<html>bad gateway</html>`,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := stubPrometheus(t, map[string]string{"up": tt.response})
+			source := NewPrometheusSource(server.URL+"/", map[string]string{}, time.Second)
+
+			got, err := source.Query(context.Background(), "up")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Query() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrometheusCollect(t *testing.T) {
+	ok := `{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"50"]}]}}`
+	tests := []struct {
+		name    string
+		queries map[string]string
+		want    int
+		wantErr bool
+	}{
+		{name: "all succeed", queries: map[string]string{"cpu_usage": "cpu", "memory_usage": "memory"}, want: 2},
+		{name: "partial failure still collects", queries: map[string]string{"cpu_usage": "cpu", "memory_usage": "broken"}, want: 1},
+		{name: "all fail", queries: map[string]string{"cpu_usage": "broken", "memory_usage": "broken"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := stubPrometheus(t, map[string]string{"cpu": ok, "memory": ok})
+			source := NewPrometheusSource(server.URL, tt.queries, time.Second)
+
+			samples, err := source.Collect(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Collect() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(samples) != tt.want {
+				t.Errorf("Collect() returned %d samples, want %d", len(samples), tt.want)
+			}
+		})
+	}
+}
+
+func TestPrometheusUnreachable(t *testing.T) {
+	server := stubPrometheus(t, nil)
+	server.Close()
+
+	source := NewPrometheusSource(server.URL, map[string]string{"cpu_usage": "cpu"}, time.Second)
+	if _, err := source.Collect(context.Background()); err == nil {
+		t.Fatal("Collect() against a closed server succeeded")
+	}
+}
@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+
+	"orchestrator/internal/models"
+)
+
+// SeriesKey renders a sample's identity in Prometheus notation, e.g.
+// pod_cpu_usage{namespace="default",pod="web-1"}. Labels are sorted so the key
+// is stable.
+func SeriesKey(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(key)
+		b.WriteString(`="`)
+		b.WriteString(labels[key])
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// MatchLabels reports whether every label in selector has the same value in
+// labels.
+func MatchLabels(labels, selector map[string]string) bool {
+	for key, value := range selector {
+		if labels[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// FilterSamples returns the samples named name whose labels match selector.
+// An empty name matches every sample.
+func FilterSamples(samples []models.Sample, name string, selector map[string]string) []models.Sample {
+	var matched []models.Sample
+	for _, sample := range samples {
+		if name != "" && sample.Name != name {
+			continue
+		}
+		if MatchLabels(sample.Labels, selector) {
+			matched = append(matched, sample)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"orchestrator/internal/models"
+)
+
+// Source produces metric samples for the collector. Samples without labels
+// are cluster-wide gauges (cpu_usage, memory_usage, ...); labelled samples are
+// per-object series such as deployment_cpu_usage{namespace,deployment}.
+type Source interface {
+	Name() string
+	Collect(ctx context.Context) ([]models.Sample, error)
+}
+
+// SourcesFromEnv builds the primary and fallback sources from METRICS_SOURCE
+// ("prometheus" or "mock") and METRICS_FALLBACK ("none" or "mock"). Without
+// METRICS_SOURCE, Prometheus is used when PROMETHEUS_URL is set. Invented
+// values must never pass for real ones, so the mock is only used when asked
+// for by name: as the source with METRICS_SOURCE=mock, or standing in for
+// Prometheus with METRICS_FALLBACK=mock. Otherwise the fallback is nil and a
+// missing or misconfigured Prometheus is an error.
+func SourcesFromEnv() (Source, Source, error) {
+	kind := os.Getenv("METRICS_SOURCE")
+	if kind == "" {
+		if os.Getenv("PROMETHEUS_URL") == "" {
+			return nil, nil, errors.New("no metrics source: set PROMETHEUS_URL, or METRICS_SOURCE=mock for synthetic development data")
+		}
+		kind = "prometheus"
+	}
+
+	switch kind {
+	case "mock":
+		log.Printf("Warning: METRICS_SOURCE=mock, cluster metrics are synthetic and do not reflect the cluster")
+		return NewMockSource(), nil, nil
+	case "prometheus":
+	default:
+		return nil, nil, fmt.Errorf("unknown METRICS_SOURCE %q", kind)
+	}
+
+	fallback := os.Getenv("METRICS_FALLBACK")
+	switch fallback {
+	case "", "none", "mock":
+	default:
+		return nil, nil, fmt.Errorf("unknown METRICS_FALLBACK %q", fallback)
+	}
+
+	primary, err := NewPrometheusSourceFromEnv()
+	if err != nil {
+		if fallback != "mock" {
+			return nil, nil, fmt.Errorf("prometheus metrics source: %v", err)
+		}
+		log.Printf("Warning: Prometheus metrics source unavailable, using mock metrics: %v", err)
+		return NewMockSource(), nil, nil
+	}
+	if fallback == "mock" {
+		return primary, NewMockSource(), nil
+	}
+	return primary, nil, nil
+}
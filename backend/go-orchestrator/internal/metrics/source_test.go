@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSourcesFromEnv(t *testing.T) {
+	tests := []struct {
+		name         string
+		env          map[string]string
+		wantPrimary  string
+		wantFallback string
+		wantErr      bool
+	}{
+		{name: "nothing configured", wantErr: true},
+		{name: "mock without prometheus", env: map[string]string{"METRICS_SOURCE": "mock"}, wantPrimary: "mock"},
+		{name: "prometheus without fallback", env: map[string]string{"PROMETHEUS_URL": "http://prometheus:9090"}, wantPrimary: "prometheus"},
+		{name: "explicit no fallback", env: map[string]string{"PROMETHEUS_URL": "http://prometheus:9090", "METRICS_FALLBACK": "none"}, wantPrimary: "prometheus"},
+		{name: "opt-in mock fallback", env: map[string]string{"PROMETHEUS_URL": "http://prometheus:9090", "METRICS_FALLBACK": "mock"}, wantPrimary: "prometheus", wantFallback: "mock"},
+		{name: "explicit mock", env: map[string]string{"PROMETHEUS_URL": "http://prometheus:9090", "METRICS_SOURCE": "mock"}, wantPrimary: "mock"},
+		{name: "prometheus without URL", env: map[string]string{"METRICS_SOURCE": "prometheus"}, wantErr: true},
+		{name: "prometheus without URL, mock allowed", env: map[string]string{"METRICS_SOURCE": "prometheus", "METRICS_FALLBACK": "mock"}, wantPrimary: "mock"},
+		{name: "invalid prometheus timeout", env: map[string]string{"PROMETHEUS_URL": "http://prometheus:9090", "PROMETHEUS_TIMEOUT": "soon"}, wantErr: true},
+		{name: "unknown source", env: map[string]string{"METRICS_SOURCE": "graphite"}, wantErr: true},
+		{name: "unknown fallback", env: map[string]string{"PROMETHEUS_URL": "http://prometheus:9090", "METRICS_FALLBACK": "zero"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"METRICS_SOURCE", "METRICS_FALLBACK", "PROMETHEUS_URL", "PROMETHEUS_TIMEOUT", "PROMETHEUS_QUERIES_FILE"} {
+				t.Setenv(name, tt.env[name])
+			}
+
+			primary, fallback, err := SourcesFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SourcesFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if primary.Name() != tt.wantPrimary {
+				t.Errorf("primary = %s, want %s", primary.Name(), tt.wantPrimary)
+			}
+			gotFallback := ""
+			if fallback != nil {
+				gotFallback = fallback.Name()
+			}
+			if gotFallback != tt.wantFallback {
+				t.Errorf("fallback = %q, want %q", gotFallback, tt.wantFallback)
+			}
+		})
+	}
+}
+
+func TestCollectSamplesDuringOutage(t *testing.T) {
+	server := stubPrometheus(t, nil)
+	server.Close()
+	prometheus := NewPrometheusSource(server.URL, map[string]string{"cpu_usage": "cpu"}, 0)
+
+	tests := []struct {
+		name        string
+		fallback    Source
+		wantSource  string
+		wantSamples bool
+	}{
+		{name: "no fallback records nothing", wantSource: "prometheus"},
+		{name: "mock fallback", fallback: NewMockSource(), wantSource: "mock", wantSamples: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Collector{source: prometheus, fallback: tt.fallback}
+			source, samples := c.collectSamples(context.Background())
+			if source != tt.wantSource {
+				t.Errorf("source = %s, want %s", source, tt.wantSource)
+			}
+			if (len(samples) > 0) != tt.wantSamples {
+				t.Errorf("got %d samples, want samples: %v", len(samples), tt.wantSamples)
+			}
+		})
+	}
+}
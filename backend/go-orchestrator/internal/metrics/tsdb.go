@@ -0,0 +1,408 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"orchestrator/internal/models"
+)
+
+// Tier is one retention level of the TSDB. The first tier holds raw samples
+// and has no resolution; later tiers hold rollups of Resolution-wide buckets.
+type Tier struct {
+	Resolution time.Duration
+	Retention  time.Duration
+}
+
+// DefaultTiers keeps raw samples for a day, 5-minute rollups for 30 days and
+// hourly rollups for a year.
+var DefaultTiers = []Tier{
+	{Retention: 24 * time.Hour},
+	{Resolution: 5 * time.Minute, Retention: 30 * 24 * time.Hour},
+	{Resolution: time.Hour, Retention: 365 * 24 * time.Hour},
+}
+
+// Aggregate summarises the values of a series over one bucket.
+type Aggregate struct {
+	Timestamp time.Time `json:"timestamp"`
+	Min       float64   `json:"min"`
+	Max       float64   `json:"max"`
+	Avg       float64   `json:"avg"`
+	P95       float64   `json:"p95"`
+	Count     int       `json:"count"`
+}
+
+// SeriesRange is the result of a range query for one series.
+type SeriesRange struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Points []Aggregate       `json:"points"`
+}
+
+type rawPoint struct {
+	ts    int64 // Unix milliseconds
+	value float64
+}
+
+type memSeries struct {
+	name   string
+	labels map[string]string
+	raw    []rawPoint
+	// rollups and pending are indexed by rollup tier (tiers[1:]). pending is
+	// the start of the oldest bucket with raw points that has not been
+	// rolled up yet, or zero.
+	rollups [][]Aggregate
+	pending []time.Time
+}
+
+// TSDB is an in-memory store of labelled series with tiered retention. Raw
+// samples are rolled up into min/max/avg/p95 buckets as each bucket closes,
+// and range queries read from the coarsest tier that still satisfies the
+// requested step. It is safe for concurrent use.
+type TSDB struct {
+	mu     sync.RWMutex
+	tiers  []Tier
+	series map[string]*memSeries
+}
+
+// NewTSDB creates a store with the given tiers; nil uses DefaultTiers. Raw
+// retention must cover at least one bucket of every rollup tier.
+func NewTSDB(tiers []Tier) *TSDB {
+	if tiers == nil {
+		tiers = DefaultTiers
+	}
+	return &TSDB{
+		tiers:  tiers,
+		series: make(map[string]*memSeries),
+	}
+}
+
+// Tiers returns the configured tiers, raw first.
+func (db *TSDB) Tiers() []Tier {
+	return db.tiers
+}
+
+// Append records samples taken at ts and returns the rollups of any buckets
+// that closed as a result, for persisting. Samples at or before a series'
+// latest raw point are ignored.
+func (db *TSDB) Append(ts time.Time, samples []models.Sample) []models.Rollup {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ms := ts.UnixMilli()
+	for _, sample := range samples {
+		key := SeriesKey(sample.Name, sample.Labels)
+		s := db.seriesFor(key, sample.Name, sample.Labels)
+		if n := len(s.raw); n > 0 && s.raw[n-1].ts >= ms {
+			continue
+		}
+		s.raw = append(s.raw, rawPoint{ts: ms, value: sample.Value})
+		for i := range s.pending {
+			if s.pending[i].IsZero() {
+				s.pending[i] = ts.Truncate(db.tiers[i+1].Resolution)
+			}
+		}
+	}
+
+	var closed []models.Rollup
+	for key, s := range db.series {
+		for i := range s.pending {
+			resolution := db.tiers[i+1].Resolution
+			for !s.pending[i].IsZero() && !ts.Before(s.pending[i].Add(resolution)) {
+				start := s.pending[i]
+				end := start.Add(resolution)
+				if agg, ok := s.aggregateRaw(start, end); ok && !s.hasRollup(i, start) {
+					s.rollups[i] = append(s.rollups[i], agg)
+					closed = append(closed, s.rollup(key, resolution, agg))
+				}
+				s.pending[i] = s.nextBucket(end, resolution)
+			}
+		}
+	}
+
+	db.prune(ts)
+	return closed
+}
+
+// LoadRollups restores persisted rollups, e.g. on startup. They must be in
+// chronological order per resolution and should be loaded before raw history
+// is replayed through Append, so already persisted buckets are not rolled up
+// again.
+func (db *TSDB) LoadRollups(rollups []models.Rollup) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, rollup := range rollups {
+		tier := db.tierIndex(time.Duration(rollup.Resolution) * time.Second)
+		if tier < 1 {
+			continue
+		}
+		s := db.seriesFor(rollup.Series, rollup.Name, rollup.Labels)
+		if s.hasRollup(tier-1, rollup.Timestamp) {
+			continue
+		}
+		s.rollups[tier-1] = append(s.rollups[tier-1], Aggregate{
+			Timestamp: rollup.Timestamp,
+			Min:       rollup.Min,
+			Max:       rollup.Max,
+			Avg:       rollup.Avg,
+			P95:       rollup.P95,
+			Count:     rollup.Count,
+		})
+	}
+}
+
+// Query returns the series named name whose labels match selector, with
+// points between from and to aggregated into step-wide buckets. The tier is
+// the finest one whose retention reaches back to from, or a coarser one when
+// step is at least its resolution. The step actually used, which is never
+// finer than the tier's resolution, is returned alongside the results. A zero
+// step on the raw tier returns every raw point.
+func (db *TSDB) Query(name string, selector map[string]string, from, to time.Time, step time.Duration) ([]SeriesRange, time.Duration) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	tier := db.tierFor(from, step, time.Now())
+	if resolution := db.tiers[tier].Resolution; step < resolution {
+		step = resolution
+	}
+
+	keys := make([]string, 0)
+	for key, s := range db.series {
+		if s.name == name && MatchLabels(s.labels, selector) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	results := make([]SeriesRange, 0, len(keys))
+	for _, key := range keys {
+		s := db.series[key]
+		var points []Aggregate
+		if tier == 0 {
+			points = s.queryRaw(from, to, step)
+		} else {
+			points = s.queryRollups(tier-1, db.tiers[tier].Resolution, from, to, step)
+		}
+		if len(points) == 0 {
+			continue
+		}
+		results = append(results, SeriesRange{Name: s.name, Labels: s.labels, Points: points})
+	}
+	return results, step
+}
+
+func (db *TSDB) seriesFor(key, name string, labels map[string]string) *memSeries {
+	s, ok := db.series[key]
+	if !ok {
+		s = &memSeries{
+			name:    name,
+			labels:  labels,
+			rollups: make([][]Aggregate, len(db.tiers)-1),
+			pending: make([]time.Time, len(db.tiers)-1),
+		}
+		db.series[key] = s
+	}
+	return s
+}
+
+func (db *TSDB) tierIndex(resolution time.Duration) int {
+	for i, tier := range db.tiers {
+		if tier.Resolution == resolution {
+			return i
+		}
+	}
+	return -1
+}
+
+func (db *TSDB) tierFor(from time.Time, step time.Duration, now time.Time) int {
+	tier := len(db.tiers) - 1
+	for i, t := range db.tiers {
+		if !from.Before(now.Add(-t.Retention)) {
+			tier = i
+			break
+		}
+	}
+	for tier+1 < len(db.tiers) && step >= db.tiers[tier+1].Resolution {
+		tier++
+	}
+	return tier
+}
+
+// prune drops data that has aged out of its tier and forgets series with
+// nothing left.
+func (db *TSDB) prune(now time.Time) {
+	rawCutoff := now.Add(-db.tiers[0].Retention).UnixMilli()
+	for key, s := range db.series {
+		if len(s.raw) > 0 && s.raw[0].ts < rawCutoff {
+			i := sort.Search(len(s.raw), func(i int) bool { return s.raw[i].ts >= rawCutoff })
+			s.raw = s.raw[i:]
+		}
+
+		empty := len(s.raw) == 0
+		for i := range s.rollups {
+			cutoff := now.Add(-db.tiers[i+1].Retention)
+			rollups := s.rollups[i]
+			if len(rollups) > 0 && rollups[0].Timestamp.Before(cutoff) {
+				j := sort.Search(len(rollups), func(j int) bool { return !rollups[j].Timestamp.Before(cutoff) })
+				s.rollups[i] = rollups[j:]
+			}
+			if len(s.rollups[i]) > 0 {
+				empty = false
+			}
+		}
+		if empty {
+			delete(db.series, key)
+		}
+	}
+}
+
+// rawRange returns the raw points with start <= timestamp < end.
+func (s *memSeries) rawRange(start, end time.Time) []rawPoint {
+	from, to := start.UnixMilli(), end.UnixMilli()
+	i := sort.Search(len(s.raw), func(i int) bool { return s.raw[i].ts >= from })
+	j := sort.Search(len(s.raw), func(j int) bool { return s.raw[j].ts >= to })
+	return s.raw[i:j]
+}
+
+func (s *memSeries) aggregateRaw(start, end time.Time) (Aggregate, bool) {
+	points := s.rawRange(start, end)
+	if len(points) == 0 {
+		return Aggregate{}, false
+	}
+	values := make([]float64, len(points))
+	for i, point := range points {
+		values[i] = point.value
+	}
+	return aggregateValues(start, values), true
+}
+
+func (s *memSeries) hasRollup(tier int, start time.Time) bool {
+	rollups := s.rollups[tier]
+	return len(rollups) > 0 && !rollups[len(rollups)-1].Timestamp.Before(start)
+}
+
+// nextBucket returns the start of the first bucket at or after from that has
+// raw points, or zero if there is none yet.
+func (s *memSeries) nextBucket(from time.Time, resolution time.Duration) time.Time {
+	ms := from.UnixMilli()
+	i := sort.Search(len(s.raw), func(i int) bool { return s.raw[i].ts >= ms })
+	if i == len(s.raw) {
+		return time.Time{}
+	}
+	return time.UnixMilli(s.raw[i].ts).Truncate(resolution)
+}
+
+func (s *memSeries) rollup(key string, resolution time.Duration, agg Aggregate) models.Rollup {
+	return models.Rollup{
+		Series:     key,
+		Name:       s.name,
+		Labels:     s.labels,
+		Resolution: int64(resolution / time.Second),
+		Timestamp:  agg.Timestamp,
+		Min:        agg.Min,
+		Max:        agg.Max,
+		Avg:        agg.Avg,
+		P95:        agg.P95,
+		Count:      agg.Count,
+	}
+}
+
+func (s *memSeries) queryRaw(from, to time.Time, step time.Duration) []Aggregate {
+	points := s.rawRange(from, to)
+	if step <= 0 {
+		result := make([]Aggregate, len(points))
+		for i, point := range points {
+			result[i] = aggregateValues(time.UnixMilli(point.ts), []float64{point.value})
+		}
+		return result
+	}
+
+	var (
+		result []Aggregate
+		values []float64
+		bucket time.Time
+	)
+	for _, point := range points {
+		start := time.UnixMilli(point.ts).Truncate(step)
+		if !start.Equal(bucket) && len(values) > 0 {
+			result = append(result, aggregateValues(bucket, values))
+			values = values[:0]
+		}
+		bucket = start
+		values = append(values, point.value)
+	}
+	if len(values) > 0 {
+		result = append(result, aggregateValues(bucket, values))
+	}
+	return result
+}
+
+// queryRollups reads a rollup tier. Buckets newer than the last rollup, which
+// include the one still filling, are computed from raw points so the most
+// recent data is not missing from long-range charts.
+func (s *memSeries) queryRollups(tier int, resolution time.Duration, from, to time.Time, step time.Duration) []Aggregate {
+	rollups := s.rollups[tier]
+	i := sort.Search(len(rollups), func(i int) bool { return !rollups[i].Timestamp.Before(from.Truncate(resolution)) })
+	j := sort.Search(len(rollups), func(j int) bool { return !rollups[j].Timestamp.Before(to) })
+	buckets := append([]Aggregate(nil), rollups[i:j]...)
+
+	tail := from.Truncate(resolution)
+	if len(rollups) > 0 {
+		if next := rollups[len(rollups)-1].Timestamp.Add(resolution); next.After(tail) {
+			tail = next
+		}
+	}
+	for start := s.nextBucket(tail, resolution); !start.IsZero() && start.Before(to); start = s.nextBucket(start.Add(resolution), resolution) {
+		if agg, ok := s.aggregateRaw(start, start.Add(resolution)); ok {
+			buckets = append(buckets, agg)
+		}
+	}
+
+	return mergeAggregates(buckets, step)
+}
+
+// mergeAggregates combines consecutive buckets into step-wide ones. Min, max
+// and the count-weighted average are exact; the merged p95 is the largest
+// input p95, an upper bound on the true value.
+func mergeAggregates(buckets []Aggregate, step time.Duration) []Aggregate {
+	var result []Aggregate
+	for _, agg := range buckets {
+		start := agg.Timestamp.Truncate(step)
+		if n := len(result); n > 0 && result[n-1].Timestamp.Equal(start) {
+			last := &result[n-1]
+			total := last.Avg*float64(last.Count) + agg.Avg*float64(agg.Count)
+			last.Count += agg.Count
+			last.Avg = total / float64(last.Count)
+			last.Min = math.Min(last.Min, agg.Min)
+			last.Max = math.Max(last.Max, agg.Max)
+			last.P95 = math.Max(last.P95, agg.P95)
+			continue
+		}
+		agg.Timestamp = start
+		result = append(result, agg)
+	}
+	return result
+}
+
+func aggregateValues(start time.Time, values []float64) Aggregate {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, value := range sorted {
+		sum += value
+	}
+	// Nearest-rank percentile
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return Aggregate{
+		Timestamp: start,
+		Min:       sorted[0],
+		Max:       sorted[len(sorted)-1],
+		Avg:       sum / float64(len(sorted)),
+		P95:       sorted[rank],
+		Count:     len(sorted),
+	}
+}
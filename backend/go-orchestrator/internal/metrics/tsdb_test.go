@@ -0,0 +1,235 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"orchestrator/internal/models"
+)
+
+// fillMinutes appends one cpu_usage sample per minute from start, with the
+// minute index as value, and returns every rollup that closed.
+func fillMinutes(db *TSDB, start time.Time, from, to int) []models.Rollup {
+	var closed []models.Rollup
+	for i := from; i <= to; i++ {
+		ts := start.Add(time.Duration(i) * time.Minute)
+		closed = append(closed, db.Append(ts, []models.Sample{{Name: "cpu_usage", Value: float64(i)}})...)
+	}
+	return closed
+}
+
+func countByResolution(rollups []models.Rollup) map[int64]int {
+	counts := map[int64]int{}
+	for _, rollup := range rollups {
+		counts[rollup.Resolution]++
+	}
+	return counts
+}
+
+func TestAggregateValues(t *testing.T) {
+	sequence := func(n int) []float64 {
+		values := make([]float64, n)
+		for i := range values {
+			values[i] = float64(i + 1)
+		}
+		return values
+	}
+	tests := []struct {
+		name   string
+		values []float64
+		want   Aggregate
+	}{
+		{"single value", []float64{7}, Aggregate{Min: 7, Max: 7, Avg: 7, P95: 7, Count: 1}},
+		{"unsorted", []float64{3, 1, 2}, Aggregate{Min: 1, Max: 3, Avg: 2, P95: 3, Count: 3}},
+		{"twenty values", sequence(20), Aggregate{Min: 1, Max: 20, Avg: 10.5, P95: 19, Count: 20}},
+		{"hundred values", sequence(100), Aggregate{Min: 1, Max: 100, Avg: 50.5, P95: 95, Count: 100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := aggregateValues(time.Time{}, tt.values); got != tt.want {
+				t.Errorf("aggregateValues() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeAggregates(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	buckets := []Aggregate{
+		{Timestamp: start, Min: 1, Max: 4, Avg: 2, P95: 4, Count: 2},
+		{Timestamp: start.Add(5 * time.Minute), Min: 0, Max: 9, Avg: 5, P95: 8, Count: 6},
+		{Timestamp: start.Add(10 * time.Minute), Min: 3, Max: 3, Avg: 3, P95: 3, Count: 1},
+	}
+
+	got := mergeAggregates(buckets, 10*time.Minute)
+	want := []Aggregate{
+		{Timestamp: start, Min: 0, Max: 9, Avg: 4.25, P95: 8, Count: 8},
+		{Timestamp: start.Add(10 * time.Minute), Min: 3, Max: 3, Avg: 3, P95: 3, Count: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("mergeAggregates() returned %d buckets, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("bucket %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTSDBRollupsCloseWithBuckets(t *testing.T) {
+	db := NewTSDB(nil)
+	start := time.Now().Truncate(time.Hour).Add(-2 * time.Hour)
+
+	closed := fillMinutes(db, start, 0, 69)
+
+	// Minutes 0-64 fill thirteen closed 5-minute buckets; 65-69 is still
+	// open. The first hour has closed.
+	counts := countByResolution(closed)
+	if counts[300] != 13 || counts[3600] != 1 {
+		t.Fatalf("closed rollups = %v, want 13 5-minute and 1 hourly", counts)
+	}
+	for _, rollup := range closed {
+		switch {
+		case rollup.Resolution == 300 && rollup.Timestamp.Equal(start):
+			if rollup.Min != 0 || rollup.Max != 4 || rollup.Avg != 2 || rollup.Count != 5 {
+				t.Errorf("first 5-minute rollup = %+v", rollup)
+			}
+		case rollup.Resolution == 3600:
+			if !rollup.Timestamp.Equal(start) || rollup.Min != 0 || rollup.Max != 59 || rollup.Avg != 29.5 || rollup.Count != 60 {
+				t.Errorf("hourly rollup = %+v", rollup)
+			}
+		}
+		if rollup.Series != "cpu_usage" || rollup.Name != "cpu_usage" {
+			t.Errorf("rollup series = %q/%q, want cpu_usage", rollup.Series, rollup.Name)
+		}
+	}
+}
+
+func TestTSDBQueryTierSelection(t *testing.T) {
+	db := NewTSDB(nil)
+	start := time.Now().Truncate(time.Hour).Add(-2 * time.Hour)
+	fillMinutes(db, start, 0, 69)
+	to := start.Add(2 * time.Hour)
+
+	tests := []struct {
+		name       string
+		from       time.Time
+		step       time.Duration
+		wantStep   time.Duration
+		wantPoints int
+		wantCount  int // of the first point
+	}{
+		{name: "raw points", from: start, wantStep: 0, wantPoints: 70, wantCount: 1},
+		{name: "raw buckets", from: start, step: 2 * time.Minute, wantStep: 2 * time.Minute, wantPoints: 35, wantCount: 2},
+		{name: "step selects the 5-minute tier", from: start, step: 5 * time.Minute, wantStep: 5 * time.Minute, wantPoints: 14, wantCount: 5},
+		{name: "range beyond raw retention", from: start.Add(-48 * time.Hour), step: time.Minute, wantStep: 5 * time.Minute, wantPoints: 14, wantCount: 5},
+		{name: "hourly tier with open bucket from raw", from: start, step: time.Hour, wantStep: time.Hour, wantPoints: 2, wantCount: 60},
+		{name: "steps merge rollups", from: start, step: 30 * time.Minute, wantStep: 30 * time.Minute, wantPoints: 3, wantCount: 30},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, step := db.Query("cpu_usage", nil, tt.from, to, tt.step)
+			if step != tt.wantStep {
+				t.Errorf("step = %s, want %s", step, tt.wantStep)
+			}
+			if len(results) != 1 {
+				t.Fatalf("got %d series, want 1", len(results))
+			}
+			points := results[0].Points
+			if len(points) != tt.wantPoints {
+				t.Fatalf("got %d points, want %d", len(points), tt.wantPoints)
+			}
+			if points[0].Count != tt.wantCount {
+				t.Errorf("first point count = %d, want %d", points[0].Count, tt.wantCount)
+			}
+			var total int
+			for _, point := range points {
+				total += point.Count
+			}
+			if total != 70 {
+				t.Errorf("points cover %d samples, want 70", total)
+			}
+		})
+	}
+}
+
+func TestTSDBQuerySelector(t *testing.T) {
+	db := NewTSDB(nil)
+	now := time.Now()
+	db.Append(now, []models.Sample{
+		{Name: "deployment_cpu_usage", Labels: map[string]string{"namespace": "a", "deployment": "web"}, Value: 1},
+		{Name: "deployment_cpu_usage", Labels: map[string]string{"namespace": "b", "deployment": "web"}, Value: 2},
+		{Name: "deployment_memory_usage", Labels: map[string]string{"namespace": "a", "deployment": "web"}, Value: 3},
+	})
+
+	tests := []struct {
+		name     string
+		selector map[string]string
+		want     int
+	}{
+		{"all series of the name", nil, 2},
+		{"one namespace", map[string]string{"namespace": "a"}, 1},
+		{"no match", map[string]string{"namespace": "c"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, _ := db.Query("deployment_cpu_usage", tt.selector, now.Add(-time.Minute), now.Add(time.Minute), 0)
+			if len(results) != tt.want {
+				t.Errorf("got %d series, want %d", len(results), tt.want)
+			}
+		})
+	}
+}
+
+func TestTSDBLoadedRollupsAreNotRepeated(t *testing.T) {
+	db := NewTSDB(nil)
+	start := time.Now().Truncate(time.Hour).Add(-2 * time.Hour)
+	db.LoadRollups([]models.Rollup{{
+		Series: "cpu_usage", Name: "cpu_usage", Resolution: 300,
+		Timestamp: start, Min: 0, Max: 4, Avg: 2, P95: 4, Count: 5,
+	}})
+
+	// Replaying raw history closes the first two buckets again; only the
+	// second is new
+	closed := fillMinutes(db, start, 0, 10)
+	if len(closed) != 1 || !closed[0].Timestamp.Equal(start.Add(5*time.Minute)) {
+		t.Fatalf("closed = %+v, want only the bucket at +5m", closed)
+	}
+}
+
+func TestTSDBAppendIgnoresOutOfOrderSamples(t *testing.T) {
+	db := NewTSDB(nil)
+	now := time.Now()
+	db.Append(now, []models.Sample{{Name: "cpu_usage", Value: 1}})
+	db.Append(now, []models.Sample{{Name: "cpu_usage", Value: 2}})
+	db.Append(now.Add(-time.Second), []models.Sample{{Name: "cpu_usage", Value: 3}})
+
+	if raw := db.series["cpu_usage"].raw; len(raw) != 1 || raw[0].value != 1 {
+		t.Errorf("raw = %+v, want only the first sample", raw)
+	}
+}
+
+func TestTSDBPrune(t *testing.T) {
+	db := NewTSDB([]Tier{
+		{Retention: 10 * time.Minute},
+		{Resolution: 5 * time.Minute, Retention: 20 * time.Minute},
+	})
+	start := time.Now().Truncate(time.Hour).Add(-2 * time.Hour)
+	fillMinutes(db, start, 0, 30)
+
+	s := db.series["cpu_usage"]
+	// Raw points from +20m on, and 5-minute buckets from +10m on, are
+	// within retention at +30m
+	if len(s.raw) != 11 || s.raw[0].value != 20 {
+		t.Errorf("kept %d raw points starting at %v, want 11 from 20", len(s.raw), s.raw[0].value)
+	}
+	if len(s.rollups[0]) != 4 || !s.rollups[0][0].Timestamp.Equal(start.Add(10*time.Minute)) {
+		t.Errorf("kept %d rollups starting at %v, want 4 from +10m", len(s.rollups[0]), s.rollups[0][0].Timestamp)
+	}
+
+	// A series with nothing left is forgotten
+	db.Append(start.Add(2*time.Hour), nil)
+	if _, ok := db.series["cpu_usage"]; ok {
+		t.Error("expired series was not removed")
+	}
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+const (
+	AnomalySeverityWarning  = "warning"
+	AnomalySeverityCritical = "critical"
+
+	AnomalyStatusActive   = "active"
+	AnomalyStatusResolved = "resolved"
+)
+
+// Anomaly is a period during which a metric series deviated from its
+// expected behaviour. It stays active while the deviation persists and is
+// resolved, with EndedAt set, once the series returns to normal.
+type Anomaly struct {
+	ID       string            `json:"id"`
+	Type     string            `json:"type"` // "spike" or "drop"
+	Series   string            `json:"series"`
+	Metric   string            `json:"metric"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Service  string            `json:"service"`
+	Severity string            `json:"severity"`
+	Status   string            `json:"status"`
+	Message  string            `json:"message"`
+	// Detectors lists the methods that flagged the most extreme sample, e.g.
+	// "zscore", "ewma" and "seasonal".
+	Detectors []string `json:"detectors"`
+	// Value is the most extreme observed value and Expected, Lower and Upper
+	// the baseline and control limits it was compared against.
+	Value     float64    `json:"value"`
+	Expected  float64    `json:"expected"`
+	Lower     float64    `json:"lower"`
+	Upper     float64    `json:"upper"`
+	Score     float64    `json:"score"` // |z| of the most extreme sample
+	StartedAt time.Time  `json:"startedAt"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	// Timestamp is when the anomaly was last observed.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SeasonalBaseline holds a series' running statistics for each hour of the
+// week (0 is Sunday 00:00 UTC), so detectors survive restarts.
+type SeasonalBaseline struct {
+	Series    string           `json:"series"`
+	Buckets   []SeasonalBucket `json:"buckets"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+}
+
+// SeasonalBucket is a Welford accumulator for one hour of the week. Weeks
+// counts the distinct weeks that contributed samples.
+type SeasonalBucket struct {
+	Count    int64   `json:"count"`
+	Mean     float64 `json:"mean"`
+	M2       float64 `json:"m2"`
+	Weeks    int     `json:"weeks"`
+	LastWeek int64   `json:"lastWeek"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"orchestrator/internal/executor"
+	"orchestrator/internal/manifest"
+)
+
+const (
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+)
+
+// ChatSession is a ChatOps conversation owned by one user.
+type ChatSession struct {
+	ID           string    `json:"id"`
+	User         string    `json:"user"`
+	Title        string    `json:"title"`
+	MessageCount int       `json:"messageCount"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// ChatMessage is one message in a session. Degraded marks assistant replies
+// that are the built-in fallback rather than an AI engine answer.
+type ChatMessage struct {
+	ID        string `json:"id"`
+	SessionID string `json:"sessionId"`
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	Code      string `json:"code,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Degraded  bool   `json:"degraded,omitempty"`
+	// ActionID links a reply to the action it proposed or resolved.
+	ActionID string `json:"actionId,omitempty"`
+	// ManifestID links a reply to the validated manifest in Code.
+	ManifestID string    `json:"manifestId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Chat action statuses.
+const (
+	ChatActionPending   = "pending"
+	ChatActionExecuting = "executing"
+	ChatActionExecuted  = "executed"
+	ChatActionFailed    = "failed"
+	ChatActionCancelled = "cancelled"
+	ChatActionExpired   = "expired"
+)
+
+// ChatAction is a cluster change proposed in a conversation. It waits in
+// pending until the user confirms or cancels it, or it expires.
+type ChatAction struct {
+	ID         string            `json:"id"`
+	SessionID  string            `json:"sessionId"`
+	User       string            `json:"user"`
+	Intent     executor.Intent   `json:"intent"`
+	Preview    *executor.Preview `json:"preview"`
+	Status     string            `json:"status"`
+	Result     *executor.Result  `json:"result,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	ExpiresAt  time.Time         `json:"expiresAt"`
+	ResolvedAt *time.Time        `json:"resolvedAt,omitempty"`
+}
+
+// Manifest statuses.
+const (
+	ManifestValid      = "valid"
+	ManifestInvalid    = "invalid"
+	ManifestUnverified = "unverified"
+	ManifestApplied    = "applied"
+	ManifestFailed     = "failed"
+)
+
+// Manifest is Kubernetes YAML generated in a conversation, with the outcome
+// of its latest server-side dry-run or apply. Unverified manifests could not
+// be checked because the cluster was unreachable.
+type Manifest struct {
+	ID         string           `json:"id"`
+	SessionID  string           `json:"sessionId"`
+	User       string           `json:"user"`
+	Content    string           `json:"content"`
+	Status     string           `json:"status"`
+	Validation *manifest.Result `json:"validation"`
+	Error      string           `json:"error,omitempty"`
+	CreatedAt  time.Time        `json:"createdAt"`
+	AppliedAt  *time.Time       `json:"appliedAt,omitempty"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// LogEntry is an audit record of a change made through the API, or of a
+// request refused for lack of permission.
+type LogEntry struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Action    string                 `json:"action"`
+	Target    string                 `json:"target"`
+	Status    string                 `json:"status"`
+	User      string                 `json:"user"`
+	RequestID string                 `json:"requestId,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	Before    interface{}            `json:"before,omitempty"`
+	After     interface{}            `json:"after,omitempty"`
+	Duration  int64                  `json:"durationMs"`
+	Error     string                 `json:"error,omitempty"`
+	Details   map[string]interface{} `json:"details"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+type MetricSnapshot struct {
+	Timestamp  time.Time                     `json:"timestamp"`
+	Source     string                        `json:"source,omitempty"`
+	Metrics    map[string]float64            `json:"metrics"`
+	Namespaces map[string]map[string]float64 `json:"namespaces,omitempty"`
+	Series     []Sample                      `json:"series,omitempty"`
+}
+
+// Sample is a single labelled value, e.g. the CPU usage of one deployment.
+// Cluster-wide values without labels are stored in MetricSnapshot.Metrics.
+type Sample struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// Rollup summarises one series over a fixed bucket of a retention tier, e.g.
+// five minutes of a pod's CPU usage. Series is the series' canonical key.
+type Rollup struct {
+	Series     string            `json:"series"`
+	Name       string            `json:"name"`
+	Labels     map[string]string `json:"labels,omitempty"`
+	Resolution int64             `json:"resolution"` // seconds
+	Timestamp  time.Time         `json:"timestamp"`  // bucket start
+	Min        float64           `json:"min"`
+	Max        float64           `json:"max"`
+	Avg        float64           `json:"avg"`
+	P95        float64           `json:"p95"`
+	Count      int               `json:"count"`
+}
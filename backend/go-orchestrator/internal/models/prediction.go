@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Prediction is a forecast for one metric produced by the AI engine. The
+// dashboard shows the newest unexpired prediction of each type.
+type Prediction struct {
+	ID              string            `json:"id"`
+	Type            string            `json:"type"` // "cpu", "memory" or "traffic"
+	Metric          string            `json:"metric"`
+	Message         string            `json:"message"`
+	Confidence      float64           `json:"confidence"`
+	TimeWindow      string            `json:"timeWindow"`
+	Peak            float64           `json:"peak"`
+	Points          []PredictionPoint `json:"points,omitempty"`
+	Recommendations []string          `json:"recommendations,omitempty"`
+	CreatedAt       time.Time         `json:"createdAt"`
+	ExpiresAt       time.Time         `json:"expiresAt"`
+}
+
+type PredictionPoint struct {
+	Timestamp  time.Time `json:"timestamp"`
+	Value      float64   `json:"value"`
+	Confidence float64   `json:"confidence"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"orchestrator/internal/executor"
+)
+
+// Recommendation statuses.
+const (
+	RecommendationPending     = "pending"
+	RecommendationApplying    = "applying"
+	RecommendationApplied     = "applied"
+	RecommendationFailed      = "failed"
+	RecommendationRejected    = "rejected"
+	RecommendationRollingBack = "rolling_back"
+	RecommendationRolledBack  = "rolled_back"
+)
+
+// RecommendationSourceAIEngine marks recommendations generated by the AI
+// engine's /api/recommendations endpoint.
+const RecommendationSourceAIEngine = "ai-engine"
+
+// Recommendation is a suggested change. Informational recommendations carry
+// no payload and cannot be applied. UpdatedAt is when it was last saved, which
+// for a decided recommendation is when it was decided.
+type Recommendation struct {
+	ID            string           `json:"id"`
+	Type          string           `json:"type"`
+	Target        string           `json:"target"`
+	Action        string           `json:"action"`
+	Confidence    float64          `json:"confidence"`
+	Reasoning     string           `json:"reasoning"`
+	Impact        string           `json:"impact"`
+	Status        string           `json:"status"`
+	Source        string           `json:"source,omitempty"`
+	CreatedAt     time.Time        `json:"createdAt"`
+	UpdatedAt     time.Time        `json:"updatedAt"`
+	Payload       *executor.Action `json:"payload,omitempty"`
+	Informational bool             `json:"informational,omitempty"`
+	Before        *executor.State  `json:"before,omitempty"`
+	After         *executor.State  `json:"after,omitempty"`
+	Error         string           `json:"error,omitempty"`
+	AppliedAt     *time.Time       `json:"appliedAt,omitempty"`
+	RolledBackAt  *time.Time       `json:"rolledBackAt,omitempty"`
+}
@@ -0,0 +1,91 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"orchestrator/internal/auth"
+)
+
+type policyKey struct{}
+
+// request is what Authorize needs to know about the request being served.
+type request struct {
+	policy *Policy
+	method string
+	path   string
+}
+
+// Require rejects callers without perm in any namespace with 403, and makes
+// the policy available to Authorize. Which namespace an action touches is
+// usually only known once its handler has loaded the object, so handlers
+// check again with Authorize.
+func Require(p *Policy, perm Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), policyKey{}, request{
+			policy: p,
+			method: c.Request.Method,
+			path:   c.Request.URL.Path,
+		})
+		c.Request = c.Request.WithContext(ctx)
+
+		identity := auth.IdentityFromContext(ctx)
+		if !p.AllowedAnywhere(identity, perm) {
+			p.denied(ctx, denial(ctx, identity, perm, ""))
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   fmt.Sprintf("%v: %s permission required", ErrForbidden, perm),
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Authorize checks that the caller holds perm in namespace, recording a
+// denial if not. It fails closed when the request did not pass through
+// Require.
+func Authorize(ctx context.Context, perm Permission, namespace string) error {
+	req, ok := ctx.Value(policyKey{}).(request)
+	if !ok {
+		return fmt.Errorf("%w: no access policy for this request", ErrForbidden)
+	}
+	identity := auth.IdentityFromContext(ctx)
+	if req.policy.Allowed(identity, perm, namespace) {
+		return nil
+	}
+	req.policy.denied(ctx, denial(ctx, identity, perm, namespace))
+	if namespace == "" {
+		return fmt.Errorf("%w: %s permission required for cluster-scoped objects", ErrForbidden, perm)
+	}
+	return fmt.Errorf("%w: %s permission required in namespace %s", ErrForbidden, perm, namespace)
+}
+
+// ScopeFor returns where the caller holds perm, for filtering results across
+// namespaces. It fails closed with an empty scope when the request did not
+// pass through Require.
+func ScopeFor(ctx context.Context, perm Permission) Scope {
+	req, ok := ctx.Value(policyKey{}).(request)
+	if !ok {
+		return Scope{}
+	}
+	return req.policy.Scope(auth.IdentityFromContext(ctx), perm)
+}
+
+func denial(ctx context.Context, identity *auth.Identity, perm Permission, namespace string) Denial {
+	req, _ := ctx.Value(policyKey{}).(request)
+	d := Denial{
+		User:       "anonymous",
+		Permission: perm,
+		Namespace:  namespace,
+		Method:     req.method,
+		Path:       req.path,
+	}
+	if identity != nil {
+		d.User = identity.Subject
+	}
+	return d
+}
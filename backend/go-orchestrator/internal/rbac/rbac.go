@@ -0,0 +1,303 @@
+// Package rbac decides what an authenticated caller may do. Callers hold
+// roles, each granting a set of permissions, either cluster-wide or in a list
+// of namespaces.
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"orchestrator/internal/auth"
+)
+
+// ErrForbidden is returned when the caller lacks a permission.
+var ErrForbidden = errors.New("permission denied")
+
+// Role is a named set of permissions.
+type Role string
+
+// Roles, from least to most privileged.
+const (
+	RoleNone     Role = ""
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+// Permission is what a route or action requires.
+type Permission string
+
+const (
+	// PermRead covers every read-only route and the WebSocket.
+	PermRead Permission = "read"
+	// PermChat covers conversations and the caller's own sessions and
+	// proposals, but not confirming a change.
+	PermChat Permission = "chat"
+	// PermOperate covers changes to workloads: applying, rejecting and
+	// rolling back recommendations, starting and stopping deployments,
+	// confirming chat changes and applying manifests.
+	PermOperate Permission = "operate"
+	// PermDelete covers deleting workloads.
+	PermDelete Permission = "delete"
+	// PermAdmin covers changes that can grant access or expose credentials:
+	// applying manifests with RBAC objects, Secrets or service accounts. Held
+	// cluster-wide, it also covers reading the action log.
+	PermAdmin Permission = "admin"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:   {PermRead, PermChat},
+	RoleOperator: {PermRead, PermChat, PermOperate},
+	RoleAdmin:    {PermRead, PermChat, PermOperate, PermDelete, PermAdmin},
+}
+
+// Grants reports whether the role includes perm.
+func (r Role) Grants(perm Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Binding gives a role to subjects and groups. Without namespaces the role
+// applies cluster-wide, including to cluster-scoped objects.
+type Binding struct {
+	Role       Role     `json:"role"`
+	Subjects   []string `json:"subjects,omitempty"`
+	Groups     []string `json:"groups,omitempty"`
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// matches reports whether the binding names the identity.
+func (b Binding) matches(identity *auth.Identity) bool {
+	for _, subject := range b.Subjects {
+		if subject == identity.Subject {
+			return true
+		}
+	}
+	for _, group := range b.Groups {
+		for _, member := range identity.Groups {
+			if group == member {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// covers reports whether the binding applies in namespace. An empty
+// namespace stands for cluster-scoped objects.
+func (b Binding) covers(namespace string) bool {
+	if len(b.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range b.Namespaces {
+		if ns == "*" || (ns == namespace && namespace != "") {
+			return true
+		}
+	}
+	return false
+}
+
+// Config is the access policy.
+type Config struct {
+	// DefaultRole is granted cluster-wide to every authenticated caller.
+	// RoleNone leaves callers without a binding with no access.
+	DefaultRole Role      `json:"defaultRole"`
+	Bindings    []Binding `json:"bindings"`
+}
+
+// Validate rejects unknown roles.
+func (cfg Config) Validate() error {
+	if cfg.DefaultRole != RoleNone {
+		if _, ok := rolePermissions[cfg.DefaultRole]; !ok {
+			return fmt.Errorf("unknown default role %q", cfg.DefaultRole)
+		}
+	}
+	for i, binding := range cfg.Bindings {
+		if _, ok := rolePermissions[binding.Role]; !ok {
+			return fmt.Errorf("binding %d: unknown role %q", i, binding.Role)
+		}
+		if len(binding.Subjects) == 0 && len(binding.Groups) == 0 {
+			return fmt.Errorf("binding %d: no subjects or groups", i)
+		}
+	}
+	return nil
+}
+
+// Denial describes a refused request, for the audit trail.
+type Denial struct {
+	User       string
+	Permission Permission
+	// Namespace is the namespace the permission was checked in, empty for a
+	// route-level check or a cluster-scoped object.
+	Namespace string
+	Method    string
+	Path      string
+}
+
+// Policy evaluates permissions against a Config.
+type Policy struct {
+	cfg      Config
+	onDenied []func(context.Context, Denial)
+}
+
+// New creates a policy after validating cfg.
+func New(cfg Config) (*Policy, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &Policy{cfg: cfg}, nil
+}
+
+// PolicyFromEnv loads the bindings in RBAC_POLICY_FILE (YAML or JSON), adds
+// the subjects listed in RBAC_ADMINS and RBAC_OPERATORS (comma-separated;
+// "group:<name>" names a group), and applies RBAC_DEFAULT_ROLE ("viewer" by
+// default, "none" for no access without a binding).
+func PolicyFromEnv() (*Policy, error) {
+	cfg := Config{DefaultRole: RoleViewer}
+	if path := os.Getenv("RBAC_POLICY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RBAC_POLICY_FILE: %v", err)
+		}
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse RBAC_POLICY_FILE: %v", err)
+		}
+	}
+	switch value := os.Getenv("RBAC_DEFAULT_ROLE"); value {
+	case "":
+	case "none":
+		cfg.DefaultRole = RoleNone
+	default:
+		cfg.DefaultRole = Role(value)
+	}
+	for role, env := range map[Role]string{RoleAdmin: "RBAC_ADMINS", RoleOperator: "RBAC_OPERATORS"} {
+		if binding, ok := bindingFromList(role, os.Getenv(env)); ok {
+			cfg.Bindings = append(cfg.Bindings, binding)
+		}
+	}
+
+	policy, err := New(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid RBAC policy: %v", err)
+	}
+	return policy, nil
+}
+
+func bindingFromList(role Role, value string) (Binding, bool) {
+	binding := Binding{Role: role}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+		case strings.HasPrefix(item, "group:"):
+			binding.Groups = append(binding.Groups, strings.TrimPrefix(item, "group:"))
+		default:
+			binding.Subjects = append(binding.Subjects, item)
+		}
+	}
+	return binding, len(binding.Subjects) > 0 || len(binding.Groups) > 0
+}
+
+// OnDenied registers fn to be called for every refused request.
+func (p *Policy) OnDenied(fn func(context.Context, Denial)) {
+	p.onDenied = append(p.onDenied, fn)
+}
+
+// Allowed reports whether identity holds perm in namespace; an empty
+// namespace asks about cluster-scoped objects. When authentication is
+// disabled every caller is allowed everything.
+func (p *Policy) Allowed(identity *auth.Identity, perm Permission, namespace string) bool {
+	if identity == nil {
+		return false
+	}
+	if identity.Method == auth.MethodNone || p.cfg.DefaultRole.Grants(perm) {
+		return true
+	}
+	for _, binding := range p.cfg.Bindings {
+		if binding.Role.Grants(perm) && binding.covers(namespace) && binding.matches(identity) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowedAnywhere reports whether identity holds perm in at least one
+// namespace.
+func (p *Policy) AllowedAnywhere(identity *auth.Identity, perm Permission) bool {
+	if identity == nil {
+		return false
+	}
+	if identity.Method == auth.MethodNone || p.cfg.DefaultRole.Grants(perm) {
+		return true
+	}
+	for _, binding := range p.cfg.Bindings {
+		if binding.Role.Grants(perm) && binding.matches(identity) {
+			return true
+		}
+	}
+	return false
+}
+
+// Scope is where a caller holds a permission, for filtering queries that span
+// namespaces. The zero Scope allows nothing.
+type Scope struct {
+	// All is set when the permission is held cluster-wide, which includes
+	// cluster-level data that belongs to no namespace.
+	All        bool
+	namespaces map[string]bool
+}
+
+// Allows reports whether the scope includes namespace; an empty namespace
+// stands for cluster-level data.
+func (s Scope) Allows(namespace string) bool {
+	return s.All || (namespace != "" && s.namespaces[namespace])
+}
+
+// Namespaces lists the namespaces of a scope that is not cluster-wide, sorted.
+func (s Scope) Namespaces() []string {
+	namespaces := make([]string, 0, len(s.namespaces))
+	for namespace := range s.namespaces {
+		namespaces = append(namespaces, namespace)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// Scope returns where identity holds perm.
+func (p *Policy) Scope(identity *auth.Identity, perm Permission) Scope {
+	if identity == nil {
+		return Scope{}
+	}
+	if identity.Method == auth.MethodNone || p.cfg.DefaultRole.Grants(perm) {
+		return Scope{All: true}
+	}
+	scope := Scope{namespaces: make(map[string]bool)}
+	for _, binding := range p.cfg.Bindings {
+		if !binding.Role.Grants(perm) || !binding.matches(identity) {
+			continue
+		}
+		if binding.covers("") {
+			return Scope{All: true}
+		}
+		for _, namespace := range binding.Namespaces {
+			scope.namespaces[namespace] = true
+		}
+	}
+	return scope
+}
+
+func (p *Policy) denied(ctx context.Context, denial Denial) {
+	for _, fn := range p.onDenied {
+		fn(ctx, denial)
+	}
+}
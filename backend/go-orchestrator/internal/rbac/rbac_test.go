@@ -0,0 +1,120 @@
+package rbac
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"orchestrator/internal/auth"
+)
+
+func testPolicy(t *testing.T, defaultRole Role) *Policy {
+	t.Helper()
+	policy, err := New(Config{
+		DefaultRole: defaultRole,
+		Bindings: []Binding{
+			{Role: RoleAdmin, Subjects: []string{"root"}},
+			{Role: RoleAdmin, Subjects: []string{"team-admin"}, Namespaces: []string{"payments"}},
+			{Role: RoleOperator, Groups: []string{"payments-team"}, Namespaces: []string{"payments"}},
+			{Role: RoleViewer, Groups: []string{"payments-team"}, Namespaces: []string{"billing"}},
+			{Role: RoleViewer, Groups: []string{"auditors"}, Namespaces: []string{"*"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return policy
+}
+
+func TestPolicyAllowed(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultRole Role
+		identity    *auth.Identity
+		perm        Permission
+		namespace   string
+		want        bool
+	}{
+		{name: "unauthenticated", defaultRole: RoleViewer, perm: PermRead, namespace: "payments"},
+		{name: "authentication disabled", identity: &auth.Identity{Method: auth.MethodNone}, perm: PermAdmin, want: true},
+		{name: "default role reads anywhere", defaultRole: RoleViewer, identity: &auth.Identity{Subject: "anyone"}, perm: PermRead, namespace: "payments", want: true},
+		{name: "default role cannot operate", defaultRole: RoleViewer, identity: &auth.Identity{Subject: "anyone"}, perm: PermOperate, namespace: "payments"},
+		{name: "no default role", identity: &auth.Identity{Subject: "anyone"}, perm: PermRead, namespace: "payments"},
+		{name: "group operator in its namespace", identity: &auth.Identity{Subject: "dev", Groups: []string{"payments-team"}}, perm: PermOperate, namespace: "payments", want: true},
+		{name: "group operator elsewhere", identity: &auth.Identity{Subject: "dev", Groups: []string{"payments-team"}}, perm: PermOperate, namespace: "default"},
+		{name: "operator is not admin", identity: &auth.Identity{Subject: "dev", Groups: []string{"payments-team"}}, perm: PermAdmin, namespace: "payments"},
+		{name: "namespace admin", identity: &auth.Identity{Subject: "team-admin"}, perm: PermAdmin, namespace: "payments", want: true},
+		{name: "namespace admin on cluster-scoped objects", identity: &auth.Identity{Subject: "team-admin"}, perm: PermAdmin},
+		{name: "cluster admin", identity: &auth.Identity{Subject: "root"}, perm: PermAdmin, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := testPolicy(t, tt.defaultRole)
+			if got := policy.Allowed(tt.identity, tt.perm, tt.namespace); got != tt.want {
+				t.Errorf("Allowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicyScope(t *testing.T) {
+	tests := []struct {
+		name           string
+		defaultRole    Role
+		identity       *auth.Identity
+		perm           Permission
+		wantAll        bool
+		wantNamespaces []string
+	}{
+		{name: "unauthenticated", defaultRole: RoleViewer, perm: PermRead, wantNamespaces: []string{}},
+		{name: "authentication disabled", identity: &auth.Identity{Method: auth.MethodNone}, perm: PermAdmin, wantAll: true},
+		{name: "default role", defaultRole: RoleViewer, identity: &auth.Identity{Subject: "anyone"}, perm: PermRead, wantAll: true},
+		{name: "no binding", identity: &auth.Identity{Subject: "anyone"}, perm: PermRead, wantNamespaces: []string{}},
+		{name: "bindings combined", identity: &auth.Identity{Subject: "dev", Groups: []string{"payments-team"}}, perm: PermRead, wantNamespaces: []string{"billing", "payments"}},
+		{name: "only bindings granting the permission", identity: &auth.Identity{Subject: "dev", Groups: []string{"payments-team"}}, perm: PermOperate, wantNamespaces: []string{"payments"}},
+		{name: "wildcard namespace", identity: &auth.Identity{Subject: "audit", Groups: []string{"auditors"}}, perm: PermRead, wantAll: true},
+		{name: "cluster-wide binding", identity: &auth.Identity{Subject: "root", Groups: []string{"payments-team"}}, perm: PermRead, wantAll: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scope := testPolicy(t, tt.defaultRole).Scope(tt.identity, tt.perm)
+			if scope.All != tt.wantAll {
+				t.Errorf("All = %v, want %v", scope.All, tt.wantAll)
+			}
+			if !tt.wantAll && !reflect.DeepEqual(scope.Namespaces(), tt.wantNamespaces) {
+				t.Errorf("Namespaces() = %v, want %v", scope.Namespaces(), tt.wantNamespaces)
+			}
+		})
+	}
+}
+
+func TestScopeAllows(t *testing.T) {
+	namespaced := Scope{namespaces: map[string]bool{"payments": true}}
+	tests := []struct {
+		name      string
+		scope     Scope
+		namespace string
+		want      bool
+	}{
+		{name: "zero scope", namespace: "payments"},
+		{name: "bound namespace", scope: namespaced, namespace: "payments", want: true},
+		{name: "other namespace", scope: namespaced, namespace: "default"},
+		{name: "cluster-level data in a namespaced scope", scope: namespaced},
+		{name: "cluster-wide", scope: Scope{All: true}, namespace: "default", want: true},
+		{name: "cluster-level data in a cluster-wide scope", scope: Scope{All: true}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.Allows(tt.namespace); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.namespace, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeForFailsClosed(t *testing.T) {
+	ctx := auth.WithIdentity(context.Background(), &auth.Identity{Method: auth.MethodNone})
+	if scope := ScopeFor(ctx, PermRead); scope.All || len(scope.Namespaces()) != 0 {
+		t.Errorf("ScopeFor() without Require = %+v, want an empty scope", scope)
+	}
+}
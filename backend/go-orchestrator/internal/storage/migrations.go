@@ -0,0 +1,250 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// migration is a forward-only schema change. Most statements are portable;
+// where the dialects differ a Postgres variant is given.
+type migration struct {
+	version  int
+	name     string
+	sqlite   string
+	postgres string
+}
+
+func (m migration) statement(d dialect) string {
+	if d == dialectPostgres && m.postgres != "" {
+		return m.postgres
+	}
+	return m.sqlite
+}
+
+// Timestamps are stored as Unix milliseconds so range queries behave the same
+// on both backends. Records are stored as JSON next to the columns used for
+// filtering and ordering.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "create recommendations",
+		sqlite: `CREATE TABLE recommendations (
+			id TEXT PRIMARY KEY,
+			status TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			data TEXT NOT NULL
+		)`,
+	},
+	{
+		version: 2,
+		name:    "create action logs",
+		sqlite: `CREATE TABLE action_logs (
+			id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			status TEXT NOT NULL,
+			ts BIGINT NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX action_logs_ts ON action_logs (ts)`,
+	},
+	{
+		version: 3,
+		name:    "create metric snapshots",
+		sqlite: `CREATE TABLE metric_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			ts BIGINT NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX metric_snapshots_ts ON metric_snapshots (ts)`,
+		postgres: `CREATE TABLE metric_snapshots (
+			id BIGSERIAL PRIMARY KEY,
+			ts BIGINT NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX metric_snapshots_ts ON metric_snapshots (ts)`,
+	},
+	{
+		version: 4,
+		name:    "create metric rollups",
+		sqlite: `CREATE TABLE metric_rollups (
+			resolution BIGINT NOT NULL,
+			series TEXT NOT NULL,
+			ts BIGINT NOT NULL,
+			data TEXT NOT NULL,
+			PRIMARY KEY (resolution, series, ts)
+		);
+		CREATE INDEX metric_rollups_ts ON metric_rollups (resolution, ts)`,
+	},
+	{
+		version: 5,
+		name:    "create anomalies",
+		sqlite: `CREATE TABLE anomalies (
+			id TEXT PRIMARY KEY,
+			metric TEXT NOT NULL,
+			severity TEXT NOT NULL,
+			status TEXT NOT NULL,
+			started_at BIGINT NOT NULL,
+			ended_at BIGINT,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX anomalies_started_at ON anomalies (started_at);
+		CREATE TABLE anomaly_baselines (
+			series TEXT PRIMARY KEY,
+			updated_at BIGINT NOT NULL,
+			data TEXT NOT NULL
+		)`,
+	},
+	{
+		version: 6,
+		name:    "create predictions",
+		sqlite: `CREATE TABLE predictions (
+			id TEXT PRIMARY KEY,
+			type TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX predictions_created_at ON predictions (created_at)`,
+	},
+	{
+		version: 7,
+		name:    "create chat sessions",
+		sqlite: `CREATE TABLE chat_sessions (
+			id TEXT PRIMARY KEY,
+			owner TEXT NOT NULL,
+			updated_at BIGINT NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX chat_sessions_owner ON chat_sessions (owner, updated_at);
+		CREATE TABLE chat_messages (
+			id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX chat_messages_session ON chat_messages (session_id, created_at)`,
+	},
+	{
+		version: 8,
+		name:    "create chat actions",
+		sqlite: `CREATE TABLE chat_actions (
+			id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX chat_actions_session ON chat_actions (session_id, status)`,
+	},
+	{
+		version: 9,
+		name:    "create manifests",
+		sqlite: `CREATE TABLE manifests (
+			id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX manifests_session ON manifests (session_id)`,
+	},
+	{
+		version: 10,
+		name:    "index action logs by user and request",
+		sqlite: `ALTER TABLE action_logs ADD COLUMN username TEXT NOT NULL DEFAULT '';
+		ALTER TABLE action_logs ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
+		UPDATE action_logs SET username = COALESCE(json_extract(data, '$.user'), '');
+		CREATE INDEX action_logs_username ON action_logs (username, ts);
+		CREATE INDEX action_logs_request_id ON action_logs (request_id)`,
+		postgres: `ALTER TABLE action_logs ADD COLUMN username TEXT NOT NULL DEFAULT '';
+		ALTER TABLE action_logs ADD COLUMN request_id TEXT NOT NULL DEFAULT '';
+		UPDATE action_logs SET username = COALESCE(data::json->>'user', '');
+		CREATE INDEX action_logs_username ON action_logs (username, ts);
+		CREATE INDEX action_logs_request_id ON action_logs (request_id)`,
+	},
+	{
+		version: 11,
+		name:    "create leases",
+		sqlite: `CREATE TABLE leases (
+			name TEXT PRIMARY KEY,
+			holder TEXT NOT NULL,
+			expires_at BIGINT NOT NULL
+		)`,
+	},
+}
+
+// migrationLockKey is the Postgres advisory lock held while migrating. Any
+// value works as long as every replica uses the same one.
+const migrationLockKey = 72610306
+
+// migrate applies pending migrations. On Postgres, replicas starting together
+// take turns under an advisory lock, so each migration runs once; a SQLite
+// database belongs to a single process.
+func (s *sqlStore) migrate(ctx context.Context) error {
+	// The advisory lock belongs to a session, so everything runs on one
+	// connection
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if s.dialect == dialectPostgres {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+			return fmt.Errorf("failed to take the migration lock: %v", err)
+		}
+		defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	}
+
+	_, err = conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return err
+	}
+
+	applied := make(map[int]bool)
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return err
+		}
+		applied[version] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, m.statement(s.dialect)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d (%s): %v", m.version, m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`),
+			m.version, time.Now().UnixMilli()); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		log.Printf("Applied storage migration %d: %s", m.version, m.name)
+	}
+	return nil
+}
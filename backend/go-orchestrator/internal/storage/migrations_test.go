@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"orchestrator/internal/models"
+)
+
+// openAtVersion creates a SQLite database with only the migrations up to
+// version applied, as an older release would have left it.
+func openAtVersion(t *testing.T, version int) (*sqlStore, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "old.db")
+	store, err := openSQLite(path)
+	if err != nil {
+		t.Fatalf("openSQLite() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	if _, err := store.db.Exec(`CREATE TABLE schema_migrations (
+		version INTEGER PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`); err != nil {
+		t.Fatalf("creating schema_migrations: %v", err)
+	}
+	for _, m := range migrations {
+		if m.version > version {
+			break
+		}
+		if _, err := store.db.Exec(m.statement(dialectSQLite)); err != nil {
+			t.Fatalf("migration %d: %v", m.version, err)
+		}
+		if _, err := store.db.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, m.version, 0); err != nil {
+			t.Fatalf("recording migration %d: %v", m.version, err)
+		}
+	}
+	return store, path
+}
+
+func TestMigrateFromV1(t *testing.T) {
+	ctx := context.Background()
+	old, path := openAtVersion(t, 1)
+	if _, err := old.db.Exec(`INSERT INTO recommendations (id, status, created_at, data) VALUES (?, ?, ?, ?)`,
+		"rec-1", models.RecommendationPending, base.UnixMilli(),
+		`{"id":"rec-1","type":"scale","target":"web","status":"pending"}`); err != nil {
+		t.Fatalf("inserting a v1 recommendation: %v", err)
+	}
+	old.Close()
+
+	store, err := Open(ctx, Config{Driver: "sqlite", DSN: path})
+	if err != nil {
+		t.Fatalf("Open() on a v1 database error = %v", err)
+	}
+	defer store.Close()
+
+	var applied int
+	if err := store.(*sqlStore).db.QueryRow(`SELECT COUNT(*) FROM schema_migrations`).Scan(&applied); err != nil {
+		t.Fatalf("counting migrations: %v", err)
+	}
+	if applied != len(migrations) {
+		t.Errorf("%d migrations applied, want %d", applied, len(migrations))
+	}
+
+	rec, err := store.GetRecommendation(ctx, "rec-1")
+	if err != nil {
+		t.Fatalf("GetRecommendation() error = %v", err)
+	}
+	if rec.Target != "web" || rec.Status != models.RecommendationPending {
+		t.Errorf("GetRecommendation() = %+v, want the v1 record", rec)
+	}
+	// The new tables work on the migrated database
+	if err := store.AppendLog(ctx, &models.LogEntry{Type: "scale", Status: "completed", User: "alice", Timestamp: time.Now()}); err != nil {
+		t.Errorf("AppendLog() error = %v", err)
+	}
+	if err := store.SaveChatSession(ctx, &models.ChatSession{User: "alice"}); err != nil {
+		t.Errorf("SaveChatSession() error = %v", err)
+	}
+
+	// Opening again applies nothing
+	store.Close()
+	store, err = Open(ctx, Config{Driver: "sqlite", DSN: path})
+	if err != nil {
+		t.Fatalf("reopening error = %v", err)
+	}
+	store.Close()
+}
+
+func TestMigrateBackfillsLogUsers(t *testing.T) {
+	ctx := context.Background()
+	old, path := openAtVersion(t, 9)
+	if _, err := old.db.Exec(`INSERT INTO action_logs (id, type, status, ts, data) VALUES (?, ?, ?, ?, ?)`,
+		"log-1", "scale", "completed", base.UnixMilli(),
+		`{"id":"log-1","type":"scale","status":"completed","user":"alice"}`); err != nil {
+		t.Fatalf("inserting a v9 log entry: %v", err)
+	}
+	old.Close()
+
+	store, err := Open(ctx, Config{Driver: "sqlite", DSN: path})
+	if err != nil {
+		t.Fatalf("Open() on a v9 database error = %v", err)
+	}
+	defer store.Close()
+
+	logs, err := store.ListLogs(ctx, LogFilter{User: "alice"})
+	if err != nil {
+		t.Fatalf("ListLogs() error = %v", err)
+	}
+	if len(logs) != 1 || logs[0].ID != "log-1" {
+		t.Errorf("ListLogs(alice) = %+v, want the entry written before the migration", logs)
+	}
+}
@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+func openPostgres(dsn string) (*sqlStore, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("postgres storage requires STORAGE_DSN or DATABASE_URL")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %v", err)
+	}
+	return &sqlStore{db: db, dialect: dialectPostgres}, nil
+}
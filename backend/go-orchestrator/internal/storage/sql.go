@@ -0,0 +1,729 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"orchestrator/internal/models"
+)
+
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+// sqlStore implements Store on top of database/sql for both SQLite and
+// Postgres. Queries are written with "?" placeholders and rebound for
+// Postgres.
+type sqlStore struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
+
+// rebind rewrites "?" placeholders to "$n" for Postgres.
+func (s *sqlStore) rebind(query string) string {
+	if s.dialect != dialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *sqlStore) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return s.db.ExecContext(ctx, s.rebind(query), args...)
+}
+
+func (s *sqlStore) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.QueryContext(ctx, s.rebind(query), args...)
+}
+
+// scanJSON decodes every row's single JSON column into a new T.
+func scanJSON[T any](rows *sql.Rows) ([]T, error) {
+	defer rows.Close()
+
+	items := []T{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var item T
+		if err := json.Unmarshal([]byte(data), &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+func (s *sqlStore) ListRecommendations(ctx context.Context) ([]models.Recommendation, error) {
+	rows, err := s.query(ctx, `SELECT data FROM recommendations ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	return scanJSON[models.Recommendation](rows)
+}
+
+func (s *sqlStore) GetRecommendation(ctx context.Context, id string) (*models.Recommendation, error) {
+	rows, err := s.query(ctx, `SELECT data FROM recommendations WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	recs, err := scanJSON[models.Recommendation](rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(recs) == 0 {
+		return nil, ErrNotFound
+	}
+	return &recs[0], nil
+}
+
+// SaveRecommendation inserts or replaces a recommendation, assigning an ID and
+// creation time when they are unset and stamping the update time.
+func (s *sqlStore) SaveRecommendation(ctx context.Context, rec *models.Recommendation) error {
+	if rec.ID == "" {
+		rec.ID = uuid.NewString()
+	}
+	rec.UpdatedAt = time.Now()
+	if rec.CreatedAt.IsZero() {
+		rec.CreatedAt = rec.UpdatedAt
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx, `INSERT INTO recommendations (id, status, created_at, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET status = excluded.status, created_at = excluded.created_at, data = excluded.data`,
+		rec.ID, rec.Status, rec.CreatedAt.UnixMilli(), string(data))
+	return err
+}
+
+// UpdateRecommendation replaces a recommendation only while its stored status
+// is one of from, so that concurrent requests cannot both act on it. It
+// stamps the update time and returns ErrConflict when the status has moved
+// on.
+func (s *sqlStore) UpdateRecommendation(ctx context.Context, rec *models.Recommendation, from ...string) error {
+	rec.UpdatedAt = time.Now()
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.updateIf(ctx, "recommendations", rec.ID, rec.Status, string(data), from)
+}
+
+// AppendLog stores an action log entry, assigning an ID and timestamp when
+// they are unset.
+func (s *sqlStore) AppendLog(ctx context.Context, entry *models.LogEntry) error {
+	if entry.ID == "" {
+		entry.ID = uuid.NewString()
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx, `INSERT INTO action_logs (id, type, status, username, request_id, ts, data) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID, entry.Type, entry.Status, entry.User, entry.RequestID, entry.Timestamp.UnixMilli(), string(data))
+	return err
+}
+
+func (s *sqlStore) ListLogs(ctx context.Context, filter LogFilter) ([]models.LogEntry, error) {
+	query := `SELECT data FROM action_logs WHERE 1 = 1`
+	var args []interface{}
+	if filter.Type != "" {
+		query += ` AND type = ?`
+		args = append(args, filter.Type)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if filter.User != "" {
+		query += ` AND username = ?`
+		args = append(args, filter.User)
+	}
+	if filter.RequestID != "" {
+		query += ` AND request_id = ?`
+		args = append(args, filter.RequestID)
+	}
+	if !filter.From.IsZero() {
+		query += ` AND ts >= ?`
+		args = append(args, filter.From.UnixMilli())
+	}
+	if !filter.To.IsZero() {
+		query += ` AND ts <= ?`
+		args = append(args, filter.To.UnixMilli())
+	}
+	query += ` ORDER BY ts DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanJSON[models.LogEntry](rows)
+}
+
+func (s *sqlStore) AppendMetricSnapshot(ctx context.Context, snapshot models.MetricSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx, `INSERT INTO metric_snapshots (ts, data) VALUES (?, ?)`,
+		snapshot.Timestamp.UnixMilli(), string(data))
+	return err
+}
+
+// ListMetricSnapshots returns snapshots with from <= timestamp <= to in
+// chronological order.
+func (s *sqlStore) ListMetricSnapshots(ctx context.Context, from, to time.Time) ([]models.MetricSnapshot, error) {
+	rows, err := s.query(ctx, `SELECT data FROM metric_snapshots WHERE ts >= ? AND ts <= ? ORDER BY ts ASC`,
+		from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	return scanJSON[models.MetricSnapshot](rows)
+}
+
+func (s *sqlStore) LatestMetricSnapshot(ctx context.Context) (*models.MetricSnapshot, error) {
+	rows, err := s.query(ctx, `SELECT data FROM metric_snapshots ORDER BY ts DESC LIMIT 1`)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := scanJSON[models.MetricSnapshot](rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, ErrNotFound
+	}
+	return &snapshots[0], nil
+}
+
+func (s *sqlStore) DeleteMetricSnapshotsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.exec(ctx, `DELETE FROM metric_snapshots WHERE ts < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// AppendRollups stores completed rollups in one transaction. Rollups that are
+// already stored are left unchanged, so replaying history is harmless.
+func (s *sqlStore) AppendRollups(ctx context.Context, rollups []models.Rollup) error {
+	if len(rollups) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, s.rebind(`INSERT INTO metric_rollups (resolution, series, ts, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (resolution, series, ts) DO NOTHING`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, rollup := range rollups {
+		data, err := json.Marshal(rollup)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, rollup.Resolution, rollup.Series, rollup.Timestamp.UnixMilli(), string(data)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListRollups returns the rollups of one resolution with from <= bucket start
+// <= to in chronological order.
+func (s *sqlStore) ListRollups(ctx context.Context, resolution time.Duration, from, to time.Time) ([]models.Rollup, error) {
+	rows, err := s.query(ctx, `SELECT data FROM metric_rollups WHERE resolution = ? AND ts >= ? AND ts <= ? ORDER BY ts ASC`,
+		int64(resolution/time.Second), from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	return scanJSON[models.Rollup](rows)
+}
+
+func (s *sqlStore) DeleteRollupsBefore(ctx context.Context, resolution time.Duration, before time.Time) (int64, error) {
+	result, err := s.exec(ctx, `DELETE FROM metric_rollups WHERE resolution = ? AND ts < ?`,
+		int64(resolution/time.Second), before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SaveAnomaly inserts or replaces an anomaly, assigning an ID when unset.
+func (s *sqlStore) SaveAnomaly(ctx context.Context, anomaly *models.Anomaly) error {
+	if anomaly.ID == "" {
+		anomaly.ID = uuid.NewString()
+	}
+
+	data, err := json.Marshal(anomaly)
+	if err != nil {
+		return err
+	}
+	var endedAt interface{}
+	if anomaly.EndedAt != nil {
+		endedAt = anomaly.EndedAt.UnixMilli()
+	}
+	_, err = s.exec(ctx, `INSERT INTO anomalies (id, metric, severity, status, started_at, ended_at, data) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET severity = excluded.severity, status = excluded.status,
+			ended_at = excluded.ended_at, data = excluded.data`,
+		anomaly.ID, anomaly.Metric, anomaly.Severity, anomaly.Status, anomaly.StartedAt.UnixMilli(), endedAt, string(data))
+	return err
+}
+
+// ListAnomalies returns matching anomalies, most recently started first.
+func (s *sqlStore) ListAnomalies(ctx context.Context, filter AnomalyFilter) ([]models.Anomaly, error) {
+	query := `SELECT data FROM anomalies WHERE 1 = 1`
+	var args []interface{}
+	if filter.Metric != "" {
+		query += ` AND metric = ?`
+		args = append(args, filter.Metric)
+	}
+	if filter.Severity != "" {
+		query += ` AND severity = ?`
+		args = append(args, filter.Severity)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if !filter.To.IsZero() {
+		query += ` AND started_at <= ?`
+		args = append(args, filter.To.UnixMilli())
+	}
+	if !filter.From.IsZero() {
+		query += ` AND (ended_at IS NULL OR ended_at >= ?)`
+		args = append(args, filter.From.UnixMilli())
+	}
+	query += ` ORDER BY started_at DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanJSON[models.Anomaly](rows)
+}
+
+// SaveSeasonalBaselines upserts the baselines in one transaction.
+func (s *sqlStore) SaveSeasonalBaselines(ctx context.Context, baselines []models.SeasonalBaseline) error {
+	if len(baselines) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.PrepareContext(ctx, s.rebind(`INSERT INTO anomaly_baselines (series, updated_at, data) VALUES (?, ?, ?)
+		ON CONFLICT (series) DO UPDATE SET updated_at = excluded.updated_at, data = excluded.data`))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, baseline := range baselines {
+		data, err := json.Marshal(baseline)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, baseline.Series, baseline.UpdatedAt.UnixMilli(), string(data)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqlStore) ListSeasonalBaselines(ctx context.Context) ([]models.SeasonalBaseline, error) {
+	rows, err := s.query(ctx, `SELECT data FROM anomaly_baselines`)
+	if err != nil {
+		return nil, err
+	}
+	return scanJSON[models.SeasonalBaseline](rows)
+}
+
+// SavePrediction inserts or replaces a prediction, assigning an ID and
+// creation time when they are unset.
+func (s *sqlStore) SavePrediction(ctx context.Context, prediction *models.Prediction) error {
+	if prediction.ID == "" {
+		prediction.ID = uuid.NewString()
+	}
+	if prediction.CreatedAt.IsZero() {
+		prediction.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(prediction)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx, `INSERT INTO predictions (id, type, created_at, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET type = excluded.type, created_at = excluded.created_at, data = excluded.data`,
+		prediction.ID, prediction.Type, prediction.CreatedAt.UnixMilli(), string(data))
+	return err
+}
+
+// ListPredictions returns matching predictions, newest first.
+func (s *sqlStore) ListPredictions(ctx context.Context, filter PredictionFilter) ([]models.Prediction, error) {
+	query := `SELECT data FROM predictions WHERE 1 = 1`
+	var args []interface{}
+	if filter.Type != "" {
+		query += ` AND type = ?`
+		args = append(args, filter.Type)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND created_at >= ?`
+		args = append(args, filter.Since.UnixMilli())
+	}
+	query += ` ORDER BY created_at DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanJSON[models.Prediction](rows)
+}
+
+func (s *sqlStore) DeletePredictionsBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := s.exec(ctx, `DELETE FROM predictions WHERE created_at < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SaveChatSession inserts or replaces a session, assigning an ID and creation
+// time when they are unset. UpdatedAt defaults to the creation time.
+func (s *sqlStore) SaveChatSession(ctx context.Context, session *models.ChatSession) error {
+	if session.ID == "" {
+		session.ID = uuid.NewString()
+	}
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = time.Now()
+	}
+	if session.UpdatedAt.IsZero() {
+		session.UpdatedAt = session.CreatedAt
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx, `INSERT INTO chat_sessions (id, owner, updated_at, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, updated_at = excluded.updated_at, data = excluded.data`,
+		session.ID, session.User, session.UpdatedAt.UnixMilli(), string(data))
+	return err
+}
+
+func (s *sqlStore) GetChatSession(ctx context.Context, id string) (*models.ChatSession, error) {
+	rows, err := s.query(ctx, `SELECT data FROM chat_sessions WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := scanJSON[models.ChatSession](rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNotFound
+	}
+	return &sessions[0], nil
+}
+
+// ListChatSessions returns the user's sessions, most recently active first.
+// Limit 0 means no limit.
+func (s *sqlStore) ListChatSessions(ctx context.Context, user string, limit int) ([]models.ChatSession, error) {
+	query := `SELECT data FROM chat_sessions WHERE owner = ? ORDER BY updated_at DESC`
+	args := []interface{}{user}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanJSON[models.ChatSession](rows)
+}
+
+// DeleteChatSession removes a session with its messages, actions and
+// manifests.
+func (s *sqlStore) DeleteChatSession(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM chat_sessions WHERE id = ?`), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM chat_messages WHERE session_id = ?`), id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM chat_actions WHERE session_id = ?`), id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.rebind(`DELETE FROM manifests WHERE session_id = ?`), id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AppendChatMessage stores a message, assigning an ID and creation time when
+// they are unset.
+func (s *sqlStore) AppendChatMessage(ctx context.Context, message *models.ChatMessage) error {
+	if message.ID == "" {
+		message.ID = uuid.NewString()
+	}
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx, `INSERT INTO chat_messages (id, session_id, created_at, data) VALUES (?, ?, ?, ?)`,
+		message.ID, message.SessionID, message.CreatedAt.UnixMilli(), string(data))
+	return err
+}
+
+// ListChatMessages returns a session's messages oldest first. A positive
+// limit keeps only the most recent messages.
+func (s *sqlStore) ListChatMessages(ctx context.Context, sessionID string, limit int) ([]models.ChatMessage, error) {
+	query := `SELECT data FROM chat_messages WHERE session_id = ? ORDER BY created_at DESC, id DESC`
+	args := []interface{}{sessionID}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := scanJSON[models.ChatMessage](rows)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// SaveChatAction inserts or replaces a chat action, assigning an ID and
+// creation time when they are unset.
+func (s *sqlStore) SaveChatAction(ctx context.Context, action *models.ChatAction) error {
+	if action.ID == "" {
+		action.ID = uuid.NewString()
+	}
+	if action.CreatedAt.IsZero() {
+		action.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx, `INSERT INTO chat_actions (id, session_id, status, created_at, data) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET session_id = excluded.session_id, status = excluded.status, data = excluded.data`,
+		action.ID, action.SessionID, action.Status, action.CreatedAt.UnixMilli(), string(data))
+	return err
+}
+
+// UpdateChatAction replaces a chat action only while its stored status is
+// one of from, so that concurrent requests cannot both resolve it. It returns
+// ErrConflict otherwise.
+func (s *sqlStore) UpdateChatAction(ctx context.Context, action *models.ChatAction, from ...string) error {
+	data, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+	return s.updateIf(ctx, "chat_actions", action.ID, action.Status, string(data), from)
+}
+
+func (s *sqlStore) GetChatAction(ctx context.Context, id string) (*models.ChatAction, error) {
+	rows, err := s.query(ctx, `SELECT data FROM chat_actions WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	actions, err := scanJSON[models.ChatAction](rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(actions) == 0 {
+		return nil, ErrNotFound
+	}
+	return &actions[0], nil
+}
+
+// ListChatActions returns matching chat actions, newest first.
+func (s *sqlStore) ListChatActions(ctx context.Context, filter ChatActionFilter) ([]models.ChatAction, error) {
+	query := `SELECT data FROM chat_actions WHERE 1 = 1`
+	var args []interface{}
+	if filter.SessionID != "" {
+		query += ` AND session_id = ?`
+		args = append(args, filter.SessionID)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	query += ` ORDER BY created_at DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanJSON[models.ChatAction](rows)
+}
+
+// SaveManifest inserts or replaces a manifest, assigning an ID and creation
+// time when they are unset.
+func (s *sqlStore) SaveManifest(ctx context.Context, manifest *models.Manifest) error {
+	if manifest.ID == "" {
+		manifest.ID = uuid.NewString()
+	}
+	if manifest.CreatedAt.IsZero() {
+		manifest.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	_, err = s.exec(ctx, `INSERT INTO manifests (id, session_id, status, created_at, data) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET status = excluded.status, data = excluded.data`,
+		manifest.ID, manifest.SessionID, manifest.Status, manifest.CreatedAt.UnixMilli(), string(data))
+	return err
+}
+
+func (s *sqlStore) GetManifest(ctx context.Context, id string) (*models.Manifest, error) {
+	rows, err := s.query(ctx, `SELECT data FROM manifests WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := scanJSON[models.Manifest](rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(manifests) == 0 {
+		return nil, ErrNotFound
+	}
+	return &manifests[0], nil
+}
+
+// AcquireLease takes or renews the named lease for holder until ttl from
+// now. It reports false while another holder's lease has not expired.
+func (s *sqlStore) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	result, err := s.exec(ctx, `INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE leases.holder = excluded.holder OR leases.expires_at < ?`,
+		name, holder, now.Add(ttl).UnixMilli(), now.UnixMilli())
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// updateIf sets the status and data of the record id in table when its
+// stored status is one of from, returning ErrConflict when none matched.
+func (s *sqlStore) updateIf(ctx context.Context, table, id, status, data string, from []string) error {
+	if len(from) == 0 {
+		return ErrConflict
+	}
+	query := `UPDATE ` + table + ` SET status = ?, data = ? WHERE id = ? AND status IN (?` + strings.Repeat(", ?", len(from)-1) + `)`
+	args := []interface{}{status, data, id}
+	for _, state := range from {
+		args = append(args, state)
+	}
+	result, err := s.exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// IsNotFound reports whether err is ErrNotFound.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsConflict reports whether err is ErrConflict.
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
@@ -0,0 +1,557 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"orchestrator/internal/models"
+)
+
+func openTestStore(t *testing.T) Store {
+	t.Helper()
+	store, err := Open(context.Background(), Config{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// base is a fixed time with millisecond precision, as stored.
+var base = time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)
+
+func at(minutes int) time.Time {
+	return base.Add(time.Duration(minutes) * time.Minute)
+}
+
+func TestRecommendations(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+
+	older := &models.Recommendation{Type: "scale", Target: "web", Status: models.RecommendationPending, CreatedAt: at(0)}
+	newer := &models.Recommendation{Type: "scale", Target: "api", Status: models.RecommendationPending, CreatedAt: at(1)}
+	for _, rec := range []*models.Recommendation{older, newer} {
+		if err := store.SaveRecommendation(ctx, rec); err != nil {
+			t.Fatalf("SaveRecommendation() error = %v", err)
+		}
+	}
+	if older.ID == "" || older.UpdatedAt.IsZero() {
+		t.Errorf("SaveRecommendation() left ID %q, UpdatedAt %v unset", older.ID, older.UpdatedAt)
+	}
+
+	got, err := store.GetRecommendation(ctx, older.ID)
+	if err != nil {
+		t.Fatalf("GetRecommendation() error = %v", err)
+	}
+	if got.Target != "web" || !got.CreatedAt.Equal(at(0)) {
+		t.Errorf("GetRecommendation() = %+v, want the saved recommendation", got)
+	}
+	if _, err := store.GetRecommendation(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("GetRecommendation(missing) error = %v, want ErrNotFound", err)
+	}
+
+	recs, err := store.ListRecommendations(ctx)
+	if err != nil {
+		t.Fatalf("ListRecommendations() error = %v", err)
+	}
+	if ids := recommendationIDs(recs); !reflect.DeepEqual(ids, []string{newer.ID, older.ID}) {
+		t.Errorf("ListRecommendations() = %v, want newest first", ids)
+	}
+
+	// Saving again replaces the record
+	older.Status = models.RecommendationRejected
+	if err := store.SaveRecommendation(ctx, older); err != nil {
+		t.Fatalf("SaveRecommendation() error = %v", err)
+	}
+	if got, _ := store.GetRecommendation(ctx, older.ID); got.Status != models.RecommendationRejected {
+		t.Errorf("status after save = %s, want %s", got.Status, models.RecommendationRejected)
+	}
+	if recs, _ := store.ListRecommendations(ctx); len(recs) != 2 {
+		t.Errorf("ListRecommendations() after save returned %d, want 2", len(recs))
+	}
+}
+
+func recommendationIDs(recs []models.Recommendation) []string {
+	ids := []string{}
+	for _, rec := range recs {
+		ids = append(ids, rec.ID)
+	}
+	return ids
+}
+
+func TestUpdateRecommendation(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	rec := &models.Recommendation{Status: models.RecommendationFailed}
+	if err := store.SaveRecommendation(ctx, rec); err != nil {
+		t.Fatalf("SaveRecommendation() error = %v", err)
+	}
+
+	rec.Status = models.RecommendationApplying
+	if err := store.UpdateRecommendation(ctx, rec, models.RecommendationPending); !IsConflict(err) {
+		t.Fatalf("UpdateRecommendation(from pending) error = %v, want ErrConflict", err)
+	}
+	if err := store.UpdateRecommendation(ctx, rec, models.RecommendationPending, models.RecommendationFailed); err != nil {
+		t.Fatalf("UpdateRecommendation(from pending or failed) error = %v", err)
+	}
+	// A second request finds it already claimed
+	if err := store.UpdateRecommendation(ctx, rec, models.RecommendationPending, models.RecommendationFailed); !IsConflict(err) {
+		t.Fatalf("second UpdateRecommendation() error = %v, want ErrConflict", err)
+	}
+	if got, _ := store.GetRecommendation(ctx, rec.ID); got.Status != models.RecommendationApplying {
+		t.Errorf("status = %s, want %s", got.Status, models.RecommendationApplying)
+	}
+	missing := &models.Recommendation{ID: "missing", Status: models.RecommendationApplying}
+	if err := store.UpdateRecommendation(ctx, missing, models.RecommendationPending); !IsConflict(err) {
+		t.Errorf("UpdateRecommendation(missing) error = %v, want ErrConflict", err)
+	}
+}
+
+func TestListLogs(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	entries := []models.LogEntry{
+		{ID: "a", Type: "scale", Status: "completed", User: "alice", RequestID: "r1", Timestamp: at(0)},
+		{ID: "b", Type: "scale", Status: "failed", User: "bob", RequestID: "r2", Timestamp: at(1)},
+		{ID: "c", Type: "delete", Status: "completed", User: "alice", RequestID: "r3", Timestamp: at(2)},
+	}
+	for i := range entries {
+		if err := store.AppendLog(ctx, &entries[i]); err != nil {
+			t.Fatalf("AppendLog() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter LogFilter
+		want   []string
+	}{
+		{name: "everything, newest first", want: []string{"c", "b", "a"}},
+		{name: "type", filter: LogFilter{Type: "scale"}, want: []string{"b", "a"}},
+		{name: "status", filter: LogFilter{Status: "completed"}, want: []string{"c", "a"}},
+		{name: "user", filter: LogFilter{User: "bob"}, want: []string{"b"}},
+		{name: "request", filter: LogFilter{RequestID: "r3"}, want: []string{"c"}},
+		{name: "time range", filter: LogFilter{From: at(1), To: at(1)}, want: []string{"b"}},
+		{name: "limit", filter: LogFilter{Limit: 1}, want: []string{"c"}},
+		{name: "no match", filter: LogFilter{User: "carol"}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs, err := store.ListLogs(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListLogs() error = %v", err)
+			}
+			ids := []string{}
+			for _, entry := range logs {
+				ids = append(ids, entry.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ListLogs(%+v) = %v, want %v", tt.filter, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetricSnapshots(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	if _, err := store.LatestMetricSnapshot(ctx); !IsNotFound(err) {
+		t.Errorf("LatestMetricSnapshot() on an empty store error = %v, want ErrNotFound", err)
+	}
+
+	for i := 0; i < 3; i++ {
+		snapshot := models.MetricSnapshot{Timestamp: at(i), Metrics: map[string]float64{"cpu": float64(i)}}
+		if err := store.AppendMetricSnapshot(ctx, snapshot); err != nil {
+			t.Fatalf("AppendMetricSnapshot() error = %v", err)
+		}
+	}
+
+	snapshots, err := store.ListMetricSnapshots(ctx, at(1), at(2))
+	if err != nil {
+		t.Fatalf("ListMetricSnapshots() error = %v", err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Metrics["cpu"] != 1 || snapshots[1].Metrics["cpu"] != 2 {
+		t.Errorf("ListMetricSnapshots() = %+v, want the last two in order", snapshots)
+	}
+	latest, err := store.LatestMetricSnapshot(ctx)
+	if err != nil || latest.Metrics["cpu"] != 2 {
+		t.Errorf("LatestMetricSnapshot() = %+v, %v, want the last one", latest, err)
+	}
+
+	deleted, err := store.DeleteMetricSnapshotsBefore(ctx, at(2))
+	if err != nil || deleted != 2 {
+		t.Errorf("DeleteMetricSnapshotsBefore() = %d, %v, want 2", deleted, err)
+	}
+	if snapshots, _ := store.ListMetricSnapshots(ctx, at(0), at(2)); len(snapshots) != 1 {
+		t.Errorf("%d snapshots left, want 1", len(snapshots))
+	}
+}
+
+func TestRollups(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	rollup := func(resolution time.Duration, minutes int, avg float64) models.Rollup {
+		return models.Rollup{Series: "cpu", Name: "cpu", Resolution: int64(resolution / time.Second), Timestamp: at(minutes), Avg: avg}
+	}
+	if err := store.AppendRollups(ctx, []models.Rollup{
+		rollup(time.Minute, 0, 1), rollup(time.Minute, 1, 2), rollup(time.Hour, 0, 10),
+	}); err != nil {
+		t.Fatalf("AppendRollups() error = %v", err)
+	}
+	// Replayed rollups are ignored
+	if err := store.AppendRollups(ctx, []models.Rollup{rollup(time.Minute, 0, 99)}); err != nil {
+		t.Fatalf("AppendRollups() replay error = %v", err)
+	}
+
+	minutes, err := store.ListRollups(ctx, time.Minute, at(0), at(1))
+	if err != nil {
+		t.Fatalf("ListRollups() error = %v", err)
+	}
+	if len(minutes) != 2 || minutes[0].Avg != 1 || minutes[1].Avg != 2 {
+		t.Errorf("ListRollups(minute) = %+v, want the two minute rollups unchanged", minutes)
+	}
+	if hours, _ := store.ListRollups(ctx, time.Hour, at(0), at(60)); len(hours) != 1 || hours[0].Avg != 10 {
+		t.Errorf("ListRollups(hour) = %+v, want the hour rollup", hours)
+	}
+
+	deleted, err := store.DeleteRollupsBefore(ctx, time.Minute, at(1))
+	if err != nil || deleted != 1 {
+		t.Errorf("DeleteRollupsBefore() = %d, %v, want 1", deleted, err)
+	}
+	if hours, _ := store.ListRollups(ctx, time.Hour, at(0), at(60)); len(hours) != 1 {
+		t.Error("DeleteRollupsBefore() removed another resolution")
+	}
+}
+
+func TestListAnomalies(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	ended := at(5)
+	anomalies := []models.Anomaly{
+		{ID: "old", Metric: "cpu", Severity: "high", Status: models.AnomalyStatusResolved, StartedAt: at(0), EndedAt: &ended},
+		{ID: "active", Metric: "memory", Severity: "low", Status: models.AnomalyStatusActive, StartedAt: at(10)},
+	}
+	for i := range anomalies {
+		if err := store.SaveAnomaly(ctx, &anomalies[i]); err != nil {
+			t.Fatalf("SaveAnomaly() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter AnomalyFilter
+		want   []string
+	}{
+		{name: "everything, most recent first", want: []string{"active", "old"}},
+		{name: "metric", filter: AnomalyFilter{Metric: "cpu"}, want: []string{"old"}},
+		{name: "severity", filter: AnomalyFilter{Severity: "low"}, want: []string{"active"}},
+		{name: "status", filter: AnomalyFilter{Status: models.AnomalyStatusResolved}, want: []string{"old"}},
+		{name: "overlapping the range", filter: AnomalyFilter{From: at(3), To: at(4)}, want: []string{"old"}},
+		{name: "active ones extend to now", filter: AnomalyFilter{From: at(60)}, want: []string{"active"}},
+		{name: "before either started", filter: AnomalyFilter{To: at(-1)}, want: []string{}},
+		{name: "limit", filter: AnomalyFilter{Limit: 1}, want: []string{"active"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.ListAnomalies(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListAnomalies() error = %v", err)
+			}
+			ids := []string{}
+			for _, anomaly := range got {
+				ids = append(ids, anomaly.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ListAnomalies(%+v) = %v, want %v", tt.filter, ids, tt.want)
+			}
+		})
+	}
+
+	// Resolving the active anomaly updates it in place
+	anomalies[1].Status = models.AnomalyStatusResolved
+	anomalies[1].EndedAt = &ended
+	if err := store.SaveAnomaly(ctx, &anomalies[1]); err != nil {
+		t.Fatalf("SaveAnomaly() error = %v", err)
+	}
+	if active, _ := store.ListAnomalies(ctx, AnomalyFilter{Status: models.AnomalyStatusActive}); len(active) != 0 {
+		t.Errorf("%d active anomalies after resolving, want 0", len(active))
+	}
+}
+
+func TestSeasonalBaselines(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	if err := store.SaveSeasonalBaselines(ctx, []models.SeasonalBaseline{
+		{Series: "cpu", Buckets: []models.SeasonalBucket{{Count: 1, Mean: 0.5}}, UpdatedAt: at(0)},
+		{Series: "memory", UpdatedAt: at(0)},
+	}); err != nil {
+		t.Fatalf("SaveSeasonalBaselines() error = %v", err)
+	}
+	if err := store.SaveSeasonalBaselines(ctx, []models.SeasonalBaseline{
+		{Series: "cpu", Buckets: []models.SeasonalBucket{{Count: 2, Mean: 0.7}}, UpdatedAt: at(1)},
+	}); err != nil {
+		t.Fatalf("SaveSeasonalBaselines() update error = %v", err)
+	}
+
+	baselines, err := store.ListSeasonalBaselines(ctx)
+	if err != nil {
+		t.Fatalf("ListSeasonalBaselines() error = %v", err)
+	}
+	bySeries := map[string]models.SeasonalBaseline{}
+	for _, baseline := range baselines {
+		bySeries[baseline.Series] = baseline
+	}
+	if len(bySeries) != 2 {
+		t.Fatalf("ListSeasonalBaselines() = %+v, want two series", baselines)
+	}
+	if buckets := bySeries["cpu"].Buckets; len(buckets) != 1 || buckets[0].Count != 2 {
+		t.Errorf("cpu buckets = %+v, want the updated ones", buckets)
+	}
+}
+
+func TestPredictions(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	predictions := []models.Prediction{
+		{ID: "cpu-old", Type: "cpu", CreatedAt: at(0)},
+		{ID: "memory", Type: "memory", CreatedAt: at(1)},
+		{ID: "cpu-new", Type: "cpu", CreatedAt: at(2)},
+	}
+	for i := range predictions {
+		if err := store.SavePrediction(ctx, &predictions[i]); err != nil {
+			t.Fatalf("SavePrediction() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter PredictionFilter
+		want   []string
+	}{
+		{name: "everything, newest first", want: []string{"cpu-new", "memory", "cpu-old"}},
+		{name: "type", filter: PredictionFilter{Type: "cpu"}, want: []string{"cpu-new", "cpu-old"}},
+		{name: "since", filter: PredictionFilter{Since: at(1)}, want: []string{"cpu-new", "memory"}},
+		{name: "limit", filter: PredictionFilter{Type: "cpu", Limit: 1}, want: []string{"cpu-new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.ListPredictions(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListPredictions() error = %v", err)
+			}
+			ids := []string{}
+			for _, prediction := range got {
+				ids = append(ids, prediction.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ListPredictions(%+v) = %v, want %v", tt.filter, ids, tt.want)
+			}
+		})
+	}
+
+	deleted, err := store.DeletePredictionsBefore(ctx, at(2))
+	if err != nil || deleted != 2 {
+		t.Errorf("DeletePredictionsBefore() = %d, %v, want 2", deleted, err)
+	}
+}
+
+func TestChatSessions(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	sessions := []models.ChatSession{
+		{ID: "a1", User: "alice", Title: "first", CreatedAt: at(0)},
+		{ID: "a2", User: "alice", Title: "second", CreatedAt: at(1)},
+		{ID: "b1", User: "bob", CreatedAt: at(2)},
+	}
+	for i := range sessions {
+		if err := store.SaveChatSession(ctx, &sessions[i]); err != nil {
+			t.Fatalf("SaveChatSession() error = %v", err)
+		}
+	}
+	if !sessions[0].UpdatedAt.Equal(at(0)) {
+		t.Errorf("UpdatedAt = %v, want the creation time", sessions[0].UpdatedAt)
+	}
+
+	got, err := store.GetChatSession(ctx, "a1")
+	if err != nil || got.Title != "first" {
+		t.Errorf("GetChatSession() = %+v, %v, want the saved session", got, err)
+	}
+	if _, err := store.GetChatSession(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("GetChatSession(missing) error = %v, want ErrNotFound", err)
+	}
+
+	// Activity moves a session to the front
+	sessions[0].UpdatedAt = at(5)
+	if err := store.SaveChatSession(ctx, &sessions[0]); err != nil {
+		t.Fatalf("SaveChatSession() error = %v", err)
+	}
+	listed, err := store.ListChatSessions(ctx, "alice", 0)
+	if err != nil {
+		t.Fatalf("ListChatSessions() error = %v", err)
+	}
+	if len(listed) != 2 || listed[0].ID != "a1" || listed[1].ID != "a2" {
+		t.Errorf("ListChatSessions(alice) = %+v, want a1, a2", listed)
+	}
+	if listed, _ := store.ListChatSessions(ctx, "alice", 1); len(listed) != 1 {
+		t.Errorf("ListChatSessions(alice, 1) returned %d, want 1", len(listed))
+	}
+
+	for i := 0; i < 3; i++ {
+		message := &models.ChatMessage{SessionID: "a1", Role: models.ChatRoleUser, Content: string(rune('x' + i)), CreatedAt: at(i)}
+		if err := store.AppendChatMessage(ctx, message); err != nil {
+			t.Fatalf("AppendChatMessage() error = %v", err)
+		}
+	}
+	messages, err := store.ListChatMessages(ctx, "a1", 2)
+	if err != nil {
+		t.Fatalf("ListChatMessages() error = %v", err)
+	}
+	if len(messages) != 2 || messages[0].Content != "y" || messages[1].Content != "z" {
+		t.Errorf("ListChatMessages(a1, 2) = %+v, want the last two, oldest first", messages)
+	}
+
+	action := &models.ChatAction{SessionID: "a1", Status: models.ChatActionPending}
+	manifest := &models.Manifest{SessionID: "a1", Status: models.ManifestValid}
+	if err := store.SaveChatAction(ctx, action); err != nil {
+		t.Fatalf("SaveChatAction() error = %v", err)
+	}
+	if err := store.SaveManifest(ctx, manifest); err != nil {
+		t.Fatalf("SaveManifest() error = %v", err)
+	}
+
+	if err := store.DeleteChatSession(ctx, "a1"); err != nil {
+		t.Fatalf("DeleteChatSession() error = %v", err)
+	}
+	if messages, _ := store.ListChatMessages(ctx, "a1", 0); len(messages) != 0 {
+		t.Errorf("%d messages left after deleting the session", len(messages))
+	}
+	if _, err := store.GetChatAction(ctx, action.ID); !IsNotFound(err) {
+		t.Errorf("GetChatAction() after deleting the session error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.GetManifest(ctx, manifest.ID); !IsNotFound(err) {
+		t.Errorf("GetManifest() after deleting the session error = %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteChatSession(ctx, "a1"); !IsNotFound(err) {
+		t.Errorf("DeleteChatSession() twice error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestChatActions(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	actions := []models.ChatAction{
+		{ID: "old", SessionID: "s1", Status: models.ChatActionCancelled, CreatedAt: at(0)},
+		{ID: "new", SessionID: "s1", Status: models.ChatActionPending, CreatedAt: at(1)},
+		{ID: "other", SessionID: "s2", Status: models.ChatActionPending, CreatedAt: at(2)},
+	}
+	for i := range actions {
+		if err := store.SaveChatAction(ctx, &actions[i]); err != nil {
+			t.Fatalf("SaveChatAction() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		filter ChatActionFilter
+		want   []string
+	}{
+		{name: "everything, newest first", want: []string{"other", "new", "old"}},
+		{name: "session", filter: ChatActionFilter{SessionID: "s1"}, want: []string{"new", "old"}},
+		{name: "status", filter: ChatActionFilter{Status: models.ChatActionPending}, want: []string{"other", "new"}},
+		{name: "limit", filter: ChatActionFilter{SessionID: "s1", Limit: 1}, want: []string{"new"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := store.ListChatActions(ctx, tt.filter)
+			if err != nil {
+				t.Fatalf("ListChatActions() error = %v", err)
+			}
+			ids := []string{}
+			for _, action := range got {
+				ids = append(ids, action.ID)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("ListChatActions(%+v) = %v, want %v", tt.filter, ids, tt.want)
+			}
+		})
+	}
+
+	claimed := actions[1]
+	claimed.Status = models.ChatActionExecuting
+	if err := store.UpdateChatAction(ctx, &claimed, models.ChatActionPending); err != nil {
+		t.Fatalf("UpdateChatAction() error = %v", err)
+	}
+	// A concurrent confirmation loses
+	if err := store.UpdateChatAction(ctx, &claimed, models.ChatActionPending); !IsConflict(err) {
+		t.Fatalf("second UpdateChatAction() error = %v, want ErrConflict", err)
+	}
+	if got, _ := store.GetChatAction(ctx, "new"); got.Status != models.ChatActionExecuting {
+		t.Errorf("status = %s, want %s", got.Status, models.ChatActionExecuting)
+	}
+	if pending, _ := store.ListChatActions(ctx, ChatActionFilter{SessionID: "s1", Status: models.ChatActionPending}); len(pending) != 0 {
+		t.Errorf("%d pending actions after the claim, want 0", len(pending))
+	}
+}
+
+func TestManifests(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	m := &models.Manifest{SessionID: "s1", User: "alice", Content: "kind: Deployment", Status: models.ManifestValid}
+	if err := store.SaveManifest(ctx, m); err != nil {
+		t.Fatalf("SaveManifest() error = %v", err)
+	}
+	if m.ID == "" || m.CreatedAt.IsZero() {
+		t.Errorf("SaveManifest() left ID %q, CreatedAt %v unset", m.ID, m.CreatedAt)
+	}
+
+	m.Status = models.ManifestApplied
+	if err := store.SaveManifest(ctx, m); err != nil {
+		t.Fatalf("SaveManifest() update error = %v", err)
+	}
+	got, err := store.GetManifest(ctx, m.ID)
+	if err != nil {
+		t.Fatalf("GetManifest() error = %v", err)
+	}
+	if got.Status != models.ManifestApplied || got.Content != m.Content || got.User != "alice" {
+		t.Errorf("GetManifest() = %+v, want the updated manifest", got)
+	}
+	if _, err := store.GetManifest(ctx, "missing"); !IsNotFound(err) {
+		t.Errorf("GetManifest(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAcquireLease(t *testing.T) {
+	ctx := context.Background()
+	store := openTestStore(t)
+	acquire := func(holder string, ttl time.Duration) bool {
+		t.Helper()
+		held, err := store.AcquireLease(ctx, "sync", holder, ttl)
+		if err != nil {
+			t.Fatalf("AcquireLease(%s) error = %v", holder, err)
+		}
+		return held
+	}
+
+	if !acquire("a", time.Hour) {
+		t.Fatal("a could not take a free lease")
+	}
+	if acquire("b", time.Hour) {
+		t.Fatal("b took a lease a holds")
+	}
+	if !acquire("a", -time.Second) {
+		t.Fatal("a could not renew its lease")
+	}
+	// a's lease has now expired
+	if !acquire("b", time.Hour) {
+		t.Fatal("b could not take an expired lease")
+	}
+	if acquire("a", time.Hour) {
+		t.Fatal("a took the lease back from b")
+	}
+	if held, _ := store.AcquireLease(ctx, "other", "a", time.Hour); !held {
+		t.Error("leases with different names conflict")
+	}
+}
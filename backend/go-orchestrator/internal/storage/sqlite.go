@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openSQLite(path string) (*sqlStore, error) {
+	dsn := path
+	if !strings.Contains(dsn, "?") {
+		dsn += "?_busy_timeout=5000&_journal_mode=WAL"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %v", err)
+	}
+	// SQLite allows a single writer; funnelling everything through one
+	// connection avoids "database is locked" errors under concurrent requests.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %v", err)
+	}
+	return &sqlStore{db: db, dialect: dialectSQLite}, nil
+}
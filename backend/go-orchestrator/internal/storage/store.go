@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"orchestrator/internal/models"
+)
+
+var (
+	// ErrNotFound is returned when a record does not exist.
+	ErrNotFound = errors.New("record not found")
+	// ErrConflict is returned by conditional updates when the record is no
+	// longer in one of the expected states.
+	ErrConflict = errors.New("record was changed concurrently")
+)
+
+// Store persists the orchestrator's recommendations, action logs, metric
+// history, metric rollups, anomalies, predictions and chat sessions so they
+// survive restarts. Leases let one replica at a time run a background job.
+type Store interface {
+	ListRecommendations(ctx context.Context) ([]models.Recommendation, error)
+	GetRecommendation(ctx context.Context, id string) (*models.Recommendation, error)
+	SaveRecommendation(ctx context.Context, rec *models.Recommendation) error
+	UpdateRecommendation(ctx context.Context, rec *models.Recommendation, from ...string) error
+
+	AppendLog(ctx context.Context, entry *models.LogEntry) error
+	ListLogs(ctx context.Context, filter LogFilter) ([]models.LogEntry, error)
+
+	AppendMetricSnapshot(ctx context.Context, snapshot models.MetricSnapshot) error
+	ListMetricSnapshots(ctx context.Context, from, to time.Time) ([]models.MetricSnapshot, error)
+	LatestMetricSnapshot(ctx context.Context) (*models.MetricSnapshot, error)
+	DeleteMetricSnapshotsBefore(ctx context.Context, before time.Time) (int64, error)
+
+	AppendRollups(ctx context.Context, rollups []models.Rollup) error
+	ListRollups(ctx context.Context, resolution time.Duration, from, to time.Time) ([]models.Rollup, error)
+	DeleteRollupsBefore(ctx context.Context, resolution time.Duration, before time.Time) (int64, error)
+
+	SaveAnomaly(ctx context.Context, anomaly *models.Anomaly) error
+	ListAnomalies(ctx context.Context, filter AnomalyFilter) ([]models.Anomaly, error)
+	SaveSeasonalBaselines(ctx context.Context, baselines []models.SeasonalBaseline) error
+	ListSeasonalBaselines(ctx context.Context) ([]models.SeasonalBaseline, error)
+
+	SavePrediction(ctx context.Context, prediction *models.Prediction) error
+	ListPredictions(ctx context.Context, filter PredictionFilter) ([]models.Prediction, error)
+	DeletePredictionsBefore(ctx context.Context, before time.Time) (int64, error)
+
+	SaveChatSession(ctx context.Context, session *models.ChatSession) error
+	GetChatSession(ctx context.Context, id string) (*models.ChatSession, error)
+	ListChatSessions(ctx context.Context, user string, limit int) ([]models.ChatSession, error)
+	DeleteChatSession(ctx context.Context, id string) error
+	AppendChatMessage(ctx context.Context, message *models.ChatMessage) error
+	ListChatMessages(ctx context.Context, sessionID string, limit int) ([]models.ChatMessage, error)
+	SaveChatAction(ctx context.Context, action *models.ChatAction) error
+	UpdateChatAction(ctx context.Context, action *models.ChatAction, from ...string) error
+	GetChatAction(ctx context.Context, id string) (*models.ChatAction, error)
+	ListChatActions(ctx context.Context, filter ChatActionFilter) ([]models.ChatAction, error)
+	SaveManifest(ctx context.Context, manifest *models.Manifest) error
+	GetManifest(ctx context.Context, id string) (*models.Manifest, error)
+
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+
+	Close() error
+}
+
+// LogFilter narrows ListLogs. Zero values match everything; Limit 0 means no
+// limit. From and To bound the entry's timestamp.
+type LogFilter struct {
+	Type      string
+	Status    string
+	User      string
+	RequestID string
+	From      time.Time
+	To        time.Time
+	Limit     int
+}
+
+// AnomalyFilter narrows ListAnomalies. Zero values match everything. From and
+// To select anomalies that overlap the range; active anomalies extend to now.
+type AnomalyFilter struct {
+	Metric   string
+	Severity string
+	Status   string
+	From     time.Time
+	To       time.Time
+	Limit    int
+}
+
+// PredictionFilter narrows ListPredictions. Zero values match everything.
+type PredictionFilter struct {
+	Type  string
+	Since time.Time
+	Limit int
+}
+
+// ChatActionFilter narrows ListChatActions. Zero values match everything.
+type ChatActionFilter struct {
+	SessionID string
+	Status    string
+	Limit     int
+}
+
+// Config selects the storage backend.
+type Config struct {
+	// Driver is "sqlite" (default) or "postgres".
+	Driver string
+	// DSN is a file path for SQLite or a connection URL for Postgres.
+	DSN string
+}
+
+// ConfigFromEnv reads STORAGE_DRIVER and STORAGE_DSN, falling back to
+// DATABASE_URL for Postgres.
+func ConfigFromEnv() Config {
+	cfg := Config{
+		Driver: os.Getenv("STORAGE_DRIVER"),
+		DSN:    os.Getenv("STORAGE_DSN"),
+	}
+	if cfg.Driver == "" {
+		cfg.Driver = "sqlite"
+	}
+	if cfg.DSN == "" {
+		switch cfg.Driver {
+		case "postgres":
+			cfg.DSN = os.Getenv("DATABASE_URL")
+		case "sqlite":
+			cfg.DSN = "orchestrator.db"
+		}
+	}
+	return cfg
+}
+
+// Open connects to the configured backend and applies pending migrations.
+func Open(ctx context.Context, cfg Config) (Store, error) {
+	var (
+		store *sqlStore
+		err   error
+	)
+	switch cfg.Driver {
+	case "sqlite":
+		store, err = openSQLite(cfg.DSN)
+	case "postgres":
+		store, err = openPostgres(cfg.DSN)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.migrate(ctx); err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to migrate %s database: %v", cfg.Driver, err)
+	}
+	return store, nil
+}
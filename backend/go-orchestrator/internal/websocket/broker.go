@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Broker carries published messages between orchestrator replicas so every
+// replica's clients see the same stream.
+type Broker interface {
+	// Publish numbers msg and sends it to every subscriber, on this replica
+	// and others. Sequence numbers increase in the order messages are
+	// delivered. Messages repeating a recent Key may be skipped.
+	Publish(ctx context.Context, msg Message) error
+	// Subscribe calls fn with every published message, in order, until ctx
+	// is cancelled.
+	Subscribe(ctx context.Context, fn func(Message)) error
+	Close() error
+}
+
+// BrokerFromEnv selects the broker from WS_BROKER: "memory" (the default) for
+// a single replica, or "redis" to share messages through the Redis server at
+// WS_REDIS_URL on the WS_REDIS_CHANNEL channel.
+func BrokerFromEnv() (Broker, error) {
+	switch driver := os.Getenv("WS_BROKER"); driver {
+	case "", "memory":
+		return NewMemoryBroker(), nil
+	case "redis":
+		url := os.Getenv("WS_REDIS_URL")
+		if url == "" {
+			url = "redis://localhost:6379/0"
+		}
+		channel := os.Getenv("WS_REDIS_CHANNEL")
+		if channel == "" {
+			channel = defaultRedisChannel
+		}
+		return NewRedisBroker(url, channel)
+	default:
+		return nil, fmt.Errorf("unknown WS_BROKER %q", driver)
+	}
+}
+
+// MemoryBroker delivers messages within one process. With a single replica
+// every message is published once, so keys are not checked.
+type MemoryBroker struct {
+	mu          sync.Mutex
+	seq         uint64
+	nextID      int
+	subscribers map[int]func(Message)
+}
+
+// NewMemoryBroker creates an in-process broker. Sequence numbers start from
+// the current time in microseconds, so they keep increasing across restarts
+// and a client resuming from before a restart is told to resync.
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		seq:         uint64(time.Now().UnixMicro()),
+		subscribers: make(map[int]func(Message)),
+	}
+}
+
+func (b *MemoryBroker) Publish(_ context.Context, msg Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	msg.Seq = b.seq
+	for _, fn := range b.subscribers {
+		fn(msg)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, fn func(Message)) error {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.subscribers[id] = fn
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.subscribers, id)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
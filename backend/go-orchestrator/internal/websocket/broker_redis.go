@@ -0,0 +1,120 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRedisChannel = "inframind:ws"
+	// dedupWindow is how long message keys are remembered.
+	dedupWindow = 5 * time.Minute
+)
+
+// publishScript numbers a message and publishes it in one step, so messages
+// reach subscribers in sequence order whichever replica published them.
+// Payloads are published as "<seq> <message JSON>". When a deduplication key
+// is passed as KEYS[3], a message whose key was seen in the last
+// dedupWindow is skipped.
+var publishScript = redis.NewScript(`
+if KEYS[3] and not redis.call("SET", KEYS[3], "1", "NX", "EX", ARGV[2]) then
+	return 0
+end
+local seq = redis.call("INCR", KEYS[1])
+redis.call("PUBLISH", KEYS[2], seq .. " " .. ARGV[1])
+return seq
+`)
+
+// RedisBroker shares messages between replicas through Redis pub/sub, with
+// sequence numbers from a Redis counter and message keys deduplicated in
+// Redis. Messages published while a replica is disconnected from Redis are
+// not delivered to it.
+type RedisBroker struct {
+	client  *redis.Client
+	channel string
+	seqKey  string
+}
+
+// NewRedisBroker connects to the Redis server at url, e.g.
+// "redis://:password@localhost:6379/0".
+func NewRedisBroker(url, channel string) (*RedisBroker, error) {
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WS_REDIS_URL: %v", err)
+	}
+	client := redis.NewClient(options)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
+	}
+	return &RedisBroker{client: client, channel: channel, seqKey: channel + ":seq"}, nil
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, msg Message) error {
+	msg.Seq = 0
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	keys := []string{b.seqKey, b.channel}
+	if msg.Key != "" {
+		keys = append(keys, b.channel+":key:"+msg.Key)
+	}
+	return publishScript.Run(ctx, b.client, keys, data, int(dedupWindow.Seconds())).Err()
+}
+
+// Subscribe listens on the channel, reconnecting as needed, until ctx is
+// cancelled.
+func (b *RedisBroker) Subscribe(ctx context.Context, fn func(Message)) error {
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	defer pubsub.Close()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case payload, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			msg, err := decodeRedisMessage(payload.Payload)
+			if err != nil {
+				log.Printf("Ignoring malformed WebSocket message from Redis: %v", err)
+				continue
+			}
+			fn(msg)
+		}
+	}
+}
+
+func (b *RedisBroker) Close() error {
+	return b.client.Close()
+}
+
+func decodeRedisMessage(payload string) (Message, error) {
+	var msg Message
+	seq, data, ok := strings.Cut(payload, " ")
+	if !ok {
+		return msg, fmt.Errorf("missing sequence number")
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return msg, fmt.Errorf("invalid sequence number %q", seq)
+	}
+	if err := json.Unmarshal([]byte(data), &msg); err != nil {
+		return msg, err
+	}
+	msg.Seq = n
+	return msg, nil
+}
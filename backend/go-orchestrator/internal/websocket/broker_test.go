@@ -0,0 +1,220 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// next waits for the next message queued for client.
+func next(t *testing.T, client *Client) Message {
+	t.Helper()
+	select {
+	case data, ok := <-client.send:
+		if !ok {
+			t.Fatal("client was disconnected")
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			t.Fatalf("decode message: %v", err)
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a message")
+	}
+	return Message{}
+}
+
+// waitSubscribers waits until broker has n subscribers, so that nothing
+// published afterwards is missed.
+func waitSubscribers(t *testing.T, broker *MemoryBroker, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		broker.mu.Lock()
+		subscribed := len(broker.subscribers)
+		broker.mu.Unlock()
+		if subscribed >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d subscribers registered", subscribed, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// runHubs starts n hubs sharing broker, as replicas would.
+func runHubs(t *testing.T, broker *MemoryBroker, n, replayBuffer int) []*Hub {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	hubs := make([]*Hub, n)
+	for i := range hubs {
+		hubs[i] = NewHub(Config{ReplayBuffer: replayBuffer}, broker)
+		go hubs[i].Run(ctx)
+	}
+	t.Cleanup(func() {
+		cancel()
+		for _, hub := range hubs {
+			<-hub.done
+		}
+	})
+	waitSubscribers(t, broker, n)
+	return hubs
+}
+
+// connect registers a metrics subscriber with hub.
+func connect(hub *Hub) *Client {
+	client := testClient(hub, map[string]*subscription{TopicMetrics: {}}, sendBuffer)
+	hub.register <- client
+	return client
+}
+
+func TestMemoryBrokerSequence(t *testing.T) {
+	start := uint64(time.Now().UnixMicro())
+	broker := NewMemoryBroker()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan Message, 10)
+	go broker.Subscribe(ctx, func(msg Message) { received <- msg })
+	waitSubscribers(t, broker, 1)
+
+	for i := 0; i < 3; i++ {
+		// Any sequence number set by the publisher is replaced
+		if err := broker.Publish(ctx, Message{Type: "test", Seq: 1}); err != nil {
+			t.Fatalf("Publish() error = %v", err)
+		}
+	}
+	var last uint64
+	for i := 0; i < 3; i++ {
+		msg := <-received
+		if msg.Seq <= last {
+			t.Errorf("message %d seq = %d, want after %d", i, msg.Seq, last)
+		}
+		last = msg.Seq
+	}
+	// Sequences start from the clock, so they keep increasing across restarts
+	if last <= start {
+		t.Errorf("seq %d, want after the start time %d", last, start)
+	}
+}
+
+func TestHubsSharingBroker(t *testing.T) {
+	hubs := runHubs(t, NewMemoryBroker(), 2, defaultReplayBuffer)
+	clients := []*Client{connect(hubs[0]), connect(hubs[1])}
+
+	// Publish alternately on each replica
+	const published = 6
+	for i := 0; i < published; i++ {
+		hubs[i%2].Publish(Message{Type: "metrics_update", Topic: TopicMetrics, Data: map[string]interface{}{"n": i}})
+	}
+
+	seen := make([][]uint64, len(clients))
+	for i, client := range clients {
+		for j := 0; j < published; j++ {
+			msg := next(t, client)
+			if n := len(seen[i]); n > 0 && msg.Seq <= seen[i][n-1] {
+				t.Errorf("client %d: seq %d after %d, want increasing", i, msg.Seq, seen[i][n-1])
+			}
+			seen[i] = append(seen[i], msg.Seq)
+		}
+	}
+	for j := range seen[0] {
+		if seen[0][j] != seen[1][j] {
+			t.Fatalf("replicas saw different sequences: %v and %v", seen[0], seen[1])
+		}
+	}
+}
+
+func TestResumeOnAnotherReplica(t *testing.T) {
+	tests := []struct {
+		name       string
+		missed     int
+		buffer     int
+		wantReplay bool
+	}{
+		{name: "gap buffered", missed: 3, buffer: defaultReplayBuffer, wantReplay: true},
+		{name: "gap longer than the buffer", missed: 3, buffer: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hubs := runHubs(t, NewMemoryBroker(), 2, tt.buffer)
+			// observer tells when the second replica has received each message
+			observer := connect(hubs[1])
+
+			// The client sees one message on the first replica, then loses
+			// its connection while more are published
+			client := connect(hubs[0])
+			hubs[0].Publish(Message{Type: "metrics_update", Topic: TopicMetrics})
+			lastSeq := next(t, client).Seq
+			next(t, observer)
+			hubs[0].unregister <- client
+
+			var missed []uint64
+			for i := 0; i < tt.missed; i++ {
+				hubs[i%2].Publish(Message{Type: "metrics_update", Topic: TopicMetrics})
+				missed = append(missed, next(t, observer).Seq)
+			}
+
+			// It reconnects to the second replica and resumes
+			resumed := connect(hubs[1])
+			hubs[1].requests <- clientRequest{client: resumed, Action: "resume", LastSeq: lastSeq}
+
+			var replayed []uint64
+			resync := false
+			for {
+				msg := next(t, resumed)
+				if msg.Type == "resumed" {
+					break
+				}
+				if msg.Type == "resync_required" {
+					resync = true
+					continue
+				}
+				replayed = append(replayed, msg.Seq)
+			}
+			if tt.wantReplay {
+				if resync || len(replayed) != len(missed) {
+					t.Fatalf("replayed %v (resync %v), want %v", replayed, resync, missed)
+				}
+				for i := range missed {
+					if replayed[i] != missed[i] {
+						t.Fatalf("replayed %v, want %v", replayed, missed)
+					}
+				}
+				return
+			}
+			if !resync || len(replayed) != 0 {
+				t.Errorf("replayed %v (resync %v), want a resync and nothing replayed", replayed, resync)
+			}
+		})
+	}
+}
+
+func TestDecodeRedisMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		wantSeq uint64
+		wantErr bool
+	}{
+		{name: "numbered message", payload: `42 {"type":"metrics_update","topic":"metrics","timestamp":"2024-01-01T00:00:00Z","data":{}}`, wantSeq: 42},
+		{name: "sequence in the payload ignored", payload: `7 {"seq":99,"type":"x","timestamp":"2024-01-01T00:00:00Z","data":null}`, wantSeq: 7},
+		{name: "missing sequence", payload: `{"type":"x"}`, wantErr: true},
+		{name: "invalid sequence", payload: `abc {"type":"x"}`, wantErr: true},
+		{name: "invalid JSON", payload: `1 {"type":`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := decodeRedisMessage(tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeRedisMessage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && msg.Seq != tt.wantSeq {
+				t.Errorf("seq = %d, want %d", msg.Seq, tt.wantSeq)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package websocket
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = 54 * time.Second
+	maxMessageSize = 4096
+	// sendBuffer bounds the messages queued for a client, including a replay.
+	sendBuffer = 1024
+)
+
+// checkOrigin accepts handshakes without an Origin header (non-browser
+// clients), from the server's own host and from the allowed origins, so that
+// other sites cannot open a connection with a visitor's credentials.
+func (cfg Config) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range cfg.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Client is one WebSocket connection. New clients are subscribed to the
+// metrics topic; they change their subscriptions by sending requests such as
+//
+//	{"action": "subscribe", "topics": ["resources"], "namespaces": ["default"]}
+//	{"action": "unsubscribe", "topics": ["metrics"]}
+//
+// A reconnecting client subscribes as before and then sends the sequence
+// number of the last message it saw to receive what it missed:
+//
+//	{"action": "resume", "lastSeq": 1760000000000123}
+//
+// Whatever it subscribes to, a client only receives the messages its caller
+// is allowed to see.
+type Client struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+	// subscriptions is only touched by the hub goroutine.
+	subscriptions map[string]*subscription
+	// allowed reports whether the caller may see messages on topic about
+	// namespace, an empty namespace being cluster-level. Nil allows all.
+	allowed func(topic, namespace string) bool
+}
+
+// subscription is a client's interest in one topic.
+type subscription struct {
+	// namespaces filters namespaced messages; empty matches every namespace.
+	namespaces map[string]bool
+	// since is the sequence number current when the subscription was made;
+	// later messages are delivered live.
+	since uint64
+}
+
+func (s *subscription) matches(namespace string) bool {
+	return namespace == "" || len(s.namespaces) == 0 || s.namespaces[namespace]
+}
+
+// clientRequest is a subscription change or resume read from a client.
+type clientRequest struct {
+	client     *Client
+	invalid    error
+	Action     string   `json:"action"`
+	Topics     []string `json:"topics"`
+	Namespaces []string `json:"namespaces"`
+	LastSeq    uint64   `json:"lastSeq"`
+}
+
+// wants reports whether the client subscribed to msg's topic and namespace
+// and may see it. Messages without a namespace go to every subscriber of the
+// topic allowed to see cluster-level messages.
+func (c *Client) wants(msg Message) bool {
+	sub, ok := c.subscriptions[msg.Topic]
+	return ok && sub.matches(msg.Namespace) && c.permits(msg.Topic, msg.Namespace)
+}
+
+func (c *Client) permits(topic, namespace string) bool {
+	return c.allowed == nil || c.allowed(topic, namespace)
+}
+
+// handleRequest applies a subscription change and acknowledges it with the
+// client's resulting subscriptions. Subscribing to a topic again replaces its
+// namespace filter, which may only name namespaces the caller can see.
+func (h *Hub) handleRequest(req clientRequest) {
+	client := req.client
+	if !h.clients[client] {
+		return
+	}
+	if req.invalid != nil {
+		h.reply(client, Message{Type: "error", Data: map[string]interface{}{"error": "invalid request: " + req.invalid.Error()}})
+		return
+	}
+	for _, topic := range req.Topics {
+		if !topics[topic] {
+			h.reply(client, Message{Type: "error", Data: map[string]interface{}{"error": "unknown topic: " + topic}})
+			return
+		}
+	}
+
+	switch req.Action {
+	case "subscribe":
+		for _, topic := range req.Topics {
+			for _, namespace := range req.Namespaces {
+				if !client.permits(topic, namespace) {
+					h.reply(client, Message{Type: "error", Data: map[string]interface{}{"error": "namespace not allowed: " + namespace}})
+					return
+				}
+			}
+		}
+		for _, topic := range req.Topics {
+			sub := &subscription{namespaces: make(map[string]bool, len(req.Namespaces)), since: h.seq}
+			for _, namespace := range req.Namespaces {
+				sub.namespaces[namespace] = true
+			}
+			client.subscriptions[topic] = sub
+		}
+	case "unsubscribe":
+		for _, topic := range req.Topics {
+			delete(client.subscriptions, topic)
+		}
+	case "resume":
+		h.resume(client, req.LastSeq)
+		return
+	default:
+		h.reply(client, Message{Type: "error", Data: map[string]interface{}{"error": "unknown action: " + req.Action}})
+		return
+	}
+
+	h.reply(client, Message{Type: "subscriptions", Data: map[string]interface{}{"topics": client.subscriptionList()}})
+}
+
+func (c *Client) subscriptionList() map[string][]string {
+	list := make(map[string][]string, len(c.subscriptions))
+	for topic, sub := range c.subscriptions {
+		namespaces := make([]string, 0, len(sub.namespaces))
+		for namespace := range sub.namespaces {
+			namespaces = append(namespaces, namespace)
+		}
+		sort.Strings(namespaces)
+		list[topic] = namespaces
+	}
+	return list
+}
+
+// readPump reads client requests until the connection closes.
+func (c *Client) readPump() {
+	defer func() {
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		req := clientRequest{client: c}
+		if err := json.Unmarshal(data, &req); err != nil {
+			req.invalid = err
+		}
+		select {
+		case c.hub.requests <- req:
+		case <-c.hub.done:
+			return
+		}
+	}
+}
+
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case message, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ServeWs upgrades the request and registers the connection with hub.
+// allowed limits what the connection receives; see Client.
+func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request, allowed func(topic, namespace string) bool) {
+	conn, err := hub.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("WebSocket upgrade failed:", err)
+		return
+	}
+
+	client := &Client{
+		hub:           hub,
+		conn:          conn,
+		send:          make(chan []byte, sendBuffer),
+		subscriptions: map[string]*subscription{TopicMetrics: {}},
+		allowed:       allowed,
+	}
+	select {
+	case hub.register <- client:
+	case <-hub.done:
+		conn.Close()
+		return
+	}
+
+	go client.writePump()
+	go client.readPump()
+}
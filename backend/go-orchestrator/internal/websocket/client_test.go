@@ -0,0 +1,90 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+)
+
+// onlyNamespace allows the messages about namespace, and no cluster-level
+// ones, as for a caller bound to that namespace.
+func onlyNamespace(namespace string) func(topic, namespace string) bool {
+	return func(_, ns string) bool { return ns == namespace }
+}
+
+func TestClientWants(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		allowed    func(topic, namespace string) bool
+		msg        Message
+		want       bool
+	}{
+		{name: "subscribed topic", msg: Message{Topic: TopicResources, Namespace: "a"}, want: true},
+		{name: "other topic", msg: Message{Topic: TopicAnomalies, Namespace: "a"}},
+		{name: "namespace filter", namespaces: []string{"a"}, msg: Message{Topic: TopicResources, Namespace: "b"}},
+		{name: "cluster-level message", namespaces: []string{"a"}, msg: Message{Topic: TopicResources}, want: true},
+		{name: "allowed namespace", allowed: onlyNamespace("a"), msg: Message{Topic: TopicResources, Namespace: "a"}, want: true},
+		{name: "namespace outside the caller's scope", allowed: onlyNamespace("a"), msg: Message{Topic: TopicResources, Namespace: "b"}},
+		{name: "cluster-level message outside the caller's scope", allowed: onlyNamespace("a"), msg: Message{Topic: TopicResources}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &subscription{namespaces: map[string]bool{}}
+			for _, namespace := range tt.namespaces {
+				sub.namespaces[namespace] = true
+			}
+			client := testClient(nil, map[string]*subscription{TopicResources: sub}, 1)
+			client.allowed = tt.allowed
+			if got := client.wants(tt.msg); got != tt.want {
+				t.Errorf("wants(%+v) = %v, want %v", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		wantError  string
+		wantTopics map[string]interface{}
+	}{
+		{
+			name:       "every namespace",
+			wantTopics: map[string]interface{}{TopicMetrics: []interface{}{}, TopicResources: []interface{}{}},
+		},
+		{
+			name:       "allowed namespace",
+			namespaces: []string{"a"},
+			wantTopics: map[string]interface{}{TopicMetrics: []interface{}{}, TopicResources: []interface{}{"a"}},
+		},
+		{name: "namespace outside the caller's scope", namespaces: []string{"a", "b"}, wantError: "namespace not allowed: b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub(Config{}, nil)
+			client := testClient(h, map[string]*subscription{TopicMetrics: {}}, sendBuffer)
+			client.allowed = onlyNamespace("a")
+			h.clients[client] = true
+
+			h.handleRequest(clientRequest{client: client, Action: "subscribe", Topics: []string{TopicResources}, Namespaces: tt.namespaces})
+
+			msgs := drain(t, client)
+			if len(msgs) != 1 {
+				t.Fatalf("replies = %+v, want one", msgs)
+			}
+			if tt.wantError != "" {
+				if msgs[0].Type != "error" || msgs[0].Data["error"] != tt.wantError {
+					t.Errorf("reply = %+v, want error %q", msgs[0], tt.wantError)
+				}
+				if _, ok := client.subscriptions[TopicResources]; ok {
+					t.Error("subscribed despite the error")
+				}
+				return
+			}
+			if msgs[0].Type != "subscriptions" || !reflect.DeepEqual(msgs[0].Data["topics"], tt.wantTopics) {
+				t.Errorf("reply = %+v, want subscriptions %v", msgs[0], tt.wantTopics)
+			}
+		})
+	}
+}
@@ -1,134 +1,279 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
 	"log"
-	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins in development
-	},
+// Topics clients can subscribe to.
+const (
+	TopicMetrics         = "metrics"
+	TopicRecommendations = "recommendations"
+	TopicActions         = "actions"
+	TopicAnomalies       = "anomalies"
+	TopicResources       = "resources"
+)
+
+var topics = map[string]bool{
+	TopicMetrics:         true,
+	TopicRecommendations: true,
+	TopicActions:         true,
+	TopicAnomalies:       true,
+	TopicResources:       true,
 }
 
+// publishBuffer is how many published messages may wait for the broker, and
+// how many received messages may wait for the hub.
+const publishBuffer = 1024
+
+// Hub fans messages out to the clients subscribed to their topic. Messages
+// published on any replica go through the broker, which numbers them, and
+// come back to every replica's hub. All client, subscription and replay state
+// is owned by the Run goroutine.
 type Hub struct {
+	cfg        Config
+	broker     Broker
+	upgrader   websocket.Upgrader
 	clients    map[*Client]bool
-	broadcast  chan []byte
+	outbound   chan Message
+	inbound    chan Message
 	register   chan *Client
 	unregister chan *Client
-}
+	requests   chan clientRequest
+	done       chan struct{}
+	dropped    atomic.Uint64
 
-type Client struct {
-	hub  *Hub
-	conn *websocket.Conn
-	send chan []byte
+	// seq is the sequence number of the last received message, zero until
+	// the first one arrives.
+	seq    uint64
+	replay map[string]*replayBuffer
 }
 
+// Message is the envelope for everything sent to clients. Namespace is set
+// for messages about namespaced objects and is matched against the client's
+// namespace filter. Published messages carry an increasing Seq assigned by
+// the broker; replies to a client's own requests have none.
 type Message struct {
+	Seq       uint64                 `json:"seq,omitempty"`
 	Type      string                 `json:"type"`
+	Topic     string                 `json:"topic,omitempty"`
+	Namespace string                 `json:"namespace,omitempty"`
 	Timestamp time.Time              `json:"timestamp"`
 	Data      map[string]interface{} `json:"data"`
+	// Key identifies a message that every replica may publish, such as a
+	// watch event seen by each replica's informers. Brokers shared between
+	// replicas deliver only the first message with a given key.
+	Key string `json:"-"`
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		broadcast:  make(chan []byte),
+// NewHub creates a hub that exchanges messages through broker, or through an
+// in-memory broker when it is nil.
+func NewHub(cfg Config, broker Broker) *Hub {
+	if broker == nil {
+		broker = NewMemoryBroker()
+	}
+	h := &Hub{
+		cfg:        cfg,
+		broker:     broker,
+		upgrader:   websocket.Upgrader{CheckOrigin: cfg.checkOrigin},
+		clients:    make(map[*Client]bool),
+		outbound:   make(chan Message, publishBuffer),
+		inbound:    make(chan Message, publishBuffer),
 		register:   make(chan *Client),
 		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+		requests:   make(chan clientRequest),
+		done:       make(chan struct{}),
+		replay:     make(map[string]*replayBuffer, len(topics)),
+	}
+	for topic := range topics {
+		h.replay[topic] = &replayBuffer{}
 	}
+	return h
 }
 
-func (h *Hub) Run() {
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
+// Publish queues msg for delivery without blocking. If the hub has fallen
+// behind, the message is dropped.
+func (h *Hub) Publish(msg Message) {
+	if msg.Timestamp.IsZero() {
+		msg.Timestamp = time.Now()
+	}
+	select {
+	case h.outbound <- msg:
+	default:
+		h.drop()
+	}
+}
+
+func (h *Hub) drop() {
+	if n := h.dropped.Add(1); n == 1 || n%1000 == 0 {
+		log.Printf("WebSocket hub is falling behind: %d messages dropped", n)
+	}
+}
+
+// Run delivers messages until ctx is cancelled, then disconnects every
+// client and closes the broker.
+func (h *Hub) Run(ctx context.Context) {
+	defer close(h.done)
+
+	var background sync.WaitGroup
+	background.Add(2)
+	go func() {
+		defer background.Done()
+		h.forward(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		h.broker.Subscribe(ctx, func(msg Message) {
+			select {
+			case h.inbound <- msg:
+			case <-ctx.Done():
+			}
+		})
+	}()
+	defer func() {
+		background.Wait()
+		if err := h.broker.Close(); err != nil {
+			log.Printf("Failed to close WebSocket broker: %v", err)
+		}
+	}()
 
 	for {
 		select {
-		case client := <-h.register:
-			h.clients[client] = true
-		case client := <-h.unregister:
-			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
-			}
-		case message := <-h.broadcast:
+		case <-ctx.Done():
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				default:
-					close(client.send)
-					delete(h.clients, client)
-				}
+				h.remove(client)
 			}
-		case <-ticker.C:
-			// Send periodic updates
-			msg := Message{
-				Type:      "metrics_update",
-				Timestamp: time.Now(),
-				Data: map[string]interface{}{
-					"cpu":    45.0 + float64(time.Now().Unix()%20),
-					"memory": 60.0 + float64(time.Now().Unix()%15),
-				},
+			return
+		case client := <-h.register:
+			for _, sub := range client.subscriptions {
+				sub.since = h.seq
 			}
-			data, _ := json.Marshal(msg)
-			h.broadcast <- data
+			h.clients[client] = true
+		case client := <-h.unregister:
+			h.remove(client)
+		case req := <-h.requests:
+			h.handleRequest(req)
+		case msg := <-h.inbound:
+			h.receive(msg)
 		}
 	}
 }
 
-func (c *Client) readPump() {
-	defer func() {
-		c.hub.unregister <- c
-		c.conn.Close()
-	}()
-
+// forward hands locally published messages to the broker. While the broker
+// is failing, messages are dropped and only the first error is logged.
+func (h *Hub) forward(ctx context.Context) {
+	failing := false
 	for {
-		_, _, err := c.conn.ReadMessage()
-		if err != nil {
-			break
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-h.outbound:
+			err := h.broker.Publish(ctx, msg)
+			switch {
+			case err == nil:
+				if failing {
+					log.Println("WebSocket broker recovered")
+				}
+				failing = false
+			case ctx.Err() == nil:
+				if !failing {
+					log.Printf("Failed to publish %s message: %v", msg.Type, err)
+				}
+				failing = true
+				h.drop()
+			}
 		}
 	}
 }
 
-func (c *Client) writePump() {
-	ticker := time.NewTicker(54 * time.Second)
-	defer func() {
-		ticker.Stop()
-		c.conn.Close()
-	}()
+// receive records a numbered message for replay and sends it to every
+// interested client. Clients that cannot keep up are disconnected rather than
+// allowed to stall the hub.
+func (h *Hub) receive(msg Message) {
+	switch {
+	case h.seq == 0:
+		// Nothing from before this hub started can be replayed
+		for _, buffer := range h.replay {
+			buffer.evicted = msg.Seq - 1
+		}
+	case msg.Seq < h.seq:
+		h.reset(msg.Seq)
+	}
+	if msg.Seq > h.seq {
+		h.seq = msg.Seq
+	}
 
-	for {
-		select {
-		case message, ok := <-c.send:
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to encode %s message: %v", msg.Type, err)
+		return
+	}
+	if buffer, ok := h.replay[msg.Topic]; ok {
+		buffer.add(replayEntry{seq: msg.Seq, namespace: msg.Namespace, data: data}, h.cfg.ReplayBuffer)
+	}
 
-			c.conn.WriteMessage(websocket.TextMessage, message)
-		case <-ticker.C:
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				return
-			}
+	for client := range h.clients {
+		if client.wants(msg) {
+			h.send(client, data)
 		}
 	}
 }
 
-func ServeWs(hub *Hub, w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// reset handles the broker's numbering starting over below the last
+// received message, as when the Redis counter is lost. Buffered messages can
+// no longer be compared with the seq a client resumes from, so they are
+// discarded, and every client is told to reload the topics it follows and to
+// continue from the new numbering.
+func (h *Hub) reset(seq uint64) {
+	log.Printf("WebSocket message numbering restarted at %d after %d, clearing the replay buffers", seq, h.seq)
+	h.seq = seq - 1
+	for _, buffer := range h.replay {
+		buffer.entries = nil
+		buffer.evicted = h.seq
+	}
+	for client := range h.clients {
+		topics := make([]string, 0, len(client.subscriptions))
+		for topic, sub := range client.subscriptions {
+			sub.since = h.seq
+			topics = append(topics, topic)
+		}
+		if len(topics) == 0 {
+			continue
+		}
+		sort.Strings(topics)
+		h.reply(client, Message{Type: "resync_required", Data: map[string]interface{}{"topics": topics, "seq": h.seq}})
+	}
+}
+
+func (h *Hub) send(client *Client, data []byte) {
+	select {
+	case client.send <- data:
+	default:
+		log.Printf("WebSocket client %s is too slow, disconnecting", client.conn.RemoteAddr())
+		h.remove(client)
+	}
+}
+
+// reply sends a hub-generated message to one client.
+func (h *Hub) reply(client *Client, msg Message) {
+	msg.Timestamp = time.Now()
+	data, err := json.Marshal(msg)
 	if err != nil {
-		log.Println("WebSocket upgrade failed:", err)
 		return
 	}
+	h.send(client, data)
+}
 
-	client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256)}
-	client.hub.register <- client
-
-	go client.writePump()
-	go client.readPump()
+func (h *Hub) remove(client *Client) {
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.send)
+	}
 }
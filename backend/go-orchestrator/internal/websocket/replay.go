@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"os"
+	"sort"
+	"strconv"
+)
+
+const defaultReplayBuffer = 256
+
+// Config tunes the hub.
+type Config struct {
+	// ReplayBuffer is how many recent messages are kept per topic for
+	// clients resuming after a reconnect.
+	ReplayBuffer int
+	// AllowedOrigins are the browser origins allowed to open a connection
+	// besides the server's own; "*" allows any. Set from the auth
+	// configuration so it matches the API's CORS policy.
+	AllowedOrigins []string
+}
+
+// ConfigFromEnv reads WS_REPLAY_BUFFER.
+func ConfigFromEnv() Config {
+	cfg := Config{ReplayBuffer: defaultReplayBuffer}
+	if value, err := strconv.Atoi(os.Getenv("WS_REPLAY_BUFFER")); err == nil && value >= 0 {
+		cfg.ReplayBuffer = value
+	}
+	return cfg
+}
+
+// replayEntry is an encoded message kept for replay.
+type replayEntry struct {
+	seq       uint64
+	namespace string
+	data      []byte
+}
+
+// replayBuffer holds a topic's most recent messages. evicted is the highest
+// sequence number no longer held, so a client that last saw an earlier
+// message has missed something that cannot be replayed.
+type replayBuffer struct {
+	entries []replayEntry
+	evicted uint64
+}
+
+func (b *replayBuffer) add(entry replayEntry, size int) {
+	if size == 0 {
+		b.evicted = entry.seq
+		return
+	}
+	if len(b.entries) < size {
+		b.entries = append(b.entries, entry)
+		return
+	}
+	b.evicted = b.entries[0].seq
+	copy(b.entries, b.entries[1:])
+	b.entries[len(b.entries)-1] = entry
+}
+
+// resume sends the messages a reconnecting client missed after lastSeq on the
+// topics it subscribes to, oldest first, then a "resumed" message. Topics
+// whose gap is no longer fully buffered are named in a "resync_required"
+// message instead, telling the client to reload that state over REST.
+func (h *Hub) resume(client *Client, lastSeq uint64) {
+	var (
+		missed []replayEntry
+		resync []string
+	)
+	for topic, sub := range client.subscriptions {
+		buffer := h.replay[topic]
+		if lastSeq > h.seq || buffer.evicted > lastSeq {
+			resync = append(resync, topic)
+			continue
+		}
+		for _, entry := range buffer.entries {
+			// Later messages were delivered live
+			if entry.seq > lastSeq && entry.seq <= sub.since && sub.matches(entry.namespace) && client.permits(topic, entry.namespace) {
+				missed = append(missed, entry)
+			}
+		}
+	}
+
+	// Never replay more than fits in the client's queue, or the client
+	// would be disconnected as too slow
+	if len(missed) >= cap(client.send)-len(client.send)-2 {
+		missed = nil
+		resync = resync[:0]
+		for topic := range client.subscriptions {
+			resync = append(resync, topic)
+		}
+	}
+
+	sort.Slice(missed, func(i, j int) bool { return missed[i].seq < missed[j].seq })
+	for _, entry := range missed {
+		h.send(client, entry.data)
+	}
+	if len(resync) > 0 {
+		sort.Strings(resync)
+		h.reply(client, Message{Type: "resync_required", Data: map[string]interface{}{"topics": resync, "seq": h.seq}})
+	}
+	h.reply(client, Message{Type: "resumed", Data: map[string]interface{}{"replayed": len(missed), "seq": h.seq}})
+}
@@ -0,0 +1,294 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// testClient is a client without a connection; the hub only writes to its
+// send queue.
+func testClient(h *Hub, subscriptions map[string]*subscription, queue int) *Client {
+	return &Client{hub: h, send: make(chan []byte, queue), subscriptions: subscriptions}
+}
+
+// drain decodes every message queued for client.
+func drain(t *testing.T, client *Client) []Message {
+	t.Helper()
+	var msgs []Message
+	for {
+		select {
+		case data := <-client.send:
+			var msg Message
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatalf("decode message: %v", err)
+			}
+			msgs = append(msgs, msg)
+		default:
+			return msgs
+		}
+	}
+}
+
+func TestReplayBufferAdd(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		seqs        []uint64
+		wantEntries []uint64
+		wantEvicted uint64
+	}{
+		{"below capacity", 3, []uint64{1, 2}, []uint64{1, 2}, 0},
+		{"oldest evicted", 3, []uint64{1, 2, 3, 4, 5}, []uint64{3, 4, 5}, 2},
+		{"disabled", 0, []uint64{1, 2}, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b replayBuffer
+			for _, seq := range tt.seqs {
+				b.add(replayEntry{seq: seq}, tt.size)
+			}
+			var got []uint64
+			for _, entry := range b.entries {
+				got = append(got, entry.seq)
+			}
+			if !reflect.DeepEqual(got, tt.wantEntries) || b.evicted != tt.wantEvicted {
+				t.Errorf("entries, evicted = %v, %d, want %v, %d", got, b.evicted, tt.wantEntries, tt.wantEvicted)
+			}
+		})
+	}
+}
+
+func TestResume(t *testing.T) {
+	// The hub has seen these messages, the first it ever received being 101
+	received := []Message{
+		{Seq: 101, Topic: TopicResources, Namespace: "a"},
+		{Seq: 102, Topic: TopicResources, Namespace: "b"},
+		{Seq: 103, Topic: TopicMetrics},
+		{Seq: 104, Topic: TopicResources, Namespace: "a"},
+		{Seq: 105, Topic: TopicResources, Namespace: "b"},
+	}
+	resources := func(namespaces ...string) *subscription {
+		sub := &subscription{namespaces: map[string]bool{}}
+		for _, namespace := range namespaces {
+			sub.namespaces[namespace] = true
+		}
+		return sub
+	}
+
+	tests := []struct {
+		name          string
+		replayBuffer  int
+		subscriptions map[string]*subscription
+		// since overrides when the subscriptions were made; by default after
+		// every received message.
+		since      uint64
+		queue      int
+		allowed    func(topic, namespace string) bool
+		lastSeq    uint64
+		wantSeqs   []uint64
+		wantResync []string
+	}{
+		{name: "gap replayed", subscriptions: map[string]*subscription{TopicResources: resources()}, lastSeq: 102, wantSeqs: []uint64{104, 105}},
+		{name: "every subscribed topic, in order", subscriptions: map[string]*subscription{TopicResources: resources(), TopicMetrics: {}}, lastSeq: 102, wantSeqs: []uint64{103, 104, 105}},
+		{name: "namespace filter", subscriptions: map[string]*subscription{TopicResources: resources("a")}, lastSeq: 100, wantSeqs: []uint64{101, 104}},
+		{
+			name: "outside the caller's scope", subscriptions: map[string]*subscription{TopicResources: resources(), TopicMetrics: {}},
+			allowed: func(_, namespace string) bool { return namespace == "b" }, lastSeq: 100, wantSeqs: []uint64{102, 105},
+		},
+		{name: "up to date", subscriptions: map[string]*subscription{TopicResources: resources()}, lastSeq: 105},
+		{name: "messages since subscribing were live", subscriptions: map[string]*subscription{TopicResources: resources()}, since: 104, lastSeq: 102, wantSeqs: []uint64{104}},
+		{
+			name: "gap evicted", replayBuffer: 2, subscriptions: map[string]*subscription{TopicResources: resources()},
+			lastSeq: 101, wantResync: []string{TopicResources},
+		},
+		{
+			name: "eviction is per topic", replayBuffer: 2, subscriptions: map[string]*subscription{TopicResources: resources(), TopicMetrics: {}},
+			lastSeq: 101, wantSeqs: []uint64{103}, wantResync: []string{TopicResources},
+		},
+		{name: "from before the hub started", subscriptions: map[string]*subscription{TopicResources: resources()}, lastSeq: 50, wantResync: []string{TopicResources}},
+		{name: "from before a broker restart", subscriptions: map[string]*subscription{TopicResources: resources()}, lastSeq: 200, wantResync: []string{TopicResources}},
+		{
+			name: "more than the queue holds", subscriptions: map[string]*subscription{TopicResources: resources(), TopicMetrics: {}},
+			queue: 4, lastSeq: 100, wantResync: []string{TopicMetrics, TopicResources},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			size := tt.replayBuffer
+			if size == 0 {
+				size = defaultReplayBuffer
+			}
+			h := NewHub(Config{ReplayBuffer: size}, nil)
+			for _, msg := range received {
+				h.receive(msg)
+			}
+			since := h.seq
+			if tt.since != 0 {
+				since = tt.since
+			}
+			for _, sub := range tt.subscriptions {
+				sub.since = since
+			}
+			queue := tt.queue
+			if queue == 0 {
+				queue = sendBuffer
+			}
+			client := testClient(h, tt.subscriptions, queue)
+			client.allowed = tt.allowed
+
+			h.resume(client, tt.lastSeq)
+
+			msgs := drain(t, client)
+			if len(msgs) == 0 || msgs[len(msgs)-1].Type != "resumed" {
+				t.Fatalf("messages = %+v, want them to end with resumed", msgs)
+			}
+			var gotSeqs []uint64
+			var gotResync []string
+			for _, msg := range msgs[:len(msgs)-1] {
+				if msg.Type == "resync_required" {
+					for _, topic := range msg.Data["topics"].([]interface{}) {
+						gotResync = append(gotResync, topic.(string))
+					}
+					continue
+				}
+				gotSeqs = append(gotSeqs, msg.Seq)
+			}
+			if !reflect.DeepEqual(gotSeqs, tt.wantSeqs) {
+				t.Errorf("replayed %v, want %v", gotSeqs, tt.wantSeqs)
+			}
+			if !reflect.DeepEqual(gotResync, tt.wantResync) {
+				t.Errorf("resync topics = %v, want %v", gotResync, tt.wantResync)
+			}
+			resumed := msgs[len(msgs)-1].Data
+			if int(resumed["replayed"].(float64)) != len(tt.wantSeqs) || uint64(resumed["seq"].(float64)) != 105 {
+				t.Errorf("resumed = %v, want %d replayed at seq 105", resumed, len(tt.wantSeqs))
+			}
+		})
+	}
+}
+
+func TestReceiveAfterNumberingReset(t *testing.T) {
+	h := NewHub(Config{ReplayBuffer: defaultReplayBuffer}, nil)
+	for seq := uint64(101); seq <= 103; seq++ {
+		h.receive(Message{Seq: seq, Topic: TopicResources})
+	}
+	subscribed := testClient(h, map[string]*subscription{TopicResources: {since: 103}, TopicMetrics: {since: 103}}, sendBuffer)
+	idle := testClient(h, map[string]*subscription{}, sendBuffer)
+	h.clients[subscribed] = true
+	h.clients[idle] = true
+
+	// The broker's counter was lost and starts over
+	h.receive(Message{Seq: 1, Topic: TopicResources})
+
+	msgs := drain(t, subscribed)
+	if len(msgs) != 2 || msgs[0].Type != "resync_required" || msgs[1].Seq != 1 {
+		t.Fatalf("messages = %+v, want resync_required then the new message", msgs)
+	}
+	topics := msgs[0].Data["topics"].([]interface{})
+	if !reflect.DeepEqual(topics, []interface{}{TopicMetrics, TopicResources}) || msgs[0].Data["seq"].(float64) != 0 {
+		t.Errorf("resync_required data = %v, want both topics at seq 0", msgs[0].Data)
+	}
+	if msgs := drain(t, idle); len(msgs) != 0 {
+		t.Errorf("client without subscriptions got %+v", msgs)
+	}
+	if h.seq != 1 || len(h.replay[TopicResources].entries) != 1 {
+		t.Errorf("seq, buffered = %d, %d, want 1, 1", h.seq, len(h.replay[TopicResources].entries))
+	}
+
+	// Resuming from the old numbering needs a reload; from the new one the
+	// message was already delivered live
+	h.resume(subscribed, 103)
+	if msgs := drain(t, subscribed); len(msgs) != 2 || msgs[0].Type != "resync_required" {
+		t.Errorf("resume from 103 = %+v, want resync_required", msgs)
+	}
+	h.resume(subscribed, 1)
+	if msgs := drain(t, subscribed); len(msgs) != 1 || msgs[0].Type != "resumed" {
+		t.Errorf("resume from 1 = %+v, want only resumed", msgs)
+	}
+}
+
+// startHub runs hub behind a test server and returns its WebSocket URL.
+func startHub(t *testing.T, hub *Hub) string {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	go hub.Run(ctx)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, w, r, nil)
+	}))
+	t.Cleanup(func() {
+		server.Close()
+		cancel()
+		<-hub.done
+	})
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	return msg
+}
+
+func TestResumeAfterReconnect(t *testing.T) {
+	hub := NewHub(Config{ReplayBuffer: defaultReplayBuffer}, nil)
+	url := startHub(t, hub)
+
+	// New connections are subscribed to metrics. The observer stays
+	// connected to tell when the hub has received each message.
+	observer := dial(t, url)
+	first := dial(t, url)
+	hub.Publish(Message{Type: "metrics_update", Topic: TopicMetrics})
+	lastSeen := readMessage(t, first).Seq
+	readMessage(t, observer)
+	first.Close()
+
+	var missed []uint64
+	for i := 0; i < 2; i++ {
+		hub.Publish(Message{Type: "metrics_update", Topic: TopicMetrics})
+		missed = append(missed, readMessage(t, observer).Seq)
+	}
+
+	second := dial(t, url)
+	if err := second.WriteJSON(map[string]interface{}{"action": "resume", "lastSeq": lastSeen}); err != nil {
+		t.Fatalf("send resume: %v", err)
+	}
+	var replayed []uint64
+	for {
+		msg := readMessage(t, second)
+		if msg.Type == "resumed" {
+			break
+		}
+		replayed = append(replayed, msg.Seq)
+	}
+	if !reflect.DeepEqual(replayed, missed) {
+		t.Errorf("replayed %v, want %v", replayed, missed)
+	}
+
+	// Live delivery continues after the replay
+	hub.Publish(Message{Type: "metrics_update", Topic: TopicMetrics})
+	if msg := readMessage(t, second); msg.Seq <= missed[len(missed)-1] {
+		t.Errorf("live message seq %d, want after %d", msg.Seq, missed[len(missed)-1])
+	}
+}
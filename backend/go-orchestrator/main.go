@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
@@ -13,9 +14,19 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
+	"orchestrator/internal/aiengine"
+	"orchestrator/internal/anomaly"
+	"orchestrator/internal/audit"
+	"orchestrator/internal/auth"
+	"orchestrator/internal/chatcontext"
+	"orchestrator/internal/events"
+	"orchestrator/internal/executor"
 	"orchestrator/internal/handlers"
 	"orchestrator/internal/k8s"
+	"orchestrator/internal/manifest"
 	"orchestrator/internal/metrics"
+	"orchestrator/internal/rbac"
+	"orchestrator/internal/storage"
 	"orchestrator/internal/websocket"
 )
 
@@ -25,67 +36,168 @@ func main() {
 		log.Println("No .env file found, using system environment variables")
 	}
 
+	// Root context for background workers, cancelled on shutdown
+	rootCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	runWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(rootCtx)
+		}()
+	}
+
+	// Initialize authentication for the API and WebSocket
+	authConfig := auth.ConfigFromEnv()
+	authenticator, err := auth.New(authConfig)
+	if err != nil {
+		log.Fatalf("Failed to initialize authentication: %v", err)
+	}
+	if authenticator.Disabled() {
+		log.Println("Warning: authentication is disabled, every request is treated as anonymous with full access")
+	}
+	policy, err := rbac.PolicyFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to load access policy: %v", err)
+	}
+
+	// Initialize WebSocket hub, shared with other replicas through the broker
+	broker, err := websocket.BrokerFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize WebSocket broker: %v", err)
+	}
+	wsConfig := websocket.ConfigFromEnv()
+	wsConfig.AllowedOrigins = authConfig.AllowedOrigins
+	hub := websocket.NewHub(wsConfig, broker)
+	runWorker(hub.Run)
+
 	// Initialize Kubernetes client
 	k8sClient, err := k8s.NewClient()
 	if err != nil {
 		log.Printf("Warning: Failed to initialize K8s client: %v", err)
+	} else {
+		k8sClient.OnResourceEvent(events.PublishResourceEvents(hub))
+		k8sClient.StartCache(rootCtx)
 	}
 
+	// Initialize persistent storage; recommendation, action log and anomaly
+	// writes are published to WebSocket clients
+	baseStore, err := storage.Open(rootCtx, storage.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer baseStore.Close()
+	store := events.WrapStore(baseStore, hub)
+	policy.OnDenied(audit.RecordDenial(store))
+
 	// Initialize metrics collector
-	metricsCollector := metrics.NewCollector()
-	if k8sClient != nil {
-		go metricsCollector.StartCollection(k8sClient)
+	metricsSource, metricsFallback, err := metrics.SourcesFromEnv()
+	if err != nil {
+		log.Fatalf("Invalid metrics configuration: %v", err)
+	}
+	metricsCollector := metrics.NewCollector(store, metricsSource, metricsFallback)
+	if k8sClient != nil && os.Getenv("METRICS_SERVER_ENABLED") != "false" {
+		metricsCollector.AddSource(metrics.NewKubernetesUsageSource(k8sClient))
+	}
+
+	// Initialize anomaly detection over collected snapshots
+	detector := anomaly.New(store, anomaly.ConfigFromEnv())
+	if err := detector.Load(rootCtx); err != nil {
+		log.Printf("Warning: Failed to load anomaly detector state: %v", err)
 	}
+	metricsCollector.OnSnapshot(detector.Observe)
+	metricsCollector.OnSnapshot(events.PublishSnapshots(hub))
+	runWorker(detector.Run)
+	runWorker(func(ctx context.Context) { metricsCollector.StartCollection(ctx, k8sClient) })
 
-	// Initialize WebSocket hub
-	hub := websocket.NewHub()
-	go hub.Run()
+	// Feed metric history to the AI engine for predictions and recommendations
+	aiClient := aiengine.NewClientFromEnv()
+	runWorker(aiengine.NewSyncer(aiClient, metricsCollector, store).Run)
 
-	// Setup Gin router
-	router := gin.Default()
+	// Initialize recommendation executor
+	recExecutor := executor.New(k8sClient)
+	validator := manifest.New(k8sClient)
+	chatContext := chatcontext.New(k8sClient, metricsCollector, store, chatcontext.ConfigFromEnv())
+
+	// Setup Gin router. The WebSocket URL may carry an access token, so it is
+	// kept out of the request log
+	router := gin.New()
+	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{SkipPaths: []string{"/ws"}}), gin.Recovery(), audit.RequestID())
 
 	// CORS configuration
 	router.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:8080", "http://localhost:5173"},
+		AllowOrigins:     authConfig.AllowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-API-Key", audit.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", audit.RequestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
-	// API routes
-	api := router.Group("/api/v1")
+	// API routes, authenticated, each requiring a permission. Action handlers
+	// check it again for the namespace of the object they change
+	api := router.Group("/api/v1", auth.Middleware(authenticator))
+	canRead := rbac.Require(policy, rbac.PermRead)
+	canChat := rbac.Require(policy, rbac.PermChat)
+	canOperate := rbac.Require(policy, rbac.PermOperate)
+	canDelete := rbac.Require(policy, rbac.PermDelete)
+	canAudit := rbac.Require(policy, rbac.PermAdmin)
 	{
 		// Overview endpoints
-		api.GET("/overview", handlers.GetOverview(k8sClient, metricsCollector))
-		api.GET("/status", handlers.GetStatus(k8sClient))
+		api.GET("/overview", canRead, handlers.GetOverview(k8sClient, metricsCollector, store))
+		api.GET("/status", canRead, handlers.GetStatus(k8sClient, aiClient))
 
 		// Metrics endpoints
-		api.GET("/metrics", handlers.GetMetrics(metricsCollector))
-		api.GET("/metrics/history", handlers.GetMetricsHistory(metricsCollector))
+		api.GET("/metrics", canRead, handlers.GetMetrics(k8sClient, metricsCollector, store))
+		api.GET("/metrics/history", canRead, handlers.GetMetricsHistory(k8sClient, metricsCollector))
+		api.GET("/metrics/usage", canRead, handlers.GetResourceUsage(k8sClient, metricsCollector))
+
+		// Prediction endpoints
+		api.GET("/predictions", canRead, handlers.GetPredictions(store))
+
+		// Anomaly endpoints
+		api.GET("/anomalies", canRead, handlers.GetAnomalies(k8sClient, store))
 
 		// Recommendations endpoints
-		api.GET("/recommendations", handlers.GetRecommendations())
-		api.POST("/recommendations/:id/apply", handlers.ApplyRecommendation(k8sClient))
-		api.POST("/recommendations/:id/reject", handlers.RejectRecommendation())
+		api.GET("/recommendations", canRead, handlers.GetRecommendations(store))
+		api.POST("/recommendations/:id/apply", canOperate, handlers.ApplyRecommendation(recExecutor, store))
+		api.POST("/recommendations/:id/reject", canOperate, handlers.RejectRecommendation(store))
+		api.POST("/recommendations/:id/rollback", canOperate, handlers.RollbackRecommendation(recExecutor, store))
 
 		// Infrastructure endpoints
-		api.GET("/infrastructure", handlers.GetInfrastructure(k8sClient))
-		api.POST("/infrastructure/:type/:id/start", handlers.StartResource(k8sClient))
-		api.POST("/infrastructure/:type/:id/stop", handlers.StopResource(k8sClient))
-		api.DELETE("/infrastructure/:type/:id", handlers.DeleteResource(k8sClient))
+		api.GET("/infrastructure", canRead, handlers.GetInfrastructure(k8sClient))
+		api.POST("/infrastructure/:type/:id/start", canOperate, handlers.StartResource(k8sClient, store))
+		api.POST("/infrastructure/:type/:id/stop", canOperate, handlers.StopResource(k8sClient, store))
+		api.DELETE("/infrastructure/:type/:id", canDelete, handlers.DeleteResource(k8sClient, store))
 
 		// Logs endpoints
-		api.GET("/logs", handlers.GetLogs())
+		api.GET("/logs", canAudit, handlers.GetLogs(store))
 
 		// ChatOps endpoints
-		api.POST("/chat", handlers.HandleChat())
+		api.POST("/chat", canChat, handlers.HandleChat(aiClient, recExecutor, validator, chatContext, store))
+		api.POST("/chat/stream", canChat, handlers.StreamChat(aiClient, recExecutor, validator, chatContext, store))
+		api.GET("/chat/sessions", canChat, handlers.ListChatSessions(store))
+		api.GET("/chat/sessions/:id", canChat, handlers.GetChatSession(store))
+		api.PATCH("/chat/sessions/:id", canChat, handlers.RenameChatSession(store))
+		api.DELETE("/chat/sessions/:id", canChat, handlers.DeleteChatSession(store))
+		api.GET("/chat/actions/:id", canChat, handlers.GetChatAction(store))
+		api.POST("/chat/actions/:id/confirm", canOperate, handlers.ConfirmChatAction(recExecutor, store))
+		api.POST("/chat/actions/:id/cancel", canChat, handlers.CancelChatAction(store))
+		api.GET("/manifests/:id", canRead, handlers.GetManifest(store))
+		api.POST("/manifests/:id/apply", canOperate, handlers.ApplyManifest(validator, store))
 	}
 
-	// WebSocket endpoint
-	router.GET("/ws", func(c *gin.Context) {
-		websocket.ServeWs(hub, c.Writer, c.Request)
+	// WebSocket endpoint, authenticated on the handshake
+	router.GET("/ws", auth.Middleware(authenticator), canRead, func(c *gin.Context) {
+		scope := rbac.ScopeFor(c.Request.Context(), rbac.PermRead)
+		audit := rbac.ScopeFor(c.Request.Context(), rbac.PermAdmin)
+		websocket.ServeWs(hub, c.Writer, c.Request, func(topic, namespace string) bool {
+			if topic == websocket.TopicActions {
+				return audit.All
+			}
+			return scope.Allows(namespace)
+		})
 	})
 
 	// Health check
@@ -93,6 +205,9 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 
+	// Readiness check: not ready until the Kubernetes informer cache has synced
+	router.GET("/ready", handlers.Ready(k8sClient))
+
 	// Start server
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -118,6 +233,10 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down server...")
+	stopWorkers()
+	// Let workers finish their final writes before the store is closed
+	workers.Wait()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
@@ -15,6 +15,7 @@ const RecommendationsTab = () => {
       confidence: 93,
       impact: "high",
       status: "pending",
+      informational: false,
     },
     {
       id: 2,
@@ -25,6 +26,7 @@ const RecommendationsTab = () => {
       confidence: 87,
       impact: "medium",
       status: "pending",
+      informational: true,
     },
     {
       id: 3,
@@ -35,6 +37,7 @@ const RecommendationsTab = () => {
       confidence: 92,
       impact: "high",
       status: "pending",
+      informational: true,
     },
     {
       id: 4,
@@ -45,6 +48,7 @@ const RecommendationsTab = () => {
       confidence: 89,
       impact: "medium",
       status: "pending",
+      informational: false,
     },
   ];
 
@@ -135,14 +139,17 @@ const RecommendationsTab = () => {
 
                   {/* Actions */}
                   <div className="flex items-center gap-2 pt-2">
-                    <Button
-                      onClick={() => handleApply(rec.id, rec.action)}
-                      className="bg-primary hover:bg-primary/90"
-                      size="sm"
-                    >
-                      <CheckCircle2 className="w-4 h-4 mr-2" />
-                      Apply
-                    </Button>
+                    {/* Informational suggestions have no action the orchestrator can apply */}
+                    {!rec.informational && (
+                      <Button
+                        onClick={() => handleApply(rec.id, rec.action)}
+                        className="bg-primary hover:bg-primary/90"
+                        size="sm"
+                      >
+                        <CheckCircle2 className="w-4 h-4 mr-2" />
+                        Apply
+                      </Button>
+                    )}
                     <Button
                       onClick={() => handleExplain(rec.reason)}
                       variant="outline"